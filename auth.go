@@ -0,0 +1,73 @@
+package gittools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+)
+
+// HeaderProvider returns HTTP headers to send with requests to remotes, for
+// example a short-lived token refreshed before each network command
+type HeaderProvider func(ctx context.Context) (map[string]string, error)
+
+// networkCommands are the subcommands that talk to remotes. Authentication,
+// credentials and RetryPolicy all apply to these commands.
+var networkCommands = map[string]bool{
+	"clone":     true,
+	"fetch":     true,
+	"ls-remote": true,
+	"pull":      true,
+	"push":      true,
+}
+
+// redacted replaces secrets in the output of commands
+const redacted = "[REDACTED]"
+
+// authArgs returns the -c http.extraHeader options for a network command, along with
+// the secret values that must be redacted from its output
+func (c *Client) authArgs(command *Command) ([]string, []string, error) {
+	if (c.AuthToken == "" && c.HeaderProvider == nil) || !networkCommands[command.Subcommand()] {
+		return nil, nil, nil
+	}
+
+	headers := make(map[string]string)
+	if c.AuthToken != "" {
+		headers["Authorization"] = "Bearer " + c.AuthToken
+	}
+	if c.HeaderProvider != nil {
+		provided, err := c.HeaderProvider(command.Context)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get HTTP headers: %w", err)
+		}
+		for name, value := range provided {
+			headers[name] = value
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var args, secrets []string
+	for _, name := range names {
+		args = append(args, "-c", fmt.Sprintf("http.extraHeader=%s: %s", name, headers[name]))
+		secrets = append(secrets, headers[name])
+	}
+	if c.AuthToken != "" {
+		secrets = append(secrets, c.AuthToken)
+	}
+	return args, secrets, nil
+}
+
+// redact replaces each of the secrets in output
+func redact(output []byte, secrets []string) []byte {
+	for _, secret := range secrets {
+		if secret != "" {
+			output = bytes.ReplaceAll(output, []byte(secret), []byte(redacted))
+		}
+	}
+	return output
+}
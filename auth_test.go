@@ -0,0 +1,85 @@
+package gittools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// argsExecutor records the arguments of each command and echoes them to stderr
+type argsExecutor struct {
+	calls [][]string
+}
+
+func (e *argsExecutor) Exec(command *Command) ([]byte, []byte, error) {
+	e.calls = append(e.calls, command.Args)
+	return nil, []byte(strings.Join(command.Args, " ")), nil
+}
+
+func TestClientAuthToken(t *testing.T) {
+	executor := &argsExecutor{}
+	hook := &argsHook{}
+	client := &Client{
+		Executor:  executor,
+		AuthToken: "secret-token",
+		HeaderProvider: func(ctx context.Context) (map[string]string, error) {
+			return map[string]string{"X-Tenant": "tenant-1"}, nil
+		},
+		Hooks: []Hook{hook},
+	}
+
+	_, stderr, err := client.Exec("fetch", "origin")
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	want := []string{
+		"-c", "http.extraHeader=Authorization: Bearer secret-token",
+		"-c", "http.extraHeader=X-Tenant: tenant-1",
+		"fetch", "origin",
+	}
+	if strings.Join(executor.calls[0], "|") != strings.Join(want, "|") {
+		t.Errorf("Expected args %q, got %q", want, executor.calls[0])
+	}
+
+	// Secrets are kept from output and hooks
+	if strings.Contains(string(stderr), "secret-token") || strings.Contains(string(stderr), "tenant-1") {
+		t.Errorf("Expected secrets to be redacted, got %q", stderr)
+	}
+	if !strings.Contains(string(stderr), "Authorization: [REDACTED]") {
+		t.Errorf("Expected redacted header in output, got %q", stderr)
+	}
+	if strings.Contains(strings.Join(hook.args, " "), "secret-token") {
+		t.Errorf("Expected hooks not to see the token, got %q", hook.args)
+	}
+
+	// Local commands are not given credentials
+	if _, _, err := client.Exec("status"); err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+	if len(executor.calls[1]) != 1 {
+		t.Errorf("Expected no headers for status, got %q", executor.calls[1])
+	}
+
+	client.HeaderProvider = func(ctx context.Context) (map[string]string, error) {
+		return nil, errors.New("token expired")
+	}
+	if _, _, err := client.Exec("push", "origin", "main"); err == nil || !strings.Contains(err.Error(), "token expired") {
+		t.Errorf("Expected header provider error, got %v", err)
+	}
+}
+
+// argsHook records the arguments of each command hooks are shown
+type argsHook struct {
+	args []string
+}
+
+func (h *argsHook) BeforeExec(cmd *Command) error {
+	h.args = append(h.args, cmd.Args...)
+	return nil
+}
+
+func (h *argsHook) AfterExec(cmd *Command, stdout, stderr []byte, err error, duration time.Duration) {
+	h.args = append(h.args, cmd.Args...)
+}
@@ -0,0 +1,110 @@
+package gittools
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultBundleRefspec fetches every branch in a bundle to refs/remotes/bundle/
+const DefaultBundleRefspec = "refs/heads/*:refs/remotes/bundle/*"
+
+// Bundle describes a bundle file, see Client.BundleVerify
+type Bundle struct {
+	// Path is the path of the bundle file
+	Path string
+
+	// Refs are the refs contained in the bundle
+	Refs []BundleRef
+}
+
+// BundleRef is a ref contained in a bundle
+type BundleRef struct {
+	Hash string
+	Name string
+}
+
+// BundleCreate writes the commits and refs selected by revs to a bundle file at path,
+// for moving repository state without network access to a remote. revs are passed to
+// git rev-list, e.g. "main", "v1.0..main" or "--all", and default to "--all".
+func (r *Repo) BundleCreate(path string, revs ...string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	if len(revs) == 0 {
+		revs = []string{"--all"}
+	}
+
+	args := append([]string{"bundle", "create", "--quiet", absPath}, revs...)
+	stdout, stderr, err := r.Client.Exec(args...)
+	if err != nil {
+		return fmt.Errorf("git bundle create failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return nil
+}
+
+// BundleVerify checks that the file at path is a valid bundle and returns the refs it
+// contains. When the Client runs in a repository, it also checks that the repository
+// has the commits the bundle requires.
+func (c *Client) BundleVerify(path string) (*Bundle, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	stdout, stderr, err := c.Exec("bundle", "verify", "--quiet", absPath)
+	// Before git 2.43 a bundle can only be verified in a repository, and
+	// list-heads still checks the bundle can be read
+	if err != nil && !strings.Contains(string(stderr), "need a repository") {
+		return nil, fmt.Errorf("git bundle verify failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+
+	stdout, stderr, err = c.Exec("bundle", "list-heads", absPath)
+	if err != nil {
+		return nil, fmt.Errorf("git bundle list-heads failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+
+	bundle := &Bundle{Path: absPath}
+	for _, line := range strings.Split(strings.TrimSpace(string(stdout)), "\n") {
+		hash, name, found := strings.Cut(line, " ")
+		if !found {
+			continue
+		}
+		bundle.Refs = append(bundle.Refs, BundleRef{Hash: hash, Name: name})
+	}
+	return bundle, nil
+}
+
+// CloneFromBundle clones a repository from a bundle containing its complete history.
+// The bundle becomes the origin remote of the clone.
+func (c *Client) CloneFromBundle(path, destination string) (*Repo, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	return c.Clone(absPath, destination)
+}
+
+// FetchFromBundle fetches refs from a bundle into the repository, which must have the
+// commits the bundle requires. Refspecs default to DefaultBundleRefspec.
+func (r *Repo) FetchFromBundle(path string, refspecs ...string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	if len(refspecs) == 0 {
+		refspecs = []string{DefaultBundleRefspec}
+	}
+
+	args := append([]string{"fetch", absPath}, refspecs...)
+	stdout, stderr, err := r.Client.Exec(args...)
+	if err != nil {
+		return fmt.Errorf("git fetch failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package gittools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBundle(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		tempDir := setupTestRepo(t)
+
+		source, err := Open(tempDir)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		source.Client.SetUser("Test User", "test@example.com")
+
+		commit := func(name string) string {
+			t.Helper()
+			path := filepath.Join(tempDir, name)
+			if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+			if err := source.Commit(fmt.Sprintf("Add %s", name), []string{path}); err != nil {
+				t.Fatalf("Failed to commit: %v", err)
+			}
+			hash, err := source.RevParse("HEAD")
+			if err != nil {
+				t.Fatalf("Failed to get HEAD: %v", err)
+			}
+			return hash
+		}
+
+		base := commit("a.txt")
+		fullBundle := filepath.Join(testDir, "full.bundle")
+		if err := source.BundleCreate(fullBundle); err != nil {
+			t.Fatalf("BundleCreate failed: %v", err)
+		}
+
+		// The test directory is not a repository, so only the format is checked
+		client := &Client{WorkDir: testDir}
+		bundle, err := client.BundleVerify(fullBundle)
+		if err != nil {
+			t.Fatalf("BundleVerify failed: %v", err)
+		}
+		if !hasBundleRef(bundle, "refs/heads/main", base) {
+			t.Errorf("Expected refs/heads/main at %s, got %+v", base, bundle.Refs)
+		}
+
+		clone, err := client.CloneFromBundle(fullBundle, filepath.Join(testDir, "clone"))
+		if err != nil {
+			t.Fatalf("CloneFromBundle failed: %v", err)
+		}
+		if head, err := clone.RevParse("HEAD"); err != nil || head != base {
+			t.Errorf("Expected clone at %s, got %s, %v", base, head, err)
+		}
+
+		latest := commit("b.txt")
+		incremental := filepath.Join(testDir, "incremental.bundle")
+		if err := source.BundleCreate(incremental, base+"..main"); err != nil {
+			t.Fatalf("BundleCreate failed: %v", err)
+		}
+
+		// The clone has the commits the incremental bundle requires
+		if _, err := clone.Client.BundleVerify(incremental); err != nil {
+			t.Errorf("Expected the clone to verify the incremental bundle: %v", err)
+		}
+		if err := clone.FetchFromBundle(incremental); err != nil {
+			t.Fatalf("FetchFromBundle failed: %v", err)
+		}
+		if fetched, err := clone.RevParse("refs/remotes/bundle/main"); err != nil || fetched != latest {
+			t.Errorf("Expected refs/remotes/bundle/main at %s, got %s, %v", latest, fetched, err)
+		}
+
+		// A repository without the base commit cannot use the incremental bundle
+		emptyDir := filepath.Join(testDir, "empty")
+		if err := os.MkdirAll(emptyDir, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		empty, err := client.Init(emptyDir, "main")
+		if err != nil {
+			t.Fatalf("Failed to init repository: %v", err)
+		}
+		if _, err := empty.Client.BundleVerify(incremental); err == nil {
+			t.Error("Expected verification to fail without the prerequisite commits")
+		}
+
+		if _, err := client.BundleVerify(filepath.Join(testDir, "missing.bundle")); err == nil {
+			t.Error("Expected an error verifying a missing bundle")
+		}
+	})
+}
+
+func hasBundleRef(bundle *Bundle, name, hash string) bool {
+	for _, ref := range bundle.Refs {
+		if ref.Name == name && ref.Hash == hash {
+			return true
+		}
+	}
+	return false
+}
@@ -3,11 +3,16 @@ package gittools
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
 func NewClient() *Client {
@@ -18,10 +23,128 @@ type Client struct {
 	Binary  string // Path to the git binary. If empty, "git" is used.
 	WorkDir string // Directory to run git commands in. If empty, the current working directory is used.
 
+	// GitDir and WorkTree, if set, are passed to git as GIT_DIR and GIT_WORK_TREE, so the
+	// repository is located explicitly rather than discovered from WorkDir, see OpenGitDir.
+	// They are not carried over to repositories cloned, initialized or added as worktrees
+	// through this Client.
+	GitDir   string
+	WorkTree string
+
 	AuthorEmail    string
 	AuthorName     string
 	CommitterEmail string
 	CommitterName  string
+
+	// DefaultTimeout kills git commands that run for longer than this duration.
+	// It does not apply to ExecContext calls whose context already has a deadline.
+	// If zero, commands run until they complete.
+	DefaultTimeout time.Duration
+
+	// Env is added to the environment of every git command, e.g. GIT_SSH_COMMAND,
+	// GIT_TERMINAL_PROMPT or GIT_TRACE. It overrides variables inherited from this process.
+	Env map[string]string
+
+	// ConfigOverrides are passed as `-c key=value` to every git command, so transient
+	// settings such as commit.gpgSign=false apply without changing the repository's config
+	ConfigOverrides map[string]string
+
+	// SSH configures the ssh command used for SSH remotes through GIT_SSH_COMMAND,
+	// so each Client can use its own key. A GIT_SSH_COMMAND in Env takes precedence.
+	SSH *SSHOptions
+
+	// HTTPProxy and HTTPSProxy are the proxies for HTTP and HTTPS remotes, e.g.
+	// "http://proxy.example.com:3128". NoProxy is a comma separated list of hosts reached
+	// directly. They are set as http_proxy, https_proxy and no_proxy for git and
+	// override the same variables inherited from this process, but an http.proxy set in
+	// git config takes precedence.
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+
+	// AuthToken is sent as a bearer token in the Authorization header of HTTP(S) requests
+	// made by clone, fetch, ls-remote, pull and push. Use HeaderProvider for other schemes.
+	// Tokens are added after Hooks run and are redacted from command output.
+	AuthToken string
+
+	// HeaderProvider supplies extra HTTP headers for the same commands as AuthToken,
+	// and is called before each of them. Header values are redacted from command output.
+	HeaderProvider HeaderProvider
+
+	// Credentials supplies credentials for clone, fetch, ls-remote, pull and push through a
+	// temporary GIT_ASKPASS helper, and stops git from prompting on the terminal.
+	// Passwords are redacted from command output.
+	Credentials CredentialProvider
+
+	// Hooks are called before and after every git command, see Hook
+	Hooks []Hook
+
+	// Retry retries clone, fetch, ls-remote, pull and push when they fail with a
+	// transient network error, see RetryPolicy. If nil, commands are not retried.
+	Retry *RetryPolicy
+
+	// DryRun previews changes without making them. --dry-run is passed to mutating
+	// commands that support it (add, clean, commit, fetch, mv, push and rm), commands that
+	// only read run as normal, and all other commands are skipped, returning empty output.
+	// Dry runs are applied after Hooks, so hooks see the original command.
+	DryRun bool
+
+	// Executor runs commands in place of the git binary, e.g. a gittoolstest.MockExecutor in tests.
+	// Hooks, DryRun and MaxConcurrent still apply. If nil, git is run as a process.
+	Executor Executor
+
+	// MaxConcurrent limits how many git processes run at once through this Client
+	// and the Repos created from it. Further commands wait for a slot. If zero, there is no limit.
+	MaxConcurrent int
+
+	// limiter holds a token for each running command when MaxConcurrent is set.
+	// It is shared with copies of the Client made by withWorkDir.
+	limiter chan struct{}
+
+	// ctx, if set, kills every command run through this Client when done, see Repo.WithContext
+	ctx context.Context
+}
+
+// limiterMu guards the lazy creation of Client limiters
+var limiterMu sync.Mutex
+
+// slots returns the channel limiting concurrent commands, or nil if there is no limit
+func (c *Client) slots() chan struct{} {
+	if c.MaxConcurrent <= 0 {
+		return nil
+	}
+
+	limiterMu.Lock()
+	defer limiterMu.Unlock()
+	if cap(c.limiter) != c.MaxConcurrent {
+		c.limiter = make(chan struct{}, c.MaxConcurrent)
+	}
+	return c.limiter
+}
+
+// withWorkDir returns a copy of the Client that runs commands in dir
+// and shares this Client's concurrency limit
+func (c *Client) withWorkDir(dir string) *Client {
+	c.slots()
+
+	limiterMu.Lock()
+	defer limiterMu.Unlock()
+	c2 := *c
+	c2.WorkDir = dir
+	c2.GitDir = ""
+	c2.WorkTree = ""
+	return &c2
+}
+
+// withContext returns a copy of the Client whose commands are killed when ctx is done
+// and shares this Client's concurrency limit
+func (c *Client) withContext(ctx context.Context) *Client {
+	c.slots()
+
+	limiterMu.Lock()
+	defer limiterMu.Unlock()
+	c2 := *c
+	c2.ctx = ctx
+	return &c2
 }
 
 // SetUser is equivalent to running `git config --global user.email <email>`
@@ -42,8 +165,7 @@ func (c *Client) gitPath() string {
 }
 
 func (c *Client) Init(destination string, defaultBranch string) (*Repo, error) {
-	c2 := *c
-	c2.WorkDir = destination
+	c2 := c.withWorkDir(destination)
 
 	_, _, err := c2.Exec("init", "--initial-branch="+defaultBranch, destination)
 	if err != nil {
@@ -51,14 +173,13 @@ func (c *Client) Init(destination string, defaultBranch string) (*Repo, error) {
 	}
 
 	return &Repo{
-		Client:   &c2,
+		Client:   c2,
 		RepoPath: destination,
 	}, nil
 }
 
 func (c *Client) InitBare(destination, defaultBranch string) (*Repo, error) {
-	c2 := *c
-	c2.WorkDir = destination
+	c2 := c.withWorkDir(destination)
 
 	stdoutContent, stdErrContent, err := c2.Exec("init", "--bare", "--initial-branch="+defaultBranch, destination)
 	if err != nil {
@@ -66,8 +187,9 @@ func (c *Client) InitBare(destination, defaultBranch string) (*Repo, error) {
 	}
 
 	return &Repo{
-		Client:   &c2,
+		Client:   c2,
 		RepoPath: destination,
+		Bare:     true,
 	}, nil
 }
 
@@ -113,36 +235,23 @@ func (c *Client) CloneWithOptions(options CloneOptions) (*Repo, error) {
 	args = append(args, options.URL, absPath)
 
 	// Use context if provided
-	if options.Context != nil {
-		// Create a command with context
-		cmd := exec.CommandContext(options.Context, c.gitPath())
-		cmd.Args = append([]string{c.gitPath()}, args...)
-		if c.WorkDir != "" {
-			cmd.Dir = c.WorkDir
-		}
-
-		var stdout, stderr bytes.Buffer
-		cmd.Stdout = &stdout
-		cmd.Stderr = &stderr
-
-		err := cmd.Run()
-		if err != nil {
-			return nil, fmt.Errorf("git clone failed: %s: %w", stderr.String(), err)
-		}
-	} else {
-		// If no context provided, use regular Exec method
-		_, stderr, err := c.Exec(args...)
-		if err != nil {
-			return nil, fmt.Errorf("git clone failed: %s: %w", stderr, err)
-		}
+	ctx := options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, stderr, err := c.ExecContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("git clone failed: %s: %w", stderr, err)
 	}
 
 	// Create a new client with the cloned repo directory
-	c2 := *c
-	c2.WorkDir = absPath
+	c2 := c.withWorkDir(absPath)
 
 	// Return a new repo with the cloned directory
-	return &Repo{Client: &c2}, nil
+	return &Repo{
+		Client:   c2,
+		RepoPath: absPath,
+	}, nil
 }
 
 func (c *Client) Clone(url, destination string) (*Repo, error) {
@@ -157,13 +266,12 @@ func (c *Client) Clone(url, destination string) (*Repo, error) {
 		return nil, fmt.Errorf("git clone failed: %s, %s: %w", stdout, stderr, err)
 	}
 
-	c2 := *c
-	c2.WorkDir = absPath
+	c2 := c.withWorkDir(absPath)
 
 	// Create and return the repo - let Git handle the default branch
 	// The default branch will already be checked out by 'git clone'
 	return &Repo{
-		Client:   &c2,
+		Client:   c2,
 		RepoPath: absPath,
 	}, nil
 }
@@ -178,29 +286,308 @@ func (c *Client) GetHash(path string) (string, error) {
 }
 
 func (c *Client) Exec(args ...string) ([]byte, []byte, error) {
-	cmd := exec.Command(c.gitPath(), args...)
-	if c.WorkDir != "" {
-		cmd.Dir = c.WorkDir
+	return c.ExecWithOptions(ExecOptions{}, args...)
+}
+
+// ExecContext runs a git command that is killed when ctx is done.
+// A deadline on ctx overrides DefaultTimeout. If the command is killed, the returned
+// error wraps ctx.Err(), e.g. context.DeadlineExceeded.
+func (c *Client) ExecContext(ctx context.Context, args ...string) ([]byte, []byte, error) {
+	return c.ExecWithOptions(ExecOptions{Context: ctx}, args...)
+}
+
+// ExecWithInput runs a git command with stdin connected to the provided reader.
+// A nil reader is equivalent to an empty stdin.
+func (c *Client) ExecWithInput(stdin io.Reader, args ...string) ([]byte, []byte, error) {
+	return c.ExecWithOptions(ExecOptions{Stdin: stdin}, args...)
+}
+
+// ExecOptions are per-call settings for ExecWithOptions
+type ExecOptions struct {
+	// Context kills the command when done, see ExecContext
+	Context context.Context
+
+	// Env is added to the environment of this command, overriding Client.Env
+	Env map[string]string
+
+	// Stdin is connected to the command's standard input, see ExecWithInput
+	Stdin io.Reader
+
+	// Progress receives the transfer progress git writes to stderr while the command
+	// runs. git only reports progress to a pipe when passed --progress.
+	Progress ProgressFunc
+
+	// Stdout receives the command's standard output as it is written instead of
+	// buffering it, for commands with large output. The returned stdout is then
+	// empty and hooks are not passed the output.
+	Stdout io.Writer
+}
+
+// ExecWithOptions runs a git command with per-call settings
+func (c *Client) ExecWithOptions(options ExecOptions, args ...string) ([]byte, []byte, error) {
+	ctx := options.Context
+	if ctx == nil {
+		ctx = context.Background()
 	}
+	if c.ctx != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = withCancelFrom(ctx, c.ctx)
+		defer cancel()
+	}
+
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline && c.DefaultTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.DefaultTimeout)
+		defer cancel()
+	}
+
+	if c.Retry.appliesTo(options, args) {
+		return c.Retry.run(ctx, func() ([]byte, []byte, error) {
+			return c.exec(ctx, options, args)
+		})
+	}
+	return c.exec(ctx, options, args)
+}
+
+// withCancelFrom returns a context that is done when either ctx or other is done
+func withCancelFrom(ctx, other context.Context) (context.Context, context.CancelFunc) {
+	if ctx.Done() == nil {
+		// ctx is never done, so other can be used as it is
+		return other, func() {}
+	}
+
+	merged, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-other.Done():
+			cancel()
+		case <-merged.Done():
+		}
+	}()
+	return merged, cancel
+}
+
+// exec runs a git command once, through the hooks
+func (c *Client) exec(ctx context.Context, options ExecOptions, args []string) ([]byte, []byte, error) {
+	command := &Command{
+		Context: ctx,
+		Args:    append(configArgs(c.ConfigOverrides), args...),
+		Dir:     c.WorkDir,
+		Env:     c.environ(options.Env),
+		Stdin:   options.Stdin,
+	}
+
+	hooks := c.Hooks
+	if c.DryRun {
+		hooks = append(hooks[:len(hooks):len(hooks)], dryRunHook{})
+	}
+
+	for i, hook := range hooks {
+		if err := hook.BeforeExec(command); err != nil {
+			// Only hooks that saw the command are told it finished
+			c.afterExec(hooks[:i+1], command, nil, nil, err, 0)
+			return nil, nil, err
+		}
+		if command.Result != nil {
+			result := command.Result
+			c.afterExec(hooks[:i+1], command, result.Stdout, result.Stderr, result.Err, 0)
+			if options.Stdout != nil {
+				if _, err := options.Stdout.Write(result.Stdout); err != nil && result.Err == nil {
+					return nil, result.Stderr, err
+				}
+				return nil, result.Stderr, result.Err
+			}
+			return result.Stdout, result.Stderr, result.Err
+		}
+	}
+
+	if slots := c.slots(); slots != nil {
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+		case <-ctx.Done():
+			err := fmt.Errorf("waiting to run git %s: %w", strings.Join(args, " "), ctx.Err())
+			c.afterExec(hooks, command, nil, nil, err, 0)
+			return nil, nil, err
+		}
+	}
+
+	// Credentials are added to a copy of the command so hooks never see them
+	authArgs, secrets, err := c.authArgs(command)
+	if err != nil {
+		c.afterExec(hooks, command, nil, nil, err, 0)
+		return nil, nil, err
+	}
+	credentialEnv, credentialSecrets, cleanup, err := c.credentialEnv(command)
+	if err != nil {
+		c.afterExec(hooks, command, nil, nil, err, 0)
+		return nil, nil, err
+	}
+	defer cleanup()
+	secrets = append(secrets, credentialSecrets...)
+
+	run := *command
+	run.Args = append(authArgs, command.Args...)
+	run.Env = append(command.Env[:len(command.Env):len(command.Env)], credentialEnv...)
+
+	start := time.Now()
+	stdout, stderr, err := c.run(&run, options.Progress, options.Stdout)
+	duration := time.Since(start)
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("%w: %v", ctx.Err(), err)
+	}
+	stdout, stderr = redact(stdout, secrets), redact(stderr, secrets)
+
+	c.afterExec(hooks, command, stdout, stderr, err, duration)
+	return stdout, stderr, err
+}
+
+// Run runs a prepared command, such as one passed to an Executor, with the Client's
+// Executor or git binary. The command's Args, Dir, Env and Stdin are used as they are:
+// the Client's hooks, settings and credentials are not applied.
+func (c *Client) Run(command *Command) ([]byte, []byte, error) {
+	if command.Context == nil {
+		run := *command
+		run.Context = context.Background()
+		command = &run
+	}
+	return c.run(command, nil, nil)
+}
+
+// run runs the command with the Executor, or the git binary if there is none.
+// If progress is not nil, it receives progress updates from stderr as they are written.
+// If stdoutWriter is not nil, stdout is written to it rather than returned.
+func (c *Client) run(command *Command, progress ProgressFunc, stdoutWriter io.Writer) ([]byte, []byte, error) {
+	if c.Executor != nil {
+		stdout, stderr, err := c.Executor.Exec(command)
+		if stdoutWriter == nil {
+			return stdout, stderr, err
+		}
+		if _, writeErr := stdoutWriter.Write(stdout); writeErr != nil && err == nil {
+			err = writeErr
+		}
+		return nil, stderr, err
+	}
+
+	cmd := exec.CommandContext(command.Context, c.gitPath(), command.Args...)
+	cmd.Dir = command.Dir
+	cmd.Env = command.Env
+	cmd.Stdin = command.Stdin
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
+	if stdoutWriter != nil {
+		cmd.Stdout = stdoutWriter
+	}
+
+	if progress != nil {
+		writer := &progressWriter{fn: progress}
+		defer writer.flush()
+		cmd.Stderr = io.MultiWriter(&stderr, writer)
+	}
+
+	err := cmd.Run()
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// proxyEnv returns the environment variables configuring proxies. https_proxy and
+// no_proxy are set in both spellings so the settings replace either form inherited
+// from this process. http_proxy is only set in lowercase: libcurl ignores HTTP_PROXY,
+// as a CGI program can receive it from a request's Proxy header, so an inherited
+// HTTP_PROXY has no effect either.
+func (c *Client) proxyEnv() []string {
+	var env []string
+	for _, proxy := range []struct {
+		names []string
+		value string
+	}{
+		{[]string{"http_proxy"}, c.HTTPProxy},
+		{[]string{"https_proxy", "HTTPS_PROXY"}, c.HTTPSProxy},
+		{[]string{"no_proxy", "NO_PROXY"}, c.NoProxy},
+	} {
+		if proxy.value == "" {
+			continue
+		}
+		for _, name := range proxy.names {
+			env = append(env, name+"="+proxy.value)
+		}
+	}
+	return env
+}
 
-	cmd.Env = os.Environ()
+// environ returns the environment for a git command, with later entries taking precedence
+func (c *Client) environ(extra map[string]string) []string {
+	env := os.Environ()
 	if c.AuthorName != "" {
-		cmd.Env = append(cmd.Env, "GIT_AUTHOR_NAME="+c.AuthorName)
+		env = append(env, "GIT_AUTHOR_NAME="+c.AuthorName)
 	}
 	if c.AuthorEmail != "" {
-		cmd.Env = append(cmd.Env, "GIT_AUTHOR_EMAIL="+c.AuthorEmail)
+		env = append(env, "GIT_AUTHOR_EMAIL="+c.AuthorEmail)
 	}
 	if c.CommitterName != "" {
-		cmd.Env = append(cmd.Env, "GIT_COMMITTER_NAME="+c.CommitterName)
+		env = append(env, "GIT_COMMITTER_NAME="+c.CommitterName)
 	}
 	if c.CommitterEmail != "" {
-		cmd.Env = append(cmd.Env, "GIT_COMMITTER_EMAIL="+c.CommitterEmail)
+		env = append(env, "GIT_COMMITTER_EMAIL="+c.CommitterEmail)
+	}
+	if c.SSH != nil {
+		env = append(env, "GIT_SSH_COMMAND="+c.SSH.command())
+	}
+	env = append(env, c.proxyEnv()...)
+	if c.GitDir != "" {
+		env = append(env, "GIT_DIR="+c.GitDir)
+	}
+	if c.WorkTree != "" {
+		env = append(env, "GIT_WORK_TREE="+c.WorkTree)
 	}
+	env = appendEnv(env, c.Env)
+	return appendEnv(env, extra)
+}
 
-	err := cmd.Run()
-	return stdout.Bytes(), stderr.Bytes(), err
+// afterExec calls AfterExec on hooks in reverse order, so the first hook sees the command last
+func (c *Client) afterExec(hooks []Hook, command *Command, stdout, stderr []byte, err error, duration time.Duration) {
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i].AfterExec(command, stdout, stderr, err, duration)
+	}
+}
+
+// appendEnv appends the variables in vars to env in a stable order
+func appendEnv(env []string, vars map[string]string) []string {
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		env = append(env, key+"="+vars[key])
+	}
+	return env
+}
+
+// configArgs renders config overrides as -c arguments in a stable order
+func configArgs(overrides map[string]string) []string {
+	keys := make([]string, 0, len(overrides))
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var args []string
+	for _, key := range keys {
+		args = append(args, "-c", key+"="+overrides[key])
+	}
+	return args
+}
+
+// ExitCode returns the exit status of the git command that produced err, or -1 if
+// err did not come from git exiting, e.g. because the command timed out.
+// Errors replayed by a Replayer report the recorded exit status.
+func ExitCode(err error) int {
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
 }
@@ -0,0 +1,248 @@
+package gittools
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientDefaultTimeout(t *testing.T) {
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep is not available")
+	}
+
+	// Substitute sleep for git to simulate a hung command
+	client := &Client{Binary: sleep, DefaultTimeout: 100 * time.Millisecond}
+
+	start := time.Now()
+	_, _, err = client.Exec("5")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected command to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected command to be killed promptly, took %v", elapsed)
+	}
+
+	// A deadline on the context overrides the default timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, _, err := client.ExecContext(ctx, "0.3"); err != nil {
+		t.Errorf("Expected context deadline to override default timeout, got %v", err)
+	}
+}
+
+func TestRepoWithContext(t *testing.T) {
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep is not available")
+	}
+
+	repo := &Repo{Client: &Client{Binary: sleep}}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	bound := repo.WithContext(ctx)
+
+	// Commands run without a context of their own, and with one, are killed
+	for _, exec := range []func() error{
+		func() error { _, _, err := bound.Client.Exec("5"); return err },
+		func() error { _, _, err := bound.Client.ExecContext(context.Background(), "5"); return err },
+	} {
+		start := time.Now()
+		if err := exec(); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected command to be killed by the Repo's context, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("Expected command to be killed promptly, took %v", elapsed)
+		}
+	}
+
+	// Repos created from the copy share its context, the original Repo is unaffected
+	if _, _, err := bound.Client.withWorkDir(t.TempDir()).Exec("0"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a derived Client to use the context, got %v", err)
+	}
+	if _, _, err := repo.Client.Exec("0.1"); err != nil {
+		t.Errorf("Expected the original Repo to run commands, got %v", err)
+	}
+}
+
+func TestClientMaxConcurrent(t *testing.T) {
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep is not available")
+	}
+
+	client := &Client{Binary: sleep, MaxConcurrent: 2}
+
+	// Copies made for repositories share the limit
+	clients := []*Client{client, client.withWorkDir(t.TempDir())}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(c *Client) {
+			defer wg.Done()
+			if _, _, err := c.Exec("0.2"); err != nil {
+				t.Errorf("Exec failed: %v", err)
+			}
+		}(clients[i%2])
+	}
+	wg.Wait()
+
+	// Four commands with two slots take at least two rounds
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("Expected commands to be limited to 2 at a time, all finished in %v", elapsed)
+	}
+}
+
+func TestClientEnv(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not available")
+	}
+
+	// Substitute sh for git to print the environment the command sees
+	client := &Client{Binary: sh, Env: map[string]string{"GITTOOLS_A": "client", "GITTOOLS_B": "client"}}
+
+	stdout, _, err := client.Exec("-c", "echo $GITTOOLS_A $GITTOOLS_B")
+	if err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if got := strings.TrimSpace(string(stdout)); got != "client client" {
+		t.Errorf("Expected client environment, got %q", got)
+	}
+
+	stdout, _, err = client.ExecWithOptions(ExecOptions{Env: map[string]string{"GITTOOLS_B": "call"}}, "-c", "echo $GITTOOLS_A $GITTOOLS_B")
+	if err != nil {
+		t.Fatalf("ExecWithOptions failed: %v", err)
+	}
+	if got := strings.TrimSpace(string(stdout)); got != "client call" {
+		t.Errorf("Expected per-call environment to override, got %q", got)
+	}
+}
+
+func TestClientConfigOverrides(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		tempDir := setupTestRepo(t)
+
+		repo, err := Open(tempDir)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		repo.Client.ConfigOverrides = map[string]string{"gittools.test": "override"}
+
+		stdout, _, err := repo.Client.Exec("config", "gittools.test")
+		if err != nil {
+			t.Fatalf("Failed to read overridden config: %v", err)
+		}
+		if value := strings.TrimSpace(string(stdout)); value != "override" {
+			t.Errorf("Expected override to apply, got %q", value)
+		}
+
+		// The repository config is not changed
+		stdout, _, err = (&Client{WorkDir: tempDir}).Exec("config", "--local", "--list")
+		if err != nil {
+			t.Fatalf("Failed to list config: %v", err)
+		}
+		if strings.Contains(string(stdout), "gittools.test") {
+			t.Errorf("Expected override not to be written to config, got %s", stdout)
+		}
+	})
+}
+
+func TestClientExecWithInput(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		tempDir := setupTestRepo(t)
+
+		repo, err := Open(tempDir)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+
+		stdout, _, err := repo.Client.ExecWithInput(strings.NewReader("hello\n"), "hash-object", "-w", "--stdin")
+		if err != nil {
+			t.Fatalf("hash-object failed: %v", err)
+		}
+		hash := strings.TrimSpace(string(stdout))
+		if hash != "ce013625030ba8dba906f756967f9e9ca394464a" {
+			t.Errorf("Unexpected hash %s", hash)
+		}
+
+		stdout, _, err = repo.Client.ExecWithInput(strings.NewReader(hash+"\n"), "cat-file", "--batch")
+		if err != nil {
+			t.Fatalf("cat-file --batch failed: %v", err)
+		}
+		if want := hash + " blob 6\nhello\n\n"; string(stdout) != want {
+			t.Errorf("Expected %q, got %q", want, stdout)
+		}
+
+		// A nil reader is an empty stdin
+		stdout, _, err = repo.Client.ExecWithInput(nil, "hash-object", "--stdin")
+		if err != nil {
+			t.Fatalf("hash-object failed: %v", err)
+		}
+		if got := strings.TrimSpace(string(stdout)); got != "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391" {
+			t.Errorf("Expected hash of empty input, got %s", got)
+		}
+	})
+}
+
+func TestProxyEnv(t *testing.T) {
+	client := &Client{
+		HTTPProxy:  "http://proxy:3128",
+		HTTPSProxy: "http://secure-proxy:3128",
+		NoProxy:    "internal.example.com",
+	}
+	want := []string{
+		"http_proxy=http://proxy:3128",
+		"https_proxy=http://secure-proxy:3128",
+		"HTTPS_PROXY=http://secure-proxy:3128",
+		"no_proxy=internal.example.com",
+		"NO_PROXY=internal.example.com",
+	}
+	if got := client.proxyEnv(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected proxy environment %q, got %q", want, got)
+	}
+
+	if got := (&Client{}).proxyEnv(); len(got) != 0 {
+		t.Errorf("Expected no proxy environment, got %q", got)
+	}
+}
+
+func TestClientProxy(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not available")
+	}
+
+	client := &Client{
+		Binary:     sh,
+		HTTPProxy:  "http://proxy:3128",
+		HTTPSProxy: "http://secure-proxy:3128",
+		NoProxy:    "internal.example.com",
+	}
+	stdout, _, err := client.Exec("-c", "echo $http_proxy $https_proxy $HTTPS_PROXY $no_proxy")
+	if err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	want := "http://proxy:3128 http://secure-proxy:3128 http://secure-proxy:3128 internal.example.com"
+	if got := strings.TrimSpace(string(stdout)); got != want {
+		t.Errorf("Expected proxy environment %q, got %q", want, got)
+	}
+
+	// git connects through the proxy, which is unreachable
+	gitClient := &Client{HTTPProxy: "http://127.0.0.1:1"}
+	_, stderr, err := gitClient.Exec("ls-remote", "http://example.invalid/repo.git")
+	if err == nil {
+		t.Fatal("Expected ls-remote through an unreachable proxy to fail")
+	}
+	if !strings.Contains(string(stderr), "127.0.0.1") {
+		t.Errorf("Expected git to connect to the proxy, got %q", stderr)
+	}
+}
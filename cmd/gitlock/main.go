@@ -0,0 +1,41 @@
+// Command gitlock acquires and manages locks stored in a Git repository, for use
+// from shell scripts and pipelines.
+//
+// Usage:
+//
+//	gitlock <command> [flags] <lock-path>
+//
+// Commands:
+//
+//	acquire  acquire a lock
+//	release  release a lock held with the same -key
+//	refresh  extend the expiry of a lock held with the same -key
+//	check    report whether a lock is held
+//	list     list locks under a directory
+//	break    remove a lock regardless of its owner
+//	hold     acquire a lock and keep it refreshed until SIGINT or SIGTERM
+//
+// Exit codes:
+//
+//	0  success, or for check the lock is free
+//	1  error
+//	2  invalid usage
+//	3  the lock is held by another process
+//	4  the lock is not held by this process
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ocuroot/gittools/internal/lockcli"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := lockcli.Run(ctx, "gitlock", os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
@@ -0,0 +1,288 @@
+// Command gittools exposes the operations of the gittools library to shell scripts
+// and pipelines, so they share the exact logic used by Go programs.
+//
+// Usage:
+//
+//	gittools <command> [flags] [args]
+//
+// Commands:
+//
+//	commits-between  list the commits between two commits, newest first
+//	file-at-commit   print the content of a file at a commit
+//	log              list commits
+//	status           list changed and untracked paths
+//	lock             manage locks, as the gitlock command
+//
+// Exit codes:
+//
+//	0  success
+//	1  error
+//	2  invalid usage
+//
+// The lock command returns the exit codes of gitlock.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/ocuroot/gittools"
+	"github.com/ocuroot/gittools/internal/lockcli"
+)
+
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+const usage = `Usage: gittools <command> [flags] [args]
+
+Commands:
+  commits-between <earliest> <latest>  list the commits between two commits, newest first
+  file-at-commit <commit> <path>       print the content of a file at a commit
+  log [<commit> [<commit>]]            list commits
+  status                               list changed and untracked paths
+  lock <command> [flags] <lock-path>   manage locks, run "gittools lock" for its commands
+
+Run "gittools <command> -h" for the flags of a command.
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// options are the flags shared by all commands
+type options struct {
+	repo string
+	json bool
+
+	// commits-between only
+	noFetch bool
+}
+
+// run executes the command in args and returns the process exit code
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	command := args[0]
+
+	var opts options
+	flags := flag.NewFlagSet("gittools "+command, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&opts.repo, "repo", ".", "path to the repository")
+
+	var nargs []int
+	switch command {
+	case "commits-between":
+		flags.BoolVar(&opts.json, "json", false, "write output as JSON")
+		flags.BoolVar(&opts.noFetch, "no-fetch", false, "only search history already in the repository")
+		nargs = []int{2}
+	case "file-at-commit":
+		nargs = []int{2}
+	case "log":
+		flags.BoolVar(&opts.json, "json", false, "write output as JSON")
+		nargs = []int{0, 1, 2}
+	case "status":
+		flags.BoolVar(&opts.json, "json", false, "write output as JSON")
+		nargs = []int{0}
+	case "lock":
+		return lockcli.Run(ctx, "gittools lock", args[1:], stdout, stderr)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "gittools: unknown command %q\n\n%s", command, usage)
+		return exitUsage
+	}
+
+	if err := flags.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if !validArgCount(flags.NArg(), nargs) {
+		fmt.Fprintf(stderr, "gittools %s: unexpected arguments %q\n\n%s", command, flags.Args(), usage)
+		return exitUsage
+	}
+
+	repo, err := gittools.Open(opts.repo)
+	if err != nil {
+		fmt.Fprintf(stderr, "gittools: failed to open repository: %v\n", err)
+		return exitError
+	}
+
+	c := &cli{repo: repo, opts: opts, stdout: stdout, stderr: stderr}
+	switch command {
+	case "commits-between":
+		return c.commitsBetween(ctx, flags.Arg(0), flags.Arg(1))
+	case "file-at-commit":
+		return c.fileAtCommit(flags.Arg(0), flags.Arg(1))
+	case "log":
+		return c.log(flags.Args())
+	default:
+		return c.status()
+	}
+}
+
+// validArgCount returns true if n is one of the allowed argument counts
+func validArgCount(n int, allowed []int) bool {
+	for _, a := range allowed {
+		if n == a {
+			return true
+		}
+	}
+	return false
+}
+
+type cli struct {
+	repo   *gittools.Repo
+	opts   options
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func (c *cli) commitsBetween(ctx context.Context, earliest, latest string) int {
+	searchOpts := gittools.DefaultCommitSearchOptions()
+	searchOpts.DoNotExpandDepth = c.opts.noFetch
+
+	commits, err := c.repo.GetCommitsBetweenContext(ctx, earliest, latest, searchOpts)
+	if err != nil {
+		return c.fail(err)
+	}
+	if commits == nil {
+		return c.fail(fmt.Errorf("%s or %s not found", earliest, latest))
+	}
+
+	if c.opts.json {
+		return c.encode(commits)
+	}
+	for _, commit := range commits {
+		fmt.Fprintln(c.stdout, commit)
+	}
+	return exitOK
+}
+
+func (c *cli) fileAtCommit(commit, path string) int {
+	content, err := c.repo.FileAtCommit(commit, path)
+	if err != nil {
+		return c.fail(err)
+	}
+	fmt.Fprint(c.stdout, content)
+	return exitOK
+}
+
+// logEntry is the JSON form of a gittools.LogItem
+type logEntry struct {
+	Commit  string   `json:"commit"`
+	Author  string   `json:"author"`
+	Date    string   `json:"date"`
+	Message string   `json:"message"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+func (c *cli) log(commits []string) int {
+	var logOpts gittools.LogOptions
+	if len(commits) > 0 {
+		logOpts.Commit1 = commits[0]
+	}
+	if len(commits) > 1 {
+		logOpts.Commit2 = commits[1]
+	}
+
+	items, err := c.repo.Log(logOpts)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	if c.opts.json {
+		entries := []logEntry{}
+		for _, item := range items {
+			entries = append(entries, logEntry(item))
+		}
+		return c.encode(entries)
+	}
+	for _, item := range items {
+		subject, _, _ := strings.Cut(strings.TrimSpace(item.Message), "\n")
+		fmt.Fprintf(c.stdout, "%s\t%s\t%s\n", item.Commit, item.Author, subject)
+	}
+	return exitOK
+}
+
+// statusEntry is the JSON form of a gittools.StatusEntry
+type statusEntry struct {
+	Path       string `json:"path"`
+	OrigPath   string `json:"orig_path,omitempty"`
+	Index      string `json:"index"`
+	WorkTree   string `json:"worktree"`
+	Staged     bool   `json:"staged"`
+	Unstaged   bool   `json:"unstaged"`
+	Untracked  bool   `json:"untracked"`
+	Conflicted bool   `json:"conflicted"`
+}
+
+func (c *cli) status() int {
+	entries, err := c.repo.Status()
+	if err != nil {
+		return c.fail(err)
+	}
+
+	if c.opts.json {
+		out := []statusEntry{}
+		for _, entry := range entries {
+			out = append(out, statusEntry{
+				Path:       entry.Path,
+				OrigPath:   entry.OrigPath,
+				Index:      string(entry.Index),
+				WorkTree:   string(entry.WorkTree),
+				Staged:     entry.Staged(),
+				Unstaged:   entry.Unstaged(),
+				Untracked:  entry.Untracked(),
+				Conflicted: entry.Conflicted(),
+			})
+		}
+		return c.encode(out)
+	}
+	// The same format as git status --short
+	for _, entry := range entries {
+		path := entry.Path
+		if entry.OrigPath != "" {
+			path = entry.OrigPath + " -> " + entry.Path
+		}
+		fmt.Fprintf(c.stdout, "%c%c %s\n", entry.Index, entry.WorkTree, path)
+	}
+	return exitOK
+}
+
+// fail reports err and returns the error exit code
+func (c *cli) fail(err error) int {
+	if c.opts.json {
+		c.encode(struct {
+			Error string `json:"error"`
+		}{err.Error()})
+	}
+	fmt.Fprintf(c.stderr, "gittools: %v\n", err)
+	return exitError
+}
+
+func (c *cli) encode(v interface{}) int {
+	if err := json.NewEncoder(c.stdout).Encode(v); err != nil {
+		fmt.Fprintf(c.stderr, "gittools: failed to write output: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ocuroot/gittools"
+	"github.com/ocuroot/gittools/internal/lockcli"
+	"github.com/ocuroot/gittools/testutils"
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	testutils.CloseTestRemoteRepos()
+	os.Exit(code)
+}
+
+func TestGittoolsCommands(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		remoteDir, cleanup, err := gittools.CreateTestRemoteRepo("gittools-cli")
+		if err != nil {
+			t.Fatalf("Failed to create remote repository: %v", err)
+		}
+		defer cleanup()
+
+		client := &gittools.Client{}
+		client.SetUser("Test User", "test@example.com")
+		repo, err := client.Clone(remoteDir, filepath.Join(tempDir, "clone"))
+		if err != nil {
+			t.Fatalf("Failed to clone remote repository: %v", err)
+		}
+		first, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+		path := filepath.Join(repo.RepoPath, "app.txt")
+		if err := os.WriteFile(path, []byte("v1\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if err := repo.Commit("Add app", []string{path}); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+		second, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("v2\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		gittoolsCmd := func(args ...string) (int, string) {
+			var stdout, stderr bytes.Buffer
+			code := run(context.Background(), args, &stdout, &stderr)
+			if code != exitOK {
+				return code, stdout.String() + stderr.String()
+			}
+			return code, stdout.String()
+		}
+		repoFlag := []string{"-repo", repo.RepoPath}
+		with := func(command string, args ...string) []string {
+			return append(append([]string{command}, repoFlag...), args...)
+		}
+
+		code, out := gittoolsCmd(with("commits-between", "-no-fetch", first, second)...)
+		if code != exitOK || out != second+"\n"+first+"\n" {
+			t.Errorf("Expected commits %s and %s, got %d: %q", second, first, code, out)
+		}
+		code, out = gittoolsCmd(with("commits-between", "-json", "-no-fetch", first, second)...)
+		var commits []string
+		if code != exitOK || json.Unmarshal([]byte(out), &commits) != nil || len(commits) != 2 {
+			t.Errorf("Expected two commits as JSON, got %d: %q", code, out)
+		}
+
+		if code, out := gittoolsCmd(with("file-at-commit", second, "app.txt")...); code != exitOK || out != "v1\n" {
+			t.Errorf("Expected the committed content, got %d: %q", code, out)
+		}
+		if code, _ := gittoolsCmd(with("file-at-commit", first, "app.txt")...); code != exitError {
+			t.Errorf("Expected an error for a missing file, got %d", code)
+		}
+
+		code, out = gittoolsCmd(with("log", "-json")...)
+		var entries []logEntry
+		if err := json.Unmarshal([]byte(out), &entries); code != exitOK || err != nil {
+			t.Fatalf("Failed to parse log output %q: %d, %v", out, code, err)
+		}
+		if len(entries) != 2 || entries[0].Commit != second || strings.TrimSpace(entries[0].Message) != "Add app" {
+			t.Errorf("Unexpected log %+v", entries)
+		}
+
+		if code, out := gittoolsCmd(with("status")...); code != exitOK || out != " M app.txt\n" {
+			t.Errorf("Expected app.txt to be modified, got %d: %q", code, out)
+		}
+		code, out = gittoolsCmd(with("status", "-json")...)
+		var status []statusEntry
+		if err := json.Unmarshal([]byte(out), &status); code != exitOK || err != nil {
+			t.Fatalf("Failed to parse status output %q: %d, %v", out, code, err)
+		}
+		if len(status) != 1 || status[0].Path != "app.txt" || !status[0].Unstaged || status[0].Staged {
+			t.Errorf("Unexpected status %+v", status)
+		}
+
+		lockArgs := []string{"-repo", repo.RepoPath, "-backend", "ref", "-key", "first", "locks/deploy.lock"}
+		if code, out := gittoolsCmd(append([]string{"lock", "acquire"}, lockArgs...)...); code != lockcli.ExitOK {
+			t.Fatalf("Expected lock acquire to succeed, got %d: %s", code, out)
+		}
+		if code, out := gittoolsCmd(append([]string{"lock", "check"}, lockArgs...)...); code != lockcli.ExitConflict {
+			t.Errorf("Expected lock check to report locked, got %d: %s", code, out)
+		}
+
+		if code, _ := gittoolsCmd("unknown"); code != exitUsage {
+			t.Errorf("Expected usage error for unknown command, got %d", code)
+		}
+		if code, _ := gittoolsCmd(with("file-at-commit", second)...); code != exitUsage {
+			t.Errorf("Expected usage error without a path, got %d", code)
+		}
+	})
+}
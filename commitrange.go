@@ -0,0 +1,154 @@
+package gittools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrCommitsNotRelated is returned by GetCommitsBetween when neither commit is an
+// ancestor of the other, so there is no single line of history between them
+var ErrCommitsNotRelated = errors.New("commits are not in an ancestor relationship")
+
+// CommitRelation describes how the two endpoints of a CommitRange are related
+type CommitRelation int
+
+const (
+	// CommitsEqual means both endpoints are the same commit
+	CommitsEqual CommitRelation = iota
+
+	// CommitsForward means the earliest commit is an ancestor of the latest commit
+	CommitsForward
+
+	// CommitsReversed means the latest commit is an ancestor of the earliest commit
+	CommitsReversed
+
+	// CommitsDiverged means the commits share history but neither is an ancestor
+	// of the other, e.g. two branches that forked from a merge base
+	CommitsDiverged
+
+	// CommitsUnrelated means the commits have no history in common. In a shallow
+	// clone this may also mean their common history has not been fetched.
+	CommitsUnrelated
+)
+
+func (c CommitRelation) String() string {
+	switch c {
+	case CommitsEqual:
+		return "equal"
+	case CommitsForward:
+		return "forward"
+	case CommitsReversed:
+		return "reversed"
+	case CommitsDiverged:
+		return "diverged"
+	case CommitsUnrelated:
+		return "unrelated"
+	default:
+		return fmt.Sprintf("CommitRelation(%d)", int(c))
+	}
+}
+
+// CommitRange is the history between two commits, see GetCommitRange
+type CommitRange struct {
+	// Earliest and Latest are the full hashes of the endpoints as requested
+	Earliest string
+	Latest   string
+
+	// Relation is how Earliest and Latest are related
+	Relation CommitRelation
+
+	// MergeBase is the best common ancestor of the endpoints, empty if they are unrelated
+	MergeBase string
+
+	// Commits lists every commit reachable from the descendant endpoint and not
+	// from the ancestor endpoint, in topological order from the descendant, followed
+	// by the ancestor endpoint. For merges this includes the commits of all merged
+	// branches. Commits is nil if the endpoints are diverged or unrelated.
+	Commits []string
+}
+
+// Related returns true if one endpoint is an ancestor of the other
+func (c *CommitRange) Related() bool {
+	switch c.Relation {
+	case CommitsEqual, CommitsForward, CommitsReversed:
+		return true
+	default:
+		return false
+	}
+}
+
+// commitRange computes the range between two commits that exist in the repository
+// using only the history that is available locally
+func (r *Repo) commitRange(ctx context.Context, earliestCommit string, latestCommit string) (*CommitRange, error) {
+	if earliestCommit == "" || latestCommit == "" {
+		return nil, fmt.Errorf("both commits must be specified")
+	}
+
+	earliest, err := r.resolveCommit(ctx, earliestCommit)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := r.resolveCommit(ctx, latestCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CommitRange{
+		Earliest: earliest,
+		Latest:   latest,
+	}
+
+	result.MergeBase, err = r.mergeBase(ctx, earliest, latest)
+	if err != nil {
+		return nil, err
+	}
+
+	var ancestor, descendant string
+	switch {
+	case earliest == latest:
+		result.Relation = CommitsEqual
+		result.Commits = []string{earliest}
+		return result, nil
+	case result.MergeBase == "":
+		result.Relation = CommitsUnrelated
+		return result, nil
+	case result.MergeBase == earliest:
+		result.Relation = CommitsForward
+		ancestor, descendant = earliest, latest
+	case result.MergeBase == latest:
+		result.Relation = CommitsReversed
+		ancestor, descendant = latest, earliest
+	default:
+		result.Relation = CommitsDiverged
+		return result, nil
+	}
+
+	commits, err := r.RevList(RevListOptions{
+		Range:     ancestor + ".." + descendant,
+		TopoOrder: true,
+		Context:   ctx,
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Commits = append(commits, ancestor)
+
+	return result, nil
+}
+
+// mergeBase returns the best common ancestor of two commits, or an empty string if
+// they have no history in common
+func (r *Repo) mergeBase(ctx context.Context, a, b string) (string, error) {
+	stdout, stderr, err := r.Client.ExecContext(ctx, "merge-base", a, b)
+	if err != nil {
+		// merge-base exits with 1 and no output when there is no common ancestor
+		if ExitCode(err) == 1 && len(strings.TrimSpace(string(stdout))) == 0 {
+			return "", nil
+		}
+		return "", fmt.Errorf("git merge-base failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
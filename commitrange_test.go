@@ -0,0 +1,143 @@
+package gittools
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ocuroot/gittools/testutils"
+)
+
+func TestGetCommitRangeNonLinearHistory(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		// base - feature
+		//     \        \
+		//      main --- merge
+		commits, err := testutils.NewRepoBuilder().
+			Commit("base").
+			Branch("feature").Commit("feature").
+			Checkout("main").Commit("main").
+			Merge("feature").Tag("merge").
+			Build(filepath.Join(testDir, "repo"))
+		if err != nil {
+			t.Fatalf("Failed to build repository: %v", err)
+		}
+		base, feature, mainCommit, merge := commits["base"], commits["feature"], commits["main"], commits["merge"]
+
+		repo, err := Open(filepath.Join(testDir, "repo"))
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+
+		forward, err := repo.GetCommitRange(base, merge, nil)
+		if err != nil {
+			t.Fatalf("GetCommitRange failed: %v", err)
+		}
+		if forward.Relation != CommitsForward || forward.MergeBase != base {
+			t.Errorf("Expected forward range with merge base %s, got %s with %s",
+				base, forward.Relation, forward.MergeBase)
+		}
+		assertCommitRange(t, forward.Commits, merge, base, mainCommit, feature)
+
+		reversed, err := repo.GetCommitRange(merge, base, nil)
+		if err != nil {
+			t.Fatalf("GetCommitRange failed: %v", err)
+		}
+		if reversed.Relation != CommitsReversed {
+			t.Errorf("Expected reversed range, got %s", reversed.Relation)
+		}
+		assertCommitRange(t, reversed.Commits, merge, base, mainCommit, feature)
+
+		between, err := repo.GetCommitsBetween("HEAD", base, nil)
+		if err != nil {
+			t.Fatalf("GetCommitsBetween failed: %v", err)
+		}
+		assertCommitRange(t, between, merge, base, mainCommit, feature)
+
+		diverged, err := repo.GetCommitRange(feature, mainCommit, nil)
+		if err != nil {
+			t.Fatalf("GetCommitRange failed: %v", err)
+		}
+		if diverged.Relation != CommitsDiverged || diverged.MergeBase != base {
+			t.Errorf("Expected diverged range with merge base %s, got %s with %s",
+				base, diverged.Relation, diverged.MergeBase)
+		}
+		if diverged.Commits != nil {
+			t.Errorf("Expected no commits for diverged range, got %v", diverged.Commits)
+		}
+
+		_, err = repo.GetCommitsBetween(feature, mainCommit, nil)
+		if !errors.Is(err, ErrCommitsNotRelated) {
+			t.Errorf("Expected ErrCommitsNotRelated, got %v", err)
+		}
+
+		same, err := repo.GetCommitRange(base, base, nil)
+		if err != nil {
+			t.Fatalf("GetCommitRange failed: %v", err)
+		}
+		if same.Relation != CommitsEqual {
+			t.Errorf("Expected equal range, got %s", same.Relation)
+		}
+		assertCommitRange(t, same.Commits, base, base)
+	})
+}
+
+// assertCommitRange checks that commits starts with first, ends with last and
+// contains exactly the given commits in between, in any order
+func assertCommitRange(t *testing.T, commits []string, first, last string, between ...string) {
+	t.Helper()
+
+	expected := len(between) + 2
+	if first == last {
+		expected = 1
+	}
+	if len(commits) != expected {
+		t.Fatalf("Expected %d commits, got %d: %v", expected, len(commits), commits)
+	}
+	if commits[0] != first {
+		t.Errorf("Expected first commit %s, got %s", first, commits[0])
+	}
+	if commits[len(commits)-1] != last {
+		t.Errorf("Expected last commit %s, got %s", last, commits[len(commits)-1])
+	}
+
+	inner := make(map[string]bool)
+	if len(commits) > 2 {
+		for _, c := range commits[1 : len(commits)-1] {
+			inner[c] = true
+		}
+	}
+	for _, c := range between {
+		if !inner[c] {
+			t.Errorf("Expected %s between %s and %s, got %v", c, first, last, commits)
+		}
+	}
+}
+
+func TestGetCommitsBetweenContextCancelled(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		remotePath, cleanup, err := CreateTestRemoteRepo("commit-range-cancel")
+		if err != nil {
+			t.Fatalf("Failed to create remote: %v", err)
+		}
+		defer cleanup()
+
+		client := &Client{}
+		repo, err := client.Clone(remotePath, filepath.Join(testDir, "repo"))
+		if err != nil {
+			t.Fatalf("Failed to clone: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		commits, err := repo.GetCommitsBetweenContext(ctx, "HEAD", "HEAD", nil)
+		if err == nil {
+			t.Fatalf("Expected an error for a cancelled context, got %v", commits)
+		}
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,197 @@
+package gittools
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrConfigNotFound is returned when reading a config key that is not set
+var ErrConfigNotFound = errors.New("git config key not found")
+
+// ConfigScope selects the config file git reads or writes
+type ConfigScope string
+
+const (
+	// ConfigScopeDefault reads the effective value from every scope and
+	// writes to the repository's config
+	ConfigScopeDefault ConfigScope = ""
+
+	// ConfigScopeLocal is the repository's .git/config
+	ConfigScopeLocal ConfigScope = "local"
+
+	// ConfigScopeGlobal is the user's ~/.gitconfig
+	ConfigScopeGlobal ConfigScope = "global"
+
+	// ConfigScopeSystem is the system wide config, e.g. /etc/gitconfig
+	ConfigScopeSystem ConfigScope = "system"
+
+	// ConfigScopeWorktree is the config of the current worktree. It requires
+	// extensions.worktreeConfig to be enabled, otherwise git uses the local scope.
+	ConfigScopeWorktree ConfigScope = "worktree"
+)
+
+// ConfigOptions defines options for reading and writing git config
+type ConfigOptions struct {
+	// Scope limits the command to a single config file
+	Scope ConfigScope
+
+	// ShowScope makes ConfigList report the scope of each entry when Scope is
+	// ConfigScopeDefault. It requires git 2.26 or later. With a Scope set, entries
+	// always report that scope.
+	ShowScope bool
+}
+
+// ConfigEntry is a config value and the scope it was read from
+type ConfigEntry struct {
+	Value string
+	Scope ConfigScope
+}
+
+// args returns the arguments to git config for the options
+func (o ConfigOptions) args() []string {
+	args := []string{"config"}
+	if o.Scope != ConfigScopeDefault {
+		args = append(args, "--"+string(o.Scope))
+	}
+	return args
+}
+
+// ConfigGetWithOptions gets a git config value without its trailing newline.
+// Returns ErrConfigNotFound if the key is not set.
+func (r *Repo) ConfigGetWithOptions(key string, options ConfigOptions) (string, error) {
+	return r.configGet(key, "", options)
+}
+
+// ConfigGetBool gets a git config value as a boolean, accepting any of the values
+// git treats as booleans such as "yes", "on" and "1".
+// Returns ErrConfigNotFound if the key is not set.
+func (r *Repo) ConfigGetBool(key string, options ConfigOptions) (bool, error) {
+	value, err := r.configGet(key, "bool", options)
+	if err != nil {
+		return false, err
+	}
+	return value == "true", nil
+}
+
+// ConfigGetInt gets a git config value as an integer, expanding the k, m and g
+// suffixes as git does.
+// Returns ErrConfigNotFound if the key is not set.
+func (r *Repo) ConfigGetInt(key string, options ConfigOptions) (int64, error) {
+	value, err := r.configGet(key, "int", options)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer %q for config key %s: %w", value, key, err)
+	}
+	return n, nil
+}
+
+// ConfigGetDuration gets a git config value as a duration in the format accepted by
+// time.ParseDuration, e.g. "30s" or "1h30m".
+// Returns ErrConfigNotFound if the key is not set.
+func (r *Repo) ConfigGetDuration(key string, options ConfigOptions) (time.Duration, error) {
+	value, err := r.configGet(key, "", options)
+	if err != nil {
+		return 0, err
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q for config key %s: %w", value, key, err)
+	}
+	return d, nil
+}
+
+func (r *Repo) configGet(key string, valueType string, options ConfigOptions) (string, error) {
+	args := options.args()
+	// --bool and --int work with every git version, unlike --type which needs git 2.18
+	if valueType != "" {
+		args = append(args, "--"+valueType)
+	}
+	args = append(args, "--get", key)
+
+	stdout, stderr, err := r.Client.Exec(args...)
+	if err != nil {
+		// git config exits with 1 when the key is not set
+		if ExitCode(err) == 1 {
+			return "", fmt.Errorf("%w: %s", ErrConfigNotFound, key)
+		}
+		return "", fmt.Errorf("git config failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return strings.TrimSuffix(string(stdout), "\n"), nil
+}
+
+// ConfigSetWithOptions sets a git config value, replacing any existing values of the key
+func (r *Repo) ConfigSetWithOptions(key, value string, options ConfigOptions) error {
+	args := append(options.args(), "--replace-all", key, value)
+	stdout, stderr, err := r.Client.Exec(args...)
+	if err != nil {
+		return fmt.Errorf("git config failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return nil
+}
+
+// ConfigUnset removes every value of a git config key.
+// Unsetting a key that is not set is not an error.
+func (r *Repo) ConfigUnset(key string, options ConfigOptions) error {
+	args := append(options.args(), "--unset-all", key)
+	stdout, stderr, err := r.Client.Exec(args...)
+	if err != nil {
+		// git config exits with 5 when the key is not set
+		if ExitCode(err) == 5 {
+			return nil
+		}
+		return fmt.Errorf("git config failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return nil
+}
+
+// ConfigList returns every config value visible to the repository, keyed by the
+// lowercased key git reports. Where a key is set more than once, the entry is the
+// value git uses: the last one read, from the most specific scope.
+// Entries only report their scope if options.Scope or options.ShowScope is set.
+func (r *Repo) ConfigList(options ConfigOptions) (map[string]ConfigEntry, error) {
+	showScope := options.ShowScope && options.Scope == ConfigScopeDefault
+	args := append(options.args(), "--list", "-z")
+	if showScope {
+		args = append(args, "--show-scope")
+	}
+	stdout, stderr, err := r.Client.Exec(args...)
+	if err != nil {
+		return nil, fmt.Errorf("git config failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return parseConfigList(string(stdout), showScope, options.Scope), nil
+}
+
+// parseConfigList parses the output of git config --list -z, which separates each
+// entry with NUL and the key from its value with a newline. A key without a newline
+// has no value. With --show-scope, each entry is preceded by its scope and a NUL,
+// otherwise entries are given the scope passed in.
+func parseConfigList(output string, withScope bool, scope ConfigScope) map[string]ConfigEntry {
+	entries := make(map[string]ConfigEntry)
+	fields := strings.Split(output, "\x00")
+	step := 1
+	if withScope {
+		step = 2
+	}
+	for i := 0; i+step < len(fields); i += step {
+		entry := fields[i]
+		if withScope {
+			scope, entry = ConfigScope(fields[i]), fields[i+1]
+		}
+		key, value, _ := strings.Cut(entry, "\n")
+		entries[key] = ConfigEntry{
+			Value: value,
+			Scope: scope,
+		}
+	}
+	return entries
+}
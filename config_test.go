@@ -0,0 +1,182 @@
+package gittools
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConfig(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		tempDir := setupTestRepo(t)
+
+		repo, err := Open(tempDir)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		// Keep global config writes inside the test directory
+		repo.Client.Env = map[string]string{
+			"HOME":                testDir,
+			"XDG_CONFIG_HOME":     testDir,
+			"GIT_CONFIG_NOSYSTEM": "1",
+		}
+
+		local := ConfigOptions{Scope: ConfigScopeLocal}
+		global := ConfigOptions{Scope: ConfigScopeGlobal}
+
+		for key, value := range map[string]string{
+			"test.enabled": "yes",
+			"test.size":    "2k",
+			"test.timeout": "1m30s",
+			"test.name":    "local name",
+		} {
+			if err := repo.ConfigSetWithOptions(key, value, local); err != nil {
+				t.Fatalf("Failed to set %s: %v", key, err)
+			}
+		}
+		if err := repo.ConfigSetWithOptions("test.name", "global name", global); err != nil {
+			t.Fatalf("Failed to set global test.name: %v", err)
+		}
+
+		enabled, err := repo.ConfigGetBool("test.enabled", ConfigOptions{})
+		if err != nil || !enabled {
+			t.Errorf("Expected test.enabled to be true, got %v, %v", enabled, err)
+		}
+		if _, err := repo.ConfigGetBool("test.name", ConfigOptions{}); err == nil {
+			t.Error("Expected an error reading a name as a boolean")
+		}
+
+		size, err := repo.ConfigGetInt("test.size", ConfigOptions{})
+		if err != nil || size != 2048 {
+			t.Errorf("Expected test.size to be 2048, got %v, %v", size, err)
+		}
+
+		timeout, err := repo.ConfigGetDuration("test.timeout", ConfigOptions{})
+		if err != nil || timeout != 90*time.Second {
+			t.Errorf("Expected test.timeout to be 1m30s, got %v, %v", timeout, err)
+		}
+
+		// The local value takes precedence, but each scope can be read on its own
+		for _, test := range []struct {
+			options  ConfigOptions
+			expected string
+		}{
+			{ConfigOptions{}, "local name"},
+			{local, "local name"},
+			{global, "global name"},
+		} {
+			name, err := repo.ConfigGetWithOptions("test.name", test.options)
+			if err != nil || name != test.expected {
+				t.Errorf("Expected %q for scope %q, got %q, %v", test.expected, test.options.Scope, name, err)
+			}
+		}
+
+		entries, err := repo.ConfigList(ConfigOptions{ShowScope: true})
+		if err != nil {
+			t.Fatalf("ConfigList failed: %v", err)
+		}
+		if entry := entries["test.name"]; entry.Value != "local name" || entry.Scope != ConfigScopeLocal {
+			t.Errorf("Expected local test.name entry, got %+v", entry)
+		}
+		globalEntries, err := repo.ConfigList(global)
+		if err != nil {
+			t.Fatalf("ConfigList failed: %v", err)
+		}
+		if entry := globalEntries["test.name"]; entry.Value != "global name" || entry.Scope != ConfigScopeGlobal {
+			t.Errorf("Expected global test.name entry, got %+v", entry)
+		}
+		if _, ok := globalEntries["test.size"]; ok {
+			t.Error("Expected only global entries in the global scope")
+		}
+
+		if err := repo.ConfigUnset("test.name", local); err != nil {
+			t.Fatalf("ConfigUnset failed: %v", err)
+		}
+		if err := repo.ConfigUnset("test.name", local); err != nil {
+			t.Errorf("Expected unsetting a missing key to succeed, got %v", err)
+		}
+		name, err := repo.ConfigGetWithOptions("test.name", ConfigOptions{})
+		if err != nil || name != "global name" {
+			t.Errorf("Expected global name after unsetting the local one, got %q, %v", name, err)
+		}
+
+		_, err = repo.ConfigGetWithOptions("test.missing", ConfigOptions{})
+		if !errors.Is(err, ErrConfigNotFound) {
+			t.Errorf("Expected ErrConfigNotFound, got %v", err)
+		}
+	})
+}
+
+func TestParseConfigList(t *testing.T) {
+	entries := parseConfigList("global\x00user.name\nTest\x00local\x00a.b\nx\x00local\x00a.b\ny\nz\x00local\x00a.flag\x00", true, ConfigScopeDefault)
+
+	expected := map[string]ConfigEntry{
+		"user.name": {Value: "Test", Scope: ConfigScopeGlobal},
+		"a.b":       {Value: "y\nz", Scope: ConfigScopeLocal},
+		"a.flag":    {Value: "", Scope: ConfigScopeLocal},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %+v", len(expected), entries)
+	}
+	for key, entry := range expected {
+		if entries[key] != entry {
+			t.Errorf("Expected %s to be %+v, got %+v", key, entry, entries[key])
+		}
+	}
+}
+
+func TestParseConfigListWithoutScope(t *testing.T) {
+	entries := parseConfigList("user.name\nTest\x00a.b\ny\nz\x00a.flag\x00", false, ConfigScopeGlobal)
+
+	expected := map[string]ConfigEntry{
+		"user.name": {Value: "Test", Scope: ConfigScopeGlobal},
+		"a.b":       {Value: "y\nz", Scope: ConfigScopeGlobal},
+		"a.flag":    {Value: "", Scope: ConfigScopeGlobal},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, entries)
+	}
+	if entries := parseConfigList("", false, ConfigScopeDefault); len(entries) != 0 {
+		t.Errorf("Expected no entries, got %+v", entries)
+	}
+}
+
+func TestConfigGetSet(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		tempDir := setupTestRepo(t)
+
+		repo, err := Open(tempDir)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+
+		if err := repo.ConfigSet("test.value", "first"); err != nil {
+			t.Fatalf("ConfigSet failed: %v", err)
+		}
+		if err := repo.ConfigSet("test.value", "second"); err != nil {
+			t.Fatalf("ConfigSet failed: %v", err)
+		}
+
+		value, err := repo.ConfigGet("test.value")
+		if err != nil || value != "second" {
+			t.Errorf("Expected test.value to be %q, got %q, %v", "second", value, err)
+		}
+
+		// Keys with several values are replaced too
+		if stdout, stderr, err := repo.Client.Exec("config", "--local", "--add", "test.value", "third"); err != nil {
+			t.Fatalf("Failed to add a value: %v\n%s%s", err, stdout, stderr)
+		}
+		if err := repo.ConfigSet("test.value", "only"); err != nil {
+			t.Fatalf("ConfigSet failed on a multi-valued key: %v", err)
+		}
+		stdout, _, err := repo.Client.Exec("config", "--local", "--get-all", "test.value")
+		if err != nil || string(stdout) != "only\n" {
+			t.Errorf("Expected test.value to have a single value, got %q, %v", stdout, err)
+		}
+
+		if _, err := repo.ConfigGet("test.missing"); !errors.Is(err, ErrConfigNotFound) {
+			t.Errorf("Expected ErrConfigNotFound, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package gittools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Credentials are a username and password, or token, for a remote
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// CredentialProvider supplies credentials for remotes, see Client.Credentials
+type CredentialProvider interface {
+	// Get returns the credentials for the remote at url. Empty credentials leave
+	// authentication to git, which fails rather than prompting.
+	Get(ctx context.Context, url string) (Credentials, error)
+}
+
+// askpassScript answers git's username and password prompts from the environment,
+// so credentials are never written to disk
+const askpassScript = `#!/bin/sh
+case "$1" in
+Username*) printf '%s\n' "$GITTOOLS_ASKPASS_USERNAME" ;;
+*) printf '%s\n' "$GITTOOLS_ASKPASS_PASSWORD" ;;
+esac
+`
+
+// credentialEnv returns the environment that supplies credentials to a network command
+// through a temporary GIT_ASKPASS helper, the secrets to redact from its output and a
+// function removing the helper
+func (c *Client) credentialEnv(command *Command) ([]string, []string, func(), error) {
+	noop := func() {}
+	if c.Credentials == nil || !networkCommands[command.Subcommand()] {
+		return nil, nil, noop, nil
+	}
+
+	// Never wait for a prompt, even if no credentials are available
+	env := []string{"GIT_TERMINAL_PROMPT=0"}
+
+	url, err := c.remoteURL(command)
+	if err != nil {
+		return nil, nil, noop, err
+	}
+	creds, err := c.Credentials.Get(command.Context, url)
+	if err != nil {
+		return nil, nil, noop, fmt.Errorf("failed to get credentials for %s: %w", url, err)
+	}
+	if creds == (Credentials{}) {
+		return env, nil, noop, nil
+	}
+
+	dir, err := os.MkdirTemp("", "gittools-askpass-")
+	if err != nil {
+		return nil, nil, noop, fmt.Errorf("failed to create askpass helper: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	helper := filepath.Join(dir, "askpass.sh")
+	if err := os.WriteFile(helper, []byte(askpassScript), 0700); err != nil {
+		cleanup()
+		return nil, nil, noop, fmt.Errorf("failed to create askpass helper: %w", err)
+	}
+
+	env = append(env,
+		"GIT_ASKPASS="+helper,
+		"GITTOOLS_ASKPASS_USERNAME="+creds.Username,
+		"GITTOOLS_ASKPASS_PASSWORD="+creds.Password,
+	)
+	return env, []string{creds.Password}, cleanup, nil
+}
+
+// valueOptions are the options of network commands that take their value as a
+// separate argument, which must not be mistaken for the remote
+var valueOptions = map[string]bool{
+	"-b": true, "--branch": true,
+	"-o": true, "--origin": true, "--server-option": true, "--push-option": true,
+	"-u": true, "--upload-pack": true, "--receive-pack": true, "--exec": true,
+	"-c": true, "--config": true,
+	"-j": true, "--jobs": true,
+	"-s": true, "--strategy": true,
+	"-X": true, "--strategy-option": true,
+	"--depth": true, "--deepen": true, "--shallow-since": true, "--shallow-exclude": true,
+	"--reference": true, "--reference-if-able": true, "--separate-git-dir": true,
+	"--template": true, "--filter": true, "--refmap": true, "--negotiation-tip": true,
+	"--sort": true, "--bundle-uri": true, "--ref-format": true, "--repo": true,
+}
+
+// remoteURL returns the URL of the remote a network command talks to
+func (c *Client) remoteURL(command *Command) (string, error) {
+	index := command.subcommandIndex()
+	remote := "origin"
+	args := command.Args[index+1:]
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			if i+1 < len(args) {
+				remote = args[i+1]
+			}
+			break
+		}
+		if strings.HasPrefix(arg, "-") {
+			// push --repo names the remote itself
+			if strings.HasPrefix(arg, "--repo=") {
+				remote = strings.TrimPrefix(arg, "--repo=")
+				break
+			}
+			if valueOptions[arg] {
+				i++
+				if arg == "--repo" && i < len(args) {
+					remote = args[i]
+					break
+				}
+			}
+			continue
+		}
+		remote = arg
+		break
+	}
+
+	// clone and commands given a URL rather than a remote name
+	if command.Subcommand() == "clone" || strings.Contains(remote, ":") || strings.Contains(remote, "/") {
+		return remote, nil
+	}
+
+	// Run directly rather than through Exec, which would run the hooks again and wait
+	// for a second slot while the command holds one
+	getURL := &Command{
+		Context: command.Context,
+		Args:    append(configArgs(c.ConfigOverrides), "remote", "get-url", remote),
+		Dir:     command.Dir,
+		Env:     command.Env,
+	}
+	stdout, stderr, err := c.run(getURL, nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("git remote get-url failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
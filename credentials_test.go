@@ -0,0 +1,153 @@
+package gittools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type staticCredentials struct {
+	urls  []string
+	creds Credentials
+	err   error
+}
+
+func (s *staticCredentials) Get(ctx context.Context, url string) (Credentials, error) {
+	s.urls = append(s.urls, url)
+	return s.creds, s.err
+}
+
+func TestClientCredentials(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		// A fake git prompts for credentials through GIT_ASKPASS as git does
+		fakeGit := filepath.Join(testDir, "fake-git")
+		script := `#!/bin/sh
+user=$("$GIT_ASKPASS" "Username for 'https://example.com': ")
+pass=$("$GIT_ASKPASS" "Password for 'https://$user@example.com': ")
+echo "user=$user pass=$pass prompt=$GIT_TERMINAL_PROMPT askpass=$GIT_ASKPASS"
+`
+		if err := os.WriteFile(fakeGit, []byte(script), 0755); err != nil {
+			t.Fatalf("Failed to write fake git: %v", err)
+		}
+
+		provider := &staticCredentials{creds: Credentials{Username: "deploy", Password: "s3cret"}}
+		client := &Client{Binary: fakeGit, Credentials: provider}
+
+		stdout, _, err := client.Exec("fetch", "https://example.com/repo.git")
+		if err != nil {
+			t.Fatalf("fetch failed: %v", err)
+		}
+		output := string(stdout)
+		if !strings.Contains(output, "user=deploy pass=[REDACTED] prompt=0") {
+			t.Errorf("Expected credentials from the askpass helper, got %q", output)
+		}
+		if len(provider.urls) != 1 || provider.urls[0] != "https://example.com/repo.git" {
+			t.Errorf("Expected credentials to be requested for the remote URL, got %v", provider.urls)
+		}
+
+		// The helper only exists while the command runs
+		_, helper, _ := strings.Cut(strings.TrimSpace(output), "askpass=")
+		if _, err := os.Stat(helper); !os.IsNotExist(err) {
+			t.Errorf("Expected askpass helper %q to be removed, got %v", helper, err)
+		}
+
+		// Local commands do not request credentials
+		if _, _, err := client.Exec("status"); err != nil {
+			t.Fatalf("status failed: %v", err)
+		}
+		if len(provider.urls) != 1 {
+			t.Errorf("Expected no credentials for status, got %v", provider.urls)
+		}
+
+		provider.err = errors.New("vault unavailable")
+		if _, _, err := client.Exec("push", "https://example.com/repo.git", "main"); err == nil || !strings.Contains(err.Error(), "vault unavailable") {
+			t.Errorf("Expected credential provider error, got %v", err)
+		}
+	})
+}
+
+func TestClientCredentialsRemoteName(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		tempDir := setupTestRepo(t)
+		repo, err := Open(tempDir)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		if _, _, err := repo.Client.Exec("remote", "add", "upstream", "https://127.0.0.1:1/upstream.git"); err != nil {
+			t.Fatalf("Failed to add remote: %v", err)
+		}
+
+		provider := &staticCredentials{}
+		repo.Client.Credentials = provider
+
+		// Nothing listens on the remote, only the credential lookup matters
+		_ = repo.Fetch("upstream", FetchOptions{})
+		if len(provider.urls) != 1 || provider.urls[0] != "https://127.0.0.1:1/upstream.git" {
+			t.Errorf("Expected credentials for the remote's URL, got %q", provider.urls)
+		}
+	})
+}
+
+func TestClientCredentialsWithConcurrencyLimit(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		tempDir := setupTestRepo(t)
+		repo, err := Open(tempDir)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		if _, _, err := repo.Client.Exec("remote", "add", "upstream", "https://127.0.0.1:1/upstream.git"); err != nil {
+			t.Fatalf("Failed to add remote: %v", err)
+		}
+
+		provider := &staticCredentials{}
+		hook := &recordingHook{}
+		repo.Client.Credentials = provider
+		repo.Client.MaxConcurrent = 1
+		repo.Client.Hooks = []Hook{hook}
+
+		// Looking up the remote's URL must not wait for the slot the fetch holds
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err = repo.Fetch("upstream", FetchOptions{Context: ctx})
+		if errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Fetch waited for its own concurrency slot: %v", err)
+		}
+		if len(provider.urls) != 1 || provider.urls[0] != "https://127.0.0.1:1/upstream.git" {
+			t.Errorf("Expected credentials for the remote's URL, got %q", provider.urls)
+		}
+		if len(hook.before) != 1 || hook.before[0] != "fetch" {
+			t.Errorf("Expected hooks to only see the fetch, got %v", hook.before)
+		}
+	})
+}
+
+func TestClientCredentialsCloneBranch(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		source := setupTestRepo(t)
+		repo, err := Open(source)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		if _, _, err := repo.Client.Exec("branch", "feature"); err != nil {
+			t.Fatalf("Failed to create branch: %v", err)
+		}
+
+		provider := &staticCredentials{}
+		client := &Client{Credentials: provider}
+		_, err = client.CloneWithOptions(CloneOptions{
+			URL:         source,
+			Destination: filepath.Join(testDir, "clone"),
+			Branch:      "feature",
+		})
+		if err != nil {
+			t.Fatalf("Clone failed: %v", err)
+		}
+		if len(provider.urls) != 1 || provider.urls[0] != source {
+			t.Errorf("Expected credentials for %q, got %q", source, provider.urls)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package gittools
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// dryRunSupported lists the mutating subcommands that accept --dry-run
+var dryRunSupported = map[string]bool{
+	"add":    true,
+	"clean":  true,
+	"commit": true,
+	"fetch":  true,
+	"mv":     true,
+	"push":   true,
+	"rm":     true,
+}
+
+// readOnly lists subcommands that never change refs, the index or the working tree.
+// Commands that only add objects to the object database are included, as the objects
+// are unreachable until a ref is updated.
+var readOnly = map[string]bool{
+	"blame":        true,
+	"cat-file":     true,
+	"check-ignore": true,
+	"commit-tree":  true,
+	"describe":     true,
+	"diff":         true,
+	"for-each-ref": true,
+	"grep":         true,
+	"hash-object":  true,
+	"log":          true,
+	"ls-files":     true,
+	"ls-remote":    true,
+	"ls-tree":      true,
+	"merge-base":   true,
+	"mktree":       true,
+	"rev-list":     true,
+	"rev-parse":    true,
+	"shortlog":     true,
+	"show":         true,
+	"show-ref":     true,
+	"status":       true,
+	"var":          true,
+	"version":      true,
+}
+
+// readOnlyWithOptions lists subcommands that only read when invoked with one of the given options
+var readOnlyWithOptions = map[string][]string{
+	"apply":        {"--check"},
+	"branch":       {"--list", "-l", "--show-current", "-a", "--all", "-r", "--remotes", "--contains", "--merged", "--no-merged"},
+	"bundle":       {"verify", "list-heads"},
+	"config":       {"--get", "--get-all", "--get-regexp", "--list", "-l", "get", "list"},
+	"format-patch": {"--stdout"},
+	"remote":       {"-v", "--verbose", "get-url", "show"},
+	"stash":        {"list", "show"},
+	"tag":          {"--list", "-l", "--contains", "--points-at"},
+	"worktree":     {"list"},
+}
+
+// listsByDefault lists subcommands that only read when given no arguments
+var listsByDefault = map[string]bool{
+	"branch": true,
+	"remote": true,
+	"tag":    true,
+}
+
+// dryRunHook implements Client.DryRun
+type dryRunHook struct{}
+
+func (dryRunHook) BeforeExec(cmd *Command) error {
+	index := cmd.subcommandIndex()
+	if index < 0 {
+		return nil
+	}
+	subcommand := cmd.Args[index]
+	rest := cmd.Args[index+1:]
+
+	switch {
+	case readOnly[subcommand], dryRunReadOnly(subcommand, rest):
+		return nil
+	case dryRunSupported[subcommand]:
+		args := append([]string{}, cmd.Args[:index+1]...)
+		args = append(args, "--dry-run")
+		cmd.Args = append(args, rest...)
+		return nil
+	default:
+		cmd.Result = &CommandResult{
+			Stderr: []byte(fmt.Sprintf("dry run: skipped git %s\n", strings.Join(cmd.Args, " "))),
+		}
+		return nil
+	}
+}
+
+func (dryRunHook) AfterExec(cmd *Command, stdout, stderr []byte, err error, duration time.Duration) {}
+
+// dryRunReadOnly returns true if the subcommand only reads with the given arguments
+func dryRunReadOnly(subcommand string, args []string) bool {
+	options, ok := readOnlyWithOptions[subcommand]
+	if !ok {
+		return false
+	}
+	if len(args) == 0 && listsByDefault[subcommand] {
+		return true
+	}
+	for _, arg := range args {
+		for _, option := range options {
+			if arg == option {
+				return true
+			}
+		}
+	}
+	return false
+}
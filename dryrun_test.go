@@ -0,0 +1,78 @@
+package gittools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClientDryRun(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		tempDir := setupTestRepo(t)
+
+		repo, err := Open(tempDir)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		head, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to rev-parse: %v", err)
+		}
+
+		untracked := filepath.Join(tempDir, "untracked.txt")
+		if err := os.WriteFile(untracked, []byte("untracked"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		hook := &recordingHook{}
+		repo.Client.Hooks = []Hook{hook}
+		repo.Client.DryRun = true
+
+		// clean supports --dry-run, so reports what it would remove
+		stdout, _, err := repo.Client.Exec("clean", "-f")
+		if err != nil {
+			t.Fatalf("Dry run clean failed: %v", err)
+		}
+		if !strings.Contains(string(stdout), "Would remove untracked.txt") {
+			t.Errorf("Expected clean to report the file it would remove, got %q", stdout)
+		}
+		if _, err := os.Stat(untracked); err != nil {
+			t.Errorf("Expected untracked file to be kept: %v", err)
+		}
+
+		// Commands without --dry-run are skipped
+		if err := repo.CreateBranch("feature"); err != nil {
+			t.Fatalf("Dry run branch failed: %v", err)
+		}
+		_, stderr, err := repo.Client.Exec("reset", "--hard", "HEAD~1")
+		if err != nil {
+			t.Fatalf("Dry run reset failed: %v", err)
+		}
+		if !strings.Contains(string(stderr), "dry run: skipped git reset --hard HEAD~1") {
+			t.Errorf("Expected skipped command to be reported, got %q", stderr)
+		}
+
+		// Commands that only read still run
+		after, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to rev-parse: %v", err)
+		}
+		if after != head {
+			t.Errorf("Expected HEAD to be unchanged, got %s, want %s", after, head)
+		}
+		branches, _, err := repo.Client.Exec("branch", "--list")
+		if err != nil {
+			t.Fatalf("Failed to list branches: %v", err)
+		}
+		if strings.Contains(string(branches), "feature") {
+			t.Errorf("Expected branch not to be created, got %q", branches)
+		}
+
+		// Hooks see every command, including skipped ones
+		want := []string{"clean", "branch", "reset", "rev-parse", "branch"}
+		if strings.Join(hook.after, ",") != strings.Join(want, ",") {
+			t.Errorf("Expected AfterExec for %v, got %v", want, hook.after)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+// Command simple demonstrates locking a resource in a Git repository with the lock package.
+//
+// Usage:
+//
+//	simple [flags] <acquire|release|check|hold>
+//
+// acquire and release must be run with the same -key to release a lock acquired by an
+// earlier run. hold acquires the lock and keeps it refreshed until interrupted.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ocuroot/gittools"
+	"github.com/ocuroot/gittools/lock"
+)
+
+func main() {
+	repoPath := flag.String("repo", ".", "path to the repository")
+	lockPath := flag.String("lock", "locks/simple.lock", "path of the lock file")
+	key := flag.String("key", "simple-example", "identifies this process as the lock owner")
+	expiry := flag.Duration("expiry", time.Minute, "how long the lock is held without being refreshed")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: simple [flags] <acquire|release|check|hold>")
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), *repoPath, *lockPath, *key, *expiry); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(action string, repoPath string, lockPath string, key string, expiry time.Duration) error {
+	repo, err := gittools.Open(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+	locking := lock.NewRepoLockingWithKey(repo, key)
+
+	switch action {
+	case "acquire":
+		err := locking.TryAcquire(lockPath, expiry, "simple example")
+		var conflict *lock.LockConflictError
+		if errors.As(err, &conflict) {
+			return fmt.Errorf("lock is held: %w", conflict)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Acquired %s until %s\n", lockPath, time.Now().Add(expiry).Format(time.RFC3339))
+		return nil
+
+	case "release":
+		if err := locking.ReleaseLock(lockPath); err != nil {
+			return err
+		}
+		fmt.Printf("Released %s\n", lockPath)
+		return nil
+
+	case "check":
+		held, err := locking.ReadLock(lockPath)
+		if err != nil {
+			return err
+		}
+		if held == nil {
+			fmt.Printf("%s is free\n", lockPath)
+			return nil
+		}
+		fmt.Printf("%s is held by %s until %s\n", lockPath, held.Owner, held.ExpiresAt.Format(time.RFC3339))
+		return nil
+
+	case "hold":
+		return hold(locking, lockPath, expiry)
+
+	default:
+		return fmt.Errorf("unknown action %q", action)
+	}
+}
+
+// hold waits for the lock, then keeps it refreshed until the process is interrupted
+// and releases it
+func hold(locking *lock.Locking, lockPath string, expiry time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	locking.Hooks.OnRefreshed = func(lockPath string, held *lock.Lock) {
+		fmt.Printf("Refreshed %s until %s\n", lockPath, held.ExpiresAt.Format(time.RFC3339))
+	}
+
+	held := false
+	err := lock.WithLock(ctx, locking, lockPath, expiry, "simple example hold", func(ctx context.Context) error {
+		held = true
+		fmt.Printf("Holding %s, press Ctrl+C to release\n", lockPath)
+		<-ctx.Done()
+		return nil
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	if held {
+		fmt.Printf("Released %s\n", lockPath)
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+package gittools
+
+// Executor runs git commands for a Client, see Client.Executor.
+// The command is the one git would be run with: Args include any -c options from
+// Client.ConfigOverrides and credentials, and Env is the complete environment.
+// An Executor must honour the command's Dir, Env and Stdin, or fail commands it
+// can't run with them, rather than run them without.
+type Executor interface {
+	Exec(command *Command) (stdout, stderr []byte, err error)
+}
@@ -0,0 +1,63 @@
+package gittools_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ocuroot/gittools"
+	"github.com/ocuroot/gittools/gittoolstest"
+)
+
+// TestConfigArgsForOlderGit pins the arguments of config reads to forms older git
+// releases accept
+func TestConfigArgsForOlderGit(t *testing.T) {
+	mock := &gittoolstest.MockExecutor{}
+	mock.On("config", "--local", "--get", "a.b").Return("value\n")
+	mock.On("config", "--bool", "--get", "a.flag").Return("true\n")
+	mock.On("config", "--int", "--get", "a.size").Return("1024\n")
+	mock.On("config", "--list", "-z").Return("a.b\nvalue\x00")
+	mock.On("config", "--global", "--list", "-z").Return("a.b\nvalue\x00")
+	repo := &gittools.Repo{Client: &gittools.Client{Executor: mock}}
+
+	if value, err := repo.ConfigGet("a.b"); err != nil || value != "value" {
+		t.Errorf("Expected value, got %q, %v", value, err)
+	}
+	if flag, err := repo.ConfigGetBool("a.flag", gittools.ConfigOptions{}); err != nil || !flag {
+		t.Errorf("Expected true, got %v, %v", flag, err)
+	}
+	if size, err := repo.ConfigGetInt("a.size", gittools.ConfigOptions{}); err != nil || size != 1024 {
+		t.Errorf("Expected 1024, got %v, %v", size, err)
+	}
+	if _, err := repo.ConfigList(gittools.ConfigOptions{}); err != nil {
+		t.Errorf("ConfigList failed: %v", err)
+	}
+	entries, err := repo.ConfigList(gittools.ConfigOptions{Scope: gittools.ConfigScopeGlobal, ShowScope: true})
+	if err != nil || entries["a.b"].Scope != gittools.ConfigScopeGlobal {
+		t.Errorf("Expected the global scope without --show-scope, got %+v, %v", entries, err)
+	}
+	mock.AssertExpectations(t)
+}
+
+func TestRevListIterExecutor(t *testing.T) {
+	executor := &gittoolstest.MockExecutor{}
+	executor.On("rev-list", "HEAD").Return("aaa\nbbb\n")
+
+	repo := &gittools.Repo{Client: &gittools.Client{Executor: executor}}
+	iter, err := repo.RevListIter(gittools.RevListOptions{Range: "HEAD"})
+	if err != nil {
+		t.Fatalf("RevListIter failed: %v", err)
+	}
+	defer iter.Close()
+
+	var commits []string
+	for iter.Next() {
+		commits = append(commits, iter.Commit())
+	}
+	if err := iter.Err(); err != nil {
+		t.Fatalf("Iteration failed: %v", err)
+	}
+	if !reflect.DeepEqual(commits, []string{"aaa", "bbb"}) {
+		t.Errorf("Expected [aaa bbb], got %v", commits)
+	}
+	executor.AssertExpectations(t)
+}
@@ -0,0 +1,58 @@
+package gittools
+
+import (
+	"strings"
+	"testing"
+)
+
+// binaryExecutor runs commands with the git binary, as a Client without an Executor does
+type binaryExecutor struct {
+	commands []*Command
+}
+
+func (e *binaryExecutor) Exec(command *Command) ([]byte, []byte, error) {
+	e.commands = append(e.commands, command)
+	return (&Client{}).Run(command)
+}
+
+func TestExecutorReceivesCommand(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		repo, err := Open(setupTestRepo(t))
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		want, err := repo.HashObject([]byte("hello\n"))
+		if err != nil {
+			t.Fatalf("Failed to hash object: %v", err)
+		}
+
+		executor := &binaryExecutor{}
+		repo.Client.Executor = executor
+		repo.Client.SetUser("Executor User", "executor@example.com")
+
+		// Stdin reaches the executor
+		got, err := repo.HashObject([]byte("hello\n"))
+		if err != nil {
+			t.Fatalf("HashObject failed: %v", err)
+		}
+		if got != want {
+			t.Errorf("Expected the hash of the content %s, got %s", want, got)
+		}
+
+		// So do the environment and working directory
+		author, _, err := repo.Ident()
+		if err != nil {
+			t.Fatalf("Ident failed: %v", err)
+		}
+		if author.Name != "Executor User" || author.Email != "executor@example.com" {
+			t.Errorf("Expected the Client's author, got %+v", author)
+		}
+		command := executor.commands[len(executor.commands)-1]
+		if command.Dir != repo.RepoPath || command.Context == nil {
+			t.Errorf("Expected the command to run in %s with a context, got %q", repo.RepoPath, command.Dir)
+		}
+		if !strings.Contains(strings.Join(command.Env, "\n"), "GIT_AUTHOR_NAME=Executor User") {
+			t.Errorf("Expected the author in the command's environment, got %v", command.Env)
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package gittools
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// nonFastForwardStderr is the output of git push rejecting a non-fast-forward update
+const nonFastForwardStderr = ` ! [rejected]        HEAD -> main (non-fast-forward)
+error: failed to push some refs
+hint: Updates were rejected because the tip of your current branch is behind
+hint: its remote counterpart.
+`
+
+// FaultInjector is a Hook that makes selected git commands fail or run slowly, so
+// retry and recovery logic can be tested deterministically with real repositories.
+//
+//	faults := &gittools.FaultInjector{}
+//	faults.On("push").Nth(1).RejectNonFastForward()
+//	faults.On("fetch").Delay(time.Second)
+//	repo.Client.Hooks = append(repo.Client.Hooks, faults)
+//
+// Commands without a matching fault run as normal.
+type FaultInjector struct {
+	mu     sync.Mutex
+	faults []*Fault
+}
+
+// Fault describes how commands matched by FaultInjector.On misbehave
+type Fault struct {
+	args  []string
+	nth   int
+	delay time.Duration
+
+	fail   bool
+	stderr []byte
+	err    error
+
+	calls int
+}
+
+// On adds a fault for commands whose arguments, after global options such as -c,
+// start with args, e.g. On("push") for every push or On("fetch", "origin").
+// By default the fault applies to every matching command and has no effect until
+// configured with Delay or Fail.
+func (f *FaultInjector) On(args ...string) *Fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fault := &Fault{args: args}
+	f.faults = append(f.faults, fault)
+	return fault
+}
+
+// Nth limits the fault to the nth matching command, counting from 1
+func (f *Fault) Nth(n int) *Fault {
+	f.nth = n
+	return f
+}
+
+// Delay holds the command for d before it runs. If the command's context is done
+// first, it fails with the context's error.
+func (f *Fault) Delay(d time.Duration) *Fault {
+	f.delay = d
+	return f
+}
+
+// Fail makes the command fail without running git, with the given stderr and exit status
+func (f *Fault) Fail(stderr string, exitCode int) *Fault {
+	f.fail = true
+	f.stderr = []byte(stderr)
+	f.err = &RecordedExitError{Code: exitCode, Message: fmt.Sprintf("exit status %d", exitCode)}
+	return f
+}
+
+// RejectNonFastForward makes a push fail as if the remote branch had moved on,
+// which Push reports as ErrPushNonFastForward
+func (f *Fault) RejectNonFastForward() *Fault {
+	return f.Fail(nonFastForwardStderr, 1)
+}
+
+// Calls returns the number of commands that matched the fault
+func (f *FaultInjector) Calls(fault *Fault) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return fault.calls
+}
+
+// BeforeExec applies the faults matching the command
+func (f *FaultInjector) BeforeExec(cmd *Command) error {
+	args := cmd.SubcommandArgs()
+
+	var delay time.Duration
+	var result *CommandResult
+	f.mu.Lock()
+	for _, fault := range f.faults {
+		if !hasArgsPrefix(args, fault.args) {
+			continue
+		}
+		fault.calls++
+		if fault.nth > 0 && fault.calls != fault.nth {
+			continue
+		}
+		delay += fault.delay
+		if fault.fail && result == nil {
+			result = &CommandResult{Stderr: fault.stderr, Err: fault.err}
+		}
+	}
+	f.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-cmd.Context.Done():
+			return fmt.Errorf("delayed git command: %w", cmd.Context.Err())
+		}
+	}
+	if result != nil {
+		cmd.Result = result
+	}
+	return nil
+}
+
+// AfterExec does nothing
+func (f *FaultInjector) AfterExec(cmd *Command, stdout, stderr []byte, err error, duration time.Duration) {
+}
+
+// hasArgsPrefix returns true if args starts with prefix
+func hasArgsPrefix(args, prefix []string) bool {
+	return len(args) >= len(prefix) && equalArgs(args[:len(prefix)], prefix)
+}
@@ -0,0 +1,64 @@
+package gittools
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFaultInjector(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		remotePath, cleanup, err := CreateTestRemoteRepo("faults")
+		if err != nil {
+			t.Fatalf("Failed to create remote: %v", err)
+		}
+		defer cleanup()
+
+		repo, err := (&Client{}).Clone(remotePath, filepath.Join(testDir, "clone"))
+		if err != nil {
+			t.Fatalf("Failed to clone: %v", err)
+		}
+		repo.Client.SetUser("Test User", "test@example.com")
+
+		faults := &FaultInjector{}
+		rejected := faults.On("push").Nth(2).RejectNonFastForward()
+		faults.On("ls-remote").Fail("fatal: unable to access remote\n", 128)
+		repo.Client.Hooks = append(repo.Client.Hooks, faults)
+
+		for i, want := range []error{nil, ErrPushNonFastForward, nil} {
+			writeAndCommit(t, repo, "a.txt", string(rune('a'+i)))
+			err := repo.Push("origin", "main")
+			if (want == nil && err != nil) || (want != nil && !errors.Is(err, want)) {
+				t.Errorf("Push %d: expected %v, got %v", i+1, want, err)
+			}
+		}
+		if calls := faults.Calls(rejected); calls != 3 {
+			t.Errorf("Expected 3 pushes to match, got %d", calls)
+		}
+
+		slow := faults.On("fetch", "origin").Delay(time.Second)
+		_, _, err = repo.Client.ExecContext(ctxWithTimeout(t, 50*time.Millisecond), "fetch", "origin")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected the delayed fetch to time out, got %v", err)
+		}
+		if err := repo.Fetch("upstream-missing", FetchOptions{}); err == nil {
+			t.Error("Expected a fetch from a missing remote to fail")
+		}
+		if calls := faults.Calls(slow); calls != 1 {
+			t.Errorf("Expected only fetches from origin to match, got %d", calls)
+		}
+
+		_, err = repo.LsRemote("origin")
+		if ExitCode(err) != 128 {
+			t.Errorf("Expected ls-remote to fail with status 128, got %v", err)
+		}
+	})
+}
+
+func ctxWithTimeout(t *testing.T, d time.Duration) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	t.Cleanup(cancel)
+	return ctx
+}
@@ -0,0 +1,190 @@
+package gittools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrFetchRejected is returned by FetchWithResult when git refused to update some refs,
+// e.g. a non-fast-forward update without a + in the refspec
+var ErrFetchRejected = errors.New("git fetch rejected some ref updates")
+
+// FetchRefUpdate describes a local ref changed by a fetch, or an update git refused to make
+type FetchRefUpdate struct {
+	// Ref is the local ref as git reports it, e.g. "origin/main" or "v1.0"
+	Ref string
+
+	// RemoteRef is the ref on the remote as git reports it, e.g. "main",
+	// or empty for a pruned ref
+	RemoteRef string
+
+	// Old is the hash the ref pointed at before the fetch, empty for new and pruned refs
+	Old string
+
+	// New is the hash the ref points at after the fetch, or would have for a rejected
+	// update. It is empty for pruned refs.
+	New string
+
+	// Forced is true for an update that was not a fast-forward
+	Forced bool
+
+	// Reason is git's explanation for a forced or rejected update, e.g. "forced update",
+	// "non-fast-forward" or "would clobber existing tag"
+	Reason string
+}
+
+// FetchResult lists the refs changed by FetchWithResult
+type FetchResult struct {
+	// Updated are existing refs moved to a new commit, including forced updates
+	Updated []FetchRefUpdate
+
+	// New are refs created by the fetch, e.g. new branches and tags
+	New []FetchRefUpdate
+
+	// Pruned are remote-tracking refs deleted because the branch was deleted on the remote
+	Pruned []FetchRefUpdate
+
+	// Rejected are updates git refused to make
+	Rejected []FetchRefUpdate
+}
+
+// Changed returns true if the fetch created, updated or deleted any ref
+func (r *FetchResult) Changed() bool {
+	return len(r.Updated) > 0 || len(r.New) > 0 || len(r.Pruned) > 0
+}
+
+// fetchLinePattern matches a ref update reported by git fetch:
+// " <flag> <summary> <from> -> <to> [(<reason>)]", see the OUTPUT section of git-fetch(1)
+var fetchLinePattern = regexp.MustCompile(`^ ([ +\-t*!]) (\[[^\]]+\]|[0-9a-f]+\.\.\.?[0-9a-f]+)\s+(\S+)\s+-> (\S+)(?:\s+\((.*)\))?$`)
+
+// FetchWithResult fetches from the remote as Fetch does, and reports which refs were
+// created, updated, pruned or rejected. Refs fetched only into FETCH_HEAD are not
+// reported.
+// If some updates were rejected, the result is returned along with an error wrapping
+// ErrFetchRejected.
+func (g *Repo) FetchWithResult(remote string, options FetchOptions) (*FetchResult, error) {
+	// The compact output abbreviates ref names, so ask for the full output
+	stdout, stderr, err := g.fetch(remote, options, "-c", "fetch.output=full")
+	result, parseErr := g.parseFetchOutput(stderr)
+	if err != nil {
+		if parseErr == nil && len(result.Rejected) > 0 {
+			return result, fmt.Errorf("%w: %s", ErrFetchRejected, strings.TrimSpace(string(stderr)))
+		}
+		return nil, fmt.Errorf("git fetch failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	return result, nil
+}
+
+// parseFetchOutput parses the ref updates from the stderr of git fetch, resolving the
+// abbreviated hashes git prints to full hashes
+func (g *Repo) parseFetchOutput(output []byte) (*FetchResult, error) {
+	type parsed struct {
+		flag    byte
+		update  FetchRefUpdate
+		oldName string
+		newName string
+	}
+
+	var lines []parsed
+	var names []string
+	for _, line := range strings.Split(string(output), "\n") {
+		match := fetchLinePattern.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if match == nil || match[4] == "FETCH_HEAD" {
+			continue
+		}
+		p := parsed{
+			flag: match[1][0],
+			update: FetchRefUpdate{
+				Ref:       match[4],
+				RemoteRef: match[3],
+				Reason:    match[5],
+			},
+		}
+		summary := match[2]
+		switch {
+		case strings.Contains(summary, "..."):
+			p.oldName, p.newName, _ = strings.Cut(summary, "...")
+		case strings.Contains(summary, ".."):
+			p.oldName, p.newName, _ = strings.Cut(summary, "..")
+		case p.flag != '-' && p.flag != '!':
+			// New refs and tag updates only print the kind of change, so read the ref itself
+			p.newName = p.update.Ref
+		}
+		if p.update.RemoteRef == "(none)" {
+			p.update.RemoteRef = ""
+		}
+		for _, name := range []string{p.oldName, p.newName} {
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+		lines = append(lines, p)
+	}
+
+	hashes, err := g.resolveObjects(context.Background(), names)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &FetchResult{}
+	for _, p := range lines {
+		update := p.update
+		update.Old = hashes[p.oldName]
+		update.New = hashes[p.newName]
+		switch p.flag {
+		case '*':
+			result.New = append(result.New, update)
+		case '-':
+			result.Pruned = append(result.Pruned, update)
+		case '!':
+			result.Rejected = append(result.Rejected, update)
+		default:
+			update.Forced = p.flag == '+'
+			result.Updated = append(result.Updated, update)
+		}
+	}
+	return result, nil
+}
+
+// resolveObjects returns the full hashes of the named objects, which may be abbreviated
+// hashes, ref names or other revisions. Names that can't be resolved are left out.
+// All names are resolved by a single git cat-file process.
+func (g *Repo) resolveObjects(ctx context.Context, names []string) (map[string]string, error) {
+	hashes := make(map[string]string)
+	if len(names) == 0 {
+		return hashes, nil
+	}
+	for _, name := range names {
+		// cat-file reads one name per line
+		if strings.ContainsAny(name, "\r\n") {
+			return nil, fmt.Errorf("invalid object name %q", name)
+		}
+	}
+
+	input := strings.Join(names, "\n") + "\n"
+	stdout, stderr, err := g.Client.ExecWithOptions(ExecOptions{
+		Context: ctx,
+		Stdin:   strings.NewReader(input),
+	}, "cat-file", "--batch-check=%(objectname)")
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+
+	// cat-file prints one line per name, "<name> missing" for names it can't resolve
+	lines := strings.Split(string(bytes.TrimRight(stdout, "\n")), "\n")
+	for i, line := range lines {
+		if i < len(names) && isHexHash(line) {
+			hashes[names[i]] = line
+		}
+	}
+	return hashes, nil
+}
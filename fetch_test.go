@@ -0,0 +1,117 @@
+package gittools
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestFetchWithResult(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		remotePath, cleanup, err := CreateTestRemoteRepo("fetch-result")
+		if err != nil {
+			t.Fatalf("Failed to create remote: %v", err)
+		}
+		defer cleanup()
+
+		client := &Client{}
+		client.SetUser("Test User", "test@example.com")
+		writer, err := client.Clone(remotePath, filepath.Join(testDir, "writer"))
+		if err != nil {
+			t.Fatalf("Failed to clone: %v", err)
+		}
+		base, err := writer.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+		writeAndCommit(t, writer, "forced.txt", "forced\n")
+		if err := writer.PushWithOptions("origin", PushOptions{Refspecs: []string{"HEAD:refs/heads/forced", "HEAD:refs/heads/gone"}}); err != nil {
+			t.Fatalf("Failed to push branches: %v", err)
+		}
+		forcedOld, err := writer.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+
+		reader, err := client.Clone(remotePath, filepath.Join(testDir, "reader"))
+		if err != nil {
+			t.Fatalf("Failed to clone: %v", err)
+		}
+		result, err := reader.FetchWithResult("origin", FetchOptions{})
+		if err != nil {
+			t.Fatalf("FetchWithResult failed: %v", err)
+		}
+		if result.Changed() {
+			t.Errorf("Expected no changes fetching an up to date clone, got %+v", result)
+		}
+
+		// Fast-forward main, create a branch and a tag, force-update a branch and delete another
+		if err := writer.Reset(ResetOptions{Mode: ResetHard, Target: base}); err != nil {
+			t.Fatalf("Failed to reset: %v", err)
+		}
+		writeAndCommit(t, writer, "main.txt", "main\n")
+		mainNew, err := writer.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+		if stdout, stderr, err := writer.Client.Exec("tag", "v1.0"); err != nil {
+			t.Fatalf("Failed to tag: %v\n%s%s", err, stdout, stderr)
+		}
+		if err := writer.Push("origin", "main", "refs/tags/v1.0", "HEAD:refs/heads/feature", "+HEAD:refs/heads/forced"); err != nil {
+			t.Fatalf("Failed to push: %v", err)
+		}
+		if err := writer.DeleteRemoteBranch("origin", "gone"); err != nil {
+			t.Fatalf("Failed to delete branch: %v", err)
+		}
+
+		result, err = reader.FetchWithResult("origin", FetchOptions{Prune: true})
+		if err != nil {
+			t.Fatalf("FetchWithResult failed: %v", err)
+		}
+		updated := map[string]FetchRefUpdate{}
+		for _, update := range result.Updated {
+			updated[update.Ref] = update
+		}
+		if u := updated["origin/main"]; u.Old != base || u.New != mainNew || u.Forced || u.RemoteRef != "main" {
+			t.Errorf("Unexpected main update %+v", u)
+		}
+		if u := updated["origin/forced"]; u.Old != forcedOld || u.New != mainNew || !u.Forced || u.Reason != "forced update" {
+			t.Errorf("Unexpected forced update %+v", u)
+		}
+		created := map[string]FetchRefUpdate{}
+		for _, update := range result.New {
+			created[update.Ref] = update
+		}
+		if u := created["origin/feature"]; u.New != mainNew || u.Old != "" {
+			t.Errorf("Unexpected new branch %+v", u)
+		}
+		if u := created["v1.0"]; u.New != mainNew {
+			t.Errorf("Unexpected new tag %+v", u)
+		}
+		if len(result.Pruned) != 1 || result.Pruned[0].Ref != "origin/gone" || result.Pruned[0].RemoteRef != "" {
+			t.Errorf("Unexpected pruned refs %+v", result.Pruned)
+		}
+		if len(result.Rejected) != 0 {
+			t.Errorf("Expected no rejected refs, got %+v", result.Rejected)
+		}
+
+		// A non-fast-forward update without + is rejected
+		if err := reader.CreateBranch("local"); err != nil {
+			t.Fatalf("Failed to create branch: %v", err)
+		}
+		if err := reader.Checkout("local"); err != nil {
+			t.Fatalf("Failed to check out local: %v", err)
+		}
+		writeAndCommit(t, reader, "local.txt", "local\n")
+		if err := reader.Checkout("main"); err != nil {
+			t.Fatalf("Failed to check out main: %v", err)
+		}
+		result, err = reader.FetchWithResult("origin", FetchOptions{Refspecs: []string{"refs/heads/forced:refs/heads/local"}})
+		if !errors.Is(err, ErrFetchRejected) {
+			t.Fatalf("Expected ErrFetchRejected, got %v", err)
+		}
+		if len(result.Rejected) != 1 || result.Rejected[0].Ref != "local" || result.Rejected[0].Reason != "non-fast-forward" {
+			t.Errorf("Unexpected rejected refs %+v", result.Rejected)
+		}
+	})
+}
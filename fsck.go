@@ -0,0 +1,218 @@
+package gittools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FsckKind is the kind of problem reported by git fsck
+type FsckKind string
+
+const (
+	// FsckDangling is an object that nothing references, e.g. a commit left behind
+	// by a reset. Dangling objects are harmless and removed by garbage collection.
+	FsckDangling FsckKind = "dangling"
+
+	// FsckUnreachable is an object that cannot be reached from any ref, reported
+	// when FsckOptions.Unreachable is set
+	FsckUnreachable FsckKind = "unreachable"
+
+	// FsckMissing is an object that is referenced but not in the object database
+	FsckMissing FsckKind = "missing"
+
+	// FsckBrokenLink is a reference from one object to another that cannot be read
+	FsckBrokenLink FsckKind = "broken link"
+
+	// FsckCorruptObject is an object that cannot be read or is malformed
+	FsckCorruptObject FsckKind = "corrupt object"
+
+	// FsckCorruptRef is a ref that does not point at a valid object
+	FsckCorruptRef FsckKind = "corrupt ref"
+
+	// FsckWarning is a minor problem with an object, e.g. a malformed timezone
+	FsckWarning FsckKind = "warning"
+
+	// FsckError is any other error reported by git fsck
+	FsckError FsckKind = "error"
+)
+
+// FsckFinding is a single problem reported by git fsck
+type FsckFinding struct {
+	Kind FsckKind
+
+	// ObjectType is the type of the object, e.g. "commit", "tree", "blob" or "tag",
+	// if git reported it
+	ObjectType string
+
+	// Object is the hash of the object the finding is about, if any
+	Object string
+
+	// From is the hash of the object holding a broken link
+	From string
+
+	// Ref is the name of a corrupt ref
+	Ref string
+
+	// Message is the line reported by git
+	Message string
+}
+
+// FsckOptions defines options for checking the repository
+type FsckOptions struct {
+	// Unreachable reports every object not reachable from a ref, rather than only
+	// the dangling ones
+	Unreachable bool
+
+	// NoDangling skips reporting dangling objects
+	NoDangling bool
+
+	// ConnectivityOnly only checks that reachable objects exist, without reading
+	// blobs, which is much faster for large repositories
+	ConnectivityOnly bool
+
+	// Strict also reports objects with problems git otherwise tolerates, e.g.
+	// trees with group-writable file modes
+	Strict bool
+}
+
+// FsckResult holds the findings of git fsck
+type FsckResult struct {
+	Findings []FsckFinding
+}
+
+// Healthy returns true if no objects or refs are missing or corrupt.
+// Dangling and unreachable objects do not affect the health of a repository.
+func (r *FsckResult) Healthy() bool {
+	for _, finding := range r.Findings {
+		if finding.Kind != FsckDangling && finding.Kind != FsckUnreachable {
+			return false
+		}
+	}
+	return true
+}
+
+// Fsck verifies the connectivity and validity of the objects in the repository and
+// returns the problems found. Problems in the repository are reported as findings
+// rather than an error, see FsckResult.Healthy.
+func (r *Repo) Fsck(opts FsckOptions) (*FsckResult, error) {
+	args := []string{"fsck", "--no-progress"}
+	if opts.Unreachable {
+		args = append(args, "--unreachable")
+	}
+	if opts.NoDangling {
+		args = append(args, "--no-dangling")
+	}
+	if opts.ConnectivityOnly {
+		args = append(args, "--connectivity-only")
+	}
+	if opts.Strict {
+		args = append(args, "--strict")
+	}
+
+	stdout, stderr, err := r.Client.Exec(args...)
+	result := &FsckResult{
+		Findings: append(parseFsckOutput(string(stdout)), parseFsckOutput(string(stderr))...),
+	}
+
+	// git fsck exits with a non-zero status when it finds problems
+	if err != nil && (ExitCode(err) == -1 || len(result.Findings) == 0) {
+		return nil, fmt.Errorf("git fsck failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return result, nil
+}
+
+// parseFsckOutput parses the findings in the output of git fsck.
+// Progress messages and notices are skipped.
+func parseFsckOutput(output string) []FsckFinding {
+	var findings []FsckFinding
+	lines := strings.Split(output, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		fields := strings.Fields(line)
+
+		switch {
+		case line == "":
+			continue
+
+		// Format: dangling|unreachable|missing <type> <hash>
+		case len(fields) == 3 && (fields[0] == "dangling" || fields[0] == "unreachable" || fields[0] == "missing"):
+			findings = append(findings, FsckFinding{
+				Kind:       FsckKind(fields[0]),
+				ObjectType: fields[1],
+				Object:     fields[2],
+				Message:    line,
+			})
+
+		// Format: broken link from <type> <hash>
+		//                       to <type> <hash>
+		case strings.HasPrefix(line, "broken link from"):
+			finding := FsckFinding{Kind: FsckBrokenLink, Message: line}
+			if len(fields) == 5 {
+				finding.From = fields[4]
+			}
+			if i+1 < len(lines) {
+				next := strings.TrimSpace(lines[i+1])
+				if to := strings.Fields(next); len(to) == 3 && to[0] == "to" {
+					finding.ObjectType = to[1]
+					finding.Object = to[2]
+					finding.Message += "\n" + next
+					i++
+				}
+			}
+			findings = append(findings, finding)
+
+		// Format: warning in <type> <hash>: <message>
+		//         error in <type> <hash>: <message>
+		case strings.HasPrefix(line, "warning in ") || strings.HasPrefix(line, "error in "):
+			finding := FsckFinding{Kind: FsckCorruptObject, Message: line}
+			if fields[0] == "warning" {
+				finding.Kind = FsckWarning
+			}
+			if len(fields) >= 4 {
+				finding.ObjectType = fields[2]
+				finding.Object = strings.TrimSuffix(fields[3], ":")
+			}
+			findings = append(findings, finding)
+
+		case strings.HasPrefix(line, "error: "):
+			findings = append(findings, parseFsckError(line))
+		}
+	}
+	return findings
+}
+
+// parseFsckError classifies an "error: " line reported by git fsck
+func parseFsckError(line string) FsckFinding {
+	message := strings.TrimPrefix(line, "error: ")
+	subject, detail, _ := strings.Cut(message, ": ")
+
+	switch {
+	// Format: error: <ref>: invalid sha1 pointer <hash>
+	//         error: <ref>: invalid reflog entry <hash>
+	case strings.HasPrefix(subject, "refs/") || subject == "HEAD":
+		finding := FsckFinding{Kind: FsckCorruptRef, Ref: subject, Message: line}
+		if fields := strings.Fields(detail); len(fields) > 0 && isHexHash(fields[len(fields)-1]) {
+			finding.Object = fields[len(fields)-1]
+		}
+		return finding
+
+	// Format: error: <hash>: object corrupt or missing: <path>
+	case isHexHash(subject):
+		return FsckFinding{Kind: FsckCorruptObject, Object: subject, Message: line}
+	}
+	return FsckFinding{Kind: FsckError, Message: line}
+}
+
+// isHexHash returns true if s looks like a full SHA-1 or SHA-256 object hash
+func isHexHash(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package gittools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFsck(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		repo := openTestRepo(t)
+		writeAndCommit(t, repo, "a.txt", "a\n")
+
+		result, err := repo.Fsck(FsckOptions{})
+		if err != nil {
+			t.Fatalf("Fsck failed: %v", err)
+		}
+		if !result.Healthy() || len(result.Findings) != 0 {
+			t.Errorf("Expected no findings in a fresh repository, got %+v", result.Findings)
+		}
+
+		dangling, err := repo.HashObject([]byte("dangling\n"))
+		if err != nil {
+			t.Fatalf("HashObject failed: %v", err)
+		}
+		result, err = repo.Fsck(FsckOptions{})
+		if err != nil {
+			t.Fatalf("Fsck failed: %v", err)
+		}
+		if !result.Healthy() || !hasFsckFinding(result, FsckDangling, dangling) {
+			t.Errorf("Expected a dangling blob %s, got %+v", dangling, result.Findings)
+		}
+
+		// Remove a blob and point a branch at an object that does not exist
+		blob, err := repo.RevParse("HEAD:a.txt")
+		if err != nil {
+			t.Fatalf("Failed to get blob: %v", err)
+		}
+		if err := os.Remove(filepath.Join(repo.RepoPath, ".git", "objects", blob[:2], blob[2:])); err != nil {
+			t.Fatalf("Failed to remove blob: %v", err)
+		}
+		bogus := "1234567890123456789012345678901234567890"
+		ref := filepath.Join(repo.RepoPath, ".git", "refs", "heads", "broken")
+		if err := os.WriteFile(ref, []byte(bogus+"\n"), 0644); err != nil {
+			t.Fatalf("Failed to write ref: %v", err)
+		}
+
+		result, err = repo.Fsck(FsckOptions{NoDangling: true})
+		if err != nil {
+			t.Fatalf("Fsck failed: %v", err)
+		}
+		if result.Healthy() {
+			t.Error("Expected the repository to be unhealthy")
+		}
+		if !hasFsckFinding(result, FsckMissing, blob) {
+			t.Errorf("Expected missing blob %s, got %+v", blob, result.Findings)
+		}
+		if !hasFsckFinding(result, FsckCorruptRef, bogus) {
+			t.Errorf("Expected corrupt ref refs/heads/broken, got %+v", result.Findings)
+		}
+		if hasFsckFinding(result, FsckDangling, dangling) {
+			t.Errorf("Expected dangling objects to be skipped, got %+v", result.Findings)
+		}
+	})
+}
+
+func TestParseFsckOutput(t *testing.T) {
+	output := "broken link from    tree 1111111111111111111111111111111111111111\n" +
+		"              to    blob 2222222222222222222222222222222222222222\n" +
+		"warning in commit 3333333333333333333333333333333333333333: badTimezone: invalid author/committer line - bad time zone\n" +
+		"error: 4444444444444444444444444444444444444444: object corrupt or missing: .git/objects/44/44\n" +
+		"error: inflate: data stream error (incorrect header check)\n" +
+		"notice: HEAD points to an unborn branch (main)\n"
+
+	findings := parseFsckOutput(output)
+	if len(findings) != 4 {
+		t.Fatalf("Expected 4 findings, got %+v", findings)
+	}
+	if f := findings[0]; f.Kind != FsckBrokenLink || f.From != "1111111111111111111111111111111111111111" ||
+		f.ObjectType != "blob" || f.Object != "2222222222222222222222222222222222222222" {
+		t.Errorf("Unexpected broken link finding: %+v", f)
+	}
+	if f := findings[1]; f.Kind != FsckWarning || f.ObjectType != "commit" || f.Object != "3333333333333333333333333333333333333333" {
+		t.Errorf("Unexpected warning finding: %+v", f)
+	}
+	if f := findings[2]; f.Kind != FsckCorruptObject || f.Object != "4444444444444444444444444444444444444444" {
+		t.Errorf("Unexpected corrupt object finding: %+v", f)
+	}
+	if f := findings[3]; f.Kind != FsckError {
+		t.Errorf("Unexpected error finding: %+v", f)
+	}
+}
+
+func hasFsckFinding(result *FsckResult, kind FsckKind, object string) bool {
+	for _, finding := range result.Findings {
+		if finding.Kind == kind && finding.Object == object {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+// Package gittoolstest provides a MockExecutor for testing code built on gittools
+// without a git binary.
+package gittoolstest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ocuroot/gittools"
+)
+
+// MockExecutor is a gittools.Executor that returns stubbed output, for testing code built
+// on gittools without a git binary.
+//
+//	mock := &gittoolstest.MockExecutor{}
+//	mock.On("rev-parse", "HEAD").Return("0123abcd\n")
+//	repo := &gittools.Repo{Client: &gittools.Client{Executor: mock}}
+//	...
+//	mock.AssertExpectations(t)
+//
+// Commands are matched on their arguments after global options such as -c.
+// Commands without a stub fail.
+type MockExecutor struct {
+	mu    sync.Mutex
+	stubs []*MockCall
+	calls [][]string
+}
+
+// MockCall is the stubbed result of a command, created with MockExecutor.On
+type MockCall struct {
+	args   []string
+	stdout []byte
+	stderr []byte
+	err    error
+
+	// times is the number of calls the stub answers, 0 for any number
+	times int
+	calls int
+}
+
+// On stubs the command with the given arguments, by default returning empty output.
+// Stubs are matched in the order they were added.
+func (m *MockExecutor) On(args ...string) *MockCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	call := &MockCall{args: args}
+	m.stubs = append(m.stubs, call)
+	return call
+}
+
+// Return sets the stdout of the command
+func (c *MockCall) Return(stdout string) *MockCall {
+	c.stdout = []byte(stdout)
+	return c
+}
+
+// ReturnError makes the command fail with err and the given stderr.
+// Use a gittools.RecordedExitError to simulate git exiting with a particular status.
+func (c *MockCall) ReturnError(stderr string, err error) *MockCall {
+	c.stderr = []byte(stderr)
+	c.err = err
+	return c
+}
+
+// Times limits the stub to answering n calls, and makes AssertExpectations
+// require exactly n calls
+func (c *MockCall) Times(n int) *MockCall {
+	c.times = n
+	return c
+}
+
+// Exec returns the output of the first stub matching the command's arguments
+func (m *MockExecutor) Exec(command *gittools.Command) ([]byte, []byte, error) {
+	args := command.SubcommandArgs()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, args)
+
+	for _, stub := range m.stubs {
+		if stub.times > 0 && stub.calls >= stub.times {
+			continue
+		}
+		if !stub.matches(args) {
+			continue
+		}
+		stub.calls++
+		return stub.stdout, stub.stderr, stub.err
+	}
+
+	return nil, nil, fmt.Errorf("unexpected git command: git %s", strings.Join(args, " "))
+}
+
+func (c *MockCall) matches(args []string) bool {
+	if len(c.args) != len(args) {
+		return false
+	}
+	for i := range args {
+		if c.args[i] != args[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Calls returns the arguments of every command run, after global options
+func (m *MockExecutor) Calls() [][]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([][]string{}, m.calls...)
+}
+
+// AssertExpectations fails the test if a stub was never called, or was called
+// a different number of times than set with Times
+func (m *MockExecutor) AssertExpectations(t testing.TB) {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, stub := range m.stubs {
+		switch {
+		case stub.times > 0 && stub.calls != stub.times:
+			t.Errorf("Expected git %s to be called %d times, got %d", strings.Join(stub.args, " "), stub.times, stub.calls)
+		case stub.calls == 0:
+			t.Errorf("Expected git %s to be called", strings.Join(stub.args, " "))
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package gittoolstest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestMockExecutor(t *testing.T) {
+	mock := &MockExecutor{}
+	mock.On("rev-parse", "HEAD").Return("0123abcd\n").Times(1)
+	mock.On("rev-parse", "HEAD").Return("4567ef01\n")
+	mock.On("status", "--porcelain=v1", "-z", "--untracked-files=all").
+		ReturnError("fatal: not a git repository\n", &gittools.RecordedExitError{Code: 128, Message: "exit status 128"})
+
+	repo := &gittools.Repo{
+		Client: &gittools.Client{
+			Executor:        mock,
+			ConfigOverrides: map[string]string{"core.quotePath": "false"},
+		},
+	}
+
+	// Stubs are used in order, a limited stub is skipped once exhausted
+	for _, want := range []string{"0123abcd", "4567ef01", "4567ef01"} {
+		got, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("RevParse failed: %v", err)
+		}
+		if got != want {
+			t.Errorf("Expected %s, got %s", want, got)
+		}
+	}
+
+	_, err := repo.Status()
+	var exitErr *gittools.RecordedExitError
+	if !errors.As(err, &exitErr) || gittools.ExitCode(err) != 128 {
+		t.Errorf("Expected stubbed exit status 128, got %v", err)
+	}
+
+	if _, _, err := repo.Client.Exec("log"); err == nil {
+		t.Error("Expected a command without a stub to fail")
+	}
+
+	calls := mock.Calls()
+	if len(calls) != 5 || calls[0][0] != "rev-parse" || calls[4][0] != "log" {
+		t.Errorf("Expected calls without global options, got %v", calls)
+	}
+
+	mock.AssertExpectations(t)
+
+	unused := &MockExecutor{}
+	unused.On("fetch", "origin")
+	recorder := &recordingT{TB: t}
+	unused.AssertExpectations(recorder)
+	if !recorder.failed {
+		t.Error("Expected AssertExpectations to report an uncalled stub")
+	}
+}
+
+// recordingT records test failures without failing the test
+type recordingT struct {
+	testing.TB
+	failed bool
+}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.failed = true
+}
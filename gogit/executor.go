@@ -0,0 +1,561 @@
+// Package gogit serves read-only git commands with go-git, a pure Go implementation
+// of git, so gittools can read repositories where no git binary is installed.
+//
+//	executor, err := gogit.NewExecutor(path)
+//	if err != nil {
+//		return err
+//	}
+//	repo := &gittools.Repo{
+//		Client:   &gittools.Client{WorkDir: path, Executor: executor},
+//		RepoPath: path,
+//	}
+//
+// The supported commands are rev-parse, cat-file, diff --name-only between two
+// commits and log. Other commands, such as push and fetch, are passed to the
+// Executor's Fallback, or fail with ErrUnsupported if there is none, as are commands
+// for any repository other than the one the Executor opened.
+//
+// gogit is a separate module, so programs that only use gittools do not depend on go-git.
+package gogit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/ocuroot/gittools"
+)
+
+// ErrUnsupported is returned for commands that go-git cannot serve when there is no Fallback
+var ErrUnsupported = errors.New("command not supported by go-git")
+
+// gitDateFormat is the default date format of git log
+const gitDateFormat = "Mon Jan 2 15:04:05 2006 -0700"
+
+// Executor is a gittools.Executor that reads a repository with go-git
+type Executor struct {
+	// Fallback runs commands that go-git cannot serve, e.g. a Client using the
+	// git binary for push and fetch. The command is passed on as it is, with its
+	// stdin and environment, using Client.Run. If nil, those commands fail with
+	// ErrUnsupported.
+	Fallback *gittools.Client
+
+	repo   *git.Repository
+	gitDir string
+	topDir string
+	bare   bool
+
+	// realGitDir and realTopDir are gitDir and topDir with symlinks resolved, to
+	// compare with the directories commands run in
+	realGitDir string
+	realTopDir string
+}
+
+// NewExecutor opens the repository containing path
+func NewExecutor(path string) (*Executor, error) {
+	repo, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	e := &Executor{repo: repo}
+	if storage, ok := repo.Storer.(*filesystem.Storage); ok {
+		e.gitDir = storage.Filesystem().Root()
+	}
+
+	worktree, err := repo.Worktree()
+	switch {
+	case errors.Is(err, git.ErrIsBareRepository):
+		e.bare = true
+	case err != nil:
+		return nil, fmt.Errorf("failed to open worktree: %w", err)
+	default:
+		e.topDir = worktree.Filesystem.Root()
+		e.realTopDir = realPath(e.topDir)
+	}
+	e.realGitDir = realPath(e.gitDir)
+
+	return e, nil
+}
+
+// realPath returns the absolute path with symlinks resolved, or as it is if that fails
+func realPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	}
+	return path
+}
+
+// within returns true if path is dir or inside it
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// binds returns true if git would run the command against the opened repository.
+// A command run in another directory, e.g. a clone, a linked worktree or a nested
+// repository, or with GIT_DIR or GIT_WORK_TREE pointing elsewhere, reads a
+// different repository.
+func (e *Executor) binds(command *gittools.Command) bool {
+	dir := realPath(command.Dir)
+
+	var gitDir, workTree string
+	for _, v := range command.Env {
+		switch {
+		case strings.HasPrefix(v, "GIT_DIR="):
+			gitDir = strings.TrimPrefix(v, "GIT_DIR=")
+		case strings.HasPrefix(v, "GIT_WORK_TREE="):
+			workTree = strings.TrimPrefix(v, "GIT_WORK_TREE=")
+		}
+	}
+	// Relative paths in the environment are relative to the command's directory
+	resolve := func(path string) string {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		return realPath(path)
+	}
+	if workTree != "" && (e.bare || resolve(workTree) != e.realTopDir) {
+		return false
+	}
+	if gitDir != "" {
+		return resolve(gitDir) == e.realGitDir
+	}
+
+	if dir == e.realGitDir {
+		return true
+	}
+	if e.bare || !within(dir, e.realTopDir) {
+		return false
+	}
+	// git uses the closest .git below the top level, such as a nested repository
+	for ; dir != e.realTopDir; dir = filepath.Dir(dir) {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// exitError reports a command that failed as git would have, with its exit status
+type exitError struct {
+	code    int
+	message string
+}
+
+func (e *exitError) Error() string {
+	return e.message
+}
+
+func (e *exitError) ExitCode() int {
+	return e.code
+}
+
+// fatal returns the output of a command that git would have failed with status 128
+func fatal(format string, args ...interface{}) ([]byte, []byte, error) {
+	return nil, []byte("fatal: " + fmt.Sprintf(format, args...) + "\n"), &exitError{code: 128, message: "exit status 128"}
+}
+
+// errUnsupported is returned by the handler of a command for arguments it cannot serve
+var errUnsupported = errors.New("unsupported arguments")
+
+// Exec runs the command with go-git, or the Fallback if go-git cannot serve it
+func (e *Executor) Exec(command *gittools.Command) ([]byte, []byte, error) {
+	args := command.Args
+	subcommand, rest := splitArgs(args)
+
+	var (
+		stdout, stderr []byte
+		err            error
+	)
+	if !e.binds(command) {
+		subcommand = ""
+	}
+	switch subcommand {
+	case "rev-parse":
+		stdout, stderr, err = e.revParse(rest)
+	case "cat-file":
+		stdout, stderr, err = e.catFile(rest)
+	case "diff":
+		stdout, stderr, err = e.diff(rest)
+	case "log":
+		stdout, stderr, err = e.log(rest)
+	default:
+		err = errUnsupported
+	}
+	if !errors.Is(err, errUnsupported) {
+		return stdout, stderr, err
+	}
+
+	if e.Fallback == nil {
+		return nil, nil, fmt.Errorf("%w: git %s", ErrUnsupported, strings.Join(args, " "))
+	}
+	return e.Fallback.Run(command)
+}
+
+// splitArgs separates the subcommand and its arguments from global options such as -c
+func splitArgs(args []string) (string, []string) {
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "-c":
+			// Config overrides do not affect the commands served by go-git
+			i++
+		case strings.HasPrefix(args[i], "-"):
+			return "", nil
+		default:
+			return args[i], args[i+1:]
+		}
+	}
+	return "", nil
+}
+
+func (e *Executor) revParse(args []string) ([]byte, []byte, error) {
+	var (
+		out       bytes.Buffer
+		abbrevRef bool
+		short     int
+		quiet     bool
+	)
+	for _, arg := range args {
+		switch arg {
+		case "--verify":
+		case "-q", "--quiet":
+			quiet = true
+		case "--abbrev-ref":
+			abbrevRef = true
+		case "--short":
+			short = 7
+		case "--is-bare-repository":
+			fmt.Fprintln(&out, e.bare)
+		case "--absolute-git-dir", "--git-dir":
+			fmt.Fprintln(&out, e.gitDir)
+		case "--show-toplevel":
+			if e.bare {
+				return fatal("this operation must be run in a work tree")
+			}
+			fmt.Fprintln(&out, e.topDir)
+		default:
+			if strings.HasPrefix(arg, "--short=") {
+				length, err := strconv.Atoi(strings.TrimPrefix(arg, "--short="))
+				if err != nil || length < 0 {
+					return nil, nil, errUnsupported
+				}
+				// git never abbreviates below 4 characters
+				short = length
+				if short < 4 {
+					short = 4
+				}
+				continue
+			}
+			if strings.HasPrefix(arg, "-") {
+				return nil, nil, errUnsupported
+			}
+
+			if abbrevRef {
+				name, err := e.abbrevRef(arg)
+				if err != nil {
+					return nil, nil, err
+				}
+				fmt.Fprintln(&out, name)
+				continue
+			}
+
+			hash, err := e.repo.ResolveRevision(plumbing.Revision(arg))
+			if err != nil {
+				if quiet {
+					return nil, nil, &exitError{code: 1, message: "exit status 1"}
+				}
+				return fatal("ambiguous argument '%s': unknown revision or path not in the working tree.", arg)
+			}
+			if short > 0 && short < len(hash.String()) {
+				fmt.Fprintln(&out, hash.String()[:short])
+			} else {
+				fmt.Fprintln(&out, hash.String())
+			}
+		}
+	}
+	return out.Bytes(), nil, nil
+}
+
+// abbrevRef returns the short name of the branch rev refers to, or HEAD if it is detached
+func (e *Executor) abbrevRef(rev string) (string, error) {
+	if rev != "HEAD" {
+		return "", errUnsupported
+	}
+
+	head, err := e.repo.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return "", fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	if head.Type() != plumbing.SymbolicReference {
+		return "HEAD", nil
+	}
+	return head.Target().Short(), nil
+}
+
+func (e *Executor) catFile(args []string) ([]byte, []byte, error) {
+	if len(args) != 2 {
+		return nil, nil, errUnsupported
+	}
+	mode, name := args[0], args[1]
+
+	obj, err := e.object(name)
+	if err != nil {
+		if mode == "-e" {
+			return nil, nil, &exitError{code: 1, message: "exit status 1"}
+		}
+		return fatal("Not a valid object name %s", name)
+	}
+
+	switch mode {
+	case "-e":
+		return nil, nil, nil
+	case "-t":
+		return []byte(obj.Type().String() + "\n"), nil, nil
+	case "-s":
+		return []byte(fmt.Sprintf("%d\n", obj.Size())), nil, nil
+	case "-p":
+		if obj.Type() == plumbing.TreeObject {
+			return e.printTree(obj)
+		}
+		reader, err := obj.Reader()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read object: %w", err)
+		}
+		defer reader.Close()
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read object: %w", err)
+		}
+		return content, nil, nil
+	default:
+		return nil, nil, errUnsupported
+	}
+}
+
+// object finds the object named by a hash or revision
+func (e *Executor) object(name string) (plumbing.EncodedObject, error) {
+	if plumbing.IsHash(name) {
+		return e.repo.Storer.EncodedObject(plumbing.AnyObject, plumbing.NewHash(name))
+	}
+
+	// go-git resolves revisions to commits, so trees and paths are looked up here
+	rev, path, hasPath := strings.Cut(name, ":")
+	rev, isTree := cutSuffix(rev, "^{tree}")
+	rev, _ = cutSuffix(rev, "^{commit}")
+
+	hash, err := e.repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, err
+	}
+	if !hasPath && !isTree {
+		return e.repo.Storer.EncodedObject(plumbing.AnyObject, *hash)
+	}
+
+	commit, err := e.repo.CommitObject(*hash)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return e.repo.Storer.EncodedObject(plumbing.TreeObject, tree.Hash)
+	}
+	entry, err := tree.FindEntry(path)
+	if err != nil {
+		return nil, err
+	}
+	return e.repo.Storer.EncodedObject(plumbing.AnyObject, entry.Hash)
+}
+
+// cutSuffix returns s without suffix and whether it was present
+func cutSuffix(s, suffix string) (string, bool) {
+	if !strings.HasSuffix(s, suffix) {
+		return s, false
+	}
+	return strings.TrimSuffix(s, suffix), true
+}
+
+// printTree formats a tree as git cat-file -p does
+func (e *Executor) printTree(obj plumbing.EncodedObject) ([]byte, []byte, error) {
+	tree, err := object.DecodeTree(e.repo.Storer, obj)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode tree: %w", err)
+	}
+
+	var out bytes.Buffer
+	for _, entry := range tree.Entries {
+		objectType := plumbing.BlobObject
+		switch entry.Mode {
+		case filemode.Dir:
+			objectType = plumbing.TreeObject
+		case filemode.Submodule:
+			objectType = plumbing.CommitObject
+		}
+		fmt.Fprintf(&out, "%06o %s %s\t%s\n", uint32(entry.Mode), objectType, entry.Hash, entry.Name)
+	}
+	return out.Bytes(), nil, nil
+}
+
+// diff serves diff --name-only between two commits
+func (e *Executor) diff(args []string) ([]byte, []byte, error) {
+	var (
+		nameOnly bool
+		revs     []string
+		paths    []string
+	)
+	for i, arg := range args {
+		switch {
+		case arg == "--name-only":
+			nameOnly = true
+		case arg == "--":
+			paths = args[i+1:]
+		case strings.HasPrefix(arg, "-"):
+			return nil, nil, errUnsupported
+		default:
+			revs = append(revs, arg)
+		}
+		if arg == "--" {
+			break
+		}
+	}
+	// Comparisons with the index or working tree need the git binary
+	if !nameOnly || len(revs) != 2 {
+		return nil, nil, errUnsupported
+	}
+
+	var trees [2]*object.Tree
+	for i, rev := range revs {
+		hash, err := e.repo.ResolveRevision(plumbing.Revision(rev))
+		if err != nil {
+			return fatal("ambiguous argument '%s': unknown revision or path not in the working tree.", rev)
+		}
+		commit, err := e.repo.CommitObject(*hash)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read commit %s: %w", rev, err)
+		}
+		if trees[i], err = commit.Tree(); err != nil {
+			return nil, nil, fmt.Errorf("failed to read tree of %s: %w", rev, err)
+		}
+	}
+
+	changes, err := object.DiffTree(trees[0], trees[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to diff trees: %w", err)
+	}
+
+	var names []string
+	for _, change := range changes {
+		name := change.To.Name
+		if name == "" {
+			name = change.From.Name
+		}
+		if matchesPaths(name, paths) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var out bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintln(&out, name)
+	}
+	return out.Bytes(), nil, nil
+}
+
+// matchesPaths returns true if name is one of paths or inside one of them, or paths is empty
+func matchesPaths(name string, paths []string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	for _, path := range paths {
+		path = strings.TrimSuffix(path, "/")
+		if name == path || strings.HasPrefix(name, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// log serves git log in the default and --oneline formats from a single revision
+func (e *Executor) log(args []string) ([]byte, []byte, error) {
+	var (
+		oneline bool
+		revs    []string
+	)
+	for _, arg := range args {
+		switch {
+		case arg == "--oneline":
+			oneline = true
+		case strings.HasPrefix(arg, "-"), strings.Contains(arg, ".."):
+			return nil, nil, errUnsupported
+		default:
+			revs = append(revs, arg)
+		}
+	}
+	if len(revs) > 1 {
+		return nil, nil, errUnsupported
+	}
+
+	rev := "HEAD"
+	if len(revs) == 1 {
+		rev = revs[0]
+	}
+	hash, err := e.repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return fatal("ambiguous argument '%s': unknown revision or path not in the working tree.", rev)
+	}
+
+	commits, err := e.repo.Log(&git.LogOptions{From: *hash, Order: git.LogOrderCommitterTime})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read log: %w", err)
+	}
+
+	var out bytes.Buffer
+	err = commits.ForEach(func(c *object.Commit) error {
+		if oneline {
+			subject, _, _ := strings.Cut(c.Message, "\n")
+			fmt.Fprintf(&out, "%s %s\n", c.Hash.String()[:7], subject)
+			return nil
+		}
+
+		if out.Len() > 0 {
+			out.WriteString("\n")
+		}
+		fmt.Fprintf(&out, "commit %s\n", c.Hash)
+		if len(c.ParentHashes) > 1 {
+			out.WriteString("Merge:")
+			for _, parent := range c.ParentHashes {
+				fmt.Fprintf(&out, " %s", parent.String()[:7])
+			}
+			out.WriteString("\n")
+		}
+		fmt.Fprintf(&out, "Author: %s <%s>\n", c.Author.Name, c.Author.Email)
+		fmt.Fprintf(&out, "Date:   %s\n\n", c.Author.When.Format(gitDateFormat))
+		for _, line := range strings.Split(strings.TrimRight(c.Message, "\n"), "\n") {
+			fmt.Fprintf(&out, "    %s\n", line)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read log: %w", err)
+	}
+
+	return out.Bytes(), nil, nil
+}
@@ -0,0 +1,227 @@
+package gogit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestExecutorMatchesGit(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		client := gittools.Client{}
+		repoDir, err := os.MkdirTemp("", "gogit-")
+		if err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		defer os.RemoveAll(repoDir)
+
+		repo, err := client.Init(repoDir, "main")
+		if err != nil {
+			t.Fatalf("Failed to init repository: %v", err)
+		}
+		writeAndCommit(t, repo, "README.md", "hello\n", "Initial commit")
+		writeAndCommit(t, repo, "dir/file.txt", "content\n", "Add file\n\nWith a body")
+		writeAndCommit(t, repo, "README.md", "hello again\n", "Update README")
+
+		executor, err := NewExecutor(repoDir)
+		if err != nil {
+			t.Fatalf("Failed to open executor: %v", err)
+		}
+
+		commands := [][]string{
+			{"rev-parse", "HEAD"},
+			{"rev-parse", "--verify", "HEAD~1"},
+			{"rev-parse", "--short", "HEAD"},
+			{"rev-parse", "--verify", "--quiet", "--short=12", "HEAD"},
+			{"rev-parse", "--abbrev-ref", "HEAD"},
+			{"rev-parse", "--is-bare-repository"},
+			{"cat-file", "-t", "HEAD"},
+			{"cat-file", "-s", "HEAD"},
+			{"cat-file", "-p", "HEAD"},
+			{"cat-file", "-p", "HEAD^{tree}"},
+			{"cat-file", "-p", "HEAD:README.md"},
+			{"diff", "--name-only", "HEAD~2", "HEAD"},
+			{"diff", "--name-only", "HEAD~2", "HEAD", "--", "dir"},
+			{"log"},
+			{"log", "--oneline", "HEAD~1"},
+		}
+		for _, args := range commands {
+			want, _, err := repo.Client.Exec(args...)
+			if err != nil {
+				t.Fatalf("git %v failed: %v", args, err)
+			}
+			got, _, err := executor.Exec(&gittools.Command{Context: context.Background(), Args: args, Dir: repoDir})
+			if err != nil {
+				t.Fatalf("go-git %v failed: %v", args, err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("Output of %v differs\ngot:\n%s\nwant:\n%s", args, got, want)
+			}
+		}
+
+		// Failures report the same exit status as git
+		for _, args := range [][]string{
+			{"rev-parse", "--verify", "missing"},
+			{"rev-parse", "--verify", "-q", "missing"},
+			{"cat-file", "-e", "0123456789012345678901234567890123456789"},
+		} {
+			_, _, wantErr := repo.Client.Exec(args...)
+			_, _, err := executor.Exec(&gittools.Command{Context: context.Background(), Args: args, Dir: repoDir})
+			if gittools.ExitCode(err) != gittools.ExitCode(wantErr) {
+				t.Errorf("Expected %v to exit with status %d, got %v", args, gittools.ExitCode(wantErr), err)
+			}
+		}
+	})
+}
+
+func TestExecutorFallback(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		client := gittools.Client{}
+		repoDir, err := os.MkdirTemp("", "gogit-")
+		if err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		defer os.RemoveAll(repoDir)
+
+		repo, err := client.Init(repoDir, "main")
+		if err != nil {
+			t.Fatalf("Failed to init repository: %v", err)
+		}
+		writeAndCommit(t, repo, "README.md", "hello\n", "Initial commit")
+
+		executor, err := NewExecutor(repoDir)
+		if err != nil {
+			t.Fatalf("Failed to open executor: %v", err)
+		}
+		goGitRepo := &gittools.Repo{
+			Client:   &gittools.Client{WorkDir: repoDir, Executor: executor},
+			RepoPath: repoDir,
+		}
+
+		if _, err := goGitRepo.CurrentBranch(); err != nil {
+			t.Errorf("Failed to get current branch: %v", err)
+		}
+		if _, err := goGitRepo.Status(); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("Expected status to be unsupported without a fallback, got %v", err)
+		}
+
+		executor.Fallback = repo.Client
+		if _, err := goGitRepo.Status(); err != nil {
+			t.Errorf("Expected status to run with the fallback, got %v", err)
+		}
+
+		// Commands reading stdin are passed on with their input
+		want, err := repo.HashObject([]byte("hello\n"))
+		if err != nil {
+			t.Fatalf("Failed to hash object: %v", err)
+		}
+		got, err := goGitRepo.HashObject([]byte("hello\n"))
+		if err != nil {
+			t.Fatalf("HashObject failed with the fallback: %v", err)
+		}
+		if got != want {
+			t.Errorf("Expected the hash of the content %s, got %s", want, got)
+		}
+		if exists, err := goGitRepo.ObjectsExist([]string{want, "HEAD", "missing"}); err != nil || !exists[want] || !exists["HEAD"] || exists["missing"] {
+			t.Errorf("Expected batch checks to run with the fallback, got %v, %v", exists, err)
+		}
+
+		// And with the environment of the go-git Client
+		goGitRepo.Client.SetUser("Fallback User", "fallback@example.com")
+		if author, _, err := goGitRepo.Ident(); err != nil || author.Name != "Fallback User" {
+			t.Errorf("Expected the go-git Client's author, got %+v, %v", author, err)
+		}
+	})
+}
+
+func TestExecutorOtherRepository(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		client := gittools.Client{}
+		repoDir := filepath.Join(tempDir, "repo")
+		otherDir := filepath.Join(tempDir, "other")
+		for _, dir := range []string{repoDir, otherDir} {
+			if err := os.Mkdir(dir, 0755); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+		}
+		repo, err := client.Init(repoDir, "main")
+		if err != nil {
+			t.Fatalf("Failed to init repository: %v", err)
+		}
+		writeAndCommit(t, repo, "README.md", "hello\n", "Initial commit")
+
+		executor, err := NewExecutor(repoDir)
+		if err != nil {
+			t.Fatalf("Failed to open executor: %v", err)
+		}
+		goGitRepo := &gittools.Repo{
+			Client:   &gittools.Client{WorkDir: repoDir, Executor: executor},
+			RepoPath: repoDir,
+		}
+
+		// Without a fallback, commands for another repository are refused rather
+		// than answered from the opened one
+		if _, err := client.Init(otherDir, "main"); err != nil {
+			t.Fatalf("Failed to init repository: %v", err)
+		}
+		other := &gittools.Repo{
+			Client:   &gittools.Client{WorkDir: otherDir, Executor: executor},
+			RepoPath: otherDir,
+		}
+		if _, err := other.RevParse("HEAD"); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("Expected another repository to be unsupported, got %v", err)
+		}
+		gitDirRepo := &gittools.Repo{
+			Client:   &gittools.Client{WorkDir: repoDir, GitDir: filepath.Join(otherDir, ".git"), Executor: executor},
+			RepoPath: repoDir,
+		}
+		if _, err := gitDirRepo.RevParse("HEAD"); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("Expected another GIT_DIR to be unsupported, got %v", err)
+		}
+
+		// A linked worktree has its own HEAD, so it is read by the fallback
+		executor.Fallback = repo.Client
+		worktree, err := goGitRepo.AddWorktree(filepath.Join(tempDir, "worktree"), gittools.WorktreeOptions{Detach: true})
+		if err != nil {
+			t.Fatalf("Failed to add worktree: %v", err)
+		}
+		worktreeRepo, err := gittools.Open(worktree.RepoPath)
+		if err != nil {
+			t.Fatalf("Failed to open worktree: %v", err)
+		}
+		writeAndCommit(t, worktreeRepo, "README.md", "changed\n", "Worktree commit")
+
+		want, err := worktreeRepo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+		got, err := worktree.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("RevParse failed in the worktree: %v", err)
+		}
+		if got != want {
+			t.Errorf("Expected the worktree's HEAD %s, got %s", want, got)
+		}
+		if head, err := goGitRepo.RevParse("HEAD"); err != nil || head == want {
+			t.Errorf("Expected the repository's own HEAD, got %s, %v", head, err)
+		}
+	})
+}
+
+func writeAndCommit(t *testing.T, repo *gittools.Repo, path, content, message string) {
+	t.Helper()
+	fullPath := filepath.Join(repo.RepoPath, path)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+	if err := repo.Commit(message, []string{path}); err != nil {
+		t.Fatalf("Failed to commit %s: %v", path, err)
+	}
+}
@@ -0,0 +1,135 @@
+package gittools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GrepOptions defines options for the git grep command
+type GrepOptions struct {
+	// Ref is the commit, branch or tag to search. If empty, the tracked files in the
+	// working tree are searched.
+	Ref string
+
+	// Paths limits the search to the given paths or pathspecs
+	Paths []string
+
+	// IgnoreCase matches the pattern case insensitively (-i)
+	IgnoreCase bool
+
+	// Regexp treats the pattern as an extended regular expression (-E) instead of
+	// a fixed string
+	Regexp bool
+
+	// FilesOnly returns one match per matching file, without line numbers or text (-l)
+	FilesOnly bool
+
+	// Context for the command, if nil context.Background() is used
+	Context context.Context
+}
+
+// GrepMatch is a line matched by Grep
+type GrepMatch struct {
+	// Ref is the ref that was searched, or empty for the working tree
+	Ref string
+
+	// Path of the file relative to the root of the repository
+	Path string
+
+	// Line is the 1-based line number of the match, or 0 if FilesOnly was set
+	Line int
+
+	// Text of the matching line without its line ending, or empty if FilesOnly was set
+	Text string
+}
+
+// Grep searches tracked files for lines matching pattern, either in the working tree
+// or at options.Ref. Binary files are skipped.
+// Returns no matches and no error if nothing matches.
+func (r *Repo) Grep(pattern string, options GrepOptions) ([]GrepMatch, error) {
+	args := []string{"grep", "-z", "-I", "--full-name", "--no-color"}
+	if options.FilesOnly {
+		args = append(args, "-l")
+	} else {
+		args = append(args, "-n")
+	}
+	if options.IgnoreCase {
+		args = append(args, "-i")
+	}
+	if options.Regexp {
+		args = append(args, "-E")
+	} else {
+		args = append(args, "-F")
+	}
+	args = append(args, "-e", pattern)
+	if options.Ref != "" {
+		args = append(args, options.Ref)
+	}
+	args = append(args, "--")
+	args = append(args, options.Paths...)
+
+	ctx := options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	stdout, stderr, err := r.Client.ExecContext(ctx, args...)
+	if err != nil {
+		// grep exits with 1 and no output when nothing matches
+		if ExitCode(err) == 1 && len(stdout) == 0 && len(bytes.TrimSpace(stderr)) == 0 {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("git grep failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return parseGrepOutput(stdout, options.Ref, options.FilesOnly)
+}
+
+// parseGrepOutput parses the output of git grep -z. Each match is the path, line
+// number and text separated by NULs and terminated by a newline, or with -l, a
+// NUL terminated path. Paths in a ref are prefixed with the ref and a colon.
+func parseGrepOutput(output []byte, ref string, filesOnly bool) ([]GrepMatch, error) {
+	var matches []GrepMatch
+	rest := output
+	next := func(terminator byte) (string, bool) {
+		i := bytes.IndexByte(rest, terminator)
+		if i < 0 {
+			return "", false
+		}
+		field := string(rest[:i])
+		rest = rest[i+1:]
+		return field, true
+	}
+
+	for len(rest) > 0 {
+		path, ok := next(0)
+		if !ok {
+			return nil, fmt.Errorf("unexpected git grep output: %q", rest)
+		}
+		match := GrepMatch{Ref: ref, Path: path}
+		if ref != "" {
+			match.Path = strings.TrimPrefix(path, ref+":")
+		}
+
+		if !filesOnly {
+			line, ok := next(0)
+			if !ok {
+				return nil, fmt.Errorf("unexpected git grep output: %q", rest)
+			}
+			number, err := strconv.Atoi(line)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected git grep line number %q: %w", line, err)
+			}
+			text, ok := next('\n')
+			if !ok {
+				text, rest = string(rest), nil
+			}
+			match.Line = number
+			match.Text = strings.TrimSuffix(text, "\r")
+		}
+		matches = append(matches, match)
+	}
+	return matches, nil
+}
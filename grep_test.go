@@ -0,0 +1,113 @@
+package gittools
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGrep(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		repo, err := Open(setupTestRepo(t))
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		repo.Client.SetUser("Test User", "test@example.com")
+		if err := os.MkdirAll(filepath.Join(repo.RepoPath, "dir"), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		writeAndCommit(t, repo, "dir/a.txt", "hello\nHello world\nbye\n")
+		writeAndCommit(t, repo, "b c.txt", "x:hello.*\r\n")
+		first, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+		writeAndCommit(t, repo, "dir/a.txt", "goodbye\n")
+
+		// Untracked files are never searched
+		if err := os.WriteFile(filepath.Join(repo.RepoPath, "untracked.txt"), []byte("hello\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+
+		tests := []struct {
+			name     string
+			pattern  string
+			options  GrepOptions
+			expected []GrepMatch
+		}{
+			{
+				name:    "working tree",
+				pattern: "hello",
+				expected: []GrepMatch{
+					{Path: "b c.txt", Line: 1, Text: "x:hello.*"},
+				},
+			},
+			{
+				name:    "ref",
+				pattern: "hello",
+				options: GrepOptions{Ref: first},
+				expected: []GrepMatch{
+					{Ref: first, Path: "b c.txt", Line: 1, Text: "x:hello.*"},
+					{Ref: first, Path: "dir/a.txt", Line: 1, Text: "hello"},
+				},
+			},
+			{
+				name:    "ignore case",
+				pattern: "HELLO",
+				options: GrepOptions{Ref: first, Paths: []string{"dir"}, IgnoreCase: true},
+				expected: []GrepMatch{
+					{Ref: first, Path: "dir/a.txt", Line: 1, Text: "hello"},
+					{Ref: first, Path: "dir/a.txt", Line: 2, Text: "Hello world"},
+				},
+			},
+			{
+				name:     "fixed string",
+				pattern:  "hello.*",
+				options:  GrepOptions{Ref: first},
+				expected: []GrepMatch{{Ref: first, Path: "b c.txt", Line: 1, Text: "x:hello.*"}},
+			},
+			{
+				name:    "regexp",
+				pattern: "^(hello|bye)$",
+				options: GrepOptions{Ref: first, Regexp: true},
+				expected: []GrepMatch{
+					{Ref: first, Path: "dir/a.txt", Line: 1, Text: "hello"},
+					{Ref: first, Path: "dir/a.txt", Line: 3, Text: "bye"},
+				},
+			},
+			{
+				name:    "files only",
+				pattern: "hello",
+				options: GrepOptions{Ref: first, FilesOnly: true},
+				expected: []GrepMatch{
+					{Ref: first, Path: "b c.txt"},
+					{Ref: first, Path: "dir/a.txt"},
+				},
+			},
+			{
+				name:    "no matches",
+				pattern: "missing",
+				options: GrepOptions{Ref: first},
+			},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				matches, err := repo.Grep(test.pattern, test.options)
+				if err != nil {
+					t.Fatalf("Grep failed: %v", err)
+				}
+				if !reflect.DeepEqual(matches, test.expected) {
+					t.Errorf("Expected %+v, got %+v", test.expected, matches)
+				}
+			})
+		}
+
+		if _, err := repo.Grep("[", GrepOptions{Regexp: true}); err == nil {
+			t.Error("Expected an error for an invalid regular expression")
+		}
+		if _, err := repo.Grep("hello", GrepOptions{Ref: "missing"}); err == nil {
+			t.Error("Expected an error for a missing ref")
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package gittools
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+)
+
+// Command describes a git command about to be run by a Client
+type Command struct {
+	// Context the command runs under, including any DefaultTimeout
+	Context context.Context
+
+	// Args passed to git, including -c config overrides.
+	// Hooks may modify Args before the command is run.
+	Args []string
+
+	// Dir is the directory the command runs in, empty for the current directory
+	Dir string
+
+	// Env is the complete environment of the command, hooks may modify it
+	Env []string
+
+	// Stdin is connected to the command's standard input, nil for an empty input
+	Stdin io.Reader
+
+	// Result may be set by a hook in BeforeExec to skip running git and return the
+	// given output instead, e.g. to mock commands in tests
+	Result *CommandResult
+}
+
+// CommandResult is the output of a command supplied by a hook instead of running git
+type CommandResult struct {
+	Stdout []byte
+	Stderr []byte
+	Err    error
+}
+
+// Subcommand returns the git subcommand, e.g. "push", skipping global options such as -c
+func (c *Command) Subcommand() string {
+	index := c.subcommandIndex()
+	if index < 0 {
+		return ""
+	}
+	return c.Args[index]
+}
+
+// SubcommandArgs returns the git subcommand and its arguments, e.g. ["push", "origin", "main"],
+// skipping global options such as -c
+func (c *Command) SubcommandArgs() []string {
+	index := c.subcommandIndex()
+	if index < 0 {
+		return nil
+	}
+	return c.Args[index:]
+}
+
+// subcommandIndex returns the index of the subcommand in Args, or -1 if there is none
+func (c *Command) subcommandIndex() int {
+	for i := 0; i < len(c.Args); i++ {
+		arg := c.Args[i]
+		switch {
+		case arg == "-c" || arg == "-C" || arg == "--git-dir" || arg == "--work-tree":
+			// These options take a separate value
+			i++
+		case strings.HasPrefix(arg, "-"):
+		default:
+			return i
+		}
+	}
+	return -1
+}
+
+// Hook intercepts the git commands run by a Client, for logging, timing, mocking or vetoing them.
+// Hooks are called in order before a command and in reverse order after it.
+// Hooks must be safe for concurrent use if the Client is.
+type Hook interface {
+	// BeforeExec is called before a command is run.
+	// Returning an error prevents the command from running and is returned to the caller.
+	BeforeExec(cmd *Command) error
+
+	// AfterExec is called once a command has finished, been vetoed or been answered by a hook,
+	// for every hook whose BeforeExec was called. duration is zero if git was not run.
+	AfterExec(cmd *Command, stdout, stderr []byte, err error, duration time.Duration)
+}
@@ -0,0 +1,80 @@
+package gittools
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingHook struct {
+	mu       sync.Mutex
+	before   []string
+	after    []string
+	veto     string
+	mock     string
+	duration time.Duration
+}
+
+func (h *recordingHook) BeforeExec(cmd *Command) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.before = append(h.before, cmd.Subcommand())
+	switch cmd.Subcommand() {
+	case h.veto:
+		return errors.New("vetoed")
+	case h.mock:
+		cmd.Result = &CommandResult{Stdout: []byte("mocked\n")}
+	}
+	return nil
+}
+
+func (h *recordingHook) AfterExec(cmd *Command, stdout, stderr []byte, err error, duration time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.after = append(h.after, cmd.Subcommand())
+	h.duration += duration
+}
+
+func TestClientHooks(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		tempDir := setupTestRepo(t)
+
+		repo, err := Open(tempDir)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+
+		hook := &recordingHook{veto: "status", mock: "hash-object"}
+		repo.Client.Hooks = []Hook{hook}
+		repo.Client.ConfigOverrides = map[string]string{"core.quotePath": "false"}
+
+		if _, err := repo.RevParse("HEAD"); err != nil {
+			t.Fatalf("Failed to rev-parse: %v", err)
+		}
+
+		if _, err := repo.Status(); err == nil || !strings.Contains(err.Error(), "vetoed") {
+			t.Errorf("Expected status to be vetoed, got %v", err)
+		}
+
+		stdout, _, err := repo.Client.Exec("hash-object", "README.md")
+		if err != nil {
+			t.Fatalf("Mocked command failed: %v", err)
+		}
+		if string(stdout) != "mocked\n" {
+			t.Errorf("Expected mocked output, got %q", stdout)
+		}
+
+		want := []string{"rev-parse", "status", "hash-object"}
+		if strings.Join(hook.before, ",") != strings.Join(want, ",") {
+			t.Errorf("Expected BeforeExec for %v, got %v", want, hook.before)
+		}
+		if strings.Join(hook.after, ",") != strings.Join(want, ",") {
+			t.Errorf("Expected AfterExec for %v, got %v", want, hook.after)
+		}
+		if hook.duration == 0 {
+			t.Error("Expected the duration of the command that ran to be recorded")
+		}
+	})
+}
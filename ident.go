@@ -0,0 +1,136 @@
+package gittools
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrIdentityNotConfigured is returned when git has no name or email to record as the
+// author or committer of a commit
+var ErrIdentityNotConfigured = errors.New("git identity not configured")
+
+// Ident is a name, email and timestamp, as git records the author and committer of a commit
+type Ident struct {
+	Name  string
+	Email string
+	When  time.Time
+}
+
+// String formats the identity as git does, e.g. "Jane Doe <jane@example.com> 1700000000 +0000"
+func (i Ident) String() string {
+	return fmt.Sprintf("%s <%s> %d %s", i.Name, i.Email, i.When.Unix(), i.When.Format("-0700"))
+}
+
+// Ident returns the author and committer identities git would use for a commit made now,
+// taking the Client's author and committer fields, the environment and the repository's
+// config into account.
+// Returns ErrIdentityNotConfigured if either identity is missing.
+func (r *Repo) Ident() (author Ident, committer Ident, err error) {
+	if author, err = r.Client.ident("GIT_AUTHOR_IDENT"); err != nil {
+		return Ident{}, Ident{}, err
+	}
+	if committer, err = r.Client.ident("GIT_COMMITTER_IDENT"); err != nil {
+		return Ident{}, Ident{}, err
+	}
+	return author, committer, nil
+}
+
+// CheckIdentity returns ErrIdentityNotConfigured if git has no author or committer
+// identity to commit with. Commits are checked before they are made, so a missing
+// identity fails fast with a clear error instead of partway through an operation.
+// If the Client sets all author and committer fields, git is not consulted.
+func (c *Client) CheckIdentity() error {
+	if c.identitySet() {
+		return nil
+	}
+	for _, variable := range []string{"GIT_AUTHOR_IDENT", "GIT_COMMITTER_IDENT"} {
+		if _, err := c.ident(variable); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// identitySet returns true if the Client sets every author and committer field and Env
+// doesn't override them
+func (c *Client) identitySet() bool {
+	for _, value := range []string{c.AuthorName, c.AuthorEmail, c.CommitterName, c.CommitterEmail} {
+		if value == "" {
+			return false
+		}
+	}
+	for _, key := range []string{"GIT_AUTHOR_NAME", "GIT_AUTHOR_EMAIL", "GIT_COMMITTER_NAME", "GIT_COMMITTER_EMAIL"} {
+		if _, overridden := c.Env[key]; overridden {
+			return false
+		}
+	}
+	return true
+}
+
+// ident reads and parses an identity with git var
+func (c *Client) ident(variable string) (Ident, error) {
+	stdout, stderr, err := c.Exec("var", variable)
+	if err != nil {
+		if reason := identError(string(stderr)); reason != "" {
+			return Ident{}, fmt.Errorf("%w: %s: %s; set user.name and user.email in git config or the Client's author and committer",
+				ErrIdentityNotConfigured, identKind(variable), reason)
+		}
+		return Ident{}, fmt.Errorf("git var failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return parseIdent(strings.TrimSpace(string(stdout)))
+}
+
+// identError returns the reason git gave for refusing an identity, or "" if stderr
+// doesn't describe a missing identity
+func identError(stderr string) string {
+	for _, line := range strings.Split(stderr, "\n") {
+		reason := strings.TrimPrefix(strings.TrimSpace(line), "fatal: ")
+		if strings.Contains(reason, "auto-detect") || strings.Contains(reason, "empty ident name") ||
+			strings.Contains(reason, "no email was given") || strings.Contains(reason, "no name was given") {
+			return reason
+		}
+	}
+	if strings.Contains(stderr, "Please tell me who you are") {
+		return "identity unknown"
+	}
+	return ""
+}
+
+func identKind(variable string) string {
+	if variable == "GIT_AUTHOR_IDENT" {
+		return "author"
+	}
+	return "committer"
+}
+
+// parseIdent parses an identity in git's "Name <email> timestamp timezone" format
+func parseIdent(value string) (Ident, error) {
+	start := strings.LastIndex(value, "<")
+	end := strings.LastIndex(value, ">")
+	if start < 0 || end < start {
+		return Ident{}, fmt.Errorf("invalid git identity %q", value)
+	}
+
+	fields := strings.Fields(value[end+1:])
+	if len(fields) != 2 {
+		return Ident{}, fmt.Errorf("invalid git identity %q", value)
+	}
+	seconds, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return Ident{}, fmt.Errorf("invalid git identity timestamp %q: %w", fields[0], err)
+	}
+	zone, err := time.Parse("-0700", fields[1])
+	if err != nil {
+		return Ident{}, fmt.Errorf("invalid git identity timezone %q: %w", fields[1], err)
+	}
+
+	return Ident{
+		Name:  strings.TrimSpace(value[:start]),
+		Email: value[start+1 : end],
+		When:  time.Unix(seconds, 0).In(zone.Location()),
+	}, nil
+}
@@ -0,0 +1,96 @@
+package gittools
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIdent(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		repo, err := Open(setupTestRepo(t))
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		repo.Client.AuthorName, repo.Client.AuthorEmail = "Author", "author@example.com"
+		repo.Client.CommitterName, repo.Client.CommitterEmail = "Committer", "committer@example.com"
+		repo.Client.Env = map[string]string{"GIT_AUTHOR_DATE": "1700000000 +0130"}
+
+		author, committer, err := repo.Ident()
+		if err != nil {
+			t.Fatalf("Ident failed: %v", err)
+		}
+		if author.Name != "Author" || author.Email != "author@example.com" {
+			t.Errorf("Unexpected author %+v", author)
+		}
+		if author.When.Unix() != 1700000000 || author.String() != "Author <author@example.com> 1700000000 +0130" {
+			t.Errorf("Expected the author date to be parsed, got %s", author)
+		}
+		if committer.Name != "Committer" || committer.Email != "committer@example.com" {
+			t.Errorf("Unexpected committer %+v", committer)
+		}
+		if since := time.Since(committer.When); since < -time.Minute || since > time.Minute {
+			t.Errorf("Expected the committer date to be now, got %v", committer.When)
+		}
+	})
+}
+
+func TestIdentNotConfigured(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		repo, err := Open(setupTestRepo(t))
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		head, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+		repo.Client.Env = map[string]string{"GIT_AUTHOR_NAME": "", "GIT_COMMITTER_NAME": ""}
+
+		if _, _, err := repo.Ident(); !errors.Is(err, ErrIdentityNotConfigured) {
+			t.Errorf("Expected ErrIdentityNotConfigured from Ident, got %v", err)
+		}
+		if err := repo.Client.CheckIdentity(); !errors.Is(err, ErrIdentityNotConfigured) {
+			t.Errorf("Expected ErrIdentityNotConfigured from CheckIdentity, got %v", err)
+		}
+
+		if err := os.WriteFile(filepath.Join(repo.RepoPath, "file.txt"), []byte("content\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if err := repo.Commit("Add file", []string{"file.txt"}); !errors.Is(err, ErrIdentityNotConfigured) {
+			t.Errorf("Expected ErrIdentityNotConfigured from Commit, got %v", err)
+		}
+		if err := repo.CommitAll("Add file"); !errors.Is(err, ErrIdentityNotConfigured) {
+			t.Errorf("Expected ErrIdentityNotConfigured from CommitAll, got %v", err)
+		}
+		if _, err := repo.CommitTree(head+"^{tree}", "Empty", head); !errors.Is(err, ErrIdentityNotConfigured) {
+			t.Errorf("Expected ErrIdentityNotConfigured from CommitTree, got %v", err)
+		}
+
+		// Nothing is staged when the identity check fails
+		if status, _, err := repo.Client.Exec("status", "--porcelain"); err != nil || string(status) != "?? file.txt\n" {
+			t.Errorf("Expected file.txt to be left untracked, got %q, %v", status, err)
+		}
+	})
+}
+
+func TestParseIdent(t *testing.T) {
+	ident, err := parseIdent("Jane <Doe> <jane@example.com> 1700000000 -0500")
+	if err != nil {
+		t.Fatalf("parseIdent failed: %v", err)
+	}
+	if ident.Name != "Jane <Doe>" || ident.Email != "jane@example.com" || ident.When.Unix() != 1700000000 {
+		t.Errorf("Unexpected identity %+v", ident)
+	}
+	if _, offset := ident.When.Zone(); offset != -5*60*60 {
+		t.Errorf("Expected a -0500 offset, got %d", offset)
+	}
+
+	for _, invalid := range []string{"", "Jane", "Jane <jane@example.com>", "Jane <jane@example.com> now +0000"} {
+		if _, err := parseIdent(invalid); err == nil {
+			t.Errorf("Expected an error parsing %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,361 @@
+// Package lockcli implements the lock commands shared by the gitlock and gittools
+// command line tools, so both behave the same.
+//
+// Usage:
+//
+//	<name> <command> [flags] <lock-path>
+//
+// Commands:
+//
+//	acquire  acquire a lock
+//	release  release a lock held with the same -key
+//	refresh  extend the expiry of a lock held with the same -key
+//	check    report whether a lock is held
+//	list     list locks under a directory
+//	break    remove a lock regardless of its owner
+//	hold     acquire a lock and keep it refreshed until SIGINT or SIGTERM
+//
+// Exit codes:
+//
+//	0  success, or for check the lock is free
+//	1  error
+//	2  invalid usage
+//	3  the lock is held by another process
+//	4  the lock is not held by this process
+package lockcli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ocuroot/gittools"
+	"github.com/ocuroot/gittools/lock"
+)
+
+// Exit codes returned by Run
+const (
+	ExitOK       = 0
+	ExitError    = 1
+	ExitUsage    = 2
+	ExitConflict = 3
+	ExitNotOwned = 4
+)
+
+const usageFormat = `Usage: %[1]s <command> [flags] <lock-path>
+
+Commands:
+  acquire  acquire a lock
+  release  release a lock held with the same -key
+  refresh  extend the expiry of a lock held with the same -key
+  check    report whether a lock is held
+  list     list locks under a directory
+  break    remove a lock regardless of its owner
+  hold     acquire a lock and keep it refreshed until SIGINT or SIGTERM
+
+Run "%[1]s <command> -h" for the flags of a command.
+`
+
+// options are the flags shared by all commands
+type options struct {
+	repo        string
+	key         string
+	backend     string
+	branch      string
+	expiry      time.Duration
+	wait        time.Duration
+	description string
+	json        bool
+
+	// break only
+	ifExpired bool
+	reason    string
+}
+
+// Run executes the lock command in args and returns the process exit code.
+// name is the command line used to run it, e.g. "gitlock", and prefixes usage and
+// error messages.
+func Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) int {
+	usage := fmt.Sprintf(usageFormat, name)
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return ExitUsage
+	}
+	command := args[0]
+
+	var opts options
+	flags := flag.NewFlagSet(name+" "+command, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&opts.repo, "repo", ".", "path to the repository")
+	flags.StringVar(&opts.key, "key", os.Getenv("GITLOCK_KEY"), "stable lock key identifying this process, defaults to $GITLOCK_KEY")
+	flags.StringVar(&opts.backend, "backend", "file", "lock storage: file, branch or ref")
+	flags.StringVar(&opts.branch, "branch", lock.DefaultLockBranch, "lock branch used by the file and branch backends")
+	flags.BoolVar(&opts.json, "json", false, "write output as JSON")
+
+	switch command {
+	case "acquire", "refresh", "hold":
+		flags.DurationVar(&opts.expiry, "expiry", 5*time.Minute, "how long the lock is valid for")
+		if command != "refresh" {
+			flags.StringVar(&opts.description, "description", "", "description recorded in the lock")
+			flags.DurationVar(&opts.wait, "wait", 0, "how long to wait for a lock held by another process, 0 fails immediately")
+		}
+	case "break":
+		flags.BoolVar(&opts.ifExpired, "if-expired", false, "only break the lock if it has expired")
+		flags.StringVar(&opts.reason, "reason", "", "reason recorded in the commit message")
+	case "release", "check", "list":
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return ExitOK
+	default:
+		fmt.Fprintf(stderr, "%s: unknown command %q\n\n%s", name, command, usage)
+		return ExitUsage
+	}
+
+	if err := flags.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+
+	var lockPath string
+	switch {
+	case command == "list" && flags.NArg() <= 1:
+		lockPath = flags.Arg(0)
+	case flags.NArg() == 1:
+		lockPath = flags.Arg(0)
+	default:
+		fmt.Fprintf(stderr, "%s %s: expected a single lock path\n", name, command)
+		return ExitUsage
+	}
+
+	locking, err := newLocking(opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", name, err)
+		return ExitError
+	}
+
+	c := &cli{name: name, locking: locking, opts: opts, stdout: stdout, stderr: stderr}
+	switch command {
+	case "acquire":
+		return c.acquire(ctx, lockPath)
+	case "release":
+		return c.release(lockPath)
+	case "refresh":
+		return c.refresh(lockPath)
+	case "check":
+		return c.check(lockPath)
+	case "list":
+		return c.list(lockPath)
+	case "break":
+		return c.breakLock(lockPath)
+	default:
+		return c.hold(ctx, lockPath)
+	}
+}
+
+// newLocking opens the repository and configures locking as described by opts
+func newLocking(opts options) (*lock.Locking, error) {
+	repo, err := gittools.Open(opts.repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	var locking *lock.Locking
+	if opts.key != "" {
+		locking = lock.NewRepoLockingWithKey(repo, opts.key)
+	} else {
+		locking = lock.NewRepoLocking(repo)
+	}
+
+	switch opts.backend {
+	case "file":
+		locking.Backend = lock.NewFileBackendWithOptions(repo, lock.FileBackendOptions{Branch: opts.branch})
+	case "branch":
+		locking.Backend = lock.NewBranchBackend(repo, opts.branch)
+	case "ref":
+		locking.Backend = lock.NewRefBackend(repo)
+	default:
+		return nil, fmt.Errorf("unknown backend %q", opts.backend)
+	}
+
+	return locking, nil
+}
+
+type cli struct {
+	name    string
+	locking *lock.Locking
+	opts    options
+	stdout  io.Writer
+	stderr  io.Writer
+}
+
+// result is written to stdout after each command
+type result struct {
+	Path   string     `json:"path,omitempty"`
+	Status string     `json:"status"`
+	Key    string     `json:"key,omitempty"`
+	Lock   *lock.Lock `json:"lock,omitempty"`
+	Error  string     `json:"error,omitempty"`
+}
+
+func (c *cli) acquire(ctx context.Context, lockPath string) int {
+	if err := c.acquireLock(ctx, lockPath); err != nil {
+		return c.fail(lockPath, err)
+	}
+	return c.report(lockPath, "acquired")
+}
+
+// acquireLock acquires the lock, waiting for up to the -wait duration if it is held
+func (c *cli) acquireLock(ctx context.Context, lockPath string) error {
+	if c.opts.wait <= 0 {
+		return c.locking.AcquireLock(lockPath, c.opts.expiry, c.opts.description)
+	}
+	return c.locking.AcquireLockWait(ctx, lockPath, c.opts.expiry, c.opts.description, c.opts.wait)
+}
+
+func (c *cli) release(lockPath string) int {
+	if err := c.locking.ReleaseLock(lockPath); err != nil {
+		return c.fail(lockPath, err)
+	}
+	return c.write(result{Path: lockPath, Status: "released"}, "released %s\n", lockPath)
+}
+
+func (c *cli) refresh(lockPath string) int {
+	if err := c.locking.RefreshLock(lockPath, time.Now().Add(c.opts.expiry)); err != nil {
+		return c.fail(lockPath, err)
+	}
+	return c.report(lockPath, "refreshed")
+}
+
+func (c *cli) check(lockPath string) int {
+	current, err := c.locking.ReadLock(lockPath)
+	if err != nil {
+		return c.fail(lockPath, err)
+	}
+	if current == nil {
+		return c.write(result{Path: lockPath, Status: "free"}, "%s is free\n", lockPath)
+	}
+
+	c.write(result{Path: lockPath, Status: "locked", Lock: current},
+		"%s is locked until %s: %s\n", lockPath, current.ExpiresAt.Format(time.RFC3339), current.Description)
+	return ExitConflict
+}
+
+func (c *cli) list(dir string) int {
+	infos, err := c.locking.ListLocks(dir)
+	if err != nil {
+		return c.fail(dir, err)
+	}
+
+	if c.opts.json {
+		if infos == nil {
+			infos = []lock.LockInfo{}
+		}
+		return c.encode(infos)
+	}
+
+	for _, info := range infos {
+		status := "locked"
+		if info.Expired {
+			status = "expired"
+		}
+		fmt.Fprintf(c.stdout, "%s\t%s\t%s\t%s\n", info.Path, status, info.Lock.ExpiresAt.Format(time.RFC3339), info.Lock.Description)
+	}
+	return ExitOK
+}
+
+func (c *cli) breakLock(lockPath string) int {
+	err := c.locking.BreakLock(lockPath, lock.BreakOptions{
+		OnlyIfExpired: c.opts.ifExpired,
+		Reason:        c.opts.reason,
+	})
+	if err != nil {
+		return c.fail(lockPath, err)
+	}
+	return c.write(result{Path: lockPath, Status: "broken"}, "broke %s\n", lockPath)
+}
+
+// hold acquires the lock and refreshes it until ctx is cancelled, then releases it
+func (c *cli) hold(ctx context.Context, lockPath string) int {
+	if err := c.acquireLock(ctx, lockPath); err != nil {
+		return c.fail(lockPath, err)
+	}
+	c.report(lockPath, "acquired")
+
+	errs, err := c.locking.KeepAlive(ctx, lockPath, c.opts.expiry/3)
+	if err != nil {
+		_ = c.locking.ReleaseLock(lockPath)
+		return c.fail(lockPath, err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return c.release(lockPath)
+		case err, ok := <-errs:
+			if !ok {
+				// The keep-alive also stops when ctx is cancelled, which may be seen here first
+				if ctx.Err() != nil {
+					return c.release(lockPath)
+				}
+				// Otherwise it stopped because the lock was lost
+				return c.fail(lockPath, fmt.Errorf("lock was lost: %w", lock.ErrLockNotOwned))
+			}
+			fmt.Fprintf(c.stderr, "%s: failed to refresh %s: %v\n", c.name, lockPath, err)
+		}
+	}
+}
+
+// report writes the current state of a lock held by this process
+func (c *cli) report(lockPath string, status string) int {
+	current, err := c.locking.ReadLock(lockPath)
+	if err != nil {
+		return c.fail(lockPath, err)
+	}
+	var expires string
+	if current != nil {
+		expires = current.ExpiresAt.Format(time.RFC3339)
+	}
+	return c.write(result{Path: lockPath, Status: status, Key: c.locking.LockKey, Lock: current},
+		"%s %s until %s with key %s\n", status, lockPath, expires, c.locking.LockKey)
+}
+
+// fail reports err and returns the matching exit code
+func (c *cli) fail(lockPath string, err error) int {
+	code := ExitError
+	switch {
+	case errors.Is(err, lock.ErrLockConflict), errors.Is(err, lock.ErrLockNotExpired):
+		code = ExitConflict
+	case errors.Is(err, lock.ErrLockNotOwned):
+		code = ExitNotOwned
+	}
+
+	if c.opts.json {
+		c.encode(result{Path: lockPath, Status: "error", Error: err.Error()})
+	}
+	fmt.Fprintf(c.stderr, "%s: %v\n", c.name, err)
+	return code
+}
+
+// write writes r as JSON, or the formatted text otherwise
+func (c *cli) write(r result, format string, args ...interface{}) int {
+	if c.opts.json {
+		return c.encode(r)
+	}
+	fmt.Fprintf(c.stdout, format, args...)
+	return ExitOK
+}
+
+func (c *cli) encode(v interface{}) int {
+	if err := json.NewEncoder(c.stdout).Encode(v); err != nil {
+		fmt.Fprintf(c.stderr, "%s: failed to write output: %v\n", c.name, err)
+		return ExitError
+	}
+	return ExitOK
+}
@@ -0,0 +1,166 @@
+package lockcli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+	"github.com/ocuroot/gittools/lock"
+	"github.com/ocuroot/gittools/testutils"
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	testutils.CloseTestRemoteRepos()
+	os.Exit(code)
+}
+
+func TestRun(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		remoteDir, cleanup, err := gittools.CreateTestRemoteRepo("gitlock-cli")
+		if err != nil {
+			t.Fatalf("Failed to create remote repository: %v", err)
+		}
+		defer cleanup()
+
+		localDir, err := os.MkdirTemp("", "gitlock-cli-local-")
+		if err != nil {
+			t.Fatalf("Failed to create local directory: %v", err)
+		}
+		defer os.RemoveAll(localDir)
+
+		client := gittools.Client{}
+		if _, err := client.Clone(fmt.Sprintf("file://%s", remoteDir), localDir); err != nil {
+			t.Fatalf("Failed to clone remote repository: %v", err)
+		}
+
+		gitlock := func(args ...string) (int, string) {
+			var stdout, stderr bytes.Buffer
+			code := Run(context.Background(), "gitlock", args, &stdout, &stderr)
+			return code, stdout.String() + stderr.String()
+		}
+
+		lockPath := "locks/deploy.lock"
+		common := []string{"-repo", localDir, "-backend", "ref"}
+		withKey := func(command, key string, args ...string) []string {
+			all := append([]string{command}, common...)
+			all = append(all, "-key", key)
+			return append(all, args...)
+		}
+
+		if code, out := gitlock(withKey("acquire", "first", "-description", "deploy", lockPath)...); code != ExitOK {
+			t.Fatalf("Expected acquire to succeed, got %d: %s", code, out)
+		}
+		if code, out := gitlock(withKey("acquire", "second", lockPath)...); code != ExitConflict {
+			t.Fatalf("Expected acquire by another key to conflict, got %d: %s", code, out)
+		}
+		if code, out := gitlock(withKey("check", "second", lockPath)...); code != ExitConflict {
+			t.Fatalf("Expected check to report locked, got %d: %s", code, out)
+		}
+		if code, out := gitlock(withKey("refresh", "second", lockPath)...); code != ExitNotOwned {
+			t.Fatalf("Expected refresh by another key to fail, got %d: %s", code, out)
+		}
+		if code, out := gitlock(withKey("refresh", "first", lockPath)...); code != ExitOK {
+			t.Fatalf("Expected refresh to succeed, got %d: %s", code, out)
+		}
+
+		code, out := gitlock(withKey("list", "first", "-json", "locks")...)
+		if code != ExitOK {
+			t.Fatalf("Expected list to succeed, got %d: %s", code, out)
+		}
+		var infos []lock.LockInfo
+		if err := json.Unmarshal([]byte(out), &infos); err != nil {
+			t.Fatalf("Failed to parse list output %q: %v", out, err)
+		}
+		if len(infos) != 1 || infos[0].Path != lockPath || infos[0].Lock.Description != "deploy" {
+			t.Errorf("Unexpected list output: %+v", infos)
+		}
+
+		if code, out := gitlock(withKey("release", "second", lockPath)...); code != ExitNotOwned {
+			t.Fatalf("Expected release by another key to fail, got %d: %s", code, out)
+		}
+		if code, out := gitlock(withKey("release", "first", lockPath)...); code != ExitOK {
+			t.Fatalf("Expected release to succeed, got %d: %s", code, out)
+		}
+		if code, out := gitlock(withKey("check", "first", lockPath)...); code != ExitOK {
+			t.Fatalf("Expected check to report free, got %d: %s", code, out)
+		}
+		if code, out := gitlock(withKey("release", "first", lockPath)...); code != ExitNotOwned {
+			t.Fatalf("Expected release of a free lock to fail, got %d: %s", code, out)
+		}
+
+		if code, _ := gitlock("unknown"); code != ExitUsage {
+			t.Errorf("Expected usage error for unknown command, got %d", code)
+		}
+		if code, _ := gitlock("acquire", "-repo", localDir); code != ExitUsage {
+			t.Errorf("Expected usage error without lock path, got %d", code)
+		}
+	})
+}
+
+func TestHoldReleasesOnCancel(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		remoteDir, cleanup, err := gittools.CreateTestRemoteRepo("gitlock-hold")
+		if err != nil {
+			t.Fatalf("Failed to create remote repository: %v", err)
+		}
+		defer cleanup()
+
+		// The lock is held from one clone and checked from another
+		client := gittools.Client{}
+		var clones []string
+		for _, name := range []string{"holder", "checker"} {
+			dir, err := os.MkdirTemp("", "gitlock-hold-"+name+"-")
+			if err != nil {
+				t.Fatalf("Failed to create local directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			if _, err := client.Clone(fmt.Sprintf("file://%s", remoteDir), dir); err != nil {
+				t.Fatalf("Failed to clone remote repository: %v", err)
+			}
+			clones = append(clones, dir)
+		}
+
+		lockPath := "locks/hold.lock"
+		check := func() (int, string) {
+			var stdout, stderr bytes.Buffer
+			code := Run(context.Background(), "gitlock", []string{"check", "-repo", clones[1], "-key", "checker", lockPath}, &stdout, &stderr)
+			return code, stdout.String() + stderr.String()
+		}
+
+		// Cancellation also stops the keep-alive, so repeat to exercise both orders
+		for i := 0; i < 3; i++ {
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan int, 1)
+			var stdout, stderr bytes.Buffer
+			go func() {
+				done <- Run(ctx, "gitlock", []string{"hold", "-repo", clones[0], "-key", "holder", "-expiry", "3s", lockPath}, &stdout, &stderr)
+			}()
+
+			deadline := time.Now().Add(30 * time.Second)
+			for {
+				if code, _ := check(); code == ExitConflict {
+					break
+				}
+				if time.Now().After(deadline) {
+					cancel()
+					t.Fatalf("Timed out waiting for hold to acquire the lock")
+				}
+				time.Sleep(50 * time.Millisecond)
+			}
+
+			cancel()
+			if code := <-done; code != ExitOK {
+				t.Fatalf("Expected hold to exit %d after cancellation, got %d: %s", ExitOK, code, stdout.String()+stderr.String())
+			}
+			if code, out := check(); code != ExitOK {
+				t.Fatalf("Expected the lock to be released, got %d: %s", code, out)
+			}
+		}
+	})
+}
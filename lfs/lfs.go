@@ -0,0 +1,252 @@
+// Package lfs manages Git LFS content in a gittools.Repo by running git-lfs.
+//
+//	files := lfs.New(repo)
+//	if err := files.Pull(lfs.FetchOptions{}); err != nil {
+//		return err
+//	}
+//
+// Commands return ErrNotInstalled if git-lfs is not on the PATH. Pointer files can
+// be parsed without git-lfs, see ParsePointer.
+package lfs
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ocuroot/gittools"
+)
+
+// ErrNotInstalled is returned when git-lfs is not installed
+var ErrNotInstalled = errors.New("git-lfs is not installed")
+
+// LFS runs git-lfs commands in a repository
+type LFS struct {
+	repo *gittools.Repo
+}
+
+// New creates an LFS for the repository
+func New(repo *gittools.Repo) *LFS {
+	return &LFS{repo: repo}
+}
+
+// FetchOptions defines options for Fetch and Pull
+type FetchOptions struct {
+	// Remote to fetch objects from, the default remote if empty
+	Remote string
+
+	// Refs to fetch objects for, the current ref if empty. Only used by Fetch.
+	Refs []string
+
+	// Include limits the objects to files matching these patterns
+	Include []string
+
+	// Exclude skips the objects of files matching these patterns
+	Exclude []string
+}
+
+// File is a file stored in LFS, as listed by Files
+type File struct {
+	// OID is the SHA-256 of the file content
+	OID string
+
+	// Path is the path of the file relative to the repository root
+	Path string
+
+	// Downloaded is false if the working tree only has the pointer file, because
+	// the object has not been fetched or checked out
+	Downloaded bool
+}
+
+// exec runs git lfs with args, returning ErrNotInstalled if git-lfs is missing
+func (l *LFS) exec(args ...string) ([]byte, error) {
+	stdout, stderr, err := l.repo.Client.Exec(append([]string{"lfs"}, args...)...)
+	if err != nil {
+		if strings.Contains(string(stderr), "'lfs' is not a git command") {
+			return nil, ErrNotInstalled
+		}
+		return nil, fmt.Errorf("git lfs %s failed: %w\nstdout: %s\nstderr: %s",
+			args[0], err, stdout, stderr)
+	}
+	return stdout, nil
+}
+
+// Version returns the git-lfs version string, e.g. "git-lfs/3.4.0 (GitHub; linux amd64; go 1.21.1)"
+func (l *LFS) Version() (string, error) {
+	stdout, err := l.exec("version")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
+
+// Installed returns true if git-lfs is available
+func (l *LFS) Installed() bool {
+	_, err := l.Version()
+	return err == nil
+}
+
+// Install configures the LFS filters and hooks for the repository only
+func (l *LFS) Install() error {
+	_, err := l.exec("install", "--local")
+	return err
+}
+
+// Enabled returns true if the repository tracks any paths with LFS.
+// It reads .gitattributes and does not need git-lfs.
+func (l *LFS) Enabled() (bool, error) {
+	patterns, err := l.Tracked()
+	if err != nil {
+		return false, err
+	}
+	return len(patterns) > 0, nil
+}
+
+// Tracked returns the patterns in the repository's root .gitattributes that are
+// stored with LFS. It does not need git-lfs.
+func (l *LFS) Tracked() ([]string, error) {
+	f, err := os.Open(filepath.Join(l.repo.RepoPath, ".gitattributes"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .gitattributes: %w", err)
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for _, attr := range fields[1:] {
+			if attr == "filter=lfs" {
+				patterns = append(patterns, fields[0])
+				break
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read .gitattributes: %w", err)
+	}
+	return patterns, nil
+}
+
+// Track stores files matching the patterns with LFS by adding them to .gitattributes.
+// The .gitattributes change must be committed.
+func (l *LFS) Track(patterns ...string) error {
+	if len(patterns) == 0 {
+		return fmt.Errorf("no patterns to track")
+	}
+	_, err := l.exec(append([]string{"track", "--"}, patterns...)...)
+	return err
+}
+
+// Untrack removes the patterns from .gitattributes. Files already committed to LFS
+// remain in LFS.
+func (l *LFS) Untrack(patterns ...string) error {
+	if len(patterns) == 0 {
+		return fmt.Errorf("no patterns to untrack")
+	}
+	_, err := l.exec(append([]string{"untrack", "--"}, patterns...)...)
+	return err
+}
+
+// Fetch downloads LFS objects into the local LFS store without changing the working tree
+func (l *LFS) Fetch(options FetchOptions) error {
+	args := append([]string{"fetch"}, options.filterArgs()...)
+	if options.Remote != "" || len(options.Refs) > 0 {
+		remote := options.Remote
+		if remote == "" {
+			remote = "origin"
+		}
+		args = append(args, remote)
+		args = append(args, options.Refs...)
+	}
+	_, err := l.exec(args...)
+	return err
+}
+
+// Pull downloads the LFS objects for the current ref and replaces their pointer files
+// in the working tree
+func (l *LFS) Pull(options FetchOptions) error {
+	args := append([]string{"pull"}, options.filterArgs()...)
+	if options.Remote != "" {
+		args = append(args, options.Remote)
+	}
+	_, err := l.exec(args...)
+	return err
+}
+
+// Checkout replaces pointer files in the working tree with LFS objects already in the
+// local LFS store. All files are checked out if no paths are given.
+func (l *LFS) Checkout(paths ...string) error {
+	args := []string{"checkout"}
+	if len(paths) > 0 {
+		args = append(append(args, "--"), paths...)
+	}
+	_, err := l.exec(args...)
+	return err
+}
+
+// Files lists the LFS files at HEAD
+func (l *LFS) Files() ([]File, error) {
+	stdout, err := l.exec("ls-files", "--long")
+	if err != nil {
+		return nil, err
+	}
+	return parseLsFiles(string(stdout))
+}
+
+// Missing lists the LFS files whose working tree copy is still a pointer file
+func (l *LFS) Missing() ([]File, error) {
+	files, err := l.Files()
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []File
+	for _, file := range files {
+		if !file.Downloaded {
+			missing = append(missing, file)
+		}
+	}
+	return missing, nil
+}
+
+func (o FetchOptions) filterArgs() []string {
+	var args []string
+	if len(o.Include) > 0 {
+		args = append(args, "--include="+strings.Join(o.Include, ","))
+	}
+	if len(o.Exclude) > 0 {
+		args = append(args, "--exclude="+strings.Join(o.Exclude, ","))
+	}
+	return args
+}
+
+// parseLsFiles parses the output of git lfs ls-files --long, one file per line as
+// "<oid> <*|-> <path>" where * marks a downloaded file and - a pointer file
+func parseLsFiles(output string) ([]File, error) {
+	var files []File
+	for _, line := range strings.Split(output, "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 || (fields[1] != "*" && fields[1] != "-") {
+			return nil, fmt.Errorf("unexpected git lfs ls-files output: %q", line)
+		}
+		files = append(files, File{
+			OID:        fields[0],
+			Path:       fields[2],
+			Downloaded: fields[1] == "*",
+		})
+	}
+	return files, nil
+}
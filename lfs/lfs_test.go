@@ -0,0 +1,175 @@
+package lfs
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ocuroot/gittools"
+	"github.com/ocuroot/gittools/gittoolstest"
+)
+
+const testOID = "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393"
+
+func TestParsePointer(t *testing.T) {
+	content := "version https://git-lfs.github.com/spec/v1\noid sha256:" + testOID + "\nsize 12345\n"
+
+	pointer, err := ParsePointer([]byte(content))
+	if err != nil {
+		t.Fatalf("ParsePointer failed: %v", err)
+	}
+	if pointer.OID != testOID || pointer.Size != 12345 {
+		t.Errorf("Unexpected pointer %+v", pointer)
+	}
+	if pointer.String() != content {
+		t.Errorf("Expected %q, got %q", content, pointer.String())
+	}
+
+	for name, content := range map[string]string{
+		"file content": "hello world\n",
+		"no oid":       "version https://git-lfs.github.com/spec/v1\nsize 1\n",
+		"bad oid":      "version https://git-lfs.github.com/spec/v1\noid sha256:abc\nsize 1\n",
+		"bad size":     "version https://git-lfs.github.com/spec/v1\noid sha256:" + testOID + "\nsize big\n",
+		"too large":    content + strings.Repeat("x", maxPointerSize),
+	} {
+		if IsPointer([]byte(content)) {
+			t.Errorf("Expected %s not to be a pointer", name)
+		}
+	}
+}
+
+func TestParseLsFiles(t *testing.T) {
+	files, err := parseLsFiles(testOID + " * assets/logo.png\n" + testOID + " - data/model file.bin\n")
+	if err != nil {
+		t.Fatalf("parseLsFiles failed: %v", err)
+	}
+	expected := []File{
+		{OID: testOID, Path: "assets/logo.png", Downloaded: true},
+		{OID: testOID, Path: "data/model file.bin", Downloaded: false},
+	}
+	if len(files) != len(expected) {
+		t.Fatalf("Expected %d files, got %+v", len(expected), files)
+	}
+	for i := range expected {
+		if files[i] != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], files[i])
+		}
+	}
+
+	if _, err := parseLsFiles("garbage\n"); err == nil {
+		t.Error("Expected an error for unexpected output")
+	}
+}
+
+func TestTracked(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		repo := &gittools.Repo{RepoPath: tempDir, Client: &gittools.Client{}}
+		files := New(repo)
+
+		enabled, err := files.Enabled()
+		if err != nil || enabled {
+			t.Errorf("Expected LFS to be disabled without .gitattributes, got %v, %v", enabled, err)
+		}
+
+		attributes := "# comment\n*.png filter=lfs diff=lfs merge=lfs -text\n*.txt text\ndata/** filter=lfs diff=lfs merge=lfs -text\n"
+		if err := os.WriteFile(filepath.Join(tempDir, ".gitattributes"), []byte(attributes), 0644); err != nil {
+			t.Fatalf("Failed to write .gitattributes: %v", err)
+		}
+
+		patterns, err := files.Tracked()
+		if err != nil {
+			t.Fatalf("Tracked failed: %v", err)
+		}
+		if strings.Join(patterns, ",") != "*.png,data/**" {
+			t.Errorf("Expected *.png and data/**, got %v", patterns)
+		}
+		if enabled, _ := files.Enabled(); !enabled {
+			t.Error("Expected LFS to be enabled")
+		}
+	})
+}
+
+func TestNotInstalled(t *testing.T) {
+	executor := &gittoolstest.MockExecutor{}
+	executor.On("lfs", "pull").ReturnError("git: 'lfs' is not a git command. See 'git --help'.\n",
+		&gittools.RecordedExitError{Code: 1, Message: "exit status 1"})
+
+	files := New(&gittools.Repo{Client: &gittools.Client{Executor: executor}})
+	if err := files.Pull(FetchOptions{}); !errors.Is(err, ErrNotInstalled) {
+		t.Errorf("Expected ErrNotInstalled, got %v", err)
+	}
+}
+
+func TestFetchArgs(t *testing.T) {
+	executor := &gittoolstest.MockExecutor{}
+	executor.On("lfs", "fetch", "--include=*.png,*.jpg", "--exclude=data/**", "upstream", "main", "v1.0")
+	executor.On("lfs", "pull", "--include=*.png")
+
+	files := New(&gittools.Repo{Client: &gittools.Client{Executor: executor}})
+	if err := files.Fetch(FetchOptions{
+		Remote:  "upstream",
+		Refs:    []string{"main", "v1.0"},
+		Include: []string{"*.png", "*.jpg"},
+		Exclude: []string{"data/**"},
+	}); err != nil {
+		t.Errorf("Fetch failed: %v", err)
+	}
+	if err := files.Pull(FetchOptions{Include: []string{"*.png"}}); err != nil {
+		t.Errorf("Pull failed: %v", err)
+	}
+	executor.AssertExpectations(t)
+}
+
+func TestLFS(t *testing.T) {
+	if _, err := exec.LookPath("git-lfs"); err != nil {
+		t.Skip("git-lfs is not installed")
+	}
+
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		client := &gittools.Client{}
+		client.SetUser("Test User", "test@example.com")
+		repo, err := client.Init(filepath.Join(tempDir, "repo"), "main")
+		if err != nil {
+			t.Fatalf("Failed to init repository: %v", err)
+		}
+
+		files := New(repo)
+		if err := files.Install(); err != nil {
+			t.Fatalf("Install failed: %v", err)
+		}
+		if err := files.Track("*.bin"); err != nil {
+			t.Fatalf("Track failed: %v", err)
+		}
+
+		path := filepath.Join(repo.RepoPath, "data.bin")
+		if err := os.WriteFile(path, []byte("large content"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if err := repo.Commit("Add data", []string{filepath.Join(repo.RepoPath, ".gitattributes"), path}); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+
+		lfsFiles, err := files.Files()
+		if err != nil {
+			t.Fatalf("Files failed: %v", err)
+		}
+		if len(lfsFiles) != 1 || lfsFiles[0].Path != "data.bin" || !lfsFiles[0].Downloaded {
+			t.Errorf("Expected downloaded data.bin, got %+v", lfsFiles)
+		}
+
+		missing, err := files.Missing()
+		if err != nil || len(missing) != 0 {
+			t.Errorf("Expected no missing files, got %+v, %v", missing, err)
+		}
+
+		if err := files.Untrack("*.bin"); err != nil {
+			t.Fatalf("Untrack failed: %v", err)
+		}
+		if enabled, _ := files.Enabled(); enabled {
+			t.Error("Expected LFS to be disabled after untracking")
+		}
+	})
+}
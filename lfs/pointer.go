@@ -0,0 +1,78 @@
+package lfs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pointerVersion is the first line of every LFS pointer file
+const pointerVersion = "version https://git-lfs.github.com/spec/v1"
+
+// maxPointerSize is the largest file git-lfs treats as a pointer
+const maxPointerSize = 1024
+
+// Pointer is the content of an LFS pointer file, which git stores in place of a
+// large file
+type Pointer struct {
+	// OID is the SHA-256 of the file content, without the "sha256:" prefix
+	OID string
+
+	// Size is the size of the file content in bytes
+	Size int64
+}
+
+// IsPointer returns true if content is an LFS pointer rather than file content,
+// such as a file checked out without the LFS object being downloaded
+func IsPointer(content []byte) bool {
+	_, err := ParsePointer(content)
+	return err == nil
+}
+
+// ParsePointer parses the content of an LFS pointer file
+func ParsePointer(content []byte) (*Pointer, error) {
+	if len(content) > maxPointerSize {
+		return nil, fmt.Errorf("not an LFS pointer: %d bytes is too large", len(content))
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	if !scanner.Scan() || scanner.Text() != pointerVersion {
+		return nil, fmt.Errorf("not an LFS pointer: missing version line")
+	}
+
+	var pointer Pointer
+	var hasSize bool
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), " ")
+		if !found {
+			return nil, fmt.Errorf("invalid LFS pointer line %q", scanner.Text())
+		}
+		switch key {
+		case "oid":
+			oid := strings.TrimPrefix(value, "sha256:")
+			if !strings.HasPrefix(value, "sha256:") || len(oid) != 64 {
+				return nil, fmt.Errorf("invalid LFS pointer oid %q", value)
+			}
+			pointer.OID = oid
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size < 0 {
+				return nil, fmt.Errorf("invalid LFS pointer size %q", value)
+			}
+			pointer.Size = size
+			hasSize = true
+		}
+	}
+	if pointer.OID == "" || !hasSize {
+		return nil, fmt.Errorf("not an LFS pointer: missing oid or size")
+	}
+
+	return &pointer, nil
+}
+
+// String returns the pointer file content for p
+func (p *Pointer) String() string {
+	return fmt.Sprintf("%s\noid sha256:%s\nsize %d\n", pointerVersion, p.OID, p.Size)
+}
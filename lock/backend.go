@@ -0,0 +1,100 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Backend stores lock state on behalf of a Locking instance.
+// Ownership and expiry rules are applied by Locking, a Backend is only
+// responsible for reading locks and replacing them atomically.
+type Backend interface {
+	// Read returns the lock stored at lockPath, or nil if no lock is stored.
+	// Expired locks are returned as-is.
+	Read(lockPath string) (*Lock, error)
+
+	// Update reads the lock currently stored at lockPath, passes it to fn and
+	// stores the lock returned by fn. Returning a nil lock removes the stored lock.
+	// If fn returns an error nothing is written and that error is returned.
+	// If the stored lock is changed concurrently, Update returns an error
+	// wrapping ErrLockConflict.
+	Update(lockPath string, message string, fn func(current *Lock) (*Lock, error)) error
+}
+
+// Compactor is implemented by backends that can discard the history of lock updates
+type Compactor interface {
+	// Compact replaces the stored history of lock updates with the current state
+	Compact() error
+}
+
+// MultiUpdater is implemented by backends that can update several locks in a single
+// atomic change, so that either all of them are written or none are
+type MultiUpdater interface {
+	// UpdateAll reads the locks currently stored at lockPaths and passes them to fn,
+	// keyed by path with nil for paths where no lock is stored. The locks returned by fn
+	// are stored together, a nil lock removing the stored lock. Paths missing from the
+	// returned map are left as they are.
+	// If fn returns an error nothing is written and that error is returned.
+	// If any of the stored locks is changed concurrently, UpdateAll returns an error
+	// wrapping ErrLockConflict and nothing is written.
+	UpdateAll(lockPaths []string, message string, fn func(current map[string]*Lock) (map[string]*Lock, error)) error
+}
+
+// contextBackend is implemented by backends whose git commands can be bound to a context
+type contextBackend interface {
+	// withContext returns a copy of the backend whose git commands are killed when ctx is done
+	withContext(ctx context.Context) Backend
+}
+
+// withContext returns a copy of backend whose git commands are killed when ctx is done,
+// or backend itself if it doesn't support contexts
+func withContext(ctx context.Context, backend Backend) Backend {
+	if b, ok := backend.(contextBackend); ok {
+		return b.withContext(ctx)
+	}
+	return backend
+}
+
+// updateOne adapts fn, which updates the lock at lockPath, to a function for UpdateAll
+func updateOne(lockPath string, fn func(current *Lock) (*Lock, error)) func(current map[string]*Lock) (map[string]*Lock, error) {
+	return func(current map[string]*Lock) (map[string]*Lock, error) {
+		next, err := fn(current[lockPath])
+		if err != nil {
+			return nil, err
+		}
+		return map[string]*Lock{lockPath: next}, nil
+	}
+}
+
+// readLocks reads the lock at each of lockPaths with read
+func readLocks(lockPaths []string, read func(lockPath string) (*Lock, error)) (map[string]*Lock, error) {
+	locks := make(map[string]*Lock, len(lockPaths))
+	for _, lockPath := range lockPaths {
+		lock, err := read(lockPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check lock status of %s: %w", lockPath, err)
+		}
+		locks[lockPath] = lock
+	}
+	return locks, nil
+}
+
+// changedLocks returns the sorted paths in next that add, replace or remove a lock in current.
+// Removing a lock that isn't stored is not a change.
+func changedLocks(current map[string]*Lock, next map[string]*Lock) ([]string, error) {
+	var changed []string
+	for lockPath, lock := range next {
+		existing, read := current[lockPath]
+		if !read {
+			return nil, fmt.Errorf("cannot update lock %s that was not read", lockPath)
+		}
+		if lock == nil && existing == nil {
+			// Nothing to remove
+			continue
+		}
+		changed = append(changed, lockPath)
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
@@ -0,0 +1,66 @@
+package lock
+
+import (
+	"fmt"
+
+	"github.com/ocuroot/gittools"
+)
+
+// updateBranch applies fn to the lock files on the lock branch without a working tree,
+// as is needed in a bare repository. The commit is written directly to the object database,
+// then pushed to origin with --force-with-lease against the tip it was based on, or moved
+// onto the local branch with a compare-and-swap if updates are not pushed.
+// If the branch moves concurrently, the update is retried against the new tip.
+func (b *fileBackend) updateBranch(lockFilePaths []string, message string, fn func(current map[string]*Lock) (map[string]*Lock, error), observe func(name MetricName)) error {
+	branch, err := b.lockBranch()
+	if err != nil {
+		return err
+	}
+
+	ref := "refs/heads/" + branch
+	var push bool
+	update := lockBranchUpdate{
+		repo:  b.repo,
+		codec: b.codec,
+		tip: func() (string, error) {
+			tip, shouldPush, err := b.lockBranchTip(branch)
+			push = shouldPush
+			return tip, err
+		},
+		publish: func(tip, commit string) error {
+			if push {
+				if err := pushLockCommit(b.repo, ref, tip, commit); err != nil {
+					return fmt.Errorf("failed to push lock commit: %w", err)
+				}
+				return nil
+			}
+
+			expected := tip
+			if expected == "" {
+				expected = gittools.NullHash
+			}
+			if err := b.repo.UpdateRef(ref, commit, expected); err != nil {
+				return fmt.Errorf("failed to update %s: %w", branch, err)
+			}
+			return nil
+		},
+	}
+	return update.run(lockFilePaths, message, fn, observe)
+}
+
+// lockBranch returns the branch lock files are committed to when they are not written
+// to the working tree: the configured lock branch, or else the current branch
+func (b *fileBackend) lockBranch() (string, error) {
+	if b.branch != "" {
+		return b.branch, nil
+	}
+
+	branch, err := b.repo.CurrentBranch()
+	if err != nil {
+		return "", fmt.Errorf("failed to get current branch: %w", err)
+	}
+	if branch == "HEAD" {
+		return "", fmt.Errorf("cannot commit lock files in a repository with a detached HEAD and no working tree")
+	}
+	return branch, nil
+}
@@ -0,0 +1,99 @@
+package lock
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestBareRepoLocking(t *testing.T) {
+	for _, mode := range []string{"--bare", "--mirror"} {
+		t.Run(mode, func(t *testing.T) {
+			gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+				_, remoteDir, cleanup := setupRemoteTestRepo(t)
+				defer cleanup()
+
+				bareDir := filepath.Join(tempDir, "bare.git")
+				if out, err := gittools.GitExec(t, tempDir, 10, "clone", mode, remoteDir, bareDir); err != nil {
+					t.Fatalf("Failed to clone: %v\n%s", err, out)
+				}
+				bare, err := gittools.Open(bareDir)
+				if err != nil {
+					t.Fatalf("Failed to open bare clone: %v", err)
+				}
+				bare.Client.SetUser("Lock Agent", "agent@example.com")
+				if !bare.Bare {
+					t.Fatal("Expected a bare repository")
+				}
+
+				workRepo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+				defer cleanupRepo()
+
+				locking := NewRepoLocking(bare)
+				other := NewRepoLocking(workRepo)
+
+				lockPath := "locks/bare.lock"
+				if err := locking.AcquireLock(lockPath, time.Minute, "bare"); err != nil {
+					t.Fatalf("Failed to acquire lock from a bare clone: %v", err)
+				}
+				if lock, err := locking.ReadLock(lockPath); err != nil || lock == nil || lock.Owner != locking.LockKey {
+					t.Errorf("Expected to read the lock back, got %+v, %v", lock, err)
+				}
+
+				// Working tree clones see the lock file on the same branch
+				if err := other.AcquireLock(lockPath, time.Minute, "other"); !errors.Is(err, ErrLockConflict) {
+					t.Errorf("Expected ErrLockConflict, got %v", err)
+				}
+				if err := other.AcquireLock("locks/other.lock", time.Minute, "other"); err != nil {
+					t.Fatalf("Failed to acquire lock from a working tree clone: %v", err)
+				}
+
+				// The bare clone updates on top of changes pushed by others
+				if err := locking.ReleaseLock(lockPath); err != nil {
+					t.Fatalf("Failed to release lock: %v", err)
+				}
+				if lock, err := locking.ReadLock("locks/other.lock"); err != nil || lock == nil {
+					t.Errorf("Expected the other lock to survive the release, got %+v, %v", lock, err)
+				}
+				if err := other.AcquireLock(lockPath, time.Minute, "other"); err != nil {
+					t.Errorf("Expected the released lock to be free, got %v", err)
+				}
+			})
+		})
+	}
+}
+
+func TestBareRepoLockingWithoutRemote(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		// Lock directly in the shared repository
+		shared, err := gittools.Open(remoteDir)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		shared.Client.SetUser("Lock Agent", "agent@example.com")
+
+		workRepo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+
+		locking := NewRepoLocking(shared)
+		lockPath := "locks/shared.lock"
+		if err := locking.AcquireLock(lockPath, time.Minute, "shared"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+		if err := NewRepoLocking(shared).AcquireLock(lockPath, time.Minute, "other"); !errors.Is(err, ErrLockConflict) {
+			t.Errorf("Expected ErrLockConflict, got %v", err)
+		}
+		if err := NewRepoLocking(workRepo).AcquireLock(lockPath, time.Minute, "clone"); !errors.Is(err, ErrLockConflict) {
+			t.Errorf("Expected ErrLockConflict from a clone, got %v", err)
+		}
+		if err := locking.ReleaseLock(lockPath); err != nil {
+			t.Fatalf("Failed to release lock: %v", err)
+		}
+	})
+}
@@ -0,0 +1,360 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/ocuroot/gittools"
+)
+
+// DefaultLockBranch is the branch used by NewBranchBackend when none is specified
+const DefaultLockBranch = "locks"
+
+// branchUpdateAttempts is how many times an update is retried when another
+// lock on the same branch changes concurrently
+const branchUpdateAttempts = 3
+
+// NewBranchBackend creates a Backend that commits lock files to a dedicated branch
+// on the origin remote rather than the caller's current branch.
+// The branch is created on first use. Commits are built directly in the object
+// database, so the caller's working tree, index and checked out branch are never touched,
+// and each update is pushed with --force-with-lease against the branch tip it was based on.
+func NewBranchBackend(repo *gittools.Repo, branch string) *BranchBackend {
+	if branch == "" {
+		branch = DefaultLockBranch
+	}
+	return &BranchBackend{repo: repo, branch: branch}
+}
+
+// BranchBackend stores locks on a dedicated branch, see NewBranchBackend
+type BranchBackend struct {
+	repo   *gittools.Repo
+	branch string
+
+	// Squash replaces the branch tip on every update instead of adding a commit,
+	// so the lock branch only ever contains a single commit with the current lock state
+	Squash bool
+
+	// Codec serializes lock files. If nil, JSONCodec is used.
+	Codec Codec
+}
+
+func (b *BranchBackend) withContext(ctx context.Context) Backend {
+	bound := *b
+	bound.repo = b.repo.WithContext(ctx)
+	return &bound
+}
+
+func (b *BranchBackend) remoteRef() string {
+	return "refs/heads/" + b.branch
+}
+
+func (b *BranchBackend) trackingRef() string {
+	return "refs/remotes/origin/" + b.branch
+}
+
+// Read reads the lock file from the tip of the lock branch on the remote
+func (b *BranchBackend) Read(lockFilePath string) (*Lock, error) {
+	tip, err := b.fetch()
+	if err != nil {
+		return nil, err
+	}
+	return b.readAt(tip, lockFilePath)
+}
+
+// Update applies fn to the lock file on the lock branch and pushes a new commit.
+// If another update lands on the branch first, the update is retried against the new tip.
+func (b *BranchBackend) Update(lockFilePath string, message string, fn func(current *Lock) (*Lock, error)) error {
+	return b.updateAllObserved([]string{lockFilePath}, message, updateOne(lockFilePath, fn), nil)
+}
+
+// UpdateAll applies fn to the lock files on the lock branch and pushes a single new commit,
+// retrying as Update does
+func (b *BranchBackend) UpdateAll(lockFilePaths []string, message string, fn func(current map[string]*Lock) (map[string]*Lock, error)) error {
+	return b.updateAllObserved(lockFilePaths, message, fn, nil)
+}
+
+func (b *BranchBackend) updateAllObserved(lockFilePaths []string, message string, fn func(current map[string]*Lock) (map[string]*Lock, error), observe func(name MetricName)) error {
+	update := lockBranchUpdate{
+		repo:   b.repo,
+		codec:  codecOrDefault(b.Codec),
+		squash: b.Squash,
+		tip:    b.fetch,
+		publish: func(tip, commit string) error {
+			if err := pushLockCommit(b.repo, b.remoteRef(), tip, commit); err != nil {
+				return fmt.Errorf("failed to push lock branch: %w", err)
+			}
+			return nil
+		},
+	}
+	return update.run(lockFilePaths, message, fn, observe)
+}
+
+// lockBranchUpdate updates lock files on a lock branch by writing commits directly to
+// the object database, without a working tree. It is shared by BranchBackend and the
+// file backend when it commits to a lock branch.
+type lockBranchUpdate struct {
+	repo   *gittools.Repo
+	codec  Codec
+	squash bool
+
+	// tip returns the current tip of the lock branch, or an empty string if it
+	// doesn't exist yet
+	tip func() (string, error)
+
+	// publish moves the lock branch from tip to commit. It must fail with
+	// ErrPushStaleInfo or ErrRefMismatch if the branch no longer points at tip.
+	publish func(tip, commit string) error
+}
+
+// run applies fn to the lock files and publishes a commit with the changes. If the
+// branch moves concurrently, the update is retried against the new tip.
+func (u lockBranchUpdate) run(lockFilePaths []string, message string, fn func(current map[string]*Lock) (map[string]*Lock, error), observe func(name MetricName)) error {
+	var lastErr error
+	for attempt := 0; attempt < branchUpdateAttempts; attempt++ {
+		if attempt > 0 && observe != nil {
+			observe(MetricPushRetries)
+		}
+		lastErr = u.try(lockFilePaths, message, fn)
+		if lastErr == nil || !branchMoved(lastErr) {
+			break
+		}
+	}
+
+	if branchMoved(lastErr) {
+		return fmt.Errorf("%w: %v", ErrLockConflict, lastErr)
+	}
+	if errors.Is(lastErr, gittools.ErrPushRejected) {
+		return lockPushError(lastErr)
+	}
+	return lastErr
+}
+
+func (u lockBranchUpdate) try(lockFilePaths []string, message string, fn func(current map[string]*Lock) (map[string]*Lock, error)) error {
+	tip, err := u.tip()
+	if err != nil {
+		return err
+	}
+
+	current, err := readLocks(lockFilePaths, func(lockFilePath string) (*Lock, error) {
+		return readLockAt(u.repo, u.codec, tip, lockFilePath)
+	})
+	if err != nil {
+		return err
+	}
+
+	next, err := fn(current)
+	if err != nil {
+		return err
+	}
+	changed, err := changedLocks(current, next)
+	if err != nil || len(changed) == 0 {
+		return err
+	}
+
+	commit, err := writeLockCommit(u.repo, u.codec, tip, changed, next, message, u.squash)
+	if err != nil {
+		return err
+	}
+	return u.publish(tip, commit)
+}
+
+// branchMoved returns true if err reports that a lock branch moved while it was being updated
+func branchMoved(err error) bool {
+	return errors.Is(err, gittools.ErrPushStaleInfo) || errors.Is(err, gittools.ErrRefMismatch)
+}
+
+// pushLockCommit pushes commit to ref on origin with --force-with-lease, so the push
+// fails with ErrPushStaleInfo if ref has moved from tip
+func pushLockCommit(repo *gittools.Repo, ref string, tip string, commit string) error {
+	return repo.PushWithOptions("origin", gittools.PushOptions{
+		Refspecs:       []string{commit + ":" + ref},
+		Atomic:         true,
+		ForceWithLease: []gittools.Lease{{Ref: ref, Expected: tip}},
+		IgnoreMirror:   true,
+	})
+}
+
+// Compact replaces the history of the lock branch with a single commit holding
+// the current state of all locks. Lock churn otherwise grows the branch history
+// with every acquire, refresh and release.
+// Compaction is a compare-and-swap against the branch tip, if a lock is updated
+// concurrently ErrLockConflict is returned and compaction can be retried.
+func (b *BranchBackend) Compact() error {
+	tip, err := b.fetch()
+	if err != nil {
+		return err
+	}
+	if tip == "" {
+		return nil
+	}
+
+	commit, err := b.repo.CommitTree(tip+"^{tree}", "Compact lock history")
+	if err != nil {
+		return fmt.Errorf("failed to write compacted commit: %w", err)
+	}
+
+	if err := pushLockCommit(b.repo, b.remoteRef(), tip, commit); err != nil {
+		if errors.Is(err, gittools.ErrPushStaleInfo) || errors.Is(err, gittools.ErrPushRejected) {
+			return fmt.Errorf("%w: %v", ErrLockConflict, err)
+		}
+		return fmt.Errorf("failed to push compacted lock branch: %w", err)
+	}
+
+	return nil
+}
+
+// fetch fetches the lock branch and returns its tip, or an empty string if it does not exist yet
+func (b *BranchBackend) fetch() (string, error) {
+	refs, err := b.repo.LsRemote("origin", b.remoteRef())
+	if err != nil {
+		return "", fmt.Errorf("failed to list remote lock branch: %w", err)
+	}
+	if _, exists := refs[b.remoteRef()]; !exists {
+		return "", nil
+	}
+
+	if err := b.repo.Fetch("origin", gittools.FetchOptions{Refspecs: []string{"+" + b.remoteRef() + ":" + b.trackingRef()}}); err != nil {
+		return "", fmt.Errorf("failed to fetch lock branch: %w", err)
+	}
+
+	tip, err := b.repo.RevParse("--verify", b.trackingRef())
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve lock branch: %w", err)
+	}
+	return tip, nil
+}
+
+// writeLockCommit writes a commit replacing the lock files at lockFilePaths in tip with
+// the locks in next encoded by codec, removing those that are nil, and returns its hash.
+// The commit's parent is tip unless squash is set or tip is empty.
+func writeLockCommit(repo *gittools.Repo, codec Codec, tip string, lockFilePaths []string, next map[string]*Lock, message string, squash bool) (string, error) {
+	tree := ""
+	var parents []string
+	if tip != "" {
+		tree = tip + "^{tree}"
+		if !squash {
+			parents = append(parents, tip)
+		}
+	}
+
+	var err error
+	for _, lockFilePath := range lockFilePaths {
+		var blob string
+		if lock := next[lockFilePath]; lock != nil {
+			lockContent, err := codec.Marshal(lock)
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal lock: %w", err)
+			}
+			blob, err = repo.HashObject(lockContent)
+			if err != nil {
+				return "", fmt.Errorf("failed to write lock blob: %w", err)
+			}
+		}
+
+		tree, err = writeTreePath(repo, tree, splitLockPath(lockFilePath), blob)
+		if err != nil {
+			return "", fmt.Errorf("failed to write lock tree: %w", err)
+		}
+	}
+	if tree == "" {
+		if tree, err = repo.MkTree(nil); err != nil {
+			return "", fmt.Errorf("failed to write lock tree: %w", err)
+		}
+	}
+
+	commit, err := repo.CommitTree(tree, message, parents...)
+	if err != nil {
+		return "", fmt.Errorf("failed to write lock commit: %w", err)
+	}
+	return commit, nil
+}
+
+// readAt reads the lock file from the given commit
+func (b *BranchBackend) readAt(commit string, lockFilePath string) (*Lock, error) {
+	return readLockAt(b.repo, codecOrDefault(b.Codec), commit, lockFilePath)
+}
+
+// readLockAt reads a lock file encoded by codec from the given commit, returning nil if it
+// does not exist there
+func readLockAt(repo *gittools.Repo, codec Codec, commit string, lockFilePath string) (*Lock, error) {
+	if commit == "" {
+		return nil, nil
+	}
+
+	object := commit + ":" + strings.Join(splitLockPath(lockFilePath), "/")
+	exists, _, err := repo.CatFile(gittools.CatFileOptions{Exists: true, ObjectID: object})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check lock file: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	_, content, err := repo.CatFile(gittools.CatFileOptions{ShowContent: true, ObjectID: object})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file: %w", err)
+	}
+
+	lock, err := codec.Unmarshal([]byte(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse lock file: %w", err)
+	}
+	return lock, nil
+}
+
+// splitLockPath splits a lock path into its components relative to the repository root
+func splitLockPath(lockFilePath string) []string {
+	return strings.Split(path.Clean(strings.TrimPrefix(lockFilePath, "/")), "/")
+}
+
+// writeTreePath writes a copy of baseTree with the file at parts replaced by blob,
+// or removed if blob is empty. Directories left empty are removed.
+// Returns an empty string if the resulting tree has no entries.
+func writeTreePath(repo *gittools.Repo, baseTree string, parts []string, blob string) (string, error) {
+	var entries []gittools.TreeEntry
+	if baseTree != "" {
+		var err error
+		entries, err = repo.LsTree(baseTree)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	name := parts[0]
+	var existing *gittools.TreeEntry
+	var kept []gittools.TreeEntry
+	for i := range entries {
+		if entries[i].Name == name {
+			existing = &entries[i]
+			continue
+		}
+		kept = append(kept, entries[i])
+	}
+
+	if len(parts) == 1 {
+		if blob != "" {
+			kept = append(kept, gittools.TreeEntry{Mode: "100644", Type: "blob", Object: blob, Name: name})
+		}
+	} else {
+		subTree := ""
+		if existing != nil && existing.Type == "tree" {
+			subTree = existing.Object
+		}
+		child, err := writeTreePath(repo, subTree, parts[1:], blob)
+		if err != nil {
+			return "", err
+		}
+		if child != "" {
+			kept = append(kept, gittools.TreeEntry{Mode: "040000", Type: "tree", Object: child, Name: name})
+		}
+	}
+
+	if len(kept) == 0 {
+		return "", nil
+	}
+	return repo.MkTree(kept)
+}
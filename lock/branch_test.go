@@ -0,0 +1,142 @@
+package lock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestBranchBackend(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo1, cleanup1 := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanup1()
+		repo2, cleanup2 := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanup2()
+
+		locking1 := NewRepoLocking(repo1)
+		locking1.Backend = NewBranchBackend(repo1, "")
+		locking2 := NewRepoLocking(repo2)
+		locking2.Backend = NewBranchBackend(repo2, "")
+
+		mainBefore, err := repo1.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to resolve HEAD: %v", err)
+		}
+
+		if err := locking1.AcquireLock("locks/env/prod.lock", time.Minute, "deploy prod"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+		if err := locking2.AcquireLock("locks/env/staging.lock", time.Minute, "deploy staging"); err != nil {
+			t.Fatalf("Failed to acquire independent lock on the same branch: %v", err)
+		}
+		if err := locking2.AcquireLock("locks/env/prod.lock", time.Minute, "deploy prod"); !errors.Is(err, ErrLockConflict) {
+			t.Fatalf("Expected ErrLockConflict, got %v", err)
+		}
+
+		if err := locking1.ReleaseLock("locks/env/prod.lock"); err != nil {
+			t.Fatalf("Failed to release lock: %v", err)
+		}
+		lock, err := locking2.ReadLock("locks/env/prod.lock")
+		if err != nil {
+			t.Fatalf("Failed to read lock: %v", err)
+		}
+		if lock != nil {
+			t.Fatalf("Expected lock to be released, got %+v", lock)
+		}
+
+		staging, err := locking1.ReadLock("locks/env/staging.lock")
+		if err != nil {
+			t.Fatalf("Failed to read lock: %v", err)
+		}
+		if staging == nil || staging.Owner != locking2.LockKey {
+			t.Fatalf("Expected staging lock to be preserved, got %+v", staging)
+		}
+
+		// The caller's branch and working tree are untouched
+		mainAfter, err := repo1.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to resolve HEAD: %v", err)
+		}
+		if mainAfter != mainBefore {
+			t.Errorf("Expected HEAD to remain %s, got %s", mainBefore, mainAfter)
+		}
+		if _, err := os.Stat(filepath.Join(repo1.RepoPath, "locks")); !os.IsNotExist(err) {
+			t.Errorf("Expected no lock files in the working tree, got %v", err)
+		}
+
+		count, err := repo1.CountCommits("refs/remotes/origin/" + DefaultLockBranch)
+		if err != nil {
+			t.Fatalf("Failed to count lock branch commits: %v", err)
+		}
+		if count != 3 {
+			t.Errorf("Expected 3 commits on the lock branch, got %d", count)
+		}
+	})
+}
+
+func TestBranchBackendCompaction(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+
+		locking := NewRepoLocking(repo)
+		locking.Backend = NewBranchBackend(repo, "locks")
+
+		for i := 0; i < 3; i++ {
+			if err := locking.AcquireLock("locks/a.lock", time.Minute, "churn"); err != nil {
+				t.Fatalf("Failed to acquire lock: %v", err)
+			}
+		}
+		if err := locking.AcquireLock("locks/b.lock", time.Minute, "kept"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+
+		if err := locking.Compact(); err != nil {
+			t.Fatalf("Failed to compact lock history: %v", err)
+		}
+		if _, err := locking.Backend.Read("locks/a.lock"); err != nil {
+			t.Fatalf("Failed to read lock after compaction: %v", err)
+		}
+
+		count, err := repo.CountCommits("refs/remotes/origin/locks")
+		if err != nil {
+			t.Fatalf("Failed to count commits: %v", err)
+		}
+		if count != 1 {
+			t.Errorf("Expected 1 commit after compaction, got %d", count)
+		}
+
+		for _, path := range []string{"locks/a.lock", "locks/b.lock"} {
+			lock, err := locking.ReadLock(path)
+			if err != nil || lock == nil {
+				t.Fatalf("Expected %s to survive compaction, got %+v, %v", path, lock, err)
+			}
+		}
+
+		// Squash mode keeps the branch at a single commit on every update
+		locking.Backend.(*BranchBackend).Squash = true
+		if err := locking.ReleaseLock("locks/a.lock"); err != nil {
+			t.Fatalf("Failed to release lock: %v", err)
+		}
+		if _, err := locking.Backend.Read("locks/a.lock"); err != nil {
+			t.Fatalf("Failed to read lock: %v", err)
+		}
+		count, err = repo.CountCommits("refs/remotes/origin/locks")
+		if err != nil {
+			t.Fatalf("Failed to count commits: %v", err)
+		}
+		if count != 1 {
+			t.Errorf("Expected 1 commit in squash mode, got %d", count)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package lock
+
+import (
+	"fmt"
+	"time"
+)
+
+// BreakOptions controls how a lock held by another process is broken
+type BreakOptions struct {
+	// OnlyIfExpired refuses to break a lock that is still valid, returning ErrLockNotExpired
+	OnlyIfExpired bool
+
+	// Reason is recorded in the commit message for auditing
+	Reason string
+}
+
+// BreakLock removes the lock at lockFilePath regardless of its owner.
+// Unlike ReadLock, expired locks are not treated as absent, so this also cleans up
+// stale lock files left behind by crashed processes.
+func (g *Locking) BreakLock(lockFilePath string, options BreakOptions) error {
+	err := g.update(g.Backend, lockFilePath, breakMessage("Break", lockFilePath, options), func(current *Lock) (*Lock, error) {
+		if err := g.checkBreakable(current, options); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to break lock: %w", err)
+	}
+
+	return nil
+}
+
+// Steal breaks the lock at lockFilePath and acquires it for this process in a single update,
+// so no other process can acquire the lock in between.
+func (g *Locking) Steal(lockFilePath string, expiryDuration time.Duration, description string, options BreakOptions) error {
+	start := time.Now()
+	var acquired *Lock
+	err := g.update(g.Backend, lockFilePath, breakMessage("Steal", lockFilePath, options), func(current *Lock) (*Lock, error) {
+		if err := g.checkBreakable(current, options); err != nil {
+			return nil, err
+		}
+		acquired = g.newLock(expiryDuration, description)
+		return acquired, nil
+	})
+	if err != nil {
+		g.observeWait(lockFilePath, start)
+		return fmt.Errorf("failed to steal lock: %w", err)
+	}
+
+	g.acquired(lockFilePath, acquired, start)
+	return nil
+}
+
+func (g *Locking) checkBreakable(current *Lock, options BreakOptions) error {
+	if options.OnlyIfExpired && g.unexpired(current) != nil {
+		return ErrLockNotExpired
+	}
+	return nil
+}
+
+// breakMessage builds the audit commit message for breaking a lock
+func breakMessage(action string, lockFilePath string, options BreakOptions) string {
+	message := fmt.Sprintf("%s lock on %s", action, lockFilePath)
+	if options.Reason != "" {
+		message += "\n\nReason: " + options.Reason
+	}
+	return message
+}
@@ -0,0 +1,70 @@
+package lock
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestBreakAndStealLock(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		localDir, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo1, err := gittools.Open(localDir)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		repo2, cleanup2 := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanup2()
+
+		holder := NewRepoLocking(repo1)
+		admin := NewRepoLocking(repo2)
+
+		lockPath := "locks/stuck.lock"
+		if err := holder.AcquireLock(lockPath, time.Minute, "stuck job"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+
+		err = admin.BreakLock(lockPath, BreakOptions{OnlyIfExpired: true, Reason: "cleanup"})
+		if !errors.Is(err, ErrLockNotExpired) {
+			t.Fatalf("Expected ErrLockNotExpired, got %v", err)
+		}
+
+		if err := admin.Steal(lockPath, time.Minute, "taking over", BreakOptions{Reason: "job is stuck"}); err != nil {
+			t.Fatalf("Failed to steal lock: %v", err)
+		}
+
+		lock, err := admin.ReadLock(lockPath)
+		if err != nil {
+			t.Fatalf("Failed to read lock: %v", err)
+		}
+		if owns, _ := admin.OwnsLock(lock); !owns {
+			t.Fatalf("Expected admin to own the stolen lock, got %+v", lock)
+		}
+
+		log, err := repo2.Log(gittools.LogOptions{Commit1: "refs/remotes/origin/" + DefaultLockBranch})
+		if err != nil {
+			t.Fatalf("Failed to read log: %v", err)
+		}
+		if log[0].Message != "Steal lock on locks/stuck.lock\nReason: job is stuck" {
+			t.Errorf("Unexpected audit message: %q", log[0].Message)
+		}
+
+		// Break an expired lock left behind by the admin
+		admin.now = func() time.Time { return time.Now().Add(time.Hour) }
+		if err := admin.BreakLock(lockPath, BreakOptions{OnlyIfExpired: true, Reason: "expired"}); err != nil {
+			t.Fatalf("Failed to break expired lock: %v", err)
+		}
+
+		raw, err := admin.Backend.Read(lockPath)
+		if err != nil {
+			t.Fatalf("Failed to read raw lock: %v", err)
+		}
+		if raw != nil {
+			t.Fatalf("Expected lock file to be removed, got %+v", raw)
+		}
+	})
+}
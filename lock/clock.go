@@ -0,0 +1,11 @@
+package lock
+
+import "time"
+
+// expired returns true if expiresAt has passed, allowing for ClockSkew.
+// offset is the ClockOffset recorded by the holder that set expiresAt, which is used
+// along with this process's ClockOffset to compare both timestamps in true time.
+func (g *Locking) expired(expiresAt time.Time, offset time.Duration) bool {
+	now := g.now().Add(-g.ClockOffset)
+	return now.After(expiresAt.Add(-offset).Add(g.ClockSkew))
+}
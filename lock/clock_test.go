@@ -0,0 +1,68 @@
+package lock
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestClockSkew(t *testing.T) {
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	locking := &Locking{LockKey: "a", now: func() time.Time { return base }}
+
+	lock := &Lock{Owner: "b", ExpiresAt: base.Add(-10 * time.Second)}
+	if locking.unexpired(lock) != nil {
+		t.Fatal("Expected the lock to have expired without a skew allowance")
+	}
+
+	locking.ClockSkew = 30 * time.Second
+	if locking.unexpired(lock) == nil {
+		t.Error("Expected the lock to be held within the skew allowance")
+	}
+	locking.now = func() time.Time { return base.Add(30 * time.Second) }
+	if locking.unexpired(lock) != nil {
+		t.Error("Expected the lock to expire once the skew allowance has passed")
+	}
+
+	shared := &Lock{Holders: []Holder{{Owner: "b", ExpiresAt: base.Add(-10 * time.Second)}}}
+	locking.now = func() time.Time { return base }
+	if locking.unexpired(shared) == nil {
+		t.Error("Expected the shared lock holder to be held within the skew allowance")
+	}
+}
+
+func TestClockOffset(t *testing.T) {
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// The holder's clock runs a minute fast, so its lock expires a minute earlier than recorded
+	holder := &Locking{LockKey: "b", ClockOffset: time.Minute, now: func() time.Time { return base.Add(time.Minute) }}
+	lock := holder.newLock(30*time.Second, "fast clock")
+	if lock.ClockOffset != time.Minute {
+		t.Fatalf("Expected the clock offset to be recorded, got %v", lock.ClockOffset)
+	}
+
+	data, err := json.Marshal(lock)
+	if err != nil {
+		t.Fatalf("Failed to marshal lock: %v", err)
+	}
+	var stored Lock
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatalf("Failed to unmarshal lock: %v", err)
+	}
+
+	observer := &Locking{LockKey: "a", now: func() time.Time { return base.Add(20 * time.Second) }}
+	if observer.unexpired(&stored) == nil {
+		t.Error("Expected the lock to be held 20s after it was acquired")
+	}
+	observer.now = func() time.Time { return base.Add(40 * time.Second) }
+	if observer.unexpired(&stored) != nil {
+		t.Error("Expected the lock to have expired 40s after it was acquired, despite the fast clock")
+	}
+
+	// An observer whose own clock runs slow corrects its own time too
+	observer.ClockOffset = -time.Minute
+	observer.now = func() time.Time { return base.Add(-40 * time.Second) }
+	if observer.unexpired(&stored) == nil {
+		t.Error("Expected the lock to be held 20s after it was acquired")
+	}
+}
@@ -0,0 +1,35 @@
+package lock
+
+// Codec serializes locks to and from the files or objects a Backend stores them in,
+// so locks can be kept in a deployment's preferred format, such as YAML or protobuf,
+// while reusing all acquisition and expiry logic.
+// Every process sharing a lock must use the same Codec.
+type Codec interface {
+	// Marshal encodes lock
+	Marshal(lock *Lock) ([]byte, error)
+
+	// Unmarshal decodes a lock encoded by Marshal
+	Unmarshal(data []byte) (*Lock, error)
+}
+
+// JSONCodec stores locks as JSON in the LockVersion format, migrating older versions
+// when they are read. It is used by every Backend unless another Codec is configured.
+var JSONCodec Codec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(lock *Lock) ([]byte, error) {
+	return encodeLock(lock)
+}
+
+func (jsonCodec) Unmarshal(data []byte) (*Lock, error) {
+	return decodeLock(data)
+}
+
+// codecOrDefault returns codec, or JSONCodec if codec is nil
+func codecOrDefault(codec Codec) Codec {
+	if codec == nil {
+		return JSONCodec
+	}
+	return codec
+}
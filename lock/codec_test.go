@@ -0,0 +1,98 @@
+package lock
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+// gobCodec stores locks in a non-JSON format
+type gobCodec struct{}
+
+func (gobCodec) Marshal(lock *Lock) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(lock); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte) (*Lock, error) {
+	var lock Lock
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&lock); err != nil {
+		return nil, err
+	}
+	return &lock, nil
+}
+
+func TestCustomCodec(t *testing.T) {
+	backends := map[string]func(repo *gittools.Repo) Backend{
+		"file": func(repo *gittools.Repo) Backend {
+			return NewFileBackendWithOptions(repo, FileBackendOptions{Codec: gobCodec{}})
+		},
+		"worktree": func(repo *gittools.Repo) Backend {
+			return NewFileBackendWithOptions(repo, FileBackendOptions{Worktree: true, Codec: gobCodec{}})
+		},
+		"branch": func(repo *gittools.Repo) Backend {
+			backend := NewBranchBackend(repo, "locks")
+			backend.Codec = gobCodec{}
+			return backend
+		},
+		"ref": func(repo *gittools.Repo) Backend {
+			return NewRefBackendWithOptions(repo, RefBackendOptions{Codec: gobCodec{}})
+		},
+	}
+	for name, newBackend := range backends {
+		t.Run(name, func(t *testing.T) {
+			gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+				_, remoteDir, cleanup := setupRemoteTestRepo(t)
+				defer cleanup()
+
+				repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+				defer cleanupRepo()
+				otherRepo, cleanupOther := checkoutRemoteTestRepo(t, remoteDir)
+				defer cleanupOther()
+
+				locking := NewRepoLocking(repo)
+				locking.Backend = newBackend(repo)
+				other := NewRepoLocking(otherRepo)
+				other.Backend = newBackend(otherRepo)
+
+				lockPath := "locks/codec.lock"
+				if err := locking.AcquireLock(lockPath, time.Minute, "gob"); err != nil {
+					t.Fatalf("Failed to acquire lock: %v", err)
+				}
+				lock, err := locking.ReadLock(lockPath)
+				if err != nil || lock == nil || lock.Owner != locking.LockKey || lock.Description != "gob" {
+					t.Fatalf("Expected to read the lock back, got %+v, %v", lock, err)
+				}
+				if err := other.AcquireLock(lockPath, time.Minute, "other"); !errors.Is(err, ErrLockConflict) {
+					t.Errorf("Expected ErrLockConflict, got %v", err)
+				}
+
+				if name == "file" {
+					data, err := os.ReadFile(filepath.Join(repo.RepoPath, lockPath))
+					if err != nil {
+						t.Fatalf("Failed to read lock file: %v", err)
+					}
+					if _, err := JSONCodec.Unmarshal(data); err == nil {
+						t.Error("Expected the lock file not to be JSON")
+					}
+				}
+
+				if err := locking.ReleaseLock(lockPath); err != nil {
+					t.Fatalf("Failed to release lock: %v", err)
+				}
+				if err := other.AcquireLock(lockPath, time.Minute, "other"); err != nil {
+					t.Errorf("Expected the released lock to be free, got %v", err)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package lock
+
+import (
+	"sort"
+	"time"
+)
+
+// ContentionStats describes the contention for a lock observed by this process,
+// to help find hot locks that should be split into several finer grained locks
+type ContentionStats struct {
+	Path string
+
+	// Acquisitions is the number of times this process acquired the lock
+	Acquisitions int
+
+	// Conflicts is the number of operations on the lock that failed with ErrLockConflict,
+	// including each failed attempt made by AcquireLockWait
+	Conflicts int
+
+	// WaitTime is the total time spent acquiring the lock, including the time
+	// AcquireLockWait slept between attempts
+	WaitTime time.Duration
+
+	// Waiters is the number of AcquireLockWait calls in this process currently waiting
+	// for the lock, an estimate of its queue depth
+	Waiters int
+
+	// LastHolder is the most recent holder of the lock seen by this process, which
+	// may be this process. It is nil if the lock has not been seen held.
+	LastHolder *Holder
+}
+
+// Contention returns the contention observed by this process for the lock at lockPath
+func (g *Locking) Contention(lockPath string) ContentionStats {
+	g.statsMu.Lock()
+	defer g.statsMu.Unlock()
+
+	if stats, ok := g.stats[lockPath]; ok {
+		return stats.copy()
+	}
+	return ContentionStats{Path: lockPath}
+}
+
+// ContentionStats returns the contention observed by this process for every lock it
+// has used, the most contended first
+func (g *Locking) ContentionStats() []ContentionStats {
+	g.statsMu.Lock()
+	var out []ContentionStats
+	for _, stats := range g.stats {
+		out = append(out, stats.copy())
+	}
+	g.statsMu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Conflicts != out[j].Conflicts {
+			return out[i].Conflicts > out[j].Conflicts
+		}
+		if out[i].WaitTime != out[j].WaitTime {
+			return out[i].WaitTime > out[j].WaitTime
+		}
+		return out[i].Path < out[j].Path
+	})
+	return out
+}
+
+// copy returns a copy of the stats that doesn't share LastHolder
+func (s *ContentionStats) copy() ContentionStats {
+	out := *s
+	if s.LastHolder != nil {
+		holder := *s.LastHolder
+		holder.Identity = holder.Identity.clone()
+		out.LastHolder = &holder
+	}
+	return out
+}
+
+// recordStats applies fn to the stats for lockPath
+func (g *Locking) recordStats(lockPath string, fn func(stats *ContentionStats)) {
+	g.statsMu.Lock()
+	defer g.statsMu.Unlock()
+
+	if g.stats == nil {
+		g.stats = make(map[string]*ContentionStats)
+	}
+	stats, ok := g.stats[lockPath]
+	if !ok {
+		stats = &ContentionStats{Path: lockPath}
+		g.stats[lockPath] = stats
+	}
+	fn(stats)
+}
+
+// observeHolder records the holder of lock, if it is held, as the last holder of lockPath
+func (g *Locking) observeHolder(lockPath string, lock *Lock) {
+	lock = g.unexpired(lock)
+	if lock == nil {
+		return
+	}
+
+	var holder Holder
+	if lock.Shared() {
+		// The most recent of the processes sharing the lock
+		holder = lock.Holders[0]
+		for _, h := range lock.Holders[1:] {
+			if h.CreatedAt.After(holder.CreatedAt) {
+				holder = h
+			}
+		}
+	} else {
+		holder = Holder{
+			Owner:       lock.Owner,
+			CreatedAt:   lock.CreatedAt,
+			ExpiresAt:   lock.ExpiresAt,
+			Description: lock.Description,
+			Identity:    lock.Identity,
+		}
+	}
+	holder.Identity = holder.Identity.clone()
+
+	g.recordStats(lockPath, func(stats *ContentionStats) {
+		stats.LastHolder = &holder
+	})
+}
@@ -0,0 +1,86 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestContentionStats(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		var lockings []*Locking
+		for i := 0; i < 2; i++ {
+			repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+			defer cleanupRepo()
+
+			locking := NewRepoLocking(repo)
+			locking.Backend = NewRefBackend(repo)
+			lockings = append(lockings, locking)
+		}
+		holder, waiter := lockings[0], lockings[1]
+		holder.Identity.Metadata = map[string]string{"job": "deploy"}
+
+		hot, cold := "locks/hot.lock", "locks/cold.lock"
+		if stats := waiter.Contention(hot); stats.Path != hot || stats.Conflicts != 0 || stats.LastHolder != nil {
+			t.Errorf("Expected empty stats for an unused lock, got %+v", stats)
+		}
+
+		if err := holder.AcquireLock(hot, time.Minute, "holder"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+		if err := waiter.AcquireLock(cold, time.Minute, "cold"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+		if err := waiter.AcquireLock(hot, time.Minute, "waiter"); !errors.Is(err, ErrLockConflict) {
+			t.Fatalf("Expected conflict, got %v", err)
+		}
+
+		stats := waiter.Contention(hot)
+		if stats.Conflicts != 1 || stats.Acquisitions != 0 || stats.WaitTime <= 0 {
+			t.Errorf("Unexpected stats after a conflict %+v", stats)
+		}
+		if stats.LastHolder == nil || stats.LastHolder.Owner != holder.LockKey || stats.LastHolder.Description != "holder" ||
+			stats.LastHolder.Metadata["job"] != "deploy" {
+			t.Errorf("Expected the holder to be recorded, got %+v", stats.LastHolder)
+		}
+
+		// Wait for the lock while it is held
+		errs := make(chan error, 1)
+		go func() {
+			errs <- waiter.AcquireLockWait(context.Background(), hot, time.Minute, "waiter", 10*time.Second)
+		}()
+		deadline := time.Now().Add(5 * time.Second)
+		for waiter.Contention(hot).Waiters != 1 {
+			if time.Now().After(deadline) {
+				t.Fatal("Expected a waiter to be counted")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		time.Sleep(100 * time.Millisecond)
+		if err := holder.ReleaseLock(hot); err != nil {
+			t.Fatalf("Failed to release lock: %v", err)
+		}
+		if err := <-errs; err != nil {
+			t.Fatalf("Failed to acquire lock after waiting: %v", err)
+		}
+
+		stats = waiter.Contention(hot)
+		if stats.Waiters != 0 || stats.Acquisitions != 1 || stats.Conflicts < 2 || stats.WaitTime < 100*time.Millisecond {
+			t.Errorf("Unexpected stats after waiting %+v", stats)
+		}
+		if stats.LastHolder == nil || stats.LastHolder.Owner != waiter.LockKey {
+			t.Errorf("Expected this process to be the last holder, got %+v", stats.LastHolder)
+		}
+
+		all := NewManager(waiter).ContentionStats()
+		if len(all) != 2 || all[0].Path != hot || all[1].Path != cold {
+			t.Errorf("Expected the hot lock to be listed first, got %+v", all)
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Elector uses a well-known lock to elect a single leader among competing processes.
+// While it is not the leader it periodically tries to acquire the lock, and while it is
+// the leader it renews the lock. A leader that fails to renew the lock steps down when
+// the lock expires, less ClockSkew, as another process may then take it over.
+// Leadership is resigned by releasing the lock when the context passed to Run is cancelled.
+type Elector struct {
+	Locking  *Locking
+	LockPath string
+
+	// Expiry is how long the leader lock remains valid without renewal
+	Expiry time.Duration
+
+	// RetryInterval is how often a follower tries to become the leader.
+	// If zero, the renewal interval set by the Renewal policy of Locking is used,
+	// Expiry/3 by default.
+	RetryInterval time.Duration
+
+	// RenewInterval is how often the leader renews its lock.
+	// If zero, it is set by the Renewal policy of Locking, Expiry/3 by default.
+	// Both intervals are jittered by the policy's Jitter.
+	RenewInterval time.Duration
+
+	// Description is recorded in the lock while this process is the leader
+	Description string
+
+	mu      sync.Mutex
+	leader  bool
+	changed chan bool
+}
+
+// NewElector creates an Elector competing for the lock at lockPath
+func NewElector(locking *Locking, lockPath string, expiry time.Duration) *Elector {
+	return &Elector{
+		Locking:     locking,
+		LockPath:    lockPath,
+		Expiry:      expiry,
+		Description: "leader",
+		changed:     make(chan bool, 1),
+	}
+}
+
+// IsLeader returns true if this process currently holds the leader lock
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader
+}
+
+// LeaderChanged returns a channel that receives the new leadership state whenever it changes.
+// Only the most recent state is buffered, so slow readers see the latest value.
+func (e *Elector) LeaderChanged() <-chan bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.changedChannel()
+}
+
+// changedChannel returns the LeaderChanged channel, creating it for an Elector that
+// wasn't made with NewElector. e.mu must be held.
+func (e *Elector) changedChannel() chan bool {
+	if e.changed == nil {
+		e.changed = make(chan bool, 1)
+	}
+	return e.changed
+}
+
+// Run competes for leadership until ctx is cancelled, then resigns if leader.
+// It returns nil when resignation succeeds.
+func (e *Elector) Run(ctx context.Context) error {
+	if e.Expiry <= 0 {
+		return fmt.Errorf("elector expiry must be positive")
+	}
+
+	// leaseExpiry is when the lock last acquired or refreshed expires
+	var leaseExpiry time.Time
+	for {
+		if e.IsLeader() && e.leaseEnded(leaseExpiry) {
+			e.setLeader(false)
+		}
+
+		interval := e.interval(e.RetryInterval)
+		if e.IsLeader() {
+			interval = e.interval(e.RenewInterval)
+			expiry := e.Locking.now().Add(e.Expiry)
+			err := e.Locking.RefreshLock(e.LockPath, expiry)
+			switch {
+			case err == nil:
+				leaseExpiry = expiry
+			case errors.Is(err, ErrLockNotOwned) || e.leaseEnded(leaseExpiry):
+				// Transient errors are retried, but only until the lease ends, when
+				// another elector may take the lock
+				e.setLeader(false)
+			}
+		} else {
+			// Conflicts and transient failures are both retried after RetryInterval
+			expiry := e.Locking.now().Add(e.Expiry)
+			if err := e.Locking.AcquireLock(e.LockPath, e.Expiry, e.Description); err == nil {
+				leaseExpiry = expiry
+				e.setLeader(true)
+				interval = e.interval(e.RenewInterval)
+			}
+		}
+
+		// Wake up to step down when the lease ends if it hasn't been renewed by then
+		if e.IsLeader() {
+			if remaining := leaseExpiry.Add(-e.Locking.ClockSkew).Sub(e.Locking.now()); remaining < interval {
+				interval = remaining
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return e.resign()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// resign releases the leader lock if held
+func (e *Elector) resign() error {
+	if !e.IsLeader() {
+		return nil
+	}
+	e.setLeader(false)
+
+	if err := e.Locking.ReleaseLock(e.LockPath); err != nil {
+		return fmt.Errorf("failed to resign leadership: %w", err)
+	}
+	return nil
+}
+
+// leaseEnded returns true once the lease expiring at leaseExpiry may have expired for
+// other processes. Leadership ends ClockSkew early, in case this clock is behind theirs.
+func (e *Elector) leaseEnded(leaseExpiry time.Time) bool {
+	return !e.Locking.now().Before(leaseExpiry.Add(-e.Locking.ClockSkew))
+}
+
+func (e *Elector) interval(configured time.Duration) time.Duration {
+	if configured <= 0 {
+		configured = e.Locking.Renewal.Interval(e.Expiry)
+	}
+	return e.Locking.Renewal.jittered(configured)
+}
+
+// setLeader records the leadership state and notifies listeners if it changed
+func (e *Elector) setLeader(leader bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.leader == leader {
+		return
+	}
+	e.leader = leader
+
+	// Replace any unread state with the latest one
+	changed := e.changedChannel()
+	select {
+	case <-changed:
+	default:
+	}
+	changed <- leader
+}
@@ -0,0 +1,147 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestElector(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		var electors []*Elector
+		for i := 0; i < 2; i++ {
+			repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+			defer cleanupRepo()
+
+			locking := NewRepoLocking(repo)
+			locking.Backend = NewRefBackend(repo)
+
+			elector := NewElector(locking, "locks/leader", time.Minute)
+			elector.RetryInterval = 50 * time.Millisecond
+			elector.RenewInterval = 50 * time.Millisecond
+			electors = append(electors, elector)
+		}
+
+		ctx1, cancel1 := context.WithCancel(context.Background())
+		done1 := make(chan error, 1)
+		go func() { done1 <- electors[0].Run(ctx1) }()
+
+		select {
+		case leader := <-electors[0].LeaderChanged():
+			if !leader {
+				t.Fatal("Expected first elector to become leader")
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("Timed out waiting for first elector to become leader")
+		}
+
+		ctx2, cancel2 := context.WithCancel(context.Background())
+		defer cancel2()
+		done2 := make(chan error, 1)
+		go func() { done2 <- electors[1].Run(ctx2) }()
+
+		// The second elector must not take over while the first is leader
+		time.Sleep(200 * time.Millisecond)
+		if electors[1].IsLeader() {
+			t.Fatal("Expected second elector to remain a follower")
+		}
+
+		// Resigning hands leadership to the second elector
+		cancel1()
+		if err := <-done1; err != nil {
+			t.Fatalf("Failed to resign: %v", err)
+		}
+		if electors[0].IsLeader() {
+			t.Fatal("Expected first elector to have resigned")
+		}
+
+		select {
+		case leader := <-electors[1].LeaderChanged():
+			if !leader {
+				t.Fatal("Expected second elector to become leader")
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("Timed out waiting for second elector to become leader")
+		}
+
+		cancel2()
+		if err := <-done2; err != nil {
+			t.Fatalf("Failed to resign: %v", err)
+		}
+	})
+}
+
+// failingPushHook fails pushes while fail is set
+type failingPushHook struct {
+	fail atomic.Bool
+}
+
+func (h *failingPushHook) BeforeExec(cmd *gittools.Command) error {
+	if h.fail.Load() && cmd.Subcommand() == "push" {
+		return errors.New("push failed: network is unreachable")
+	}
+	return nil
+}
+
+func (h *failingPushHook) AfterExec(cmd *gittools.Command, stdout, stderr []byte, err error, duration time.Duration) {
+}
+
+func TestElectorStepsDownWhenRenewalFails(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+		hook := &failingPushHook{}
+		repo.Client.Hooks = append(repo.Client.Hooks, hook)
+
+		locking := NewRepoLocking(repo)
+		locking.Backend = NewRefBackend(repo)
+		expiry := time.Second
+		elector := NewElector(locking, "locks/leader", expiry)
+		elector.RetryInterval = 50 * time.Millisecond
+		elector.RenewInterval = 50 * time.Millisecond
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- elector.Run(ctx) }()
+
+		waitFor := func(want bool) {
+			t.Helper()
+			select {
+			case leader := <-elector.LeaderChanged():
+				if leader != want {
+					t.Fatalf("Expected leadership to become %v", want)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatalf("Timed out waiting for leadership to become %v", want)
+			}
+		}
+		waitFor(true)
+
+		// Renewals keep failing for longer than the expiry
+		hook.fail.Store(true)
+		failedAt := time.Now()
+		waitFor(false)
+		if elapsed := time.Since(failedAt); elapsed < expiry/2 || elapsed > expiry+500*time.Millisecond {
+			t.Errorf("Expected to step down when the lease ended after %v, took %v", expiry, elapsed)
+		}
+
+		// Leadership is regained once pushes succeed again
+		hook.fail.Store(false)
+		waitFor(true)
+
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatalf("Failed to resign: %v", err)
+		}
+	})
+}
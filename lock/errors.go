@@ -1,6 +1,81 @@
 package lock
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 // ErrLockConflict is returned when a lock acquisition fails due to the resource being locked
 var ErrLockConflict = errors.New("lock conflict: resource is already locked")
+
+// ErrLockNotExpired is returned when breaking a lock that is restricted to expired locks
+var ErrLockNotExpired = errors.New("lock has not expired")
+
+// ErrLockNotOwned is returned when refreshing or releasing a lock that is not held by this process
+var ErrLockNotOwned = errors.New("lock is not owned by this process")
+
+// ErrUnsupportedLockVersion is returned when reading a lock file written in a newer
+// format than this package understands, see LockVersion
+var ErrUnsupportedLockVersion = errors.New("unsupported lock file version")
+
+// LockConflictError is returned when a lock can't be acquired because it is held by
+// another process. It matches ErrLockConflict with errors.Is, and can be retrieved with
+// errors.As to find out who holds the lock.
+type LockConflictError struct {
+	// Path of the lock that is held. With Locking.Hierarchical this may be a parent
+	// or child of the lock being acquired.
+	Path string
+
+	// Holder is the lock as it was stored when the conflict was found, or nil if the
+	// lock changed concurrently, such as when another process pushed first
+	Holder *Lock
+
+	// Err is the underlying error reported by the Backend, if any
+	Err error
+}
+
+func (e *LockConflictError) Error() string {
+	switch {
+	case e.Holder != nil:
+		return fmt.Sprintf("%v: %s is held by %s", ErrLockConflict, e.Path, describeHolder(e.Holder))
+	case e.Err != nil:
+		return fmt.Sprintf("%s: %v", e.Path, e.Err)
+	default:
+		return fmt.Sprintf("%v: %s", ErrLockConflict, e.Path)
+	}
+}
+
+// Is makes every LockConflictError match ErrLockConflict
+func (e *LockConflictError) Is(target error) bool {
+	return target == ErrLockConflict
+}
+
+func (e *LockConflictError) Unwrap() error {
+	return e.Err
+}
+
+// describeHolder summarizes who holds lock for an error message
+func describeHolder(lock *Lock) string {
+	expires := lock.ExpiresAt.Format(time.RFC3339)
+	switch {
+	case lock.Capacity > 0:
+		return fmt.Sprintf("%d of %d semaphore slots until %s", len(lock.Holders), lock.Capacity, expires)
+	case lock.Shared():
+		return fmt.Sprintf("%d readers until %s", len(lock.Holders), expires)
+	case lock.Description != "":
+		return fmt.Sprintf("%s (%s) until %s", lock.Owner, lock.Description, expires)
+	default:
+		return fmt.Sprintf("%s until %s", lock.Owner, expires)
+	}
+}
+
+// conflictError returns err as a *LockConflictError for lockFilePath if it is a lock
+// conflict reported without one, such as a push that lost a race with another process
+func conflictError(lockFilePath string, err error) error {
+	var conflict *LockConflictError
+	if !errors.Is(err, ErrLockConflict) || errors.As(err, &conflict) {
+		return err
+	}
+	return &LockConflictError{Path: lockFilePath, Err: err}
+}
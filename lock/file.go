@@ -0,0 +1,491 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ocuroot/gittools"
+)
+
+// NewFileBackend creates a Backend that stores locks as files committed
+// to the repository's current branch.
+// Only the current branch is fetched from origin, and the lock files are read from the
+// fetched commit, so the working tree is only updated when a lock changes.
+// Lock updates are pushed to origin. If there is no origin remote
+// or HEAD is detached, locks are only committed locally. If the current branch
+// does not exist on origin yet, it is created by the first push.
+// Only the lock files are committed, and a lock commit that can't be pushed is only
+// undone while it is still the tip of the branch, but merging and pushing the current
+// branch also merges and pushes any other commits on it. Set FileBackendOptions.Branch
+// to keep lock commits away from the caller's branch and working tree.
+func NewFileBackend(repo *gittools.Repo) Backend {
+	return &fileBackend{repo: repo, codec: JSONCodec}
+}
+
+// FileBackendOptions configures a Backend created with NewFileBackendWithOptions
+type FileBackendOptions struct {
+	// Offline commits lock files locally without pulling from or pushing to origin,
+	// so locks are only visible to processes sharing the same repository
+	Offline bool
+
+	// Worktree commits lock files to Branch in a temporary linked worktree created
+	// for each update, rather than building the commits in the object database.
+	Worktree bool
+
+	// Branch is the branch lock files are committed to, rather than the current branch.
+	// The caller's working tree, index and checked out branch are never touched, so local
+	// changes are neither committed nor discarded by lock updates.
+	// If empty, lock files are committed to the current branch, unless Worktree is set,
+	// in which case DefaultLockBranch is used.
+	Branch string
+
+	// Codec serializes lock files. If nil, JSONCodec is used.
+	Codec Codec
+}
+
+// NewFileBackendWithOptions creates a Backend that stores locks as files committed
+// to Branch, or the repository's current branch, see NewFileBackend
+func NewFileBackendWithOptions(repo *gittools.Repo, options FileBackendOptions) Backend {
+	b := &fileBackend{
+		repo:     repo,
+		offline:  options.Offline,
+		codec:    codecOrDefault(options.Codec),
+		branch:   options.Branch,
+		worktree: options.Worktree,
+	}
+	if b.worktree && b.branch == "" {
+		b.branch = DefaultLockBranch
+	}
+	return b
+}
+
+type fileBackend struct {
+	repo    *gittools.Repo
+	offline bool
+	codec   Codec
+
+	// branch is the lock branch, empty to use the current branch of repo
+	branch string
+
+	// worktree updates branch through a temporary worktree rather than the object database
+	worktree bool
+}
+
+func (b *fileBackend) withContext(ctx context.Context) Backend {
+	bound := *b
+	bound.repo = b.repo.WithContext(ctx)
+	return &bound
+}
+
+// Read reads the lock file from the working tree, or from the lock branch when one is
+// configured or the repository is bare
+func (b *fileBackend) Read(lockFilePath string) (*Lock, error) {
+	if b.branch != "" || b.repo.Bare {
+		tip, err := b.lockRevision()
+		if err != nil {
+			return nil, err
+		}
+		return readLockAt(b.repo, b.codec, tip, lockFilePath)
+	}
+
+	lockFileFull := filepath.Join(b.repo.RepoPath, lockFilePath)
+	data, err := os.ReadFile(lockFileFull)
+	if os.IsNotExist(err) {
+		// No lock file, resource is not locked
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file: %w", err)
+	}
+
+	lock, err := b.codec.Unmarshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse lock file: %w", err)
+	}
+
+	return lock, nil
+}
+
+// lockRevision returns the commit lock files are read from: HEAD when they are committed
+// to the current branch, otherwise the tip of the lock branch, which is empty if the
+// lock branch does not exist yet
+func (b *fileBackend) lockRevision() (string, error) {
+	if b.branch == "" && !b.repo.Bare {
+		return "HEAD", nil
+	}
+	branch, err := b.lockBranch()
+	if err != nil {
+		return "", err
+	}
+	tip, _, err := b.lockBranchTip(branch)
+	return tip, err
+}
+
+// Update fetches the lock branch, applies fn to the lock file and pushes the result.
+// If the push fails the local commit is discarded.
+func (b *fileBackend) Update(lockFilePath string, message string, fn func(current *Lock) (*Lock, error)) error {
+	return b.updateAllObserved([]string{lockFilePath}, message, updateOne(lockFilePath, fn), nil)
+}
+
+// UpdateAll fetches the lock branch, applies fn to the lock files and pushes the
+// result as a single commit. If the push fails the local commit is discarded.
+func (b *fileBackend) UpdateAll(lockFilePaths []string, message string, fn func(current map[string]*Lock) (map[string]*Lock, error)) error {
+	return b.updateAllObserved(lockFilePaths, message, fn, nil)
+}
+
+func (b *fileBackend) updateAllObserved(lockFilePaths []string, message string, fn func(current map[string]*Lock) (map[string]*Lock, error), observe func(name MetricName)) error {
+	if b.worktree {
+		return b.updateInWorktree(lockFilePaths, message, fn, observe)
+	}
+	if b.branch != "" || b.repo.Bare {
+		return b.updateBranch(lockFilePaths, message, fn, observe)
+	}
+
+	currentBranch, err := b.repo.CurrentBranch()
+	if err != nil {
+		return fmt.Errorf("failed to get current branch: %w", err)
+	}
+
+	pull, push, err := b.syncMode(currentBranch)
+	if err != nil {
+		return err
+	}
+
+	// Read the latest locks from the branch on origin, fetching nothing else,
+	// so the working tree is only updated if the locks change
+	read := b.Read
+	var remoteTip string
+	if pull {
+		if remoteTip, err = b.fetchBranch(currentBranch); err != nil {
+			return err
+		}
+		read = func(lockFilePath string) (*Lock, error) {
+			return readLockAt(b.repo, b.codec, remoteTip, lockFilePath)
+		}
+	}
+
+	current, err := readLocks(lockFilePaths, read)
+	if err != nil {
+		return err
+	}
+
+	next, err := fn(current)
+	if err != nil {
+		return err
+	}
+
+	changed, err := changedLocks(current, next)
+	if err != nil || len(changed) == 0 {
+		return err
+	}
+
+	// Commit on top of the latest changes
+	if remoteTip != "" {
+		if err := b.repo.Merge(remoteTip); err != nil {
+			if errors.Is(err, gittools.ErrMergeConflict) {
+				_ = b.repo.MergeAbort()
+				return fmt.Errorf("%w: %v", ErrLockConflict, err)
+			}
+			return fmt.Errorf("failed to merge latest changes: %w", err)
+		}
+	}
+
+	committed, err := b.commitLocks(message, current, next)
+	if err != nil || !committed || !push {
+		return err
+	}
+
+	commit, pushErr := b.pushWithRetry(currentBranch, observe)
+	if pushErr != nil {
+		// If push failed, discard our commit
+		if err := b.discardCommit(currentBranch, commit, changed); err != nil {
+			fmt.Printf("Warning: failed to discard lock commit after push error: %v\n", err)
+		}
+		return lockPushError(pushErr)
+	}
+
+	return nil
+}
+
+// discardCommit undoes commit, which changed the lock files at lockFilePaths, if it is
+// still the tip of branch, restoring the lock files to their state in its parent.
+// Commits made on top of it and any other local changes are left alone.
+func (b *fileBackend) discardCommit(branch string, commit string, lockFilePaths []string) error {
+	parent, err := b.repo.RevParse("--verify", commit+"^")
+	if err != nil {
+		return fmt.Errorf("failed to resolve parent of lock commit: %w", err)
+	}
+	if err := b.repo.UpdateRef("refs/heads/"+branch, parent, commit); err != nil {
+		return fmt.Errorf("failed to move %s back: %w", branch, err)
+	}
+
+	for _, lockFilePath := range lockFilePaths {
+		exists, _, err := b.repo.CatFile(gittools.CatFileOptions{Exists: true, ObjectID: parent + ":" + lockFilePath})
+		if err != nil {
+			return fmt.Errorf("failed to check lock file: %w", err)
+		}
+		if exists {
+			if stdout, stderr, err := b.repo.Client.Exec("checkout", parent, "--", lockFilePath); err != nil {
+				return fmt.Errorf("git checkout failed: %w\nstdout: %s\nstderr: %s", err, stdout, stderr)
+			}
+			continue
+		}
+		if stdout, stderr, err := b.repo.Client.Exec("rm", "-q", "-f", "--ignore-unmatch", "--", lockFilePath); err != nil {
+			return fmt.Errorf("git rm failed: %w\nstdout: %s\nstderr: %s", err, stdout, stderr)
+		}
+	}
+	return nil
+}
+
+// commitLocks writes the locks in next to their lock files, removing the files of nil
+// locks, and commits the changes. Returns false if there was nothing to commit.
+func (b *fileBackend) commitLocks(message string, current map[string]*Lock, next map[string]*Lock) (bool, error) {
+	changed, err := changedLocks(current, next)
+	if err != nil || len(changed) == 0 {
+		return false, err
+	}
+
+	// Restore the lock files to their committed state
+	restore := func() {
+		for _, lockFilePath := range changed {
+			_, _, _ = b.repo.Client.Exec("checkout", "HEAD", "--", lockFilePath)
+			if current[lockFilePath] == nil {
+				_ = os.Remove(filepath.Join(b.repo.RepoPath, lockFilePath))
+			}
+		}
+	}
+
+	for _, lockFilePath := range changed {
+		if err := b.writeLockFile(lockFilePath, next[lockFilePath]); err != nil {
+			restore()
+			return false, err
+		}
+	}
+
+	// We need to use the relative path for the commit (not the full path which might be outside the repo)
+	// This ensures files are only committed within the repository's directory structure
+	if err := b.commitOnly(message, changed); err != nil {
+		restore()
+		return false, fmt.Errorf("failed to commit lock file: %w", err)
+	}
+
+	return true, nil
+}
+
+// commitOnly commits the files at paths, leaving any other changes staged in the index
+// uncommitted
+func (b *fileBackend) commitOnly(message string, paths []string) error {
+	if err := b.repo.Client.CheckIdentity(); err != nil {
+		return err
+	}
+
+	args := append([]string{"add", "-A", "--"}, paths...)
+	if stdout, stderr, err := b.repo.Client.Exec(args...); err != nil {
+		return fmt.Errorf("git add failed: %w\nstdout: %s\nstderr: %s", err, stdout, stderr)
+	}
+
+	args = append([]string{"commit", "--only", "-m", message, "--"}, paths...)
+	if stdout, stderr, err := b.repo.Client.Exec(args...); err != nil {
+		return fmt.Errorf("git commit failed: %w\nstdout: %s\nstderr: %s", err, stdout, stderr)
+	}
+	return nil
+}
+
+// writeLockFile writes lock to the lock file in the working tree, or removes it if lock is nil
+func (b *fileBackend) writeLockFile(lockFilePath string, lock *Lock) error {
+	fullLockPath := filepath.Join(b.repo.RepoPath, lockFilePath)
+	if lock == nil {
+		if err := os.Remove(fullLockPath); err != nil {
+			return fmt.Errorf("failed to remove lock file: %w", err)
+		}
+		return nil
+	}
+
+	// Create lock file directory if it doesn't exist
+	if err := os.MkdirAll(filepath.Dir(fullLockPath), 0755); err != nil {
+		return fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	lockContent, err := b.codec.Marshal(lock)
+	if err != nil {
+		return fmt.Errorf("failed to marshal lock: %w", err)
+	}
+
+	if err := os.WriteFile(fullLockPath, lockContent, 0644); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+	return nil
+}
+
+// lockPushError converts the error from a failed push of a lock update to an appropriate lock error
+func lockPushError(pushErr error) error {
+	switch {
+	case errors.Is(pushErr, gittools.ErrPushPermissionDenied):
+		return fmt.Errorf("lock update failed due to permission issues: %w", pushErr)
+	case errors.Is(pushErr, gittools.ErrPushRemoteRefMissing):
+		return fmt.Errorf("lock update failed due to missing remote reference: %w", pushErr)
+	default:
+		// Non-fast-forward pushes, rejections and rebase conflicts all
+		// mean someone else has pushed changes
+		return fmt.Errorf("%w: %v", ErrLockConflict, pushErr)
+	}
+}
+
+// syncMode determines whether lock updates on branch should be pulled from and pushed to origin
+func (b *fileBackend) syncMode(branch string) (pull bool, push bool, err error) {
+	if b.offline || branch == "HEAD" {
+		// Offline or detached HEAD, there is no branch to synchronize
+		return false, false, nil
+	}
+
+	remotes, err := b.repo.Remotes()
+	if err != nil {
+		return false, false, fmt.Errorf("failed to list remotes: %w", err)
+	}
+	hasOrigin := false
+	for _, remote := range remotes {
+		if remote.Name == "origin" {
+			hasOrigin = true
+		}
+	}
+	if !hasOrigin {
+		return false, false, nil
+	}
+
+	refs, err := b.repo.LsRemote("origin", "refs/heads/"+branch)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to check remote branch: %w", err)
+	}
+	if _, exists := refs["refs/heads/"+branch]; !exists {
+		// First use of this branch, the push will create it
+		return false, true, nil
+	}
+
+	return true, true, nil
+}
+
+// lockBranchTip returns the tip of a branch that lock files are committed to without
+// the working tree, fetching it from origin if it is pushed there, and whether updates
+// should be pushed to origin.
+// The tip is empty if the branch does not exist yet.
+func (b *fileBackend) lockBranchTip(branch string) (tip string, push bool, err error) {
+	pull, push, err := b.syncMode(branch)
+	if err != nil {
+		return "", false, err
+	}
+
+	ref := "refs/heads/" + branch
+	switch {
+	case pull:
+		tip, err := b.fetchBranch(branch)
+		return tip, push, err
+	case push:
+		// First use of the branch, the push will create it
+		return "", true, nil
+	default:
+		exists, err := b.repo.BranchExists(branch, false)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to check lock branch: %w", err)
+		}
+		if !exists {
+			return "", false, nil
+		}
+	}
+
+	tip, err = b.repo.RevParse("--verify", ref)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to resolve lock branch: %w", err)
+	}
+	return tip, push, nil
+}
+
+// fetchBranch fetches only branch from origin into its remote-tracking ref and returns its tip
+func (b *fileBackend) fetchBranch(branch string) (string, error) {
+	tracking := "refs/remotes/origin/" + branch
+	if err := b.repo.Fetch("origin", gittools.FetchOptions{Refspecs: []string{"+refs/heads/" + branch + ":" + tracking}}); err != nil {
+		return "", fmt.Errorf("failed to fetch lock branch: %w", err)
+	}
+
+	tip, err := b.repo.RevParse("--verify", tracking)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve lock branch: %w", err)
+	}
+	return tip, nil
+}
+
+// pushWithRetry attempts to push to origin with retry logic using Git rebase
+// for handling non-fast-forward conflicts. If observe is not nil, it is called
+// for each retry and rebase. Returns the commit last pushed, which is a rebased
+// copy of HEAD if a rebase was needed.
+func (b *fileBackend) pushWithRetry(branch string, observe func(name MetricName)) (string, error) {
+	const maxRetries = 2
+	var head string
+	var lastErr error
+
+	for retry := 0; retry <= maxRetries; retry++ {
+		var err error
+		if head, err = b.repo.RevParse("HEAD"); err != nil {
+			return "", fmt.Errorf("failed to resolve lock commit: %w", err)
+		}
+
+		// Try to push, from a detached HEAD when run in a worktree
+		lastErr = b.repo.PushWithOptions("origin", gittools.PushOptions{Refspecs: []string{"HEAD:refs/heads/" + branch}})
+		if lastErr == nil {
+			// Push succeeded
+			return head, nil
+		}
+
+		// Only retry for non-fast-forward or fetch-first errors
+		if retry < maxRetries && (errors.Is(lastErr, gittools.ErrPushNonFastForward) || errors.Is(lastErr, gittools.ErrPushFetchFirst)) {
+			// First fetch the latest changes
+			if _, fetchErr := b.fetchBranch(branch); fetchErr != nil {
+				// Failed to fetch, continue to next retry attempt
+				continue
+			}
+
+			// Make sure we're not in a rebase already
+			state, err := b.repo.State()
+			if err != nil {
+				// Failed to check repository state, continue to next retry attempt
+				continue
+			}
+			if state.Rebasing {
+				if err := b.repo.RebaseAbort(); err != nil {
+					// Failed to abort rebase, continue to next retry attempt
+					continue
+				}
+			}
+
+			// Try to rebase our changes on top of the remote
+			if observe != nil {
+				observe(MetricRebaseFallbacks)
+			}
+			rebaseErr := b.repo.Rebase("refs/remotes/origin/" + branch)
+			if rebaseErr != nil {
+				// If rebase fails for any reason, abort it and stop retrying
+				if err := b.repo.RebaseAbort(); err != nil {
+					fmt.Printf("Warning: failed to abort rebase after rebase error: %v\n", err)
+				}
+				// For a locking mechanism, a rebase failure indicates true contention
+				// Set the last error to the rebase error and break out completely
+				lastErr = rebaseErr
+				break
+			}
+
+			// Continue to next retry attempt after rebase
+			if observe != nil {
+				observe(MetricPushRetries)
+			}
+			continue
+		} else {
+			// For any other errors, stop trying
+			break
+		}
+	}
+
+	// If we've reached here, the push failed after all retries
+	return head, lastErr
+}
@@ -0,0 +1,254 @@
+package lock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+// repoSnapshot returns HEAD, the current branch and the status of the working tree and index
+func repoSnapshot(t *testing.T, repo *gittools.Repo) string {
+	t.Helper()
+	head, err := repo.RevParse("HEAD")
+	if err != nil {
+		t.Fatalf("Failed to get HEAD: %v", err)
+	}
+	status, _, err := repo.Client.Exec("status", "--porcelain", "--branch")
+	if err != nil {
+		t.Fatalf("Failed to get status: %v", err)
+	}
+	return head + "\n" + string(status)
+}
+
+func TestDefaultBackendLeavesBranchAlone(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+		otherRepo, cleanupOther := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupOther()
+
+		branch, err := repo.CurrentBranch()
+		if err != nil {
+			t.Fatalf("Failed to get current branch: %v", err)
+		}
+		remoteBefore, err := repo.LsRemote("origin", "refs/heads/"+branch)
+		if err != nil {
+			t.Fatalf("Failed to list remote refs: %v", err)
+		}
+
+		// An unpushed local commit, and staged and unstaged changes
+		if err := os.WriteFile(filepath.Join(repo.RepoPath, "local.txt"), []byte("local\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if err := repo.Commit("Local work", []string{"local.txt"}); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(repo.RepoPath, "staged.txt"), []byte("staged\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if _, _, err := repo.Client.Exec("add", "staged.txt"); err != nil {
+			t.Fatalf("Failed to stage file: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(repo.RepoPath, "README.md"), []byte("unstaged\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		before := repoSnapshot(t, repo)
+
+		locking := NewRepoLocking(repo)
+		other := NewRepoLocking(otherRepo)
+		lockPath := "locks/isolated.lock"
+		if err := locking.AcquireLock(lockPath, time.Minute, "isolated"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+		if lock, err := other.ReadLock(lockPath); err != nil || lock == nil || lock.Owner != locking.LockKey {
+			t.Errorf("Expected the other clone to read the lock, got %+v, %v", lock, err)
+		}
+		if err := locking.ReleaseLock(lockPath); err != nil {
+			t.Fatalf("Failed to release lock: %v", err)
+		}
+
+		if after := repoSnapshot(t, repo); after != before {
+			t.Errorf("Expected the branch, working tree and index to be untouched, before:\n%s\nafter:\n%s", before, after)
+		}
+		remoteAfter, err := repo.LsRemote("origin", "refs/heads/"+branch)
+		if err != nil {
+			t.Fatalf("Failed to list remote refs: %v", err)
+		}
+		if remoteAfter["refs/heads/"+branch] != remoteBefore["refs/heads/"+branch] {
+			t.Error("Expected the local commit not to be pushed")
+		}
+		refs, err := repo.LsRemote("origin", "refs/heads/"+DefaultLockBranch)
+		if err != nil {
+			t.Fatalf("Failed to list remote refs: %v", err)
+		}
+		if _, exists := refs["refs/heads/"+DefaultLockBranch]; !exists {
+			t.Errorf("Expected locks to be pushed to the lock branch, got %v", refs)
+		}
+
+		// A detached HEAD makes no difference
+		if _, _, err := repo.Client.Exec("checkout", "--detach"); err != nil {
+			t.Fatalf("Failed to detach HEAD: %v", err)
+		}
+		if err := locking.AcquireLock(lockPath, time.Minute, "detached"); err != nil {
+			t.Fatalf("Failed to acquire lock with a detached HEAD: %v", err)
+		}
+		if lock, err := other.ReadLock(lockPath); err != nil || lock == nil || lock.Description != "detached" {
+			t.Errorf("Expected the lock to be pushed from a detached HEAD, got %+v, %v", lock, err)
+		}
+	})
+}
+
+func TestFileBackendPushFailureKeepsLocalChanges(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+
+		if err := os.WriteFile(filepath.Join(repo.RepoPath, "staged.txt"), []byte("staged\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if _, _, err := repo.Client.Exec("add", "staged.txt"); err != nil {
+			t.Fatalf("Failed to stage file: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(repo.RepoPath, "README.md"), []byte("unstaged\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		before := repoSnapshot(t, repo)
+
+		faults := &gittools.FaultInjector{}
+		faults.On("push").Fail("fatal: the remote end hung up unexpectedly", 128)
+		repo.Client.Hooks = append(repo.Client.Hooks, faults)
+
+		locking := NewRepoLocking(repo)
+		locking.Backend = NewFileBackend(repo)
+		lockPath := "locks/current.lock"
+		if err := locking.AcquireLock(lockPath, time.Minute, "current"); err == nil {
+			t.Fatal("Expected the acquisition to fail when the push fails")
+		}
+
+		// Only the lock commit is undone, and the staged change was never committed
+		if after := repoSnapshot(t, repo); after != before {
+			t.Errorf("Expected local changes to be kept, before:\n%s\nafter:\n%s", before, after)
+		}
+		if _, err := os.Stat(filepath.Join(repo.RepoPath, lockPath)); !os.IsNotExist(err) {
+			t.Errorf("Expected the lock file to be removed, got %v", err)
+		}
+	})
+}
+
+func TestFileBackendFetchesOnlyLockBranch(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+		otherRepo, cleanupOther := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupOther()
+
+		faults := &gittools.FaultInjector{}
+		pull := faults.On("pull")
+		repo.Client.Hooks = append(repo.Client.Hooks, faults)
+
+		locking := NewRepoLocking(repo)
+		locking.Backend = NewFileBackend(repo)
+		other := NewRepoLocking(otherRepo)
+		other.Backend = NewFileBackend(otherRepo)
+
+		// Another branch on origin, which is never fetched
+		if err := otherRepo.CreateBranch("unrelated"); err != nil {
+			t.Fatalf("Failed to create branch: %v", err)
+		}
+		if err := otherRepo.PushWithOptions("origin", gittools.PushOptions{Refspecs: []string{"unrelated"}}); err != nil {
+			t.Fatalf("Failed to push branch: %v", err)
+		}
+
+		lockPath := "locks/sparse.lock"
+		if err := other.AcquireLock(lockPath, time.Minute, "other"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+		worktree := func() string {
+			t.Helper()
+			head, err := repo.RevParse("HEAD")
+			if err != nil {
+				t.Fatalf("Failed to get HEAD: %v", err)
+			}
+			status, _, err := repo.Client.Exec("status", "--porcelain")
+			if err != nil {
+				t.Fatalf("Failed to get status: %v", err)
+			}
+			return head + "\n" + string(status)
+		}
+		before := worktree()
+
+		// A conflict is found from the fetched commit without updating the working tree
+		if err := locking.AcquireLock(lockPath, time.Minute, "sparse"); !errors.Is(err, ErrLockConflict) {
+			t.Fatalf("Expected ErrLockConflict, got %v", err)
+		}
+		if after := worktree(); after != before {
+			t.Errorf("Expected the working tree to be untouched by a conflict, before:\n%s\nafter:\n%s", before, after)
+		}
+		if _, err := os.Stat(filepath.Join(repo.RepoPath, lockPath)); !os.IsNotExist(err) {
+			t.Errorf("Expected the other lock file not to be checked out, got %v", err)
+		}
+
+		if err := other.ReleaseLock(lockPath); err != nil {
+			t.Fatalf("Failed to release lock: %v", err)
+		}
+		if err := locking.AcquireLock(lockPath, time.Minute, "sparse"); err != nil {
+			t.Fatalf("Failed to acquire released lock: %v", err)
+		}
+		if lock, err := locking.ReadLock(lockPath); err != nil || lock == nil || lock.Owner != locking.LockKey {
+			t.Errorf("Expected to hold the lock, got %+v, %v", lock, err)
+		}
+
+		if calls := faults.Calls(pull); calls != 0 {
+			t.Errorf("Expected no pulls, got %d", calls)
+		}
+		if _, err := repo.RevParse("--verify", "refs/remotes/origin/unrelated"); err == nil {
+			t.Error("Expected other branches not to be fetched")
+		}
+	})
+}
+
+func TestLockingWithoutIdentity(t *testing.T) {
+	backends := map[string]func(repo *gittools.Repo) Backend{
+		"default": func(repo *gittools.Repo) Backend {
+			return NewFileBackendWithOptions(repo, FileBackendOptions{Branch: DefaultLockBranch})
+		},
+		"file": NewFileBackend,
+		"ref":  NewRefBackend,
+	}
+	for name, newBackend := range backends {
+		t.Run(name, func(t *testing.T) {
+			gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+				_, remoteDir, cleanup := setupRemoteTestRepo(t)
+				defer cleanup()
+
+				repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+				defer cleanupRepo()
+				repo.Client.Env = map[string]string{"GIT_AUTHOR_NAME": "", "GIT_COMMITTER_NAME": ""}
+				before := repoSnapshot(t, repo)
+
+				locking := NewRepoLocking(repo)
+				locking.Backend = newBackend(repo)
+				lockPath := "locks/ident.lock"
+				if err := locking.AcquireLock(lockPath, time.Minute, "ident"); !errors.Is(err, gittools.ErrIdentityNotConfigured) {
+					t.Fatalf("Expected ErrIdentityNotConfigured, got %v", err)
+				}
+				if after := repoSnapshot(t, repo); after != before {
+					t.Errorf("Expected the repository to be untouched, before:\n%s\nafter:\n%s", before, after)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// heartbeatMissedLimit is how many heartbeats in a row a holder may miss before
+// IsHolderAlive considers it dead
+const heartbeatMissedLimit = 3
+
+// Heartbeat records when a lock holder last showed it was alive.
+// Heartbeats are sent more often than a lock is refreshed, so a crashed holder can be
+// detected long before its lock expires.
+type Heartbeat struct {
+	At time.Time `json:"at"`
+
+	// Interval is how often the holder sends heartbeats
+	Interval time.Duration `json:"interval"`
+}
+
+// Heartbeat records that this process, which holds the lock at lockFilePath, is still
+// alive, without extending the lock's expiry. It requires HeartbeatInterval to be set.
+// If the lock is no longer held by this process, an error wrapping ErrLockNotOwned is returned.
+func (g *Locking) Heartbeat(lockFilePath string) error {
+	return g.heartbeat(g.Backend, lockFilePath)
+}
+
+func (g *Locking) heartbeat(backend Backend, lockFilePath string) error {
+	if g.HeartbeatInterval <= 0 {
+		return fmt.Errorf("cannot send heartbeats without a HeartbeatInterval")
+	}
+
+	var lost *Lock
+	err := g.update(backend, lockFilePath, fmt.Sprintf("Heartbeat for %s", lockFilePath), func(current *Lock) (*Lock, error) {
+		lock := g.unexpired(current)
+		if lock == nil {
+			return nil, fmt.Errorf("cannot send heartbeat: %w", ErrLockNotOwned)
+		}
+
+		if lock.Shared() {
+			holder := lock.holder(g.LockKey)
+			if holder == nil {
+				lost = lock
+				return nil, fmt.Errorf("cannot send heartbeat for shared lock: %w", ErrLockNotOwned)
+			}
+			holder.Heartbeat = g.newHeartbeat()
+			return lock, nil
+		}
+
+		if lock.Owner != g.LockKey {
+			lost = lock
+			return nil, fmt.Errorf("cannot send heartbeat for lock held by %s: %w", lock.Owner, ErrLockNotOwned)
+		}
+		lock.Heartbeat = g.newHeartbeat()
+		return lock, nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrLockNotOwned) {
+			g.Hooks.lost(lockFilePath, lost)
+		}
+		return fmt.Errorf("failed to send heartbeat: %w", err)
+	}
+
+	return nil
+}
+
+// IsHolderAlive returns false if lock has expired, or if its holder sends heartbeats
+// and has missed several in a row, which suggests it crashed without releasing the lock.
+// Holders that don't send heartbeats are considered alive until the lock expires.
+// A shared lock is alive while any of its holders is.
+func (g *Locking) IsHolderAlive(lock *Lock) bool {
+	lock = g.unexpired(lock)
+	if lock == nil {
+		return false
+	}
+
+	if lock.Shared() {
+		for _, h := range lock.Holders {
+			if g.heartbeatAlive(h.Heartbeat, h.ClockOffset) {
+				return true
+			}
+		}
+		return false
+	}
+	return g.heartbeatAlive(lock.Heartbeat, lock.ClockOffset)
+}
+
+// heartbeatAlive returns true unless heartbeat, sent by a holder with the given ClockOffset,
+// is overdue by heartbeatMissedLimit intervals
+func (g *Locking) heartbeatAlive(heartbeat *Heartbeat, offset time.Duration) bool {
+	if heartbeat == nil || heartbeat.Interval <= 0 {
+		return true
+	}
+	return !g.expired(heartbeat.At.Add(heartbeatMissedLimit*heartbeat.Interval), offset)
+}
+
+// newHeartbeat returns a heartbeat sent now, or nil if this process doesn't send heartbeats
+func (g *Locking) newHeartbeat() *Heartbeat {
+	if g.HeartbeatInterval <= 0 {
+		return nil
+	}
+	return &Heartbeat{At: g.now(), Interval: g.HeartbeatInterval}
+}
+
+// beat returns heartbeat renewed as of now, for a lock being refreshed
+func (g *Locking) beat(heartbeat *Heartbeat) *Heartbeat {
+	if g.HeartbeatInterval > 0 || heartbeat == nil {
+		return g.newHeartbeat()
+	}
+	return &Heartbeat{At: g.now(), Interval: heartbeat.Interval}
+}
@@ -0,0 +1,150 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestHeartbeat(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+
+		locking := NewRepoLocking(repo)
+		locking.Backend = NewRefBackend(repo)
+		locking.HeartbeatInterval = 10 * time.Second
+		observer := NewRepoLocking(repo)
+		observer.Backend = locking.Backend
+
+		lockPath := "locks/heartbeat.lock"
+		if err := locking.AcquireLock(lockPath, time.Hour, "Long running job"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+		lock, err := observer.ReadLock(lockPath)
+		if err != nil {
+			t.Fatalf("Failed to read lock: %v", err)
+		}
+		if lock.Heartbeat == nil || lock.Heartbeat.Interval != locking.HeartbeatInterval {
+			t.Fatalf("Expected a heartbeat to be recorded, got %+v", lock.Heartbeat)
+		}
+		if !observer.IsHolderAlive(lock) {
+			t.Error("Expected a fresh holder to be alive")
+		}
+
+		// A holder that misses several heartbeats is considered crashed before the lock expires
+		observer.now = func() time.Time { return time.Now().Add(time.Minute) }
+		if observer.IsHolderAlive(lock) {
+			t.Error("Expected a holder that missed its heartbeats not to be alive")
+		}
+
+		// Heartbeats revive the holder without extending the lock
+		locking.now = observer.now
+		if err := locking.Heartbeat(lockPath); err != nil {
+			t.Fatalf("Heartbeat failed: %v", err)
+		}
+		updated, err := observer.ReadLock(lockPath)
+		if err != nil {
+			t.Fatalf("Failed to read lock: %v", err)
+		}
+		if !observer.IsHolderAlive(updated) {
+			t.Error("Expected the holder to be alive after a heartbeat")
+		}
+		if !updated.ExpiresAt.Equal(lock.ExpiresAt) {
+			t.Errorf("Expected expiry to stay at %v, got %v", lock.ExpiresAt, updated.ExpiresAt)
+		}
+
+		if err := observer.Heartbeat(lockPath); err == nil {
+			t.Error("Expected a heartbeat without an interval to fail")
+		}
+		observer.HeartbeatInterval = time.Second
+		if err := observer.Heartbeat(lockPath); !errors.Is(err, ErrLockNotOwned) {
+			t.Errorf("Expected ErrLockNotOwned, got %v", err)
+		}
+
+		// Expired locks are never alive
+		observer.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+		if observer.IsHolderAlive(updated) {
+			t.Error("Expected the holder of an expired lock not to be alive")
+		}
+	})
+}
+
+func TestIsHolderAliveWithoutHeartbeats(t *testing.T) {
+	base := time.Now()
+	locking := &Locking{LockKey: "a", now: func() time.Time { return base }}
+
+	lock := &Lock{Owner: "b", ExpiresAt: base.Add(time.Minute)}
+	if !locking.IsHolderAlive(lock) {
+		t.Error("Expected a holder without heartbeats to be alive until expiry")
+	}
+	if locking.IsHolderAlive(nil) {
+		t.Error("Expected a missing lock not to be alive")
+	}
+
+	stale := &Heartbeat{At: base.Add(-time.Minute), Interval: time.Second}
+	shared := &Lock{ExpiresAt: base.Add(time.Minute), Holders: []Holder{
+		{Owner: "b", ExpiresAt: base.Add(time.Minute), Heartbeat: stale},
+		{Owner: "c", ExpiresAt: base.Add(time.Minute), Heartbeat: stale},
+	}}
+	if locking.IsHolderAlive(shared) {
+		t.Error("Expected a shared lock with only crashed holders not to be alive")
+	}
+	shared.Holders[1].Heartbeat = &Heartbeat{At: base, Interval: time.Second}
+	if !locking.IsHolderAlive(shared) {
+		t.Error("Expected a shared lock with a live holder to be alive")
+	}
+}
+
+func TestKeepAliveHeartbeat(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+
+		locking := NewRepoLocking(repo)
+		locking.Backend = NewRefBackend(repo)
+		locking.HeartbeatInterval = 20 * time.Millisecond
+
+		lockPath := "locks/keepalive.lock"
+		if err := locking.AcquireLock(lockPath, time.Minute, "Long running job"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+		original, err := locking.ReadLock(lockPath)
+		if err != nil {
+			t.Fatalf("Failed to read lock: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		if _, err := locking.KeepAlive(ctx, lockPath, time.Hour); err != nil {
+			t.Fatalf("Failed to start keep-alive: %v", err)
+		}
+
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			lock, err := locking.ReadLock(lockPath)
+			if err != nil {
+				t.Fatalf("Failed to read lock: %v", err)
+			}
+			if lock.Heartbeat.At.After(original.Heartbeat.At) {
+				if !lock.ExpiresAt.Equal(original.ExpiresAt) {
+					t.Errorf("Expected heartbeats not to refresh the lock, expiry changed to %v", lock.ExpiresAt)
+				}
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("Expected keep-alive to send heartbeats")
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	})
+}
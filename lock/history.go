@@ -0,0 +1,170 @@
+package lock
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+// LockAction identifies the kind of change recorded by a LockEvent
+type LockAction string
+
+const (
+	ActionAcquire LockAction = "acquire"
+	ActionRefresh LockAction = "refresh"
+	ActionRelease LockAction = "release"
+	ActionBreak   LockAction = "break"
+	ActionSteal   LockAction = "steal"
+
+	// ActionUnknown is used for commits touching the lock file that were not made by this package
+	ActionUnknown LockAction = "unknown"
+)
+
+// LockEvent is a single change to a lock, recovered from git history
+type LockEvent struct {
+	Action LockAction
+
+	// Commit that made the change
+	Commit string
+
+	// Author of the commit, as "Name <email>"
+	Author string
+
+	// Time the change was committed
+	Time time.Time
+
+	// Message is the full commit message, including any break reason
+	Message string
+
+	// Lock is the state of the lock after the change, nil if the lock was removed
+	Lock *Lock
+}
+
+// HistoryOptions limits the events returned by History
+type HistoryOptions struct {
+	// Since excludes events committed before this time
+	Since time.Time
+
+	// Limit returns only the most recent events, 0 returns all events
+	Limit int
+}
+
+// HistoryReader is implemented by backends that keep a history of lock updates
+type HistoryReader interface {
+	// History returns the changes made to the lock at lockPath, oldest first
+	History(lockPath string, options HistoryOptions) ([]LockEvent, error)
+}
+
+// History returns the acquire, refresh, release, break and steal events for the lock
+// at lockFilePath, oldest first, by reading the commits that touched the lock file.
+// Backends that do not keep history, such as NewRefBackend, return an error.
+// History removed by Compact or a squashing BranchBackend is not available.
+func (g *Locking) History(lockFilePath string, options HistoryOptions) ([]LockEvent, error) {
+	reader, ok := g.Backend.(HistoryReader)
+	if !ok {
+		return nil, fmt.Errorf("lock backend %T does not support history", g.Backend)
+	}
+
+	g.opMu.Lock()
+	defer g.opMu.Unlock()
+	return reader.History(g.namespaced(lockFilePath), options)
+}
+
+// History reads the history of the lock file on the current branch, or the lock branch
+func (b *fileBackend) History(lockFilePath string, options HistoryOptions) ([]LockEvent, error) {
+	rev, err := b.lockRevision()
+	if err != nil || rev == "" {
+		return nil, err
+	}
+	return lockHistory(b.repo, b.codec, rev, lockFilePath, options)
+}
+
+// History reads the history of the lock file on the lock branch
+func (b *BranchBackend) History(lockFilePath string, options HistoryOptions) ([]LockEvent, error) {
+	tip, err := b.fetch()
+	if err != nil {
+		return nil, err
+	}
+	if tip == "" {
+		return nil, nil
+	}
+	return lockHistory(b.repo, codecOrDefault(b.Codec), tip, lockFilePath, options)
+}
+
+// lockHistory parses the commits reachable from rev that touched lockFilePath,
+// decoding the lock file in each with codec
+func lockHistory(repo *gittools.Repo, codec Codec, rev string, lockFilePath string, options HistoryOptions) ([]LockEvent, error) {
+	lockFilePath = strings.Join(splitLockPath(lockFilePath), "/")
+
+	// Records are separated by \x1e and fields by \x1f, neither can appear in a commit message
+	args := []string{"log", "--reverse", "--format=%H%x1f%an <%ae>%x1f%ct%x1f%B%x1e"}
+	if !options.Since.IsZero() {
+		args = append(args, "--since="+options.Since.Format(time.RFC3339))
+	}
+	if options.Limit > 0 {
+		args = append(args, "-n", strconv.Itoa(options.Limit))
+	}
+	args = append(args, rev, "--", lockFilePath)
+
+	stdout, stderr, err := repo.Client.Exec(args...)
+	if err != nil {
+		return nil, fmt.Errorf("git log failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+
+	var events []LockEvent
+	for _, record := range strings.Split(string(stdout), "\x1e") {
+		record = strings.TrimLeft(record, "\n")
+		if record == "" {
+			continue
+		}
+
+		fields := strings.SplitN(record, "\x1f", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected git log output: %q", record)
+		}
+
+		seconds, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse commit time %q: %w", fields[2], err)
+		}
+
+		lock, err := readLockAt(repo, codec, fields[0], lockFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read lock at %s: %w", fields[0], err)
+		}
+
+		message := strings.TrimSpace(fields[3])
+		events = append(events, LockEvent{
+			Action:  actionFromMessage(message),
+			Commit:  fields[0],
+			Author:  fields[1],
+			Time:    time.Unix(seconds, 0).UTC(),
+			Message: message,
+			Lock:    lock,
+		})
+	}
+	return events, nil
+}
+
+// actionFromMessage determines the action from the subject of a lock commit
+func actionFromMessage(message string) LockAction {
+	verb, _, _ := strings.Cut(message, " ")
+	switch verb {
+	case "Acquire":
+		return ActionAcquire
+	case "Refresh":
+		return ActionRefresh
+	case "Release":
+		return ActionRelease
+	case "Break":
+		return ActionBreak
+	case "Steal":
+		return ActionSteal
+	default:
+		return ActionUnknown
+	}
+}
@@ -0,0 +1,87 @@
+package lock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestLockHistory(t *testing.T) {
+	backends := map[string]func(repo *gittools.Repo) Backend{
+		"file": NewFileBackend,
+		"default": func(repo *gittools.Repo) Backend {
+			return NewFileBackendWithOptions(repo, FileBackendOptions{Branch: DefaultLockBranch})
+		},
+		"branch": func(repo *gittools.Repo) Backend { return NewBranchBackend(repo, "") },
+	}
+
+	for name, newBackend := range backends {
+		t.Run(name, func(t *testing.T) {
+			gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+				_, remoteDir, cleanup := setupRemoteTestRepo(t)
+				defer cleanup()
+
+				repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+				defer cleanupRepo()
+
+				locking := NewRepoLocking(repo)
+				locking.Backend = newBackend(repo)
+
+				lockPath := "locks/history.lock"
+				if err := locking.AcquireLock(lockPath, time.Minute, "deploy"); err != nil {
+					t.Fatalf("Failed to acquire lock: %v", err)
+				}
+				if err := locking.RefreshLock(lockPath, time.Now().Add(time.Hour)); err != nil {
+					t.Fatalf("Failed to refresh lock: %v", err)
+				}
+				if err := locking.ReleaseLock(lockPath); err != nil {
+					t.Fatalf("Failed to release lock: %v", err)
+				}
+				if err := locking.BreakLock(lockPath, BreakOptions{Reason: "cleanup"}); err != nil {
+					t.Fatalf("Failed to break missing lock: %v", err)
+				}
+				if err := locking.Steal(lockPath, time.Minute, "takeover", BreakOptions{Reason: "stuck"}); err != nil {
+					t.Fatalf("Failed to steal lock: %v", err)
+				}
+
+				events, err := locking.History(lockPath, HistoryOptions{})
+				if err != nil {
+					t.Fatalf("Failed to read history: %v", err)
+				}
+
+				// Breaking a missing lock writes nothing
+				expected := []LockAction{ActionAcquire, ActionRefresh, ActionRelease, ActionSteal}
+				if len(events) != len(expected) {
+					t.Fatalf("Expected %d events, got %+v", len(expected), events)
+				}
+				for i, action := range expected {
+					if events[i].Action != action {
+						t.Errorf("Event %d: expected %s, got %s", i, action, events[i].Action)
+					}
+				}
+
+				if events[0].Lock == nil || events[0].Lock.Description != "deploy" || events[0].Lock.Owner != locking.LockKey {
+					t.Errorf("Expected acquire event to record the lock, got %+v", events[0].Lock)
+				}
+				if events[2].Lock != nil {
+					t.Errorf("Expected release event to have no lock, got %+v", events[2].Lock)
+				}
+				if events[3].Message != "Steal lock on "+lockPath+"\n\nReason: stuck" {
+					t.Errorf("Unexpected steal message: %q", events[3].Message)
+				}
+				if events[0].Author == "" || events[0].Time.IsZero() {
+					t.Errorf("Expected author and time to be set, got %+v", events[0])
+				}
+
+				events, err = locking.History(lockPath, HistoryOptions{Limit: 1})
+				if err != nil {
+					t.Fatalf("Failed to read limited history: %v", err)
+				}
+				if len(events) != 1 || events[0].Action != ActionSteal {
+					t.Errorf("Expected only the latest event, got %+v", events)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package lock
+
+import "time"
+
+// Hooks are callbacks invoked as locks move through their lifecycle.
+// Any hook may be nil. Hooks are called synchronously from the goroutine performing
+// the lock operation, so they should return quickly.
+type Hooks struct {
+	// OnAcquired is called after a lock has been acquired by this process
+	OnAcquired func(lockPath string, lock *Lock)
+
+	// OnRefreshed is called after a lock held by this process has been refreshed
+	OnRefreshed func(lockPath string, lock *Lock)
+
+	// OnExpiringSoon is called by KeepAlive when the lock will expire within
+	// ExpiringSoonThreshold and has not been refreshed, such as when refreshes keep
+	// failing or the refresh interval is longer than the threshold allows for.
+	// It is called once until the lock is next refreshed.
+	OnExpiringSoon func(lockPath string, expiresAt time.Time)
+
+	// ExpiringSoonThreshold is how close to expiry a lock must be for OnExpiringSoon to be called
+	ExpiringSoonThreshold time.Duration
+
+	// OnLost is called when a refresh discovers the lock is no longer held by this process.
+	// current is the lock as it is now held, or nil if it has been released or expired.
+	OnLost func(lockPath string, current *Lock)
+}
+
+func (h Hooks) acquired(lockPath string, lock *Lock) {
+	if h.OnAcquired != nil {
+		h.OnAcquired(lockPath, lock)
+	}
+}
+
+func (h Hooks) refreshed(lockPath string, lock *Lock) {
+	if h.OnRefreshed != nil {
+		h.OnRefreshed(lockPath, lock)
+	}
+}
+
+func (h Hooks) expiringSoon(lockPath string, expiresAt time.Time, now time.Time) {
+	if h.OnExpiringSoon != nil && !now.Add(h.ExpiringSoonThreshold).Before(expiresAt) {
+		h.OnExpiringSoon(lockPath, expiresAt)
+	}
+}
+
+func (h Hooks) lost(lockPath string, current *Lock) {
+	if h.OnLost != nil {
+		h.OnLost(lockPath, current)
+	}
+}
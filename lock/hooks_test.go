@@ -0,0 +1,149 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestLockHooks(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo1, cleanup1 := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanup1()
+		repo2, cleanup2 := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanup2()
+
+		var events []string
+		var lostTo *Lock
+
+		locking := NewRepoLocking(repo1)
+		locking.Backend = NewRefBackend(repo1)
+		locking.Hooks = Hooks{
+			OnAcquired:  func(lockPath string, lock *Lock) { events = append(events, "acquired") },
+			OnRefreshed: func(lockPath string, lock *Lock) { events = append(events, "refreshed") },
+			OnLost: func(lockPath string, current *Lock) {
+				events = append(events, "lost")
+				lostTo = current
+			},
+		}
+
+		other := NewRepoLocking(repo2)
+		other.Backend = NewRefBackend(repo2)
+
+		lockPath := "locks/hooks.lock"
+		if err := locking.AcquireLock(lockPath, time.Minute, "hooks"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+		if err := locking.RefreshLock(lockPath, time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("Failed to refresh lock: %v", err)
+		}
+
+		if err := other.Steal(lockPath, time.Minute, "takeover", BreakOptions{}); err != nil {
+			t.Fatalf("Failed to steal lock: %v", err)
+		}
+
+		err := locking.RefreshLock(lockPath, time.Now().Add(time.Hour))
+		if !errors.Is(err, ErrLockNotOwned) {
+			t.Fatalf("Expected ErrLockNotOwned, got %v", err)
+		}
+
+		expected := []string{"acquired", "refreshed", "lost"}
+		if len(events) != len(expected) {
+			t.Fatalf("Expected events %v, got %v", expected, events)
+		}
+		for i := range expected {
+			if events[i] != expected[i] {
+				t.Fatalf("Expected events %v, got %v", expected, events)
+			}
+		}
+		if lostTo == nil || lostTo.Owner != other.LockKey {
+			t.Errorf("Expected lock to be lost to %s, got %+v", other.LockKey, lostTo)
+		}
+	})
+}
+
+func TestHooksExpiringSoon(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var called bool
+	hooks := Hooks{
+		OnExpiringSoon:        func(lockPath string, expiresAt time.Time) { called = true },
+		ExpiringSoonThreshold: time.Minute,
+	}
+
+	hooks.expiringSoon("lock", now.Add(2*time.Minute), now)
+	if called {
+		t.Fatal("Expected OnExpiringSoon not to be called outside the threshold")
+	}
+
+	hooks.expiringSoon("lock", now.Add(30*time.Second), now)
+	if !called {
+		t.Fatal("Expected OnExpiringSoon to be called within the threshold")
+	}
+}
+
+func TestKeepAliveExpiringSoon(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+
+		type warning struct {
+			lockPath  string
+			expiresAt time.Time
+		}
+		warnings := make(chan warning, 2)
+
+		locking := NewRepoLocking(repo)
+		locking.Backend = NewRefBackend(repo)
+		locking.Hooks = Hooks{
+			OnExpiringSoon: func(lockPath string, expiresAt time.Time) {
+				warnings <- warning{lockPath, expiresAt}
+			},
+			ExpiringSoonThreshold: 1500 * time.Millisecond,
+		}
+
+		lockPath := "locks/expiring.lock"
+		if err := locking.AcquireLock(lockPath, 2*time.Second, "expiring"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+		lock, err := locking.ReadLock(lockPath)
+		if err != nil {
+			t.Fatalf("Failed to read lock: %v", err)
+		}
+
+		// The lock is never refreshed, so the warning comes from the schedule alone
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		if _, err := locking.KeepAlive(ctx, lockPath, time.Hour); err != nil {
+			t.Fatalf("Failed to start keep-alive: %v", err)
+		}
+
+		select {
+		case w := <-warnings:
+			if w.lockPath != lockPath || !w.expiresAt.Equal(lock.ExpiresAt) {
+				t.Errorf("Expected a warning for %s expiring at %v, got %+v", lockPath, lock.ExpiresAt, w)
+			}
+			if early := time.Until(w.expiresAt.Add(-locking.Hooks.ExpiringSoonThreshold)); early > 100*time.Millisecond {
+				t.Errorf("Expected the warning once the lock was within the threshold, it came %v early", early)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("Timed out waiting for OnExpiringSoon")
+		}
+
+		// The warning is only given once for each expiry
+		select {
+		case w := <-warnings:
+			t.Errorf("Expected a single warning, got another %+v", w)
+		case <-time.After(time.Second):
+		}
+	})
+}
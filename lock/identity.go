@@ -0,0 +1,42 @@
+package lock
+
+import (
+	"os"
+	"os/user"
+)
+
+// Identity describes the process holding a lock, so operators know who to
+// contact when a lock is stuck
+type Identity struct {
+	Hostname string            `json:"hostname,omitempty"`
+	PID      int               `json:"pid,omitempty"`
+	Username string            `json:"username,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// CurrentIdentity returns the identity of the current process.
+// Fields that cannot be determined are left empty.
+func CurrentIdentity() Identity {
+	identity := Identity{
+		PID: os.Getpid(),
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		identity.Hostname = hostname
+	}
+	if u, err := user.Current(); err == nil {
+		identity.Username = u.Username
+	}
+	return identity
+}
+
+// clone returns a copy of the identity that does not share its Metadata map
+func (i Identity) clone() Identity {
+	if i.Metadata != nil {
+		metadata := make(map[string]string, len(i.Metadata))
+		for k, v := range i.Metadata {
+			metadata[k] = v
+		}
+		i.Metadata = metadata
+	}
+	return i
+}
@@ -0,0 +1,52 @@
+package lock
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestLockIdentity(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo1, cleanup1 := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanup1()
+		repo2, cleanup2 := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanup2()
+
+		locking := NewRepoLockingWithKey(repo1, "deployer-1")
+		locking.Backend = NewRefBackend(repo1)
+		locking.Identity.Metadata = map[string]string{"job": "deploy-42"}
+
+		lockPath := "locks/identity.lock"
+		if err := locking.AcquireLock(lockPath, time.Minute, "deploy"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+
+		// A restarted process with the same key can read and reclaim its lock
+		restarted := NewRepoLockingWithKey(repo2, "deployer-1")
+		restarted.Backend = NewRefBackend(repo2)
+
+		lock, err := restarted.ReadLock(lockPath)
+		if err != nil {
+			t.Fatalf("Failed to read lock: %v", err)
+		}
+		if lock.PID != os.Getpid() {
+			t.Errorf("Expected PID %d, got %d", os.Getpid(), lock.PID)
+		}
+		if hostname, _ := os.Hostname(); lock.Hostname != hostname {
+			t.Errorf("Expected hostname %q, got %q", hostname, lock.Hostname)
+		}
+		if lock.Metadata["job"] != "deploy-42" {
+			t.Errorf("Expected metadata to be recorded, got %v", lock.Metadata)
+		}
+
+		if err := restarted.ReleaseLock(lockPath); err != nil {
+			t.Fatalf("Failed to release lock with stable key: %v", err)
+		}
+	})
+}
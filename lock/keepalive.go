@@ -0,0 +1,176 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// KeepAlive starts a goroutine that refreshes the lock at lockPath every interval,
+// extending its expiry by the duration it was originally acquired for.
+// If interval is zero, it is set from the lock's lifetime by the Renewal policy.
+// Every wait is randomized by the Renewal policy's Jitter, if set.
+// If HeartbeatInterval is shorter than interval, a heartbeat is also sent every
+// HeartbeatInterval between refreshes.
+// If Hooks.OnExpiringSoon is set, it is called once the lock is within
+// Hooks.ExpiringSoonThreshold of expiring without having been refreshed.
+// The goroutine stops when ctx is cancelled, which also abandons a refresh in progress,
+// when the lock is released through this Locking, or when a refresh discovers the lock
+// is no longer owned by this process.
+//
+// Refresh failures are reported on the returned channel, which is closed when the
+// keep-alive stops. Callers should drain the channel, a pending failure blocks
+// further refreshes until it is received or ctx is cancelled.
+func (g *Locking) KeepAlive(ctx context.Context, lockPath string, interval time.Duration) (<-chan error, error) {
+	lock, err := g.ReadLock(lockPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock: %w", err)
+	}
+	if owns, _ := g.OwnsLock(lock); !owns {
+		return nil, fmt.Errorf("cannot keep alive lock that is not owned by this process: %s", lockPath)
+	}
+	ttl := lock.ExpiresAt.Sub(lock.CreatedAt)
+	expiresAt := lock.ExpiresAt
+	if holder := lock.holder(g.LockKey); holder != nil {
+		ttl = holder.ExpiresAt.Sub(holder.CreatedAt)
+		expiresAt = holder.ExpiresAt
+	}
+	if interval == 0 {
+		interval = g.Renewal.Interval(ttl)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("cannot keep alive lock with a renewal interval of %v: %s", interval, lockPath)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	ka := &keepAlive{cancel: cancel}
+	g.setKeepAlive(lockPath, ka)
+
+	// Refreshes are abandoned once the keep-alive stops, so they can't hold up a release
+	backend := withContext(ctx, g.Backend)
+
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer g.removeKeepAlive(lockPath, ka)
+
+		tick := interval
+		if g.HeartbeatInterval > 0 && g.HeartbeatInterval < interval {
+			tick = g.HeartbeatInterval
+		}
+
+		nextRefresh := time.Now().Add(g.Renewal.jittered(interval))
+		warned := false
+		for {
+			// Send heartbeats until the refresh is due
+			wait := time.Until(nextRefresh)
+			heartbeat := false
+			if beat := g.Renewal.jittered(tick); tick < interval && beat < wait {
+				wait = beat
+				heartbeat = true
+			}
+
+			// Warn once if the lock comes within ExpiringSoonThreshold of expiring before it is refreshed
+			warn := false
+			if g.Hooks.OnExpiringSoon != nil && !warned {
+				if until := expiresAt.Add(-g.Hooks.ExpiringSoonThreshold).Sub(g.now()); until < wait {
+					wait = until
+					warn = true
+				}
+			}
+
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if warn {
+				warned = true
+				g.Hooks.expiringSoon(lockPath, expiresAt, g.now())
+				continue
+			}
+
+			var err error
+			if heartbeat {
+				err = g.heartbeat(backend, lockPath)
+			} else {
+				next := g.now().Add(ttl)
+				if err = g.refresh(backend, lockPath, next); err == nil {
+					expiresAt = next
+					warned = false
+					nextRefresh = time.Now().Add(g.Renewal.jittered(interval))
+				}
+			}
+			if err == nil {
+				continue
+			}
+			if ctx.Err() != nil {
+				// The update was abandoned because the keep-alive stopped
+				return
+			}
+
+			if !heartbeat {
+				// Retry the refresh on the next tick
+				nextRefresh = time.Now().Add(g.Renewal.jittered(tick))
+			}
+
+			select {
+			case errs <- err:
+			case <-ctx.Done():
+				return
+			}
+
+			// Stop refreshing once the lock has been lost
+			if errors.Is(err, ErrLockNotOwned) {
+				return
+			}
+		}
+	}()
+
+	return errs, nil
+}
+
+// keepAlive tracks a running keep-alive goroutine
+type keepAlive struct {
+	cancel context.CancelFunc
+}
+
+// setKeepAlive registers a keep-alive for lockPath, stopping any existing one
+func (g *Locking) setKeepAlive(lockPath string, ka *keepAlive) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.keepAlives == nil {
+		g.keepAlives = make(map[string]*keepAlive)
+	}
+	if existing, ok := g.keepAlives[lockPath]; ok {
+		existing.cancel()
+	}
+	g.keepAlives[lockPath] = ka
+}
+
+// removeKeepAlive stops ka and unregisters it if it is still the keep-alive for lockPath
+func (g *Locking) removeKeepAlive(lockPath string, ka *keepAlive) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ka.cancel()
+	if g.keepAlives[lockPath] == ka {
+		delete(g.keepAlives, lockPath)
+	}
+}
+
+// stopKeepAlive stops the keep-alive for lockPath, if one is running
+func (g *Locking) stopKeepAlive(lockPath string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if ka, ok := g.keepAlives[lockPath]; ok {
+		ka.cancel()
+		delete(g.keepAlives, lockPath)
+	}
+}
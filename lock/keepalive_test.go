@@ -0,0 +1,83 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestKeepAlive(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+
+		locking := NewRepoLocking(repo)
+		locking.Backend = NewRefBackend(repo)
+
+		lockPath := "locks/keepalive.lock"
+		if err := locking.AcquireLock(lockPath, time.Minute, "Long running job"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+
+		original, err := locking.ReadLock(lockPath)
+		if err != nil {
+			t.Fatalf("Failed to read lock: %v", err)
+		}
+
+		errs, err := locking.KeepAlive(context.Background(), lockPath, 50*time.Millisecond)
+		if err != nil {
+			t.Fatalf("Failed to start keep-alive: %v", err)
+		}
+
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			lock, err := locking.ReadLock(lockPath)
+			if err != nil {
+				t.Fatalf("Failed to read lock: %v", err)
+			}
+			if lock.ExpiresAt.After(original.ExpiresAt) {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("Timed out waiting for the lock to be refreshed")
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+
+		// Releasing the lock stops the keep-alive and closes the channel
+		if err := locking.ReleaseLock(lockPath); err != nil {
+			t.Fatalf("Failed to release lock: %v", err)
+		}
+
+		select {
+		case _, ok := <-errs:
+			for ok {
+				_, ok = <-errs
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("Timed out waiting for keep-alive to stop")
+		}
+	})
+}
+
+func TestKeepAliveRequiresOwnership(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+
+		locking := NewRepoLocking(repo)
+		locking.Backend = NewRefBackend(repo)
+
+		if _, err := locking.KeepAlive(context.Background(), "locks/missing.lock", time.Second); err == nil {
+			t.Fatal("Expected keep-alive of an unheld lock to fail")
+		}
+	})
+}
@@ -0,0 +1,207 @@
+package lock
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ocuroot/gittools"
+)
+
+// Lister is implemented by backends that can enumerate the locks they store
+type Lister interface {
+	// List returns every lock stored under dir, keyed by lock path.
+	// An empty dir lists all locks. Expired locks are returned as-is.
+	List(dir string) (map[string]*Lock, error)
+}
+
+// LockInfo describes a single lock returned by ListLocks
+type LockInfo struct {
+	// Path of the lock, as passed to AcquireLock
+	Path string
+
+	// Lock as stored, including any expired holders
+	Lock *Lock
+
+	// Expired is true if the lock, or every holder of a shared lock, has expired
+	Expired bool
+}
+
+// ListLocks returns all locks stored under dir, sorted by path.
+// Every file under dir is expected to be a lock file.
+// Unlike ReadLock, expired locks are included with Expired set so that stale locks can be found and broken.
+func (g *Locking) ListLocks(dir string) ([]LockInfo, error) {
+	lister, ok := g.Backend.(Lister)
+	if !ok {
+		return nil, fmt.Errorf("lock backend %T does not support listing locks", g.Backend)
+	}
+
+	locks, err := g.list(lister, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]LockInfo, 0, len(locks))
+	for lockPath, lock := range locks {
+		infos = append(infos, LockInfo{
+			Path:    lockPath,
+			Lock:    lock,
+			Expired: g.unexpired(lock) == nil,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Path < infos[j].Path
+	})
+	return infos, nil
+}
+
+// list lists the locks under dir in Namespace, keyed by their path within it,
+// serialized with other operations on this Locking
+func (g *Locking) list(lister Lister, dir string) (map[string]*Lock, error) {
+	g.opMu.Lock()
+	backendLocks, err := lister.List(g.namespaced(dir))
+	g.opMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	locks := make(map[string]*Lock, len(backendLocks))
+	for backendPath, lock := range backendLocks {
+		locks[g.unnamespaced(backendPath)] = lock
+	}
+	return locks, nil
+}
+
+// List lists lock files under dir in the commit at HEAD, or at the tip of the lock branch
+func (b *fileBackend) List(dir string) (map[string]*Lock, error) {
+	rev, err := b.lockRevision()
+	if err != nil || rev == "" {
+		return nil, err
+	}
+	paths, err := listTreeFiles(b.repo, rev, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lock files: %w", err)
+	}
+
+	locks := make(map[string]*Lock, len(paths))
+	for _, lockPath := range paths {
+		lock, err := readLockAt(b.repo, b.codec, rev, lockPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", lockPath, err)
+		}
+		if lock != nil {
+			locks[lockPath] = lock
+		}
+	}
+	return locks, nil
+}
+
+// List lists lock files under dir at the tip of the lock branch on the remote
+func (b *BranchBackend) List(dir string) (map[string]*Lock, error) {
+	tip, err := b.fetch()
+	if err != nil {
+		return nil, err
+	}
+	if tip == "" {
+		return map[string]*Lock{}, nil
+	}
+
+	paths, err := listTreeFiles(b.repo, tip, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lock files: %w", err)
+	}
+
+	locks := make(map[string]*Lock, len(paths))
+	for _, lockPath := range paths {
+		lock, err := b.readAt(tip, lockPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", lockPath, err)
+		}
+		if lock != nil {
+			locks[lockPath] = lock
+		}
+	}
+	return locks, nil
+}
+
+// List lists lock refs under dir on the remote
+func (b *refBackend) List(dir string) (map[string]*Lock, error) {
+	prefix := RefLockPrefix
+	if dir != "" && dir != "." {
+		if err := checkRefLockName(dir); err != nil {
+			return nil, err
+		}
+		prefix = RefLockName(dir) + "/"
+	}
+
+	refs, err := b.repo.LsRemote("origin", prefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote lock refs: %w", err)
+	}
+
+	locks := make(map[string]*Lock, len(refs))
+	if len(refs) == 0 {
+		return locks, nil
+	}
+
+	if err := b.repo.Fetch("origin", gittools.FetchOptions{Refspecs: []string{"+" + prefix + "*:" + prefix + "*"}}); err != nil {
+		return nil, fmt.Errorf("failed to fetch lock refs: %w", err)
+	}
+
+	for ref, commit := range refs {
+		// ls-remote patterns only match the end of a ref name
+		if !strings.HasPrefix(ref, prefix) {
+			continue
+		}
+		lock, err := b.readCommit(commit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", ref, err)
+		}
+		locks[refLockPath(ref)] = lock
+	}
+	return locks, nil
+}
+
+// listTreeFiles returns the paths of all files under dir in treeish.
+// Returns an empty list if dir does not exist.
+func listTreeFiles(repo *gittools.Repo, treeish string, dir string) ([]string, error) {
+	dir = strings.Join(splitLockPath(dir), "/")
+	object := treeish + "^{tree}"
+	if dir != "." {
+		object = treeish + ":" + dir
+	}
+
+	exists, _, err := repo.CatFile(gittools.CatFileOptions{Exists: true, ObjectID: object})
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	entries, err := repo.LsTree(object)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		entryPath := entry.Name
+		if dir != "." {
+			entryPath = path.Join(dir, entry.Name)
+		}
+
+		switch entry.Type {
+		case "blob":
+			files = append(files, entryPath)
+		case "tree":
+			children, err := listTreeFiles(repo, treeish, entryPath)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, children...)
+		}
+	}
+	return files, nil
+}
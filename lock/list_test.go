@@ -0,0 +1,66 @@
+package lock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestListLocks(t *testing.T) {
+	backends := map[string]func(repo *gittools.Repo) Backend{
+		"file": NewFileBackend,
+		"default": func(repo *gittools.Repo) Backend {
+			return NewFileBackendWithOptions(repo, FileBackendOptions{Branch: DefaultLockBranch})
+		},
+		"ref":    NewRefBackend,
+		"branch": func(repo *gittools.Repo) Backend { return NewBranchBackend(repo, "") },
+	}
+
+	for name, newBackend := range backends {
+		t.Run(name, func(t *testing.T) {
+			gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+				_, remoteDir, cleanup := setupRemoteTestRepo(t)
+				defer cleanup()
+
+				repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+				defer cleanupRepo()
+
+				locking := NewRepoLocking(repo)
+				locking.Backend = newBackend(repo)
+
+				if err := locking.AcquireLock("locks/a.lock", time.Minute, "a"); err != nil {
+					t.Fatalf("Failed to acquire lock: %v", err)
+				}
+				if err := locking.AcquireLock("locks/nested/b.lock", -time.Minute, "b"); err != nil {
+					t.Fatalf("Failed to acquire lock: %v", err)
+				}
+				if err := locking.AcquireLock("other/c.lock", time.Minute, "c"); err != nil {
+					t.Fatalf("Failed to acquire lock: %v", err)
+				}
+
+				infos, err := locking.ListLocks("locks")
+				if err != nil {
+					t.Fatalf("Failed to list locks: %v", err)
+				}
+				if len(infos) != 2 {
+					t.Fatalf("Expected 2 locks, got %+v", infos)
+				}
+				if infos[0].Path != "locks/a.lock" || infos[0].Expired || infos[0].Lock.Description != "a" {
+					t.Errorf("Unexpected first lock: %+v", infos[0])
+				}
+				if infos[1].Path != "locks/nested/b.lock" || !infos[1].Expired {
+					t.Errorf("Unexpected second lock: %+v", infos[1])
+				}
+
+				infos, err = locking.ListLocks("missing")
+				if err != nil {
+					t.Fatalf("Failed to list missing directory: %v", err)
+				}
+				if len(infos) != 0 {
+					t.Errorf("Expected no locks, got %+v", infos)
+				}
+			})
+		})
+	}
+}
@@ -1,11 +1,9 @@
 package lock
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/ocuroot/gittools"
@@ -14,16 +12,99 @@ import (
 
 // Lock represents a lock on a resource
 type Lock struct {
+	// Version is the format the lock was read in. Locks are always written in LockVersion.
+	Version int `json:"version,omitempty"`
+
 	Owner       string    `json:"owner"` // ULID of the process holding the lock
 	CreatedAt   time.Time `json:"created_at"`
 	ExpiresAt   time.Time `json:"expires_at"`
 	Description string    `json:"description,omitempty"`
+	Identity
+
+	// Holders lists the processes sharing the lock when it is held for reading
+	// or as a semaphore. Owner is empty for a shared lock.
+	Holders []Holder `json:"holders,omitempty"`
+
+	// Capacity is the maximum number of holders when the lock is used as a semaphore
+	Capacity int `json:"capacity,omitempty"`
+
+	// Heartbeat is the last sign of life from the owner, if it sends heartbeats
+	Heartbeat *Heartbeat `json:"heartbeat,omitempty"`
+
+	// ClockOffset is how far the owner's clock was ahead of true time when it last
+	// set ExpiresAt, see Locking.ClockOffset
+	ClockOffset time.Duration `json:"clock_offset,omitempty"`
+}
+
+// Holder records one of the processes sharing a lock
+type Holder struct {
+	Owner       string    `json:"owner"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Description string    `json:"description,omitempty"`
+	Identity
+
+	// Heartbeat is the last sign of life from the holder, if it sends heartbeats
+	Heartbeat *Heartbeat `json:"heartbeat,omitempty"`
+
+	// ClockOffset is how far the holder's clock was ahead of true time when it last
+	// set ExpiresAt, see Locking.ClockOffset
+	ClockOffset time.Duration `json:"clock_offset,omitempty"`
+}
+
+// Shared returns true if the lock is held by one or more readers rather than a single owner
+func (l *Lock) Shared() bool {
+	return len(l.Holders) > 0
+}
+
+// holder returns the holder entry for owner, or nil if owner does not share the lock
+func (l *Lock) holder(owner string) *Holder {
+	for i := range l.Holders {
+		if l.Holders[i].Owner == owner {
+			return &l.Holders[i]
+		}
+	}
+	return nil
+}
+
+// removeHolder removes owner from the holders of the lock
+func (l *Lock) removeHolder(owner string) {
+	var holders []Holder
+	for _, h := range l.Holders {
+		if h.Owner != owner {
+			holders = append(holders, h)
+		}
+	}
+	l.Holders = holders
+	l.updateExpiry()
+}
+
+// updateExpiry sets the expiry of a shared lock to that of its longest lived holder
+func (l *Lock) updateExpiry() {
+	if !l.Shared() {
+		return
+	}
+	l.ExpiresAt = l.Holders[0].ExpiresAt
+	for _, h := range l.Holders[1:] {
+		if h.ExpiresAt.After(l.ExpiresAt) {
+			l.ExpiresAt = h.ExpiresAt
+		}
+	}
 }
 
 func NewRepoLocking(repo *gittools.Repo) *Locking {
+	return NewRepoLockingWithKey(repo, ulid.Make().String())
+}
+
+// NewRepoLockingWithKey creates a Locking that identifies itself with a stable lock key
+// rather than a random ULID, so a restarted process can reclaim, refresh or release
+// the locks it held before restarting.
+func NewRepoLockingWithKey(repo *gittools.Repo, lockKey string) *Locking {
 	return &Locking{
-		repo:    repo,
-		LockKey: ulid.Make().String(),
+		repo:     repo,
+		Backend:  NewFileBackendWithOptions(repo, FileBackendOptions{Branch: DefaultLockBranch}),
+		LockKey:  lockKey,
+		Identity: CurrentIdentity(),
 		now: func() time.Time {
 			return time.Now()
 		},
@@ -31,331 +112,388 @@ func NewRepoLocking(repo *gittools.Repo) *Locking {
 }
 
 type Locking struct {
-	repo    *gittools.Repo
-	LockKey string // ULID for identifying this process
-	now     func() time.Time
+	repo     *gittools.Repo
+	Backend  Backend  // Storage for lock state, defaults to files committed to DefaultLockBranch
+	LockKey  string   // ULID for identifying this process
+	Identity Identity // Recorded in every lock acquired by this process
+	Hooks    Hooks    // Lifecycle callbacks, also invoked for locks held through a Manager
+	Metrics  Metrics  // Optional receiver for lock activity metrics
+
+	// HeartbeatInterval, if set, records a heartbeat in every lock acquired by this
+	// process. KeepAlive renews it at this interval so other processes can tell a
+	// crashed holder from a long running one with IsHolderAlive.
+	HeartbeatInterval time.Duration
+
+	// Renewal sets how often KeepAlive refreshes locks when no interval is given,
+	// and how much each refresh is jittered
+	Renewal RenewalPolicy
+
+	// ClockSkew is how long locks are still treated as held after they expire, to allow
+	// for the clocks of other machines disagreeing with this one. It applies to every
+	// expiry decision, including taking over a lock and reporting it in ReadLock.
+	ClockSkew time.Duration
+
+	// ClockOffset is how far this machine's clock is known to be ahead of true time,
+	// negative if it is behind, such as reported by NTP. It is recorded in every lock
+	// this process acquires so that others can correct its timestamps, and is used to
+	// correct the timestamps recorded by others.
+	ClockOffset time.Duration
+
+	// Hierarchical treats lock paths as nested scopes. A lock such as locks/env/prod.lock
+	// also covers every lock under locks/env/prod/, so it can't be acquired while one of
+	// those is held by another process, and they can't be acquired while it is held.
+	// Only read locks may be held on a parent and a child at the same time.
+	// Children are found by listing the lock directory, so the Backend must be a Lister.
+	Hierarchical bool
+
+	// Namespace, if set, is prefixed to every lock path passed to this Locking, so that
+	// consumers sharing a repository can't collide. Its components are escaped as in PathFor.
+	Namespace string
+
+	now func() time.Time
+
+	mu         sync.Mutex
+	keepAlives map[string]*keepAlive
+	held       map[string]Backend // Locks held by this process, for ReleaseAll
+
+	statsMu sync.Mutex
+	stats   map[string]*ContentionStats
+
+	// opMu serializes backend operations, which share a single repository
+	opMu sync.Mutex
 }
 
-// AcquireLock attempts to acquire a lock on the specified lockFilePath
-// It will return ErrLockConflict if the lock is already held by another process
-// The timeout parameter is kept for API compatibility but no longer used for retries
+// AcquireLock attempts to acquire a lock on the specified lockFilePath.
+// It is equivalent to TryAcquire: if the lock is already held by another process a
+// *LockConflictError is returned, which matches ErrLockConflict with errors.Is.
 // expiryDuration specifies how long the lock should be valid for
 func (g *Locking) AcquireLock(lockFilePath string, expiryDuration time.Duration, description string) error {
-	currentBranch, err := g.repo.CurrentBranch()
-	if err != nil {
-		return fmt.Errorf("failed to get current branch: %w", err)
-	}
+	return g.TryAcquire(lockFilePath, expiryDuration, description)
+}
 
-	// Make sure we have latest changes
-	// Silently continue if fetch fails (e.g., during tests)
-	err = g.repo.Pull("origin", currentBranch)
-	if err != nil {
-		return fmt.Errorf("failed to pull latest changes: %w", err)
-	}
+// TryAcquire makes a single attempt to acquire the lock at lockFilePath, without waiting.
+// If the lock is held by another process, a *LockConflictError describing the holder is
+// returned, which matches ErrLockConflict with errors.Is. Use Acquire to wait for the lock.
+func (g *Locking) TryAcquire(lockFilePath string, expiryDuration time.Duration, description string) error {
+	return g.acquire(g.Backend, lockFilePath, expiryDuration, description)
+}
 
-	// Check if lock already exists
-	existingLock, err := g.ReadLock(lockFilePath)
-	if err != nil {
-		return fmt.Errorf("failed to check lock status: %w", err)
-	}
+func (g *Locking) acquire(backend Backend, lockFilePath string, expiryDuration time.Duration, description string) error {
+	start := time.Now()
+	var acquired, previous *Lock
+	err := g.checkScope(backend, lockFilePath, false)
+	if err == nil {
+		err = g.update(backend, lockFilePath, fmt.Sprintf("Acquire lock on %s", lockFilePath), func(current *Lock) (*Lock, error) {
+			previous = current
+			existingLock := g.unexpired(current)
+
+			// If locked by someone else, return error
+			if existingLock != nil && !g.holdsAlone(existingLock) {
+				return nil, &LockConflictError{Path: lockFilePath, Holder: existingLock}
+			}
 
-	// Check if we own the lock
-	ownsLock, err := g.OwnsLock(existingLock)
-	if err != nil {
-		return fmt.Errorf("failed to check lock ownership: %w", err)
+			acquired = g.newLock(expiryDuration, description)
+			return acquired, nil
+		})
 	}
-
-	// If locked by someone else, return error
-	if existingLock != nil && !ownsLock {
-		return ErrLockConflict
+	if err == nil {
+		err = g.confirmScope(backend, lockFilePath, false, previous)
 	}
-
-	// Create lock file directory if it doesn't exist
-	lockDir := filepath.Dir(lockFilePath)
-	if err := os.MkdirAll(filepath.Join(g.repo.RepoPath, lockDir), 0755); err != nil {
-		return fmt.Errorf("failed to create lock directory: %w", err)
+	if err != nil {
+		g.observeWait(lockFilePath, start)
+		return conflictError(lockFilePath, err)
 	}
 
-	// Create the lock object
-	lock := &Lock{
+	g.acquired(lockFilePath, acquired, start)
+	return nil
+}
+
+// newLock creates an exclusive lock owned by this process
+func (g *Locking) newLock(expiryDuration time.Duration, description string) *Lock {
+	return &Lock{
 		Owner:       g.LockKey,
 		CreatedAt:   g.now(),
 		ExpiresAt:   g.now().Add(expiryDuration),
 		Description: description,
+		Identity:    g.Identity.clone(),
+		Heartbeat:   g.newHeartbeat(),
+		ClockOffset: g.ClockOffset,
 	}
+}
 
-	// Write the lock file
-	lockContent, err := json.Marshal(lock)
-	if err != nil {
-		return fmt.Errorf("failed to marshal lock: %w", err)
-	}
-
-	fullLockPath := filepath.Join(g.repo.RepoPath, lockFilePath)
-	if err := os.WriteFile(fullLockPath, lockContent, 0644); err != nil {
-		return fmt.Errorf("failed to write lock file: %w", err)
-	}
-
-	// Commit and push the lock file
-	// We need to use the relative path for the commit (not the full path which might be outside the repo)
-	// This ensures files are only committed within the repository's directory structure
-	if err := g.repo.Commit(fmt.Sprintf("Acquire lock on %s", lockFilePath), []string{lockFilePath}); err != nil {
-		// Remove the lock file
-		_ = os.Remove(fullLockPath)
-		return fmt.Errorf("failed to commit lock file: %w", err)
-	}
-
-	// Try to push the lock with retries for conflicts
-	pushErr := g.pushWithRetry(currentBranch)
-
-	if pushErr != nil {
-		// If push failed, clean up by removing the lock file and reset
-		_ = os.Remove(fullLockPath)
-		_ = g.repo.ResetHard("HEAD~1")
-
-		// Convert the push error to an appropriate lock error
-		switch {
-		case errors.Is(pushErr, gittools.ErrPushNonFastForward), errors.Is(pushErr, gittools.ErrPushRejected):
-			// These errors typically mean someone else has pushed changes
-			return fmt.Errorf("%w: %v", ErrLockConflict, pushErr)
-		case errors.Is(pushErr, gittools.ErrRebaseMergeConflict):
-			// Rebase merge conflict means lock contention
-			return fmt.Errorf("%w: %v", ErrLockConflict, pushErr)
-		case errors.Is(pushErr, gittools.ErrPushPermissionDenied):
-			return fmt.Errorf("lock acquisition failed due to permission issues: %w", pushErr)
-		case errors.Is(pushErr, gittools.ErrPushRemoteRefMissing):
-			return fmt.Errorf("lock acquisition failed due to missing remote reference: %w", pushErr)
-		default:
-			// For any other errors, return a lock conflict
-			return fmt.Errorf("%w: %v", ErrLockConflict, pushErr)
-		}
+// newHolder creates an entry for this process in a shared lock
+func (g *Locking) newHolder(expiryDuration time.Duration, description string) Holder {
+	return Holder{
+		Owner:       g.LockKey,
+		CreatedAt:   g.now(),
+		ExpiresAt:   g.now().Add(expiryDuration),
+		Description: description,
+		Identity:    g.Identity.clone(),
+		Heartbeat:   g.newHeartbeat(),
+		ClockOffset: g.ClockOffset,
 	}
-
-	return nil
 }
 
-// ReleaseLock releases a lock by deleting the lock file
+// ReleaseLock releases a lock by deleting the lock file.
+// If the lock is shared, only this process is removed from its holders.
+// If the lock is not held by this process, an error wrapping ErrLockNotOwned is returned.
 func (g *Locking) ReleaseLock(lockFilePath string) error {
-	// Get current branch to restore later
-	currentBranch, err := g.repo.CurrentBranch()
-	if err != nil {
-		return fmt.Errorf("failed to get current branch: %w", err)
-	}
-
-	if err := g.repo.Pull("origin", currentBranch); err != nil {
-		return fmt.Errorf("failed to pull latest changes: %w", err)
-	}
+	return g.release(g.Backend, lockFilePath)
+}
 
-	lock, err := g.ReadLock(lockFilePath)
-	if err != nil {
-		return fmt.Errorf("failed to check lock: %w", err)
-	}
+func (g *Locking) release(backend Backend, lockFilePath string) error {
+	g.stopKeepAlive(lockFilePath)
 
-	// Check if we're the owner of the lock
-	ownsLock, err := g.OwnsLock(lock)
+	err := g.update(backend, lockFilePath, fmt.Sprintf("Release lock for %s", lockFilePath), func(current *Lock) (*Lock, error) {
+		return g.releasedLock(lockFilePath, current)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to check lock ownership: %w", err)
-	}
-	if !ownsLock {
-		lock, err := g.ReadLock(lockFilePath)
-		if err != nil {
-			return fmt.Errorf("failed to check lock: %w", err)
-		}
-		return fmt.Errorf("cannot release lock that is not owned by this process: lock owner %s", lock.Owner)
+		return fmt.Errorf("failed to release lock: %w", err)
 	}
 
-	// Make sure we have latest changes
-	if err := g.repo.Fetch("origin", gittools.FetchOptions{}); err != nil {
-		return fmt.Errorf("failed to fetch latest changes: %w", err)
-	}
+	g.incCounter(MetricReleases, lockFilePath)
+	return nil
+}
 
-	// Pull latest changes
-	if err := g.repo.Pull("origin", currentBranch); err != nil {
-		return fmt.Errorf("failed to pull latest changes: %w", err)
+// releasedLock returns the lock to store at lockFilePath once this process has released
+// current, or an error if this process does not hold it
+func (g *Locking) releasedLock(lockFilePath string, current *Lock) (*Lock, error) {
+	lock := g.unexpired(current)
+	if lock == nil {
+		return nil, fmt.Errorf("cannot release lock that is not held: %s: %w", lockFilePath, ErrLockNotOwned)
 	}
 
-	// Delete the lock file
-	lockFileFull := filepath.Join(g.repo.RepoPath, lockFilePath)
-	if err := os.Remove(lockFileFull); err != nil {
-		return fmt.Errorf("failed to remove lock file: %w", err)
+	if lock.Shared() {
+		if lock.holder(g.LockKey) == nil {
+			return nil, fmt.Errorf("cannot release shared lock: %w", ErrLockNotOwned)
+		}
+		lock.removeHolder(g.LockKey)
+		if lock.Shared() {
+			return lock, nil
+		}
+		return nil, nil
 	}
 
-	// Commit the change - this was missing and causing issues
-	if err := g.repo.Commit(fmt.Sprintf("Release lock for %s", lockFilePath), []string{lockFilePath}); err != nil {
-		return fmt.Errorf("failed to commit lock file removal: %w", err)
+	// Check if we're the owner of the lock
+	if lock.Owner != g.LockKey {
+		return nil, fmt.Errorf("cannot release lock held by %s: %w", lock.Owner, ErrLockNotOwned)
 	}
 
-	// Push the branch with retries
-	pushErr := g.pushWithRetry(currentBranch)
-
-	if pushErr != nil {
-		// If push failed, revert our change by restoring the lock file and reset
-		if err := g.repo.ResetHard("HEAD~1"); err != nil {
-			fmt.Printf("Warning: failed to reset after push error: %v\n", err)
-		}
-
-		// Return a descriptive error
-		return fmt.Errorf("failed to push lock release: %w", pushErr)
-	}
+	return nil, nil
+}
 
-	return nil
+// RefreshLock refreshes a lock by updating its expiry time
+// If the lock is no longer held by this process, an error wrapping ErrLockNotOwned is returned.
+func (g *Locking) RefreshLock(lockFilePath string, expirationTime time.Time) error {
+	return g.refresh(g.Backend, lockFilePath, expirationTime)
 }
 
-// pushWithRetry attempts to push to origin with retry logic using Git rebase
-// for handling non-fast-forward conflicts
-func (g *Locking) pushWithRetry(branch string) error {
-	const maxRetries = 2
-	var lastErr error
-
-	for retry := 0; retry <= maxRetries; retry++ {
-		// Try to push
-		lastErr = g.repo.Push("origin", branch)
-		if lastErr == nil {
-			// Push succeeded
-			return nil
+func (g *Locking) refresh(backend Backend, lockFilePath string, expirationTime time.Time) error {
+	var refreshed, lost *Lock
+	err := g.update(backend, lockFilePath, fmt.Sprintf("Refresh lock for %s", lockFilePath), func(current *Lock) (*Lock, error) {
+		lock := g.unexpired(current)
+		if lock == nil {
+			return nil, fmt.Errorf("cannot refresh lock: %w", ErrLockNotOwned)
 		}
 
-		// Only retry for non-fast-forward or fetch-first errors
-		if retry < maxRetries && (errors.Is(lastErr, gittools.ErrPushNonFastForward) || errors.Is(lastErr, gittools.ErrPushFetchFirst)) {
-			// First fetch the latest changes
-			fetchErr := g.repo.Fetch("origin", gittools.FetchOptions{})
-			if fetchErr != nil {
-				// Failed to fetch, continue to next retry attempt
-				continue
-			}
-
-			// Make sure we're not in a rebase already
-			if err := g.repo.RebaseAbort(); err != nil {
-				// Failed to abort rebase, continue to next retry attempt
-				continue
+		if lock.Shared() {
+			holder := lock.holder(g.LockKey)
+			if holder == nil {
+				lost = lock
+				return nil, fmt.Errorf("cannot refresh shared lock: %w", ErrLockNotOwned)
 			}
+			holder.ExpiresAt = expirationTime
+			holder.ClockOffset = g.ClockOffset
+			holder.Heartbeat = g.beat(holder.Heartbeat)
+			lock.updateExpiry()
+			refreshed = lock
+			return lock, nil
+		}
 
-			// Try to rebase our changes on top of the remote
-			rebaseErr := g.repo.Rebase("refs/remotes/origin/" + branch)
-			if rebaseErr != nil {
-				// If rebase fails for any reason, abort it and stop retrying
-				if err := g.repo.RebaseAbort(); err != nil {
-					fmt.Printf("Warning: failed to abort rebase after rebase error: %v\n", err)
-				}
-				// For a locking mechanism, a rebase failure indicates true contention
-				// Set the last error to the rebase error and break out completely
-				lastErr = rebaseErr
-				break
-			}
+		if lock.Owner != g.LockKey {
+			lost = lock
+			return nil, fmt.Errorf("cannot refresh lock held by %s: %w", lock.Owner, ErrLockNotOwned)
+		}
 
-			// Continue to next retry attempt after rebase
-			continue
-		} else {
-			// For any other errors, stop trying
-			break
+		lock.ExpiresAt = expirationTime
+		lock.ClockOffset = g.ClockOffset
+		lock.Heartbeat = g.beat(lock.Heartbeat)
+		refreshed = lock
+		return lock, nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrLockNotOwned) {
+			g.trackHeld(backend, lockFilePath, nil)
+			g.Hooks.lost(lockFilePath, lost)
 		}
+		return fmt.Errorf("failed to refresh lock: %w", err)
 	}
 
-	// If we've reached here, the push failed after all retries
-	return lastErr
+	g.incCounter(MetricRefreshes, lockFilePath)
+	g.Hooks.refreshed(lockFilePath, refreshed)
+	return nil
 }
 
-// RefreshLock refreshes a lock by updating its expiry time
-func (g *Locking) RefreshLock(lockFilePath string, expirationTime time.Time) error {
-	// Get current branch to restore later
-	currentBranch, err := g.repo.CurrentBranch()
-	if err != nil {
-		return fmt.Errorf("failed to get current branch: %w", err)
+// Compact discards the history of lock updates if the Backend supports it.
+// See BranchBackend.Compact.
+func (g *Locking) Compact() error {
+	compactor, ok := g.Backend.(Compactor)
+	if !ok {
+		return fmt.Errorf("lock backend %T does not support compaction", g.Backend)
 	}
 
-	// Check if we're the owner of the lock
-	lock, err := g.ReadLock(lockFilePath)
-	if err != nil {
-		return fmt.Errorf("failed to check lock ownership: %w", err)
-	}
+	g.opMu.Lock()
+	defer g.opMu.Unlock()
+	return compactor.Compact()
+}
 
-	ownsLock, err := g.OwnsLock(lock)
+// ReadLock checks if a resource is locked and returns the lock if it exists
+// Returns:
+// - *Lock: the lock object if the resource is locked, nil otherwise
+// - error: any error that occurred
+func (g *Locking) ReadLock(lockFilePath string) (*Lock, error) {
+	lock, err := g.read(g.Backend, lockFilePath)
 	if err != nil {
-		return fmt.Errorf("failed to check lock ownership: %w", err)
-	}
-
-	if !ownsLock {
-		return fmt.Errorf("cannot refresh lock that is not owned by this process")
+		return nil, err
 	}
+	g.observeHolder(lockFilePath, lock)
 
-	// Read the original lock content first so we can restore it if needed
-	lockFileFull := filepath.Join(g.repo.RepoPath, lockFilePath)
-	originalLockContent, err := os.ReadFile(lockFileFull)
-	if err != nil {
-		_ = g.repo.Checkout(currentBranch)
-		return fmt.Errorf("failed to read original lock file: %w", err)
-	}
+	return g.unexpired(lock), nil
+}
 
-	// Update the lock file
-	lock.ExpiresAt = expirationTime
+// read reads a lock from backend, serialized with other operations on this Locking
+func (g *Locking) read(backend Backend, lockFilePath string) (*Lock, error) {
+	g.opMu.Lock()
+	defer g.opMu.Unlock()
+	return backend.Read(g.namespaced(lockFilePath))
+}
 
-	lockData, err := json.MarshalIndent(lock, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal lock data: %w", err)
-	}
+// update updates a lock in backend, serialized with other operations on this Locking
+func (g *Locking) update(backend Backend, lockFilePath string, message string, fn func(current *Lock) (*Lock, error)) error {
+	g.opMu.Lock()
+	defer g.opMu.Unlock()
+
+	// Record who held the lock, and who holds it once the update succeeds
+	var next *Lock
+	observe := func(current *Lock) (*Lock, error) {
+		g.observeHolder(lockFilePath, current)
+		var err error
+		next, err = fn(current)
+		return next, err
+	}
+
+	var err error
+	backendPath := g.namespaced(lockFilePath)
+	if observed, ok := backend.(observedBackend); ok && g.Metrics != nil {
+		err = observed.updateAllObserved([]string{backendPath}, message, updateOne(backendPath, observe), func(name MetricName) {
+			g.incCounter(name, lockFilePath)
+		})
+	} else {
+		err = backend.Update(backendPath, message, observe)
+	}
+	if err == nil {
+		g.observeHolder(lockFilePath, next)
+		g.trackHeld(backend, lockFilePath, next)
+	}
+	g.observeUpdateError(lockFilePath, err)
+	return err
+}
 
-	if err := os.WriteFile(lockFileFull, lockData, 0644); err != nil {
-		return fmt.Errorf("failed to write lock file: %w", err)
+// updateAll updates several locks in backend in a single change, serialized with
+// other operations on this Locking
+func (g *Locking) updateAll(backend MultiUpdater, lockFilePaths []string, message string, fn func(current map[string]*Lock) (map[string]*Lock, error)) error {
+	g.opMu.Lock()
+	defer g.opMu.Unlock()
+
+	// Record who held the locks, and who holds them once the update succeeds.
+	// fn sees the locks by the paths it was given, rather than their namespaced paths.
+	var next map[string]*Lock
+	observe := func(backendCurrent map[string]*Lock) (map[string]*Lock, error) {
+		current := make(map[string]*Lock, len(backendCurrent))
+		for backendPath, lock := range backendCurrent {
+			current[g.unnamespaced(backendPath)] = lock
+			g.observeHolder(g.unnamespaced(backendPath), lock)
+		}
+		var err error
+		next, err = fn(current)
+		if err != nil {
+			return nil, err
+		}
+		backendNext := make(map[string]*Lock, len(next))
+		for lockFilePath, lock := range next {
+			backendNext[g.namespaced(lockFilePath)] = lock
+		}
+		return backendNext, nil
 	}
 
-	// Commit the change
-	// Using relative paths ensures we only commit within the repo directory
-	if err := g.repo.Commit(fmt.Sprintf("Refresh lock for %s", lockFilePath), []string{lockFilePath}); err != nil {
-		return fmt.Errorf("failed to commit lock refresh: %w", err)
+	backendPaths := make([]string, len(lockFilePaths))
+	for i, lockFilePath := range lockFilePaths {
+		backendPaths[i] = g.namespaced(lockFilePath)
 	}
 
-	// Push the branch with retries
-	pushErr := g.pushWithRetry(currentBranch)
-
-	if pushErr != nil {
-		// If push failed, revert our change by restoring the lock file and reset
-		_ = os.WriteFile(lockFileFull, originalLockContent, 0644)
-		if err := g.repo.ResetHard("HEAD~1"); err != nil {
-			fmt.Printf("Warning: failed to reset after push error: %v\n", err)
+	var err error
+	if observed, ok := backend.(observedBackend); ok && g.Metrics != nil {
+		err = observed.updateAllObserved(backendPaths, message, observe, func(name MetricName) {
+			for _, lockFilePath := range lockFilePaths {
+				g.incCounter(name, lockFilePath)
+			}
+		})
+	} else {
+		err = backend.UpdateAll(backendPaths, message, observe)
+	}
+	if err == nil {
+		for lockFilePath, lock := range next {
+			g.observeHolder(lockFilePath, lock)
+			if single, ok := backend.(Backend); ok {
+				g.trackHeld(single, lockFilePath, lock)
+			}
 		}
-
-		// Return a descriptive error
-		return fmt.Errorf("failed to push lock refresh: %w", pushErr)
 	}
-
-	return nil
+	for _, lockFilePath := range lockFilePaths {
+		g.observeUpdateError(lockFilePath, err)
+	}
+	return err
 }
 
-// ReadLock checks if a resource is locked and returns the lock if it exists
-// Returns:
-// - *Lock: the lock object if the resource is locked, nil otherwise
-// - error: any error that occurred
-func (g *Locking) ReadLock(lockFilePath string) (*Lock, error) {
-	// Ensure lock directory exists first (added for tests)
-	lockDir := filepath.Dir(filepath.Join(g.repo.RepoPath, lockFilePath))
-	_ = os.MkdirAll(lockDir, 0755) // Ignore errors
-
-	// Check if the lock file exists
-	lockFileFull := filepath.Join(g.repo.RepoPath, lockFilePath)
-	data, err := os.ReadFile(lockFileFull)
-	if os.IsNotExist(err) {
-		// No lock file, resource is not locked
-		return nil, nil
-	}
-	if err != nil {
-		return nil, fmt.Errorf("failed to read lock file: %w", err)
+// unexpired returns the lock if it has not yet expired, allowing for ClockSkew, nil otherwise.
+// Expired holders are dropped from shared locks.
+func (g *Locking) unexpired(lock *Lock) *Lock {
+	if lock == nil {
+		return nil
 	}
 
-	// Parse the lock file
-	var lock Lock
-	if err := json.Unmarshal(data, &lock); err != nil {
-		return nil, fmt.Errorf("failed to parse lock file: %w", err)
+	if lock.Shared() {
+		var holders []Holder
+		for _, h := range lock.Holders {
+			if !g.expired(h.ExpiresAt, h.ClockOffset) {
+				holders = append(holders, h)
+			}
+		}
+		if len(holders) == 0 {
+			return nil
+		}
+		pruned := *lock
+		pruned.Holders = holders
+		pruned.updateExpiry()
+		return &pruned
 	}
 
-	// Check if the lock is expired
-	if g.now().After(lock.ExpiresAt) {
-		// Lock is expired
-		return nil, nil
+	if g.expired(lock.ExpiresAt, lock.ClockOffset) {
+		return nil
 	}
+	return lock
+}
 
-	return &lock, nil
+// holdsAlone returns true if this process is the only holder of the lock
+func (g *Locking) holdsAlone(lock *Lock) bool {
+	if lock.Shared() {
+		return len(lock.Holders) == 1 && lock.Holders[0].Owner == g.LockKey
+	}
+	return lock.Owner == g.LockKey
 }
 
 // OwnsLock checks if this repo owns the lock on the specified resource
+// For shared locks, this is true if this repo is one of the holders
 // Returns:
 // - bool: true if this repo owns the lock, false otherwise
 // - error: any error that occurred
@@ -364,5 +502,8 @@ func (g *Locking) OwnsLock(lock *Lock) (bool, error) {
 		return false, nil
 	}
 
+	if lock.Shared() {
+		return lock.holder(g.LockKey) != nil, nil
+	}
 	return lock.Owner == g.LockKey, nil
 }
@@ -1,6 +1,7 @@
 package lock
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -107,7 +108,7 @@ func TestLockCollision(t *testing.T) {
 		elapsed := time.Since(start)
 
 		// Check that we got the expected error
-		if err != ErrLockConflict {
+		if !errors.Is(err, ErrLockConflict) {
 			t.Errorf("Expected ErrLockConflict when acquiring locked resource, got: %v", err)
 		} else {
 			t.Log("Correctly received ErrLockConflict when trying to acquire an already locked resource")
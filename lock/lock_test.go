@@ -9,8 +9,15 @@ import (
 	"time"
 
 	"github.com/ocuroot/gittools"
+	"github.com/ocuroot/gittools/testutils"
 )
 
+func TestMain(m *testing.M) {
+	code := m.Run()
+	testutils.CloseTestRemoteRepos()
+	os.Exit(code)
+}
+
 func checkoutRemoteTestRepo(t *testing.T, remoteDir string) (*gittools.Repo, func()) {
 	t.Helper()
 	
@@ -0,0 +1,211 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HeldLock describes a lock currently held through a Manager
+type HeldLock struct {
+	Path        string
+	Description string
+	AcquiredAt  time.Time
+	Expiry      time.Duration
+
+	// LastRefreshError is the most recent error reported while refreshing the lock
+	LastRefreshError error
+}
+
+// Manager tracks all locks acquired through a Locking instance and keeps them refreshed
+type Manager struct {
+	Locking *Locking
+
+	// RefreshInterval is how often held locks are refreshed.
+	// If zero, it is set by the Renewal policy of Locking, a third of the expiry
+	// duration by default.
+	RefreshInterval time.Duration
+
+	mu   sync.Mutex
+	held map[string]*managedLock
+}
+
+type managedLock struct {
+	info   HeldLock
+	cancel context.CancelFunc
+	done   <-chan struct{}
+}
+
+// NewManager creates a Manager that acquires locks through locking
+func NewManager(locking *Locking) *Manager {
+	return &Manager{
+		Locking: locking,
+		held:    make(map[string]*managedLock),
+	}
+}
+
+// Acquire acquires the lock at lockPath and keeps it refreshed until it is released
+// or ctx is cancelled
+func (m *Manager) Acquire(ctx context.Context, lockPath string, expiryDuration time.Duration, description string) error {
+	if err := m.Locking.AcquireLock(lockPath, expiryDuration, description); err != nil {
+		return err
+	}
+	if err := m.track(ctx, lockPath, expiryDuration, description); err != nil {
+		_ = m.Locking.ReleaseLock(lockPath)
+		return err
+	}
+	return nil
+}
+
+// track starts refreshing a lock acquired through m.Locking and records it as held.
+// A lock that is lost because another process took it over is no longer held.
+func (m *Manager) track(ctx context.Context, lockPath string, expiryDuration time.Duration, description string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	errs, err := m.Locking.KeepAlive(ctx, lockPath, m.RefreshInterval)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to start refreshing lock: %w", err)
+	}
+
+	done := make(chan struct{})
+	managed := &managedLock{
+		info: HeldLock{
+			Path:        lockPath,
+			Description: description,
+			AcquiredAt:  m.Locking.now(),
+			Expiry:      expiryDuration,
+		},
+		cancel: cancel,
+		done:   done,
+	}
+
+	m.mu.Lock()
+	if m.held == nil {
+		m.held = make(map[string]*managedLock)
+	}
+	previous := m.held[lockPath]
+	m.held[lockPath] = managed
+	m.mu.Unlock()
+
+	// A lock acquired again replaces the earlier acquisition and its refresher
+	if previous != nil {
+		previous.cancel()
+		<-previous.done
+	}
+
+	// Record refresh failures so they can be inspected through Held
+	go func() {
+		defer close(done)
+		for err := range errs {
+			m.mu.Lock()
+			managed.info.LastRefreshError = err
+			if errors.Is(err, ErrLockNotOwned) && m.held[lockPath] == managed {
+				delete(m.held, lockPath)
+			}
+			m.mu.Unlock()
+		}
+	}()
+
+	return nil
+}
+
+// AcquireAll acquires all of the locks in lockPaths with Locking.AcquireAll, in a
+// single update where the backend supports it, and keeps them refreshed. Duplicate
+// paths are acquired once.
+// If any acquisition fails, the locks acquired by this call are released.
+func (m *Manager) AcquireAll(ctx context.Context, lockPaths []string, expiryDuration time.Duration, description string) error {
+	sorted := sortedPaths(lockPaths)
+	if err := m.Locking.AcquireAll(sorted, expiryDuration, description); err != nil {
+		return err
+	}
+
+	for i, lockPath := range sorted {
+		if err := m.track(ctx, lockPath, expiryDuration, description); err != nil {
+			for _, tracked := range sorted[:i] {
+				m.stopRefreshing(tracked)
+			}
+			_ = m.Locking.ReleaseLocks(sorted)
+			return fmt.Errorf("failed to acquire lock %s: %w", lockPath, err)
+		}
+	}
+	return nil
+}
+
+// Release stops refreshing and releases a lock held by this Manager
+func (m *Manager) Release(lockPath string) error {
+	return m.release(context.Background(), lockPath)
+}
+
+// release stops refreshing and releases a lock held by this Manager, abandoning the
+// release when ctx is done
+func (m *Manager) release(ctx context.Context, lockPath string) error {
+	if !m.stopRefreshing(lockPath) {
+		return fmt.Errorf("lock is not held by this manager: %s", lockPath)
+	}
+	return m.Locking.release(withContext(ctx, m.Locking.Backend), lockPath)
+}
+
+// stopRefreshing stops refreshing a lock held by this Manager and stops tracking it,
+// returning false if it is not held
+func (m *Manager) stopRefreshing(lockPath string) bool {
+	m.mu.Lock()
+	managed, ok := m.held[lockPath]
+	delete(m.held, lockPath)
+	m.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+	managed.cancel()
+	<-managed.done
+	return true
+}
+
+// ReleaseAll releases every lock held by this Manager, until ctx is done, which also
+// abandons a release in progress. Locks that can't be released before then are no
+// longer refreshed, so they expire.
+// All locks are attempted, and any failures are combined into the returned error.
+func (m *Manager) ReleaseAll(ctx context.Context) error {
+	var failures []string
+	for _, held := range m.Held() {
+		if err := ctx.Err(); err != nil {
+			m.stopRefreshing(held.Path)
+			failures = append(failures, fmt.Sprintf("%s: %v", held.Path, err))
+			continue
+		}
+		if err := m.release(ctx, held.Path); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", held.Path, err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to release %d locks:\n%s", len(failures), strings.Join(failures, "\n"))
+	}
+	return nil
+}
+
+// Held returns the locks currently held by this Manager, sorted by path.
+// Locks lost to another process while refreshing them are not included.
+func (m *Manager) Held() []HeldLock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out []HeldLock
+	for _, managed := range m.held {
+		out = append(out, managed.info)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Path < out[j].Path
+	})
+	return out
+}
+
+// ContentionStats returns the contention observed for every lock used through the
+// Manager's Locking, the most contended first, see Locking.ContentionStats
+func (m *Manager) ContentionStats() []ContentionStats {
+	return m.Locking.ContentionStats()
+}
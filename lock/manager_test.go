@@ -0,0 +1,128 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestManagerAcquireReleaseAll(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo1, cleanup1 := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanup1()
+		repo2, cleanup2 := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanup2()
+
+		locking1 := NewRepoLocking(repo1)
+		locking1.Backend = NewRefBackend(repo1)
+		manager1 := NewManager(locking1)
+
+		locking2 := NewRepoLocking(repo2)
+		locking2.Backend = NewRefBackend(repo2)
+		manager2 := NewManager(locking2)
+
+		ctx := context.Background()
+		paths := []string{"locks/c", "locks/a", "locks/b"}
+		if err := manager1.AcquireAll(ctx, paths, time.Minute, "orchestrator"); err != nil {
+			t.Fatalf("Failed to acquire locks: %v", err)
+		}
+
+		held := manager1.Held()
+		if len(held) != 3 {
+			t.Fatalf("Expected 3 held locks, got %d", len(held))
+		}
+		if held[0].Path != "locks/a" || held[2].Path != "locks/c" {
+			t.Errorf("Expected held locks sorted by path, got %+v", held)
+		}
+
+		// A conflicting AcquireAll must not leave partial holds behind
+		err := manager2.AcquireAll(ctx, []string{"locks/0", "locks/b"}, time.Minute, "other")
+		if !errors.Is(err, ErrLockConflict) {
+			t.Fatalf("Expected ErrLockConflict, got %v", err)
+		}
+		if len(manager2.Held()) != 0 {
+			t.Fatalf("Expected no locks held after failed AcquireAll, got %+v", manager2.Held())
+		}
+		lock, err := locking1.ReadLock("locks/0")
+		if err != nil {
+			t.Fatalf("Failed to read lock: %v", err)
+		}
+		if lock != nil {
+			t.Fatalf("Expected partially acquired lock to be released, got %+v", lock)
+		}
+
+		if err := manager1.ReleaseAll(ctx); err != nil {
+			t.Fatalf("Failed to release locks: %v", err)
+		}
+		if len(manager1.Held()) != 0 {
+			t.Fatalf("Expected no held locks after ReleaseAll, got %+v", manager1.Held())
+		}
+
+		for _, path := range paths {
+			lock, err := locking2.ReadLock(path)
+			if err != nil {
+				t.Fatalf("Failed to read lock: %v", err)
+			}
+			if lock != nil {
+				t.Errorf("Expected %s to be released, got %+v", path, lock)
+			}
+		}
+	})
+}
+
+func TestManagerDropsLostLocks(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo1, cleanup1 := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanup1()
+		repo2, cleanup2 := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanup2()
+
+		locking1 := NewRepoLocking(repo1)
+		locking1.Backend = NewRefBackend(repo1)
+		manager := NewManager(locking1)
+		manager.RefreshInterval = 50 * time.Millisecond
+
+		locking2 := NewRepoLocking(repo2)
+		locking2.Backend = NewRefBackend(repo2)
+
+		// Duplicate paths are acquired once
+		ctx := context.Background()
+		if err := manager.AcquireAll(ctx, []string{"locks/b", "locks/a", "locks/b"}, time.Minute, "orchestrator"); err != nil {
+			t.Fatalf("Failed to acquire locks: %v", err)
+		}
+		if held := manager.Held(); len(held) != 2 || held[0].Path != "locks/a" || held[1].Path != "locks/b" {
+			t.Fatalf("Expected each path to be held once, got %+v", held)
+		}
+
+		// A lock taken over by another process is no longer reported as held
+		if err := locking2.Steal("locks/a", time.Minute, "thief", BreakOptions{}); err != nil {
+			t.Fatalf("Failed to steal lock: %v", err)
+		}
+		deadline := time.Now().Add(10 * time.Second)
+		for len(manager.Held()) != 1 {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected the stolen lock to be dropped, got %+v", manager.Held())
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if held := manager.Held(); held[0].Path != "locks/b" {
+			t.Errorf("Expected only locks/b to be held, got %+v", held)
+		}
+
+		if err := manager.ReleaseAll(ctx); err != nil {
+			t.Fatalf("Failed to release locks: %v", err)
+		}
+		if lock, err := locking2.ReadLock("locks/a"); err != nil || lock == nil || lock.Owner != locking2.LockKey {
+			t.Errorf("Expected the stolen lock to be left alone, got %+v, %v", lock, err)
+		}
+	})
+}
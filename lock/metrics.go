@@ -0,0 +1,87 @@
+package lock
+
+import (
+	"errors"
+	"time"
+)
+
+// MetricName identifies a counter or histogram reported through Metrics
+type MetricName string
+
+const (
+	// MetricAcquisitions counts locks, read locks and semaphore slots acquired by this process
+	MetricAcquisitions MetricName = "acquisitions"
+
+	// MetricConflicts counts lock operations that failed with ErrLockConflict
+	MetricConflicts MetricName = "conflicts"
+
+	// MetricRefreshes counts locks refreshed by this process
+	MetricRefreshes MetricName = "refreshes"
+
+	// MetricReleases counts locks released by this process
+	MetricReleases MetricName = "releases"
+
+	// MetricPushRetries counts pushes retried because the remote changed during an update
+	MetricPushRetries MetricName = "push_retries"
+
+	// MetricRebaseFallbacks counts updates that had to be rebased onto the remote branch
+	// before they could be pushed
+	MetricRebaseFallbacks MetricName = "rebase_fallbacks"
+
+	// MetricWaitTime is a histogram of the time taken to acquire a lock, whether or not it succeeded
+	MetricWaitTime MetricName = "wait_time"
+)
+
+// Metrics receives counters and histograms describing lock activity, so lock
+// contention can be measured with Prometheus, expvar or similar.
+// Implementations must be safe for concurrent use and should return quickly.
+type Metrics interface {
+	// IncCounter increments the named counter for the lock at lockPath
+	IncCounter(name MetricName, lockPath string)
+
+	// ObserveDuration records a sample for the named histogram for the lock at lockPath
+	ObserveDuration(name MetricName, lockPath string, d time.Duration)
+}
+
+// observedBackend is implemented by backends that retry within a single Update or
+// UpdateAll, so the retries can be reported through Metrics
+type observedBackend interface {
+	updateAllObserved(lockPaths []string, message string, fn func(current map[string]*Lock) (map[string]*Lock, error), observe func(name MetricName)) error
+}
+
+func (g *Locking) incCounter(name MetricName, lockPath string) {
+	if g.Metrics != nil {
+		g.Metrics.IncCounter(name, lockPath)
+	}
+}
+
+// acquired reports a lock acquisition that started at start to Hooks and Metrics
+func (g *Locking) acquired(lockPath string, lock *Lock, start time.Time) {
+	g.incCounter(MetricAcquisitions, lockPath)
+	g.recordStats(lockPath, func(stats *ContentionStats) {
+		stats.Acquisitions++
+	})
+	g.observeWait(lockPath, start)
+	g.Hooks.acquired(lockPath, lock)
+}
+
+// observeWait records the time spent trying to acquire a lock since start
+func (g *Locking) observeWait(lockPath string, start time.Time) {
+	wait := time.Since(start)
+	if g.Metrics != nil {
+		g.Metrics.ObserveDuration(MetricWaitTime, lockPath, wait)
+	}
+	g.recordStats(lockPath, func(stats *ContentionStats) {
+		stats.WaitTime += wait
+	})
+}
+
+// observeUpdateError counts conflicts reported by a backend update
+func (g *Locking) observeUpdateError(lockPath string, err error) {
+	if errors.Is(err, ErrLockConflict) {
+		g.incCounter(MetricConflicts, lockPath)
+		g.recordStats(lockPath, func(stats *ContentionStats) {
+			stats.Conflicts++
+		})
+	}
+}
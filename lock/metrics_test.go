@@ -0,0 +1,108 @@
+package lock
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+type testMetrics struct {
+	mu        sync.Mutex
+	counters  map[MetricName]int
+	durations map[MetricName]int
+}
+
+func (m *testMetrics) IncCounter(name MetricName, lockPath string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[name]++
+}
+
+func (m *testMetrics) ObserveDuration(name MetricName, lockPath string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.durations[name]++
+}
+
+func TestLockMetrics(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		metrics := &testMetrics{counters: map[MetricName]int{}, durations: map[MetricName]int{}}
+
+		var lockings []*Locking
+		for i := 0; i < 2; i++ {
+			repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+			defer cleanupRepo()
+
+			locking := NewRepoLocking(repo)
+			locking.Backend = NewRefBackend(repo)
+			locking.Metrics = metrics
+			lockings = append(lockings, locking)
+		}
+
+		lockPath := "locks/metrics.lock"
+		if err := lockings[0].AcquireLock(lockPath, time.Minute, "first"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+		if err := lockings[1].AcquireLock(lockPath, time.Minute, "second"); !errors.Is(err, ErrLockConflict) {
+			t.Fatalf("Expected conflict, got %v", err)
+		}
+		if err := lockings[0].RefreshLock(lockPath, time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("Failed to refresh lock: %v", err)
+		}
+		if err := lockings[0].ReleaseLock(lockPath); err != nil {
+			t.Fatalf("Failed to release lock: %v", err)
+		}
+
+		expected := map[MetricName]int{
+			MetricAcquisitions: 1,
+			MetricConflicts:    1,
+			MetricRefreshes:    1,
+			MetricReleases:     1,
+		}
+		for name, count := range expected {
+			if metrics.counters[name] != count {
+				t.Errorf("Expected %s to be %d, got %d", name, count, metrics.counters[name])
+			}
+		}
+		if metrics.durations[MetricWaitTime] != 2 {
+			t.Errorf("Expected 2 wait time samples, got %d", metrics.durations[MetricWaitTime])
+		}
+	})
+}
+
+func TestLockMetricsPushRetry(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+
+		// Reject the first push as if another process had pushed first
+		faults := &gittools.FaultInjector{}
+		push := faults.On("push")
+		push.Nth(1).RejectNonFastForward()
+		repo.Client.Hooks = append(repo.Client.Hooks, faults)
+
+		metrics := &testMetrics{counters: map[MetricName]int{}, durations: map[MetricName]int{}}
+		locking := NewRepoLocking(repo)
+		locking.Backend = NewFileBackend(repo)
+		locking.Metrics = metrics
+
+		if err := locking.AcquireLock("locks/retry.lock", time.Minute, "retry"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+		if calls := faults.Calls(push); calls != 2 {
+			t.Errorf("Expected 2 pushes, got %d", calls)
+		}
+		if metrics.counters[MetricPushRetries] != 1 {
+			t.Errorf("Expected %s to be 1, got %d", MetricPushRetries, metrics.counters[MetricPushRetries])
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package lock
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AcquireAll acquires the locks at lockFilePaths all-or-nothing, returning an error
+// wrapping a *LockConflictError without holding any of them if one is held by another process.
+// If the Backend is a MultiUpdater, the locks are written in a single change, otherwise
+// they are acquired one at a time in sorted order, so that concurrent callers cannot
+// deadlock each other, and those already acquired are released on failure.
+// Release the locks together with ReleaseLocks.
+func (g *Locking) AcquireAll(lockFilePaths []string, expiryDuration time.Duration, description string) error {
+	sorted := sortedPaths(lockFilePaths)
+
+	multi, ok := g.Backend.(MultiUpdater)
+	if !ok {
+		var acquired []string
+		for _, lockFilePath := range sorted {
+			if err := g.AcquireLock(lockFilePath, expiryDuration, description); err != nil {
+				for _, held := range acquired {
+					_ = g.ReleaseLock(held)
+				}
+				return fmt.Errorf("failed to acquire lock %s: %w", lockFilePath, err)
+			}
+			acquired = append(acquired, lockFilePath)
+		}
+		return nil
+	}
+
+	start := time.Now()
+	var err error
+	for _, lockFilePath := range sorted {
+		if err = g.checkScope(g.Backend, lockFilePath, false); err != nil {
+			break
+		}
+	}
+
+	var acquired, previous map[string]*Lock
+	if err == nil {
+		message := fmt.Sprintf("Acquire locks on %s", strings.Join(sorted, ", "))
+		err = g.updateAll(multi, sorted, message, func(current map[string]*Lock) (map[string]*Lock, error) {
+			previous = current
+			acquired = make(map[string]*Lock, len(sorted))
+			for _, lockFilePath := range sorted {
+				existingLock := g.unexpired(current[lockFilePath])
+				if existingLock != nil && !g.holdsAlone(existingLock) {
+					return nil, &LockConflictError{Path: lockFilePath, Holder: existingLock}
+				}
+				acquired[lockFilePath] = g.newLock(expiryDuration, description)
+			}
+			return acquired, nil
+		})
+	}
+	if err == nil {
+		err = g.confirmAllScopes(multi, sorted, previous)
+	}
+	if err != nil {
+		for _, lockFilePath := range sorted {
+			g.observeWait(lockFilePath, start)
+		}
+		return err
+	}
+
+	for _, lockFilePath := range sorted {
+		g.acquired(lockFilePath, acquired[lockFilePath], start)
+	}
+	return nil
+}
+
+// confirmAllScopes checks the scopes of locks acquired by AcquireAll, as confirmScope
+// does, restoring all of them to previous on conflict
+func (g *Locking) confirmAllScopes(multi MultiUpdater, lockFilePaths []string, previous map[string]*Lock) error {
+	var conflict error
+	for _, lockFilePath := range lockFilePaths {
+		if conflict = g.checkScope(g.Backend, lockFilePath, false); conflict != nil {
+			break
+		}
+	}
+	if conflict == nil {
+		return nil
+	}
+
+	err := g.updateAll(multi, lockFilePaths, "Restore locks", func(current map[string]*Lock) (map[string]*Lock, error) {
+		next := make(map[string]*Lock, len(lockFilePaths))
+		for _, lockFilePath := range lockFilePaths {
+			if lock := current[lockFilePath]; lock != nil && !lock.Shared() && lock.Owner == g.LockKey {
+				next[lockFilePath] = previous[lockFilePath]
+			}
+		}
+		return next, nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w; failed to restore locks: %v", conflict, err)
+	}
+	return conflict
+}
+
+// ReleaseLocks releases the locks at lockFilePaths together, such as those acquired with
+// AcquireAll. If the Backend is a MultiUpdater they are released in a single change and
+// nothing is released if any of them is not held by this process. Otherwise every lock is
+// released in turn, and any failures are combined into the returned error.
+func (g *Locking) ReleaseLocks(lockFilePaths []string) error {
+	sorted := sortedPaths(lockFilePaths)
+	for _, lockFilePath := range sorted {
+		g.stopKeepAlive(lockFilePath)
+	}
+
+	multi, ok := g.Backend.(MultiUpdater)
+	if !ok {
+		var failures []string
+		for _, lockFilePath := range sorted {
+			if err := g.ReleaseLock(lockFilePath); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", lockFilePath, err))
+			}
+		}
+		if len(failures) > 0 {
+			return fmt.Errorf("failed to release %d locks:\n%s", len(failures), strings.Join(failures, "\n"))
+		}
+		return nil
+	}
+
+	message := fmt.Sprintf("Release locks for %s", strings.Join(sorted, ", "))
+	err := g.updateAll(multi, sorted, message, func(current map[string]*Lock) (map[string]*Lock, error) {
+		next := make(map[string]*Lock, len(sorted))
+		for _, lockFilePath := range sorted {
+			lock, err := g.releasedLock(lockFilePath, current[lockFilePath])
+			if err != nil {
+				return nil, err
+			}
+			next[lockFilePath] = lock
+		}
+		return next, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release locks: %w", err)
+	}
+
+	for _, lockFilePath := range sorted {
+		g.incCounter(MetricReleases, lockFilePath)
+	}
+	return nil
+}
+
+// sortedPaths returns a sorted copy of lockFilePaths without duplicates
+func sortedPaths(lockFilePaths []string) []string {
+	sorted := append([]string(nil), lockFilePaths...)
+	sort.Strings(sorted)
+
+	var unique []string
+	for i, lockFilePath := range sorted {
+		if i == 0 || lockFilePath != sorted[i-1] {
+			unique = append(unique, lockFilePath)
+		}
+	}
+	return unique
+}
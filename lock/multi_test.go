@@ -0,0 +1,107 @@
+package lock
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestAcquireAll(t *testing.T) {
+	backends := map[string]func(repo *gittools.Repo) Backend{
+		"file": NewFileBackend,
+		"worktree": func(repo *gittools.Repo) Backend {
+			return NewFileBackendWithOptions(repo, FileBackendOptions{Worktree: true})
+		},
+		"ref":    NewRefBackend,
+		"branch": func(repo *gittools.Repo) Backend { return NewBranchBackend(repo, "") },
+	}
+
+	for name, newBackend := range backends {
+		t.Run(name, func(t *testing.T) {
+			gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+				_, remoteDir, cleanup := setupRemoteTestRepo(t)
+				defer cleanup()
+
+				var lockings []*Locking
+				for i := 0; i < 2; i++ {
+					repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+					defer cleanupRepo()
+
+					locking := NewRepoLocking(repo)
+					locking.Backend = newBackend(repo)
+					lockings = append(lockings, locking)
+				}
+				locking, other := lockings[0], lockings[1]
+
+				paths := []string{"locks/c.lock", "locks/a.lock", "locks/b.lock"}
+				if err := locking.AcquireAll(paths, time.Minute, "deploy"); err != nil {
+					t.Fatalf("AcquireAll failed: %v", err)
+				}
+				for _, lockPath := range paths {
+					if lock, err := locking.ReadLock(lockPath); err != nil || lock == nil || lock.Owner != locking.LockKey {
+						t.Errorf("Expected %s to be held, got %+v, %v", lockPath, lock, err)
+					}
+				}
+
+				// A conflict on any lock acquires none of them
+				err := other.AcquireAll([]string{"locks/d.lock", "locks/b.lock"}, time.Minute, "other")
+				if !errors.Is(err, ErrLockConflict) || !strings.Contains(err.Error(), "locks/b.lock") {
+					t.Fatalf("Expected ErrLockConflict naming the held lock, got %v", err)
+				}
+				if err := locking.AcquireLock("locks/d.lock", time.Minute, "deploy"); err != nil {
+					t.Errorf("Expected no partial hold after a conflict, got %v", err)
+				}
+
+				// Releasing fails as a whole if any lock isn't held
+				if err := other.ReleaseLocks(paths); err == nil {
+					t.Error("Expected releasing locks held by another process to fail")
+				}
+				if err := locking.ReleaseLocks(append(paths, "locks/d.lock")); err != nil {
+					t.Fatalf("ReleaseLocks failed: %v", err)
+				}
+				for _, lockPath := range paths {
+					if lock, err := locking.ReadLock(lockPath); err != nil || lock != nil {
+						t.Errorf("Expected %s to be released, got %+v, %v", lockPath, lock, err)
+					}
+				}
+				if err := other.AcquireAll([]string{"locks/b.lock", "locks/d.lock"}, time.Minute, "other"); err != nil {
+					t.Errorf("Expected released locks to be free, got %v", err)
+				}
+			})
+		})
+	}
+}
+
+func TestAcquireAllSingleCommit(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+
+		locking := NewRepoLocking(repo)
+		locking.Backend = NewBranchBackend(repo, "")
+		if err := locking.AcquireAll([]string{"locks/a.lock", "locks/b.lock"}, time.Minute, "deploy"); err != nil {
+			t.Fatalf("AcquireAll failed: %v", err)
+		}
+
+		count, _, err := repo.Client.Exec("rev-list", "--count", "refs/remotes/origin/"+DefaultLockBranch)
+		if err != nil {
+			t.Fatalf("Failed to count lock commits: %v", err)
+		}
+		if strings.TrimSpace(string(count)) != "1" {
+			t.Errorf("Expected both locks to be acquired in a single commit, got %s commits", count)
+		}
+	})
+}
+
+func TestSortedPaths(t *testing.T) {
+	sorted := sortedPaths([]string{"b", "a", "b", "c", "a"})
+	if strings.Join(sorted, ",") != "a,b,c" {
+		t.Errorf("Expected a,b,c, got %v", sorted)
+	}
+}
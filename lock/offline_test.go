@@ -0,0 +1,111 @@
+package lock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestLocalOnlyRepoLocking(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		localDir := filepath.Join(tempDir, "local")
+		if err := os.MkdirAll(localDir, 0755); err != nil {
+			t.Fatalf("Failed to create repository directory: %v", err)
+		}
+
+		client := gittools.Client{}
+		repo, err := client.Init(localDir, "main")
+		if err != nil {
+			t.Fatalf("Failed to init repository: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(repo.RepoPath, "README.md"), []byte("local\n"), 0644); err != nil {
+			t.Fatalf("Failed to write README: %v", err)
+		}
+		if err := repo.CommitAll("Initial commit"); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+
+		locking := NewRepoLocking(repo)
+		lockPath := "locks/local.lock"
+		if err := locking.AcquireLock(lockPath, time.Minute, "local"); err != nil {
+			t.Fatalf("Failed to acquire lock without a remote: %v", err)
+		}
+
+		other := NewRepoLocking(repo)
+		if err := other.AcquireLock(lockPath, time.Minute, "other"); err == nil {
+			t.Fatal("Expected second process to be refused the local lock")
+		}
+
+		if err := locking.ReleaseLock(lockPath); err != nil {
+			t.Fatalf("Failed to release lock without a remote: %v", err)
+		}
+	})
+}
+
+func TestLockingOnUnpushedBranch(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+
+		if err := repo.CreateBranch("feature"); err != nil {
+			t.Fatalf("Failed to create branch: %v", err)
+		}
+		if err := repo.Checkout("feature"); err != nil {
+			t.Fatalf("Failed to checkout branch: %v", err)
+		}
+
+		locking := NewRepoLocking(repo)
+		locking.Backend = NewFileBackend(repo)
+		if err := locking.AcquireLock("locks/feature.lock", time.Minute, "feature"); err != nil {
+			t.Fatalf("Failed to acquire lock on a branch without upstream: %v", err)
+		}
+
+		refs, err := repo.LsRemote("origin", "refs/heads/feature")
+		if err != nil {
+			t.Fatalf("Failed to list remote refs: %v", err)
+		}
+		if _, exists := refs["refs/heads/feature"]; !exists {
+			t.Error("Expected the lock to be pushed to a new remote branch")
+		}
+	})
+}
+
+func TestOfflineFileBackend(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+
+		before, err := repo.LsRemote("origin", "refs/heads/main")
+		if err != nil {
+			t.Fatalf("Failed to list remote refs: %v", err)
+		}
+
+		locking := NewRepoLocking(repo)
+		locking.Backend = NewFileBackendWithOptions(repo, FileBackendOptions{Offline: true})
+		if err := locking.AcquireLock("locks/offline.lock", time.Minute, "offline"); err != nil {
+			t.Fatalf("Failed to acquire offline lock: %v", err)
+		}
+
+		lock, err := locking.ReadLock("locks/offline.lock")
+		if err != nil || lock == nil {
+			t.Fatalf("Expected offline lock to be readable, got %+v, %v", lock, err)
+		}
+
+		after, err := repo.LsRemote("origin", "refs/heads/main")
+		if err != nil {
+			t.Fatalf("Failed to list remote refs: %v", err)
+		}
+		if before["refs/heads/main"] != after["refs/heads/main"] {
+			t.Error("Expected offline lock not to be pushed")
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package lock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// maxPathPartLength is the longest a single escaped path component may be before it
+// is shortened, keeping lock paths well inside the Windows MAX_PATH limit
+const maxPathPartLength = 64
+
+// windowsReservedNames can't be used as the name of a file on Windows, with or without an extension
+var windowsReservedNames = map[string]bool{
+	"con": true, "prn": true, "aux": true, "nul": true,
+	"com0": true, "com1": true, "com2": true, "com3": true, "com4": true,
+	"com5": true, "com6": true, "com7": true, "com8": true, "com9": true,
+	"lpt0": true, "lpt1": true, "lpt2": true, "lpt3": true, "lpt4": true,
+	"lpt5": true, "lpt6": true, "lpt7": true, "lpt8": true, "lpt9": true,
+}
+
+// PathFor returns the path of the lock file for a resource identified by parts within
+// namespace, such as PathFor("deploy", "prod", "service-a") for deploy/prod/service-a.lock.
+//
+// Each part becomes a single path component that is valid on every platform: characters
+// other than lowercase letters, digits, '-' and '.' are escaped as '_' followed by their hex
+// value, so slashes in a part don't create directories and names differing only in case don't
+// collide on case-insensitive filesystems. Components that are Windows device names are escaped
+// and overly long components are shortened with a hash, so distinct resources always map to
+// distinct paths. namespace may contain '/' to nest namespaces. With no parts, PathFor returns
+// the lock for the namespace itself, which is the parent of every lock in it when
+// Locking.Hierarchical is set.
+func PathFor(namespace string, parts ...string) string {
+	components := namespaceParts(namespace)
+	for _, part := range parts {
+		components = append(components, escapePathPart(part))
+	}
+	if len(components) == 0 {
+		components = []string{escapePathPart("")}
+	}
+	return strings.Join(components, "/") + ".lock"
+}
+
+// namespaceParts escapes each '/' separated component of namespace
+func namespaceParts(namespace string) []string {
+	var parts []string
+	for _, part := range strings.Split(namespace, "/") {
+		if part != "" {
+			parts = append(parts, escapePathPart(part))
+		}
+	}
+	return parts
+}
+
+// escapePathPart converts part into a path component that is safe on every platform,
+// see PathFor. Distinct parts always produce distinct components.
+func escapePathPart(part string) string {
+	if part == "" {
+		// Never produced otherwise, since '_' always starts an escape
+		return "_"
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(part); i++ {
+		c := part[i]
+		dot := c == '.' && i > 0 && i < len(part)-1
+		if c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || dot {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "_%02x", c)
+		}
+	}
+	escaped := b.String()
+
+	stem := escaped
+	if i := strings.Index(stem, "."); i >= 0 {
+		stem = stem[:i]
+	}
+	if windowsReservedNames[stem] {
+		escaped = fmt.Sprintf("_%02x", escaped[0]) + escaped[1:]
+	}
+
+	if len(escaped) > maxPathPartLength {
+		// "__" never appears in an escaped part, so shortened parts can't collide with others
+		sum := sha256.Sum256([]byte(escaped))
+		suffix := "__" + hex.EncodeToString(sum[:8])
+		cut := maxPathPartLength - len(suffix)
+		// Don't split an escape
+		if i := strings.LastIndex(escaped[:cut], "_"); i >= 0 && i+3 > cut {
+			cut = i
+		}
+		escaped = escaped[:cut] + suffix
+	}
+	return escaped
+}
+
+// namespaced returns the path lockFilePath is stored at in the backend, under Namespace if set
+func (g *Locking) namespaced(lockFilePath string) string {
+	namespace := namespaceParts(g.Namespace)
+	if len(namespace) == 0 {
+		return lockFilePath
+	}
+	if cleaned := path.Clean(strings.TrimPrefix(lockFilePath, "/")); cleaned != "." {
+		namespace = append(namespace, cleaned)
+	}
+	return strings.Join(namespace, "/")
+}
+
+// unnamespaced returns the path a lock stored at backendPath was given to this Locking
+// under, reversing namespaced
+func (g *Locking) unnamespaced(backendPath string) string {
+	namespace := namespaceParts(g.Namespace)
+	if len(namespace) == 0 {
+		return backendPath
+	}
+	return strings.TrimPrefix(backendPath, strings.Join(namespace, "/")+"/")
+}
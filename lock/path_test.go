@@ -0,0 +1,137 @@
+package lock
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestPathFor(t *testing.T) {
+	long := strings.Repeat("a", 100)
+	tests := []struct {
+		namespace string
+		parts     []string
+		expected  string
+	}{
+		{"deploy", []string{"prod", "service-a"}, "deploy/prod/service-a.lock"},
+		{"", []string{"service"}, "service.lock"},
+		{"deploy", nil, "deploy.lock"},
+		{"", nil, "_.lock"},
+		{"teams/infra", []string{"db"}, "teams/infra/db.lock"},
+		{"deploy", []string{"feature/login"}, "deploy/feature_2flogin.lock"},
+		{"deploy", []string{"Service"}, "deploy/_53ervice.lock"},
+		{"deploy", []string{"a_b"}, "deploy/a_5fb.lock"},
+		{"deploy", []string{"C:\\dir"}, "deploy/_43_3a_5cdir.lock"},
+		{"deploy", []string{"..", ".hidden", "v1.2"}, "deploy/_2e_2e/_2ehidden/v1.2.lock"},
+		{"deploy", []string{"trailing."}, "deploy/trailing_2e.lock"},
+		{"deploy", []string{"con"}, "deploy/_63on.lock"},
+		{"deploy", []string{"nul.txt"}, "deploy/_6eul.txt.lock"},
+		{"deploy", []string{"console"}, "deploy/console.lock"},
+		{"deploy", []string{""}, "deploy/_.lock"},
+	}
+	for _, test := range tests {
+		if got := PathFor(test.namespace, test.parts...); got != test.expected {
+			t.Errorf("PathFor(%q, %q) = %q, expected %q", test.namespace, test.parts, got, test.expected)
+		}
+	}
+
+	// Long parts are shortened, keeping distinct parts distinct
+	shortened := PathFor("deploy", long)
+	if parts := strings.Split(shortened, "/"); len(parts[1]) > maxPathPartLength+len(".lock") {
+		t.Errorf("Expected a shortened path, got %q", shortened)
+	}
+	if !strings.HasPrefix(shortened, "deploy/aaaa") {
+		t.Errorf("Expected the shortened path to keep a readable prefix, got %q", shortened)
+	}
+	if PathFor("deploy", long+"b") == shortened {
+		t.Error("Expected long parts with a common prefix not to collide")
+	}
+	upper := PathFor("deploy", strings.Repeat("A", 100))
+	if prefix := strings.Split(strings.Split(upper, "/")[1], "__")[0]; strings.ReplaceAll(prefix, "_41", "") != "" {
+		t.Errorf("Expected the shortened path not to split an escape, got %q", upper)
+	}
+
+	// Escaping never maps two parts to the same component
+	parts := []string{"", "_", "a", "A", "a/b", "a_2fb", "a.b", "a..b", ".", "..", "con", "_63on", long, long + "b"}
+	seen := map[string]string{}
+	for _, part := range parts {
+		escaped := escapePathPart(part)
+		if other, exists := seen[escaped]; exists {
+			t.Errorf("Expected %q and %q to escape differently, both gave %q", part, other, escaped)
+		}
+		seen[escaped] = part
+		if strings.ContainsAny(escaped, "/\\:*?\"<>|") || escaped != strings.ToLower(escaped) {
+			t.Errorf("Expected %q to escape to a portable name, got %q", part, escaped)
+		}
+	}
+}
+
+func TestLockingNamespace(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+
+		locking := NewRepoLocking(repo)
+		locking.Backend = NewRefBackend(repo)
+		locking.Namespace = "Team A"
+		other := NewRepoLocking(repo)
+		other.Backend = NewRefBackend(repo)
+		other.Namespace = "team-b"
+		plain := NewRepoLocking(repo)
+		plain.Backend = NewRefBackend(repo)
+
+		lockPath := "locks/db.lock"
+		if err := locking.AcquireLock(lockPath, time.Minute, "a"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+		if err := other.AcquireLock(lockPath, time.Minute, "b"); err != nil {
+			t.Errorf("Expected locks in another namespace not to conflict, got %v", err)
+		}
+		if err := NewRepoLocking(repo).AcquireLock(lockPath, time.Minute, "plain"); err != nil {
+			t.Errorf("Expected locks outside a namespace not to conflict, got %v", err)
+		}
+
+		// The lock is stored under the escaped namespace
+		if lock, err := plain.ReadLock("_54eam_20_41/" + lockPath); err != nil || lock == nil || lock.Owner != locking.LockKey {
+			t.Errorf("Expected the lock to be stored under the namespace, got %+v, %v", lock, err)
+		}
+		contender := NewRepoLocking(repo)
+		contender.Backend = NewRefBackend(repo)
+		contender.Namespace = "Team A"
+		if err := contender.AcquireLock(lockPath, time.Minute, "contender"); !errors.Is(err, ErrLockConflict) {
+			t.Errorf("Expected ErrLockConflict within the namespace, got %v", err)
+		}
+
+		// Paths are reported relative to the namespace
+		infos, err := locking.ListLocks("locks")
+		if err != nil {
+			t.Fatalf("Failed to list locks: %v", err)
+		}
+		if len(infos) != 1 || infos[0].Path != lockPath {
+			t.Errorf("Expected only the namespaced lock, got %+v", infos)
+		}
+
+		multi := []string{"locks/a.lock", "locks/b.lock"}
+		if err := locking.AcquireAll(multi, time.Minute, "multi"); err != nil {
+			t.Fatalf("Failed to acquire locks: %v", err)
+		}
+		if lock, err := plain.ReadLock("_54eam_20_41/locks/b.lock"); err != nil || lock == nil {
+			t.Errorf("Expected the lock to be stored under the namespace, got %+v, %v", lock, err)
+		}
+		if err := locking.ReleaseLocks(multi); err != nil {
+			t.Fatalf("Failed to release locks: %v", err)
+		}
+		if err := locking.ReleaseLock(lockPath); err != nil {
+			t.Fatalf("Failed to release lock: %v", err)
+		}
+		if lock, err := plain.ReadLock("_54eam_20_41/" + lockPath); err != nil || lock != nil {
+			t.Errorf("Expected the lock to be released, got %+v, %v", lock, err)
+		}
+	})
+}
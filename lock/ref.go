@@ -0,0 +1,273 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+// RefLockPrefix is the ref namespace under which ref-based locks are stored
+const RefLockPrefix = "refs/locks/"
+
+// refLockFile is the name of the blob holding the lock JSON in a lock commit's tree
+const refLockFile = "lock.json"
+
+// RefLockName returns the full ref name used to store the lock with the given name.
+// Path components ending in ".lock" are not valid in ref names, so they are suffixed with "_".
+// Other components that are not valid in ref names, such as "..", are kept as they are,
+// and the ref backend rejects them before running git.
+func RefLockName(name string) string {
+	parts := splitLockPath(name)
+	for i, part := range parts {
+		if strings.HasSuffix(part, ".lock") {
+			parts[i] = part + "_"
+		}
+	}
+	return RefLockPrefix + strings.Join(parts, "/")
+}
+
+// checkRefLockName returns an error if the lock with the given name can't be stored
+// under a valid ref name, following the rules of git check-ref-format
+func checkRefLockName(name string) error {
+	for _, part := range splitLockPath(name) {
+		var problem string
+		switch {
+		case part == "." || part == "..":
+			problem = fmt.Sprintf("it has a %q component", part)
+		case strings.HasPrefix(part, "."):
+			problem = "a component starts with '.'"
+		case strings.HasSuffix(part, "."):
+			problem = "a component ends with '.'"
+		case strings.Contains(part, ".."):
+			problem = "it contains '..'"
+		case strings.Contains(part, "@{"):
+			problem = "it contains '@{'"
+		case part == "@":
+			problem = "a component is '@'"
+		case strings.IndexFunc(part, func(r rune) bool { return r < 0x20 || r == 0x7f }) >= 0:
+			problem = "it contains a control character"
+		case strings.ContainsAny(part, " ~^:?*[\\"):
+			problem = "it contains one of ' ~^:?*[\\'"
+		default:
+			continue
+		}
+		return fmt.Errorf("invalid lock name %q: %s, which is not allowed in a ref name", name, problem)
+	}
+	return nil
+}
+
+// refLockPath returns the lock name stored under the given ref, reversing RefLockName
+func refLockPath(ref string) string {
+	parts := strings.Split(strings.TrimPrefix(ref, RefLockPrefix), "/")
+	for i, part := range parts {
+		if strings.HasSuffix(part, ".lock_") {
+			parts[i] = strings.TrimSuffix(part, "_")
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// NewRefBackend creates a Backend that stores each lock under RefLockPrefix on the
+// origin remote. The lock JSON is written as a blob in a dangling commit, so lock
+// churn never appears in branch history or conflicts with content changes.
+// Updates are pushed with --atomic and --force-with-lease, making every change a
+// compare-and-swap on the remote.
+func NewRefBackend(repo *gittools.Repo) Backend {
+	return &refBackend{repo: repo, codec: JSONCodec}
+}
+
+// RefBackendOptions configures a Backend created with NewRefBackendWithOptions
+type RefBackendOptions struct {
+	// Codec serializes the lock stored in each lock commit. If nil, JSONCodec is used.
+	Codec Codec
+}
+
+// NewRefBackendWithOptions creates a Backend that stores each lock under RefLockPrefix
+// on the origin remote, see NewRefBackend
+func NewRefBackendWithOptions(repo *gittools.Repo, options RefBackendOptions) Backend {
+	return &refBackend{repo: repo, codec: codecOrDefault(options.Codec)}
+}
+
+type refBackend struct {
+	repo  *gittools.Repo
+	codec Codec
+}
+
+func (b *refBackend) withContext(ctx context.Context) Backend {
+	bound := *b
+	bound.repo = b.repo.WithContext(ctx)
+	return &bound
+}
+
+// Read fetches the lock ref for lockPath from the remote
+func (b *refBackend) Read(lockPath string) (*Lock, error) {
+	if err := checkRefLockName(lockPath); err != nil {
+		return nil, err
+	}
+	lock, _, err := b.fetch(RefLockName(lockPath))
+	return lock, err
+}
+
+// Update replaces the lock ref, succeeding only if the remote ref is unchanged since it was read
+func (b *refBackend) Update(lockPath string, message string, fn func(current *Lock) (*Lock, error)) error {
+	return b.UpdateAll([]string{lockPath}, message, updateOne(lockPath, fn))
+}
+
+// UpdateAll replaces the lock refs in a single atomic push, succeeding only if none of
+// the remote refs have changed since they were read
+func (b *refBackend) UpdateAll(lockPaths []string, message string, fn func(current map[string]*Lock) (map[string]*Lock, error)) error {
+	for _, lockPath := range lockPaths {
+		if err := checkRefLockName(lockPath); err != nil {
+			return err
+		}
+	}
+
+	current := make(map[string]*Lock, len(lockPaths))
+	expected := make(map[string]string, len(lockPaths))
+	for _, lockPath := range lockPaths {
+		lock, commit, err := b.fetch(RefLockName(lockPath))
+		if err != nil {
+			return fmt.Errorf("failed to check lock status of %s: %w", lockPath, err)
+		}
+		current[lockPath] = lock
+		expected[lockPath] = commit
+	}
+
+	next, err := fn(current)
+	if err != nil {
+		return err
+	}
+	changed, err := changedLocks(current, next)
+	if err != nil || len(changed) == 0 {
+		return err
+	}
+
+	var refspecs []string
+	var leases []gittools.Lease
+	for _, lockPath := range changed {
+		ref := RefLockName(lockPath)
+		if lock := next[lockPath]; lock == nil {
+			refspecs = append(refspecs, ":"+ref)
+		} else {
+			commit, err := b.writeCommit(lock, message)
+			if err != nil {
+				return err
+			}
+			refspecs = append(refspecs, commit+":"+ref)
+		}
+		leases = append(leases, gittools.Lease{Ref: ref, Expected: expected[lockPath]})
+	}
+
+	err = b.repo.PushWithOptions("origin", gittools.PushOptions{
+		Refspecs:       refspecs,
+		Atomic:         true,
+		ForceWithLease: leases,
+		IgnoreMirror:   true,
+	})
+	if err != nil {
+		if errors.Is(err, gittools.ErrPushStaleInfo) || errors.Is(err, gittools.ErrPushRejected) {
+			return fmt.Errorf("%w: %v", ErrLockConflict, err)
+		}
+		return fmt.Errorf("failed to push lock ref: %w", err)
+	}
+
+	return nil
+}
+
+// AcquireRefLock acquires a lock stored as a ref on the remote, regardless of
+// the Backend configured on this Locking. See NewRefBackend.
+// No pull, rebase or retry is performed: if another process updates the ref between
+// our read and our push, ErrLockConflict is returned.
+func (g *Locking) AcquireRefLock(name string, expiryDuration time.Duration, description string) error {
+	return g.acquire(NewRefBackend(g.repo), name, expiryDuration, description)
+}
+
+// ReleaseRefLock releases a lock acquired with AcquireRefLock by deleting its ref on the remote.
+// The deletion only succeeds if the ref still points at the lock this process read.
+func (g *Locking) ReleaseRefLock(name string) error {
+	return g.release(NewRefBackend(g.repo), name)
+}
+
+// ReadRefLock reads a lock stored as a ref on the remote
+// Returns nil if the lock does not exist or has expired
+func (g *Locking) ReadRefLock(name string) (*Lock, error) {
+	lock, err := g.read(NewRefBackend(g.repo), name)
+	if err != nil {
+		return nil, err
+	}
+	return g.unexpired(lock), nil
+}
+
+// fetch fetches the lock ref from the remote and parses the lock it points to.
+// Returns the lock (nil if the ref does not exist) and the commit the ref pointed to,
+// which should be used as the expected value for any subsequent lease.
+func (b *refBackend) fetch(ref string) (*Lock, string, error) {
+	refs, err := b.repo.LsRemote("origin", ref)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list remote lock ref: %w", err)
+	}
+	if _, exists := refs[ref]; !exists {
+		return nil, "", nil
+	}
+
+	// Fetch into the same ref name locally so the commit is available for reading
+	if err := b.repo.Fetch("origin", gittools.FetchOptions{Refspecs: []string{"+" + ref + ":" + ref}}); err != nil {
+		return nil, "", fmt.Errorf("failed to fetch lock ref: %w", err)
+	}
+
+	commit, err := b.repo.RevParse("--verify", ref)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to resolve lock ref: %w", err)
+	}
+
+	lock, err := b.readCommit(commit)
+	if err != nil {
+		return nil, "", err
+	}
+	return lock, commit, nil
+}
+
+// readCommit parses the lock stored in a lock commit
+func (b *refBackend) readCommit(commit string) (*Lock, error) {
+	content, err := b.repo.FileAtCommit(commit, refLockFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock from ref: %w", err)
+	}
+
+	lock, err := b.codec.Unmarshal([]byte(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse lock from ref: %w", err)
+	}
+	return lock, nil
+}
+
+// writeCommit writes the lock as a blob in a new parentless commit and returns its hash
+func (b *refBackend) writeCommit(lock *Lock, message string) (string, error) {
+	lockContent, err := b.codec.Marshal(lock)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal lock: %w", err)
+	}
+
+	blob, err := b.repo.HashObject(lockContent)
+	if err != nil {
+		return "", fmt.Errorf("failed to write lock blob: %w", err)
+	}
+
+	tree, err := b.repo.MkTree([]gittools.TreeEntry{
+		{Mode: "100644", Type: "blob", Object: blob, Name: refLockFile},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to write lock tree: %w", err)
+	}
+
+	commit, err := b.repo.CommitTree(tree, message)
+	if err != nil {
+		return "", fmt.Errorf("failed to write lock commit: %w", err)
+	}
+
+	return commit, nil
+}
@@ -0,0 +1,199 @@
+package lock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestRefLockCompareAndSwap(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo1, cleanup1 := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanup1()
+		repo2, cleanup2 := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanup2()
+
+		locking1 := NewRepoLocking(repo1)
+		locking2 := NewRepoLocking(repo2)
+
+		commitsBefore, err := repo1.CountCommits("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to count commits: %v", err)
+		}
+
+		lockName := "locks/deploy.lock"
+		if err := locking1.AcquireRefLock(lockName, 10*time.Minute, "deploy"); err != nil {
+			t.Fatalf("Failed to acquire ref lock: %v", err)
+		}
+
+		// The second process must see the conflict without touching its branch
+		err = locking2.AcquireRefLock(lockName, 10*time.Minute, "deploy")
+		if !errors.Is(err, ErrLockConflict) {
+			t.Fatalf("Expected ErrLockConflict, got %v", err)
+		}
+
+		lock, err := locking2.ReadRefLock(lockName)
+		if err != nil {
+			t.Fatalf("Failed to read ref lock: %v", err)
+		}
+		if lock == nil || lock.Owner != locking1.LockKey {
+			t.Fatalf("Expected lock owned by %s, got %+v", locking1.LockKey, lock)
+		}
+
+		if err := locking2.ReleaseRefLock(lockName); err == nil {
+			t.Fatal("Expected release by non-owner to fail")
+		}
+
+		if err := locking1.ReleaseRefLock(lockName); err != nil {
+			t.Fatalf("Failed to release ref lock: %v", err)
+		}
+
+		if err := locking2.AcquireRefLock(lockName, 10*time.Minute, "deploy"); err != nil {
+			t.Fatalf("Failed to acquire ref lock after release: %v", err)
+		}
+
+		// Lock churn must not appear in branch history
+		commitsAfter, err := repo1.CountCommits("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to count commits: %v", err)
+		}
+		if commitsAfter != commitsBefore {
+			t.Errorf("Expected %d commits on branch, got %d", commitsBefore, commitsAfter)
+		}
+	})
+}
+
+func TestRefLockStaleLease(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+
+		backend := &refBackend{repo: repo, codec: JSONCodec}
+		ref := RefLockName("resource")
+
+		// Simulate a concurrent writer by creating the ref after we expect it to be absent
+		commit, err := backend.writeCommit(&Lock{Owner: "other", ExpiresAt: time.Now().Add(time.Hour)}, "other")
+		if err != nil {
+			t.Fatalf("Failed to write lock commit: %v", err)
+		}
+		if err := repo.PushWithOptions("origin", gittools.PushOptions{Refspecs: []string{commit + ":" + ref}}); err != nil {
+			t.Fatalf("Failed to push lock ref: %v", err)
+		}
+
+		ours, err := backend.writeCommit(&Lock{Owner: "ours", ExpiresAt: time.Now().Add(time.Hour)}, "ours")
+		if err != nil {
+			t.Fatalf("Failed to write lock commit: %v", err)
+		}
+
+		err = repo.PushWithOptions("origin", gittools.PushOptions{
+			Refspecs:       []string{ours + ":" + ref},
+			Atomic:         true,
+			ForceWithLease: []gittools.Lease{{Ref: ref, Expected: ""}},
+		})
+		if !errors.Is(err, gittools.ErrPushStaleInfo) {
+			t.Fatalf("Expected ErrPushStaleInfo, got %v", err)
+		}
+	})
+}
+
+func TestRefLockName(t *testing.T) {
+	tests := map[string]string{
+		"resource":          "refs/locks/resource",
+		"/locks/env/prod":   "refs/locks/locks/env/prod",
+		"locks/deploy.lock": "refs/locks/locks/deploy.lock_",
+	}
+	for name, expected := range tests {
+		if got := RefLockName(name); got != expected {
+			t.Errorf("RefLockName(%q) = %q, expected %q", name, got, expected)
+		}
+	}
+}
+
+func TestCheckRefLockName(t *testing.T) {
+	valid := []string{"resource", "/locks/env/prod", "locks/deploy.lock", "a/../b", "v1.2", "a@b"}
+	for _, name := range valid {
+		if err := checkRefLockName(name); err != nil {
+			t.Errorf("checkRefLockName(%q) = %v, expected no error", name, err)
+		}
+	}
+
+	invalid := []string{
+		"", "..", "../x", "a/../../x", "a..b", ".hidden", "a/b.",
+		"a~1", "a^", "a:b", "a b", "a?", "a*", "a[b", "a\\b", "a\tb", "a@{1}", "@",
+	}
+	for _, name := range invalid {
+		if err := checkRefLockName(name); err == nil {
+			t.Errorf("checkRefLockName(%q) = nil, expected an error", name)
+		}
+	}
+
+	// Invalid names are rejected before any git command runs
+	backend := &refBackend{codec: JSONCodec}
+	if _, err := backend.Read("../x"); err == nil || !strings.Contains(err.Error(), "invalid lock name") {
+		t.Errorf("Read: expected an invalid lock name error, got %v", err)
+	}
+	if err := backend.UpdateAll([]string{"ok", "a~1"}, "update", nil); err == nil || !strings.Contains(err.Error(), "invalid lock name") {
+		t.Errorf("UpdateAll: expected an invalid lock name error, got %v", err)
+	}
+	if _, err := backend.List("a:b"); err == nil || !strings.Contains(err.Error(), "invalid lock name") {
+		t.Errorf("List: expected an invalid lock name error, got %v", err)
+	}
+}
+
+func TestRefBackend(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo1, cleanup1 := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanup1()
+		repo2, cleanup2 := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanup2()
+
+		locking1 := NewRepoLocking(repo1)
+		locking1.Backend = NewRefBackend(repo1)
+		locking2 := NewRepoLocking(repo2)
+		locking2.Backend = NewRefBackend(repo2)
+
+		lockPath := "locks/resource.lock"
+		if err := locking1.AcquireLock(lockPath, 10*time.Minute, "Test lock"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+
+		if err := locking1.RefreshLock(lockPath, time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("Failed to refresh lock: %v", err)
+		}
+
+		if err := locking2.AcquireLock(lockPath, 10*time.Minute, "Test lock"); !errors.Is(err, ErrLockConflict) {
+			t.Fatalf("Expected ErrLockConflict, got %v", err)
+		}
+
+		if err := locking1.ReleaseLock(lockPath); err != nil {
+			t.Fatalf("Failed to release lock: %v", err)
+		}
+
+		lock, err := locking2.ReadLock(lockPath)
+		if err != nil {
+			t.Fatalf("Failed to read lock: %v", err)
+		}
+		if lock != nil {
+			t.Fatalf("Expected no lock after release, got %+v", lock)
+		}
+
+		// Nothing should have been written to the working tree
+		if _, err := os.Stat(filepath.Join(repo1.RepoPath, lockPath)); !os.IsNotExist(err) {
+			t.Errorf("Expected no lock file in working tree, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package lock
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// defaultRenewalFraction is the fraction of a lock's lifetime after which it is renewed
+// when RenewalPolicy.Fraction is not set
+const defaultRenewalFraction = 1.0 / 3
+
+// maxRenewalJitter caps RenewalPolicy.Jitter, so a renewal is never scheduled immediately
+// or later than the lock can tolerate
+const maxRenewalJitter = 0.5
+
+var (
+	jitterMu   sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// RenewalPolicy controls when locks are renewed by KeepAlive, and so by Manager, WithLock
+// and Elector, when no explicit interval is given.
+// Many processes that acquire locks at the same time would otherwise renew them in lockstep,
+// all pushing to the same remote at once. Jitter spreads their renewals out.
+type RenewalPolicy struct {
+	// Fraction of a lock's lifetime after which it is renewed, between 0 and 1.
+	// If zero, locks are renewed after a third of their lifetime.
+	Fraction float64
+
+	// Jitter randomly shortens or lengthens each renewal interval by up to this fraction
+	// of it, so a Jitter of 0.2 waits between 80% and 120% of the interval.
+	// Jitter is capped at 0.5.
+	Jitter float64
+}
+
+// Interval returns the renewal interval for a lock that lasts ttl, before jitter is applied
+func (p RenewalPolicy) Interval(ttl time.Duration) time.Duration {
+	fraction := p.Fraction
+	if fraction <= 0 || fraction > 1 {
+		fraction = defaultRenewalFraction
+	}
+	return time.Duration(float64(ttl) * fraction)
+}
+
+// jittered returns interval randomly adjusted by up to Jitter in either direction
+func (p RenewalPolicy) jittered(interval time.Duration) time.Duration {
+	jitter := p.Jitter
+	if jitter <= 0 || interval <= 0 {
+		return interval
+	}
+	if jitter > maxRenewalJitter {
+		jitter = maxRenewalJitter
+	}
+
+	jitterMu.Lock()
+	r := jitterRand.Float64()
+	jitterMu.Unlock()
+	return time.Duration(float64(interval) * (1 + jitter*(2*r-1)))
+}
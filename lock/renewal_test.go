@@ -0,0 +1,86 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestRenewalPolicyInterval(t *testing.T) {
+	if interval := (RenewalPolicy{}).Interval(time.Minute); interval != 20*time.Second {
+		t.Errorf("Expected a third of the lifetime by default, got %v", interval)
+	}
+	if interval := (RenewalPolicy{Fraction: 0.5}).Interval(time.Minute); interval != 30*time.Second {
+		t.Errorf("Expected half of the lifetime, got %v", interval)
+	}
+
+	if interval := (RenewalPolicy{}).jittered(time.Minute); interval != time.Minute {
+		t.Errorf("Expected no jitter by default, got %v", interval)
+	}
+
+	policy := RenewalPolicy{Jitter: 0.2}
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 100; i++ {
+		interval := policy.jittered(time.Minute)
+		if interval < 48*time.Second || interval > 72*time.Second {
+			t.Fatalf("Expected jitter within 20%%, got %v", interval)
+		}
+		seen[interval] = true
+	}
+	if len(seen) < 2 {
+		t.Error("Expected jittered intervals to vary")
+	}
+
+	capped := RenewalPolicy{Jitter: 5}
+	for i := 0; i < 100; i++ {
+		if interval := capped.jittered(time.Minute); interval < 30*time.Second || interval > 90*time.Second {
+			t.Fatalf("Expected jitter to be capped at 50%%, got %v", interval)
+		}
+	}
+}
+
+func TestKeepAliveRenewalPolicy(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+
+		locking := NewRepoLocking(repo)
+		locking.Backend = NewRefBackend(repo)
+		locking.Renewal = RenewalPolicy{Fraction: 0.1, Jitter: 0.5}
+
+		lockPath := "locks/renewal.lock"
+		if err := locking.AcquireLock(lockPath, time.Second, "Long running job"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+		original, err := locking.ReadLock(lockPath)
+		if err != nil {
+			t.Fatalf("Failed to read lock: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		if _, err := locking.KeepAlive(ctx, lockPath, 0); err != nil {
+			t.Fatalf("Failed to start keep-alive: %v", err)
+		}
+
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			lock, err := locking.ReadLock(lockPath)
+			if err != nil {
+				t.Fatalf("Failed to read lock: %v", err)
+			}
+			if lock != nil && lock.ExpiresAt.After(original.ExpiresAt) {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("Expected the lock to be refreshed at a fraction of its lifetime")
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	})
+}
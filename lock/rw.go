@@ -0,0 +1,61 @@
+package lock
+
+import (
+	"fmt"
+	"time"
+)
+
+// AcquireReadLock acquires a shared lock on lockFilePath.
+// Any number of processes may hold a read lock at the same time, each recorded
+// as a Holder in the lock file with its own expiry.
+// It returns a *LockConflictError if the resource is held by a writer.
+// Read locks are refreshed and released with RefreshLock and ReleaseLock.
+func (g *Locking) AcquireReadLock(lockFilePath string, expiryDuration time.Duration, description string) error {
+	start := time.Now()
+	var acquired, previous *Lock
+	err := g.checkScope(g.Backend, lockFilePath, true)
+	if err == nil {
+		err = g.update(g.Backend, lockFilePath, fmt.Sprintf("Acquire read lock on %s", lockFilePath), func(current *Lock) (*Lock, error) {
+			previous = current
+			existingLock := g.unexpired(current)
+
+			// Readers are blocked by any writer, including ourselves, and by semaphores
+			if existingLock != nil && (!existingLock.Shared() || existingLock.Capacity > 0) {
+				return nil, &LockConflictError{Path: lockFilePath, Holder: existingLock}
+			}
+
+			holder := g.newHolder(expiryDuration, description)
+
+			if existingLock == nil {
+				existingLock = &Lock{
+					CreatedAt:   g.now(),
+					Description: "shared",
+				}
+			}
+
+			existingLock.removeHolder(g.LockKey)
+			existingLock.Holders = append(existingLock.Holders, holder)
+			existingLock.updateExpiry()
+			acquired = existingLock
+			return existingLock, nil
+		})
+	}
+	if err == nil {
+		err = g.confirmScope(g.Backend, lockFilePath, true, previous)
+	}
+	if err != nil {
+		g.observeWait(lockFilePath, start)
+		return conflictError(lockFilePath, err)
+	}
+
+	g.acquired(lockFilePath, acquired, start)
+	return nil
+}
+
+// AcquireWriteLock acquires an exclusive lock on lockFilePath.
+// It returns ErrLockConflict if the resource is held by another writer or by any
+// reader other than this process. A process that is the only reader is upgraded
+// to the writer.
+func (g *Locking) AcquireWriteLock(lockFilePath string, expiryDuration time.Duration, description string) error {
+	return g.AcquireLock(lockFilePath, expiryDuration, description)
+}
@@ -0,0 +1,84 @@
+package lock
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestReadWriteLocks(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		var lockings []*Locking
+		for i := 0; i < 3; i++ {
+			repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+			defer cleanupRepo()
+
+			locking := NewRepoLocking(repo)
+			locking.Backend = NewRefBackend(repo)
+			lockings = append(lockings, locking)
+		}
+		reader1, reader2, writer := lockings[0], lockings[1], lockings[2]
+
+		lockPath := "locks/state.lock"
+		if err := reader1.AcquireReadLock(lockPath, time.Minute, "reader 1"); err != nil {
+			t.Fatalf("Failed to acquire first read lock: %v", err)
+		}
+		if err := reader2.AcquireReadLock(lockPath, time.Minute, "reader 2"); err != nil {
+			t.Fatalf("Failed to acquire second read lock: %v", err)
+		}
+
+		lock, err := writer.ReadLock(lockPath)
+		if err != nil {
+			t.Fatalf("Failed to read lock: %v", err)
+		}
+		if !lock.Shared() || len(lock.Holders) != 2 {
+			t.Fatalf("Expected shared lock with 2 holders, got %+v", lock)
+		}
+
+		if err := writer.AcquireWriteLock(lockPath, time.Minute, "writer"); !errors.Is(err, ErrLockConflict) {
+			t.Fatalf("Expected writer to conflict with readers, got %v", err)
+		}
+
+		if err := reader1.ReleaseLock(lockPath); err != nil {
+			t.Fatalf("Failed to release first read lock: %v", err)
+		}
+		if err := reader2.ReleaseLock(lockPath); err != nil {
+			t.Fatalf("Failed to release second read lock: %v", err)
+		}
+
+		if err := writer.AcquireWriteLock(lockPath, time.Minute, "writer"); err != nil {
+			t.Fatalf("Failed to acquire write lock after readers released: %v", err)
+		}
+		if err := reader1.AcquireReadLock(lockPath, time.Minute, "reader 1"); !errors.Is(err, ErrLockConflict) {
+			t.Fatalf("Expected reader to conflict with writer, got %v", err)
+		}
+	})
+}
+
+func TestSharedLockExpiry(t *testing.T) {
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	locking := &Locking{LockKey: "a", now: func() time.Time { return base }}
+
+	lock := &Lock{
+		Holders: []Holder{
+			{Owner: "a", ExpiresAt: base.Add(time.Minute)},
+			{Owner: "b", ExpiresAt: base.Add(-time.Minute)},
+		},
+	}
+
+	active := locking.unexpired(lock)
+	if active == nil || len(active.Holders) != 1 || active.Holders[0].Owner != "a" {
+		t.Fatalf("Expected only holder a to remain, got %+v", active)
+	}
+	if !active.ExpiresAt.Equal(base.Add(time.Minute)) {
+		t.Errorf("Expected expiry to follow remaining holder, got %v", active.ExpiresAt)
+	}
+	if !locking.holdsAlone(active) {
+		t.Error("Expected a to hold the lock alone")
+	}
+}
@@ -0,0 +1,62 @@
+package lock
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// LockVersion is the version of the lock file format written by this package.
+// Lock files written before the format was versioned have no version and are read as version 1.
+const LockVersion = 2
+
+// lockMigrations upgrade the fields of a lock file from one version to the next, the
+// first from version 1 to 2. Lock files in older formats are migrated when they are
+// read, so a format change only needs a migration added here and LockVersion bumped.
+var lockMigrations = []func(fields map[string]json.RawMessage) error{
+	// Version 2 only added the version field
+	func(fields map[string]json.RawMessage) error { return nil },
+}
+
+// decodeLock parses a lock file written in LockVersion or any older format.
+// Lock files written in a newer format are rejected with ErrUnsupportedLockVersion,
+// as their meaning may have changed in ways this package can't honour.
+func decodeLock(data []byte) (*Lock, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	version := 1
+	if raw, ok := fields["version"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return nil, fmt.Errorf("invalid lock file version: %w", err)
+		}
+	}
+	if version < 1 || version > LockVersion {
+		return nil, fmt.Errorf("%w: version %d, this package reads up to version %d", ErrUnsupportedLockVersion, version, LockVersion)
+	}
+
+	for v := version; v < LockVersion; v++ {
+		if err := lockMigrations[v-1](fields); err != nil {
+			return nil, fmt.Errorf("failed to migrate lock file from version %d: %w", v, err)
+		}
+	}
+
+	migrated, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	var lock Lock
+	if err := json.Unmarshal(migrated, &lock); err != nil {
+		return nil, err
+	}
+	lock.Version = version
+	return &lock, nil
+}
+
+// encodeLock serializes lock in the LockVersion format
+func encodeLock(lock *Lock) ([]byte, error) {
+	versioned := *lock
+	versioned.Version = LockVersion
+	return json.Marshal(&versioned)
+}
@@ -0,0 +1,51 @@
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDecodeLock(t *testing.T) {
+	// Written before the format was versioned
+	v1 := `{"owner":"01H","created_at":"2024-01-02T03:04:05Z","expires_at":"2024-01-02T03:05:05Z","description":"deploy","hostname":"build-1"}`
+	lock, err := decodeLock([]byte(v1))
+	if err != nil {
+		t.Fatalf("Failed to decode version 1 lock: %v", err)
+	}
+	if lock.Version != 1 || lock.Owner != "01H" || lock.Description != "deploy" || lock.Hostname != "build-1" ||
+		!lock.ExpiresAt.Equal(time.Date(2024, 1, 2, 3, 5, 5, 0, time.UTC)) {
+		t.Errorf("Unexpected version 1 lock %+v", lock)
+	}
+
+	// Locks are always written in the current format
+	data, err := encodeLock(lock)
+	if err != nil {
+		t.Fatalf("Failed to encode lock: %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("Failed to parse encoded lock: %v", err)
+	}
+	if fields["version"] != float64(LockVersion) {
+		t.Errorf("Expected version %d to be written, got %v", LockVersion, fields["version"])
+	}
+	reread, err := decodeLock(data)
+	if err != nil {
+		t.Fatalf("Failed to decode current lock: %v", err)
+	}
+	if reread.Version != LockVersion || reread.Owner != lock.Owner || !reread.ExpiresAt.Equal(lock.ExpiresAt) {
+		t.Errorf("Expected the lock to survive a round trip, got %+v", reread)
+	}
+
+	if _, err := decodeLock([]byte(`{"version":99,"owner":"01H"}`)); !errors.Is(err, ErrUnsupportedLockVersion) {
+		t.Errorf("Expected ErrUnsupportedLockVersion for a newer format, got %v", err)
+	}
+	if _, err := decodeLock([]byte(`{"version":"two"}`)); err == nil {
+		t.Error("Expected an invalid version to be rejected")
+	}
+	if len(lockMigrations) != LockVersion-1 {
+		t.Errorf("Expected a migration for every version up to %d, got %d", LockVersion, len(lockMigrations))
+	}
+}
@@ -0,0 +1,139 @@
+package lock
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// scopeParents returns the paths of the locks whose scope contains lockFilePath,
+// nearest first. locks/env/prod/service-a.lock has the parents locks/env/prod.lock,
+// locks/env.lock and locks.lock. Lock paths without an extension have no parents.
+func scopeParents(lockFilePath string) []string {
+	ext := path.Ext(lockFilePath)
+	if ext == "" {
+		return nil
+	}
+
+	var parents []string
+	parts := splitLockPath(lockFilePath)
+	for i := len(parts) - 1; i > 0; i-- {
+		parents = append(parents, strings.Join(parts[:i], "/")+ext)
+	}
+	return parents
+}
+
+// scopeDir returns the directory holding the children of lockFilePath,
+// or an empty string if lockFilePath has no extension and so can't have children
+func scopeDir(lockFilePath string) string {
+	ext := path.Ext(lockFilePath)
+	if ext == "" {
+		return ""
+	}
+	return strings.TrimSuffix(strings.Join(splitLockPath(lockFilePath), "/"), ext)
+}
+
+// checkScope returns an error wrapping ErrLockConflict if a parent or child of
+// lockFilePath is held in a way that conflicts with acquiring it. Only shared
+// read locks may be held on a parent and its children at the same time.
+// It does nothing unless Hierarchical is set.
+func (g *Locking) checkScope(backend Backend, lockFilePath string, shared bool) error {
+	if !g.Hierarchical {
+		return nil
+	}
+
+	err := g.findScopeConflict(backend, lockFilePath, shared)
+	g.observeUpdateError(lockFilePath, err)
+	return err
+}
+
+// findScopeConflict checks the parents and children of lockFilePath for conflicting locks
+func (g *Locking) findScopeConflict(backend Backend, lockFilePath string, shared bool) error {
+	for _, parent := range scopeParents(lockFilePath) {
+		lock, err := g.read(backend, parent)
+		if err != nil {
+			return fmt.Errorf("failed to read parent lock %s: %w", parent, err)
+		}
+		if g.scopeConflict(lock, shared) {
+			return fmt.Errorf("parent lock is held: %w", &LockConflictError{Path: parent, Holder: lock})
+		}
+	}
+
+	dir := scopeDir(lockFilePath)
+	if dir == "" {
+		return nil
+	}
+	lister, ok := backend.(Lister)
+	if !ok {
+		return fmt.Errorf("lock backend %T does not support listing locks, which hierarchical locking requires", backend)
+	}
+	children, err := g.list(lister, dir)
+	if err != nil {
+		return fmt.Errorf("failed to list child locks of %s: %w", lockFilePath, err)
+	}
+
+	childPaths := make([]string, 0, len(children))
+	for childPath := range children {
+		childPaths = append(childPaths, childPath)
+	}
+	sort.Strings(childPaths)
+	for _, childPath := range childPaths {
+		if g.scopeConflict(children[childPath], shared) {
+			return fmt.Errorf("child lock is held: %w", &LockConflictError{Path: childPath, Holder: children[childPath]})
+		}
+	}
+	return nil
+}
+
+// scopeConflict returns true if lock, a parent or child of a lock being acquired,
+// is held by another process in a way that conflicts
+func (g *Locking) scopeConflict(lock *Lock, shared bool) bool {
+	lock = g.unexpired(lock)
+	if lock == nil || g.holdsAlone(lock) {
+		return false
+	}
+	return !shared || !lock.Shared() || lock.Capacity > 0
+}
+
+// confirmScope checks the scope of lockFilePath again once it has been acquired,
+// catching parent or child locks acquired concurrently. On conflict the lock is
+// restored to previous, the lock it replaced, and the conflict is returned.
+func (g *Locking) confirmScope(backend Backend, lockFilePath string, shared bool, previous *Lock) error {
+	conflict := g.checkScope(backend, lockFilePath, shared)
+	if conflict == nil {
+		return nil
+	}
+
+	err := g.update(backend, lockFilePath, fmt.Sprintf("Restore lock on %s", lockFilePath), func(current *Lock) (*Lock, error) {
+		if current == nil {
+			return nil, fmt.Errorf("cannot restore lock that is not held: %s", lockFilePath)
+		}
+
+		if !current.Shared() {
+			if current.Owner != g.LockKey {
+				return nil, fmt.Errorf("cannot restore lock that is not owned by this process: lock owner %s", current.Owner)
+			}
+			return previous, nil
+		}
+
+		if current.holder(g.LockKey) == nil {
+			return nil, fmt.Errorf("cannot restore shared lock that is not held by this process")
+		}
+		current.removeHolder(g.LockKey)
+		if previous != nil {
+			if holder := previous.holder(g.LockKey); holder != nil {
+				current.Holders = append(current.Holders, *holder)
+				current.updateExpiry()
+			}
+		}
+		if !current.Shared() {
+			return nil, nil
+		}
+		return current, nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w; failed to restore lock: %v", conflict, err)
+	}
+	return conflict
+}
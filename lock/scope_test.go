@@ -0,0 +1,126 @@
+package lock
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestScopePaths(t *testing.T) {
+	parents := scopeParents("locks/env/prod/service-a.lock")
+	if expected := []string{"locks/env/prod.lock", "locks/env.lock", "locks.lock"}; !reflect.DeepEqual(parents, expected) {
+		t.Errorf("Expected parents %v, got %v", expected, parents)
+	}
+	if dir := scopeDir("locks/env/prod.lock"); dir != "locks/env/prod" {
+		t.Errorf("Expected scope dir locks/env/prod, got %q", dir)
+	}
+	if parents, dir := scopeParents("locks/env/prod"), scopeDir("locks/env/prod"); parents != nil || dir != "" {
+		t.Errorf("Expected a lock without an extension to have no scope, got %v, %q", parents, dir)
+	}
+}
+
+func TestHierarchicalLocking(t *testing.T) {
+	backends := map[string]func(repo *gittools.Repo) Backend{
+		"file":   NewFileBackend,
+		"ref":    NewRefBackend,
+		"branch": func(repo *gittools.Repo) Backend { return NewBranchBackend(repo, "") },
+	}
+
+	for name, newBackend := range backends {
+		t.Run(name, func(t *testing.T) {
+			gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+				_, remoteDir, cleanup := setupRemoteTestRepo(t)
+				defer cleanup()
+
+				var lockings []*Locking
+				for i := 0; i < 2; i++ {
+					repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+					defer cleanupRepo()
+
+					locking := NewRepoLocking(repo)
+					locking.Backend = newBackend(repo)
+					locking.Hierarchical = true
+					lockings = append(lockings, locking)
+				}
+				service, freeze := lockings[0], lockings[1]
+
+				parent, child := "locks/env/prod.lock", "locks/env/prod/service-a.lock"
+				if err := service.AcquireLock(child, time.Minute, "deploy service-a"); err != nil {
+					t.Fatalf("Failed to acquire child lock: %v", err)
+				}
+				if err := freeze.AcquireLock(parent, time.Minute, "freeze"); !errors.Is(err, ErrLockConflict) {
+					t.Fatalf("Expected a held child to block its parent, got %v", err)
+				}
+				if err := freeze.AcquireLock("locks/env.lock", time.Minute, "freeze"); !errors.Is(err, ErrLockConflict) {
+					t.Fatalf("Expected a held child to block its grandparent, got %v", err)
+				}
+				if err := freeze.AcquireLock("locks/env/staging.lock", time.Minute, "freeze"); err != nil {
+					t.Fatalf("Expected an unrelated scope to be free, got %v", err)
+				}
+
+				// The holder of a child may also take its parent
+				if err := service.AcquireLock(parent, time.Minute, "deploy all"); err != nil {
+					t.Fatalf("Failed to acquire parent of own child lock: %v", err)
+				}
+				if err := service.ReleaseLock(child); err != nil {
+					t.Fatalf("Failed to release child lock: %v", err)
+				}
+				if err := freeze.AcquireLock("locks/env/prod/service-b.lock", time.Minute, "deploy service-b"); !errors.Is(err, ErrLockConflict) {
+					t.Fatalf("Expected a held parent to block its children, got %v", err)
+				}
+				if err := service.ReleaseLock(parent); err != nil {
+					t.Fatalf("Failed to release parent lock: %v", err)
+				}
+
+				// Read locks on a parent and child may be held together
+				if err := service.AcquireReadLock(child, time.Minute, "read"); err != nil {
+					t.Fatalf("Failed to acquire child read lock: %v", err)
+				}
+				if err := freeze.AcquireReadLock(parent, time.Minute, "read"); err != nil {
+					t.Fatalf("Expected read locks on a parent and child to be compatible, got %v", err)
+				}
+				if err := freeze.AcquireLock(parent, time.Minute, "freeze"); !errors.Is(err, ErrLockConflict) {
+					t.Fatalf("Expected a child read lock to block a parent write lock, got %v", err)
+				}
+			})
+		})
+	}
+}
+
+func TestHierarchicalLockingRestoresOnConflict(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+
+		service := NewRepoLocking(repo)
+		service.Backend = NewRefBackend(repo)
+		freeze := NewRepoLocking(repo)
+		freeze.Backend = service.Backend
+
+		// Both locks are taken before either process checks the other's scope
+		parent := "locks/env/prod.lock"
+		if err := freeze.AcquireLock(parent, time.Minute, "freeze"); err != nil {
+			t.Fatalf("Failed to acquire parent lock: %v", err)
+		}
+		if err := service.AcquireLock("locks/env/prod/service-a.lock", time.Minute, "deploy"); err != nil {
+			t.Fatalf("Failed to acquire child lock: %v", err)
+		}
+
+		freeze.Hierarchical = true
+		if err := freeze.confirmScope(freeze.Backend, parent, false, nil); !errors.Is(err, ErrLockConflict) {
+			t.Fatalf("Expected ErrLockConflict, got %v", err)
+		}
+		if lock, err := freeze.ReadLock(parent); err != nil || lock != nil {
+			t.Errorf("Expected the parent lock to be given up, got %+v, %v", lock, err)
+		}
+		if stats := freeze.Contention(parent); stats.Conflicts != 1 {
+			t.Errorf("Expected the conflict to be counted, got %+v", stats)
+		}
+	})
+}
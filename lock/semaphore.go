@@ -0,0 +1,98 @@
+package lock
+
+import (
+	"fmt"
+	"time"
+)
+
+// Semaphore allows up to a fixed number of processes to hold a lock at the same time.
+// Each holder registers a slot in the lock file with its own expiry, so slots held by
+// crashed processes are reclaimed once they expire.
+type Semaphore struct {
+	locking  *Locking
+	lockPath string
+	capacity int
+}
+
+// Semaphore returns a Semaphore stored at lockPath allowing up to capacity holders
+func (g *Locking) Semaphore(lockPath string, capacity int) *Semaphore {
+	return &Semaphore{
+		locking:  g,
+		lockPath: lockPath,
+		capacity: capacity,
+	}
+}
+
+// Acquire takes a slot in the semaphore.
+// It returns a *LockConflictError if all slots are taken or the lock is held exclusively.
+// Acquiring a slot that this process already holds renews it.
+func (s *Semaphore) Acquire(expiryDuration time.Duration, description string) error {
+	if s.capacity < 1 {
+		return fmt.Errorf("semaphore capacity must be at least 1, got %d", s.capacity)
+	}
+
+	g := s.locking
+	start := time.Now()
+	var acquired, previous *Lock
+	err := g.checkScope(g.Backend, s.lockPath, false)
+	if err == nil {
+		err = g.update(g.Backend, s.lockPath, fmt.Sprintf("Acquire semaphore slot on %s", s.lockPath), func(current *Lock) (*Lock, error) {
+			previous = current
+			existingLock := g.unexpired(current)
+
+			if existingLock == nil {
+				existingLock = &Lock{
+					CreatedAt:   g.now(),
+					Description: "semaphore",
+				}
+			} else if !existingLock.Shared() || existingLock.Capacity == 0 {
+				// Held exclusively or by readers
+				return nil, &LockConflictError{Path: s.lockPath, Holder: existingLock}
+			}
+
+			held := *existingLock
+			existingLock.removeHolder(g.LockKey)
+			if len(existingLock.Holders) >= s.capacity {
+				return nil, &LockConflictError{Path: s.lockPath, Holder: &held}
+			}
+
+			existingLock.Capacity = s.capacity
+			existingLock.Holders = append(existingLock.Holders, g.newHolder(expiryDuration, description))
+			existingLock.updateExpiry()
+			acquired = existingLock
+			return existingLock, nil
+		})
+	}
+	if err == nil {
+		err = g.confirmScope(g.Backend, s.lockPath, false, previous)
+	}
+	if err != nil {
+		g.observeWait(s.lockPath, start)
+		return conflictError(s.lockPath, err)
+	}
+
+	g.acquired(s.lockPath, acquired, start)
+	return nil
+}
+
+// Refresh extends the expiry of the slot held by this process
+func (s *Semaphore) Refresh(expirationTime time.Time) error {
+	return s.locking.RefreshLock(s.lockPath, expirationTime)
+}
+
+// Release frees the slot held by this process
+func (s *Semaphore) Release() error {
+	return s.locking.ReleaseLock(s.lockPath)
+}
+
+// Holders returns the processes currently holding a slot
+func (s *Semaphore) Holders() ([]Holder, error) {
+	lock, err := s.locking.ReadLock(s.lockPath)
+	if err != nil {
+		return nil, err
+	}
+	if lock == nil {
+		return nil, nil
+	}
+	return lock.Holders, nil
+}
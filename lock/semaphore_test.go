@@ -0,0 +1,61 @@
+package lock
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestSemaphore(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		lockPath := "locks/env/prod.lock"
+
+		var semaphores []*Semaphore
+		for i := 0; i < 3; i++ {
+			repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+			defer cleanupRepo()
+
+			locking := NewRepoLocking(repo)
+			locking.Backend = NewRefBackend(repo)
+			semaphores = append(semaphores, locking.Semaphore(lockPath, 2))
+		}
+
+		if err := semaphores[0].Acquire(time.Minute, "deploy 1"); err != nil {
+			t.Fatalf("Failed to acquire first slot: %v", err)
+		}
+		if err := semaphores[1].Acquire(time.Minute, "deploy 2"); err != nil {
+			t.Fatalf("Failed to acquire second slot: %v", err)
+		}
+		if err := semaphores[2].Acquire(time.Minute, "deploy 3"); !errors.Is(err, ErrLockConflict) {
+			t.Fatalf("Expected ErrLockConflict when semaphore is full, got %v", err)
+		}
+
+		holders, err := semaphores[2].Holders()
+		if err != nil {
+			t.Fatalf("Failed to read holders: %v", err)
+		}
+		if len(holders) != 2 {
+			t.Fatalf("Expected 2 holders, got %+v", holders)
+		}
+
+		if err := semaphores[0].Release(); err != nil {
+			t.Fatalf("Failed to release slot: %v", err)
+		}
+		if err := semaphores[2].Acquire(time.Minute, "deploy 3"); err != nil {
+			t.Fatalf("Failed to acquire freed slot: %v", err)
+		}
+
+		// Exclusive locks and read locks cannot be taken while slots are held
+		if err := semaphores[0].locking.AcquireLock(lockPath, time.Minute, "exclusive"); !errors.Is(err, ErrLockConflict) {
+			t.Fatalf("Expected ErrLockConflict for exclusive lock, got %v", err)
+		}
+		if err := semaphores[0].locking.AcquireReadLock(lockPath, time.Minute, "reader"); !errors.Is(err, ErrLockConflict) {
+			t.Fatalf("Expected ErrLockConflict for read lock, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,168 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultShutdownTimeout is how long ReleaseOnSignal spends releasing locks by default
+const DefaultShutdownTimeout = 10 * time.Second
+
+// Releaser releases every lock it holds, such as a Locking or a Manager
+type Releaser interface {
+	ReleaseAll(ctx context.Context) error
+}
+
+// trackHeld records whether lock, as stored at lockPath in backend after an update,
+// is held by this process, so that ReleaseAll can release it
+func (g *Locking) trackHeld(backend Backend, lockPath string, lock *Lock) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if owned, _ := g.OwnsLock(lock); !owned {
+		delete(g.held, lockPath)
+		return
+	}
+	if g.held == nil {
+		g.held = make(map[string]Backend)
+	}
+	g.held[lockPath] = backend
+}
+
+// ReleaseAll stops every keep-alive and releases every lock this process has acquired
+// through this Locking and not yet released, including read locks and semaphore slots.
+// Locks are released in sorted order until ctx is done, which also abandons a release
+// in progress, such as a push to an unresponsive remote. Any that could not be released
+// are combined into the returned error, and are left to expire.
+func (g *Locking) ReleaseAll(ctx context.Context) error {
+	g.mu.Lock()
+	held := make(map[string]Backend, len(g.held))
+	lockPaths := make([]string, 0, len(g.held))
+	for lockPath, backend := range g.held {
+		held[lockPath] = backend
+		lockPaths = append(lockPaths, lockPath)
+	}
+	// Stop refreshing first, so that locks that can't be released before ctx is done still expire
+	for lockPath, ka := range g.keepAlives {
+		ka.cancel()
+		delete(g.keepAlives, lockPath)
+	}
+	g.mu.Unlock()
+	sort.Strings(lockPaths)
+
+	var failures []string
+	for _, lockPath := range lockPaths {
+		if err := ctx.Err(); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", lockPath, err))
+			continue
+		}
+		if err := g.release(withContext(ctx, held[lockPath]), lockPath); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", lockPath, err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to release %d locks:\n%s", len(failures), strings.Join(failures, "\n"))
+	}
+	return nil
+}
+
+// ShutdownOptions controls how ReleaseOnSignal releases locks
+type ShutdownOptions struct {
+	// Signals that trigger releasing locks. If empty, SIGINT and SIGTERM.
+	Signals []os.Signal
+
+	// Timeout limits how long releasing locks may take. If zero, DefaultShutdownTimeout is used.
+	Timeout time.Duration
+
+	// OnError, if set, is called with the error from releasing locks
+	OnError func(err error)
+
+	// Exit, if set, is called once locks have been released, instead of letting the
+	// signal terminate the process, such as to finish shutting down gracefully
+	Exit func(sig os.Signal)
+}
+
+// ReleaseOnSignal releases every lock held by releaser when the process receives one of
+// the shutdown signals, so that an interrupted job doesn't leave its locks held until they
+// expire. Once locks have been released the signal is raised again, terminating the process
+// as it would have been without ReleaseOnSignal, unless ShutdownOptions.Exit is set.
+// A second signal while locks are being released terminates the process immediately.
+// Call the returned function to stop watching for signals, such as after releasing locks
+// during a normal exit.
+func ReleaseOnSignal(releaser Releaser, options ShutdownOptions) (stop func()) {
+	signals := options.Signals
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+	stopWatching := watchSignals(received, releaser, options, func() {
+		signal.Stop(received)
+	})
+	return func() {
+		signal.Stop(received)
+		stopWatching()
+	}
+}
+
+// watchSignals releases every lock held by releaser once a signal is received, see ReleaseOnSignal.
+// reset is called before releasing locks, to restore the default handling of signals.
+func watchSignals(received <-chan os.Signal, releaser Releaser, options ShutdownOptions, reset func()) (stop func()) {
+	timeout := options.Timeout
+	if timeout == 0 {
+		timeout = DefaultShutdownTimeout
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-done:
+			return
+		case sig := <-received:
+			reset()
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			err := releaser.ReleaseAll(ctx)
+			cancel()
+			if err != nil && options.OnError != nil {
+				options.OnError(err)
+			}
+
+			if options.Exit != nil {
+				options.Exit(sig)
+				return
+			}
+			raise(sig)
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+		})
+		<-stopped
+	}
+}
+
+// raise sends sig to this process, whose handling has been reset to the default,
+// exiting instead if that isn't possible on this platform
+func raise(sig os.Signal) {
+	if process, err := os.FindProcess(os.Getpid()); err == nil {
+		if err := process.Signal(sig); err == nil {
+			return
+		}
+	}
+	os.Exit(1)
+}
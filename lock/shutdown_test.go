@@ -0,0 +1,208 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestLockingReleaseAll(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+
+		locking := NewRepoLocking(repo)
+		locking.Backend = NewRefBackend(repo)
+		other := NewRepoLocking(repo)
+		other.Backend = NewRefBackend(repo)
+
+		if err := locking.AcquireLock("locks/a.lock", time.Minute, "a"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+		if err := locking.AcquireReadLock("locks/read.lock", time.Minute, "read"); err != nil {
+			t.Fatalf("Failed to acquire read lock: %v", err)
+		}
+		if err := other.AcquireReadLock("locks/read.lock", time.Minute, "other"); err != nil {
+			t.Fatalf("Failed to acquire read lock: %v", err)
+		}
+		if err := locking.Semaphore("locks/pool.lock", 2).Acquire(time.Minute, "slot"); err != nil {
+			t.Fatalf("Failed to acquire semaphore slot: %v", err)
+		}
+		if err := locking.AcquireAll([]string{"locks/m1.lock", "locks/m2.lock"}, time.Minute, "multi"); err != nil {
+			t.Fatalf("Failed to acquire locks: %v", err)
+		}
+		if err := locking.AcquireLock("locks/released.lock", time.Minute, "released"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+		if err := locking.ReleaseLock("locks/released.lock"); err != nil {
+			t.Fatalf("Failed to release lock: %v", err)
+		}
+		if _, err := locking.KeepAlive(context.Background(), "locks/a.lock", time.Hour); err != nil {
+			t.Fatalf("Failed to start keep-alive: %v", err)
+		}
+
+		if err := locking.ReleaseAll(context.Background()); err != nil {
+			t.Fatalf("Failed to release all locks: %v", err)
+		}
+		if len(locking.keepAlives) != 0 {
+			t.Errorf("Expected keep-alives to be stopped, got %v", locking.keepAlives)
+		}
+		for _, lockPath := range []string{"locks/a.lock", "locks/m1.lock", "locks/m2.lock"} {
+			if lock, err := other.ReadLock(lockPath); err != nil || lock != nil {
+				t.Errorf("Expected %s to be released, got %+v, %v", lockPath, lock, err)
+			}
+		}
+		if lock, err := other.ReadLock("locks/read.lock"); err != nil || lock == nil || len(lock.Holders) != 1 || lock.Holders[0].Owner != other.LockKey {
+			t.Errorf("Expected only the other reader to remain, got %+v, %v", lock, err)
+		}
+		if holders, err := other.Semaphore("locks/pool.lock", 2).Holders(); err != nil || len(holders) != 0 {
+			t.Errorf("Expected the semaphore slot to be released, got %+v, %v", holders, err)
+		}
+
+		// Nothing is left to release
+		if err := locking.ReleaseAll(context.Background()); err != nil {
+			t.Errorf("Expected nothing to release, got %v", err)
+		}
+	})
+}
+
+func TestLockingReleaseAllCancelled(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+
+		locking := NewRepoLocking(repo)
+		locking.Backend = NewRefBackend(repo)
+		manager := NewManager(locking)
+
+		if err := manager.Acquire(context.Background(), "locks/a.lock", time.Minute, "a"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := manager.ReleaseAll(ctx); err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+			t.Errorf("Expected the release to be cancelled, got %v", err)
+		}
+		if len(manager.Held()) != 0 || len(locking.keepAlives) != 0 {
+			t.Errorf("Expected the lock to no longer be refreshed, got %+v", manager.Held())
+		}
+
+		// The lock is left to expire, and can still be released through the Locking
+		if err := locking.ReleaseAll(ctx); err == nil {
+			t.Error("Expected the release to be cancelled")
+		}
+		if lock, err := locking.ReadLock("locks/a.lock"); err != nil || lock == nil {
+			t.Errorf("Expected the lock to still be held, got %+v, %v", lock, err)
+		}
+		if err := locking.ReleaseAll(context.Background()); err != nil {
+			t.Fatalf("Failed to release all locks: %v", err)
+		}
+		if lock, err := locking.ReadLock("locks/a.lock"); err != nil || lock != nil {
+			t.Errorf("Expected the lock to be released, got %+v, %v", lock, err)
+		}
+	})
+}
+
+func TestReleaseAllAbandonsBlockedRelease(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+
+		locking := NewRepoLocking(repo)
+		locking.Backend = NewRefBackend(repo)
+		manager := NewManager(NewRepoLocking(repo))
+		manager.Locking.Backend = NewRefBackend(repo)
+
+		if err := locking.AcquireLock("locks/a.lock", time.Minute, "a"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+		if err := manager.Acquire(context.Background(), "locks/b.lock", time.Minute, "b"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+
+		// The remote stops responding to pushes
+		faults := &gittools.FaultInjector{}
+		faults.On("push").Delay(time.Minute)
+		repo.Client.Hooks = append(repo.Client.Hooks, faults)
+
+		for name, releaser := range map[string]Releaser{"Locking": locking, "Manager": manager} {
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			start := time.Now()
+			err := releaser.ReleaseAll(ctx)
+			cancel()
+			if err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+				t.Errorf("%s: expected the release to be abandoned at the deadline, got %v", name, err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("%s: expected the release to be abandoned promptly, took %v", name, elapsed)
+			}
+		}
+	})
+}
+
+type releaserFunc func(ctx context.Context) error
+
+func (f releaserFunc) ReleaseAll(ctx context.Context) error {
+	return f(ctx)
+}
+
+func TestWatchSignals(t *testing.T) {
+	failure := errors.New("release failed")
+	released := make(chan time.Time, 1)
+	releaser := releaserFunc(func(ctx context.Context) error {
+		deadline, _ := ctx.Deadline()
+		released <- deadline
+		return failure
+	})
+
+	received := make(chan os.Signal, 1)
+	var reset bool
+	var reported error
+	exited := make(chan os.Signal, 1)
+	stop := watchSignals(received, releaser, ShutdownOptions{
+		Timeout: time.Minute,
+		OnError: func(err error) { reported = err },
+		Exit:    func(sig os.Signal) { exited <- sig },
+	}, func() { reset = true })
+
+	received <- syscall.SIGTERM
+	if sig := <-exited; sig != syscall.SIGTERM {
+		t.Errorf("Expected exit on SIGTERM, got %v", sig)
+	}
+	stop()
+	if deadline := <-released; time.Until(deadline) < 50*time.Second {
+		t.Errorf("Expected locks to be released within the timeout, got deadline %v", deadline)
+	}
+	if !reset {
+		t.Error("Expected signal handling to be reset before releasing locks")
+	}
+	if reported != failure {
+		t.Errorf("Expected the release error to be reported, got %v", reported)
+	}
+
+	// Stopping before a signal releases nothing
+	stop = watchSignals(make(chan os.Signal), releaser, ShutdownOptions{}, func() {})
+	stop()
+	stop()
+	select {
+	case <-released:
+		t.Error("Expected no locks to be released after stopping")
+	default:
+	}
+}
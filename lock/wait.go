@@ -0,0 +1,63 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cloudflare/backoff"
+)
+
+// maxWaitInterval is the longest AcquireLockWait sleeps between attempts
+const maxWaitInterval = 5 * time.Second
+
+// Acquire acquires the lock at lockFilePath, retrying with exponential backoff while it
+// is held by another process until ctx is done. Use context.WithTimeout or
+// context.WithDeadline to bound the wait. If the lock isn't acquired in time, the
+// returned error wraps the last *LockConflictError. Use TryAcquire to make a single attempt.
+func (g *Locking) Acquire(ctx context.Context, lockFilePath string, expiryDuration time.Duration, description string) error {
+	return g.AcquireLockWait(ctx, lockFilePath, expiryDuration, description, 0)
+}
+
+// AcquireLockWait acquires the lock at lockFilePath, retrying with exponential backoff
+// while it is held by another process.
+// If the lock cannot be acquired within timeout, or before ctx is done, an error wrapping
+// ErrLockConflict is returned. A timeout of zero waits until ctx is done.
+// Errors other than ErrLockConflict are returned immediately.
+func (g *Locking) AcquireLockWait(ctx context.Context, lockFilePath string, expiryDuration time.Duration, description string, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	g.recordStats(lockFilePath, func(stats *ContentionStats) {
+		stats.Waiters++
+	})
+	defer g.recordStats(lockFilePath, func(stats *ContentionStats) {
+		stats.Waiters--
+	})
+
+	b := backoff.New(maxWaitInterval, 50*time.Millisecond)
+	for {
+		err := g.AcquireLock(lockFilePath, expiryDuration, description)
+		if err == nil || !errors.Is(err, ErrLockConflict) {
+			return err
+		}
+
+		slept := time.Now()
+		var done bool
+		select {
+		case <-ctx.Done():
+			done = true
+		case <-time.After(b.Duration()):
+		}
+		g.recordStats(lockFilePath, func(stats *ContentionStats) {
+			stats.WaitTime += time.Since(slept)
+		})
+		if done {
+			return fmt.Errorf("gave up waiting for lock %s: %w", lockFilePath, err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestAcquireLockWait(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		var lockings []*Locking
+		for i := 0; i < 2; i++ {
+			repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+			defer cleanupRepo()
+
+			locking := NewRepoLocking(repo)
+			locking.Backend = NewRefBackend(repo)
+			lockings = append(lockings, locking)
+		}
+		holder, waiter := lockings[0], lockings[1]
+
+		lockPath := "locks/wait.lock"
+		if err := holder.AcquireLock(lockPath, time.Minute, "holder"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+
+		start := time.Now()
+		err := waiter.AcquireLockWait(context.Background(), lockPath, time.Minute, "waiter", 300*time.Millisecond)
+		if !errors.Is(err, ErrLockConflict) {
+			t.Fatalf("Expected timeout to return ErrLockConflict, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+			t.Errorf("Expected to wait for the timeout, returned after %v", elapsed)
+		}
+
+		released := make(chan error, 1)
+		go func() {
+			time.Sleep(200 * time.Millisecond)
+			released <- holder.ReleaseLock(lockPath)
+		}()
+
+		if err := waiter.AcquireLockWait(context.Background(), lockPath, time.Minute, "waiter", 30*time.Second); err != nil {
+			t.Fatalf("Expected to acquire lock once released, got %v", err)
+		}
+		if err := <-released; err != nil {
+			t.Fatalf("Failed to release lock: %v", err)
+		}
+
+		lock, err := waiter.ReadLock(lockPath)
+		if err != nil {
+			t.Fatalf("Failed to read lock: %v", err)
+		}
+		if owns, _ := waiter.OwnsLock(lock); !owns {
+			t.Errorf("Expected waiter to own the lock, got %+v", lock)
+		}
+	})
+}
+
+func TestTryAcquireAndAcquire(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		var lockings []*Locking
+		for i := 0; i < 2; i++ {
+			repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+			defer cleanupRepo()
+
+			locking := NewRepoLocking(repo)
+			locking.Backend = NewRefBackend(repo)
+			lockings = append(lockings, locking)
+		}
+		holder, waiter := lockings[0], lockings[1]
+
+		lockPath := "locks/try.lock"
+		if err := holder.TryAcquire(lockPath, time.Minute, "holder"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+
+		err := waiter.TryAcquire(lockPath, time.Minute, "waiter")
+		var conflict *LockConflictError
+		if !errors.Is(err, ErrLockConflict) || !errors.As(err, &conflict) {
+			t.Fatalf("Expected a LockConflictError, got %v", err)
+		}
+		if conflict.Path != lockPath || conflict.Holder == nil || conflict.Holder.Owner != holder.LockKey ||
+			conflict.Holder.Description != "holder" {
+			t.Errorf("Expected the conflict to describe the holder, got %+v", conflict)
+		}
+
+		// Acquire waits until the context deadline, keeping the holder in the error
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		err = waiter.Acquire(ctx, lockPath, time.Minute, "waiter")
+		if !errors.As(err, &conflict) || conflict.Holder == nil || conflict.Holder.Owner != holder.LockKey {
+			t.Fatalf("Expected Acquire to give up with a LockConflictError, got %v", err)
+		}
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			_ = holder.ReleaseLock(lockPath)
+		}()
+		if err := waiter.Acquire(context.Background(), lockPath, time.Minute, "waiter"); err != nil {
+			t.Fatalf("Expected to acquire lock once released, got %v", err)
+		}
+	})
+}
+
+func TestLockConflictErrorMessage(t *testing.T) {
+	expires := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		err      *LockConflictError
+		expected string
+	}{
+		{
+			err:      &LockConflictError{Path: "a.lock", Holder: &Lock{Owner: "owner", Description: "deploy", ExpiresAt: expires}},
+			expected: "lock conflict: resource is already locked: a.lock is held by owner (deploy) until 2024-01-02T03:04:05Z",
+		},
+		{
+			err:      &LockConflictError{Path: "a.lock", Holder: &Lock{Holders: []Holder{{}, {}}, ExpiresAt: expires}},
+			expected: "lock conflict: resource is already locked: a.lock is held by 2 readers until 2024-01-02T03:04:05Z",
+		},
+		{
+			err:      &LockConflictError{Path: "a.lock", Err: errors.New("push rejected")},
+			expected: "a.lock: push rejected",
+		},
+	}
+
+	for _, test := range tests {
+		if message := test.err.Error(); message != test.expected {
+			t.Errorf("Expected %q, got %q", test.expected, message)
+		}
+		if !errors.Is(test.err, ErrLockConflict) {
+			t.Errorf("Expected %v to match ErrLockConflict", test.err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WithLock acquires the lock at lockPath, waiting while it is held by another process
+// until ctx is done, then runs fn while refreshing the lock as set by the Renewal policy
+// of locking, every expiry/3 by default.
+// The lock is released when fn returns, including when it panics or ctx is cancelled.
+//
+// The context passed to fn is cancelled if ctx is, or if the lock is lost because
+// another process broke or took it over, in which case WithLock returns an error
+// wrapping ErrLockNotOwned unless fn returned an error of its own.
+// A failure to release the lock is also returned if fn succeeded.
+func WithLock(ctx context.Context, locking *Locking, lockPath string, expiry time.Duration, description string, fn func(ctx context.Context) error) (err error) {
+	if err := locking.AcquireLockWait(ctx, lockPath, expiry, description, 0); err != nil {
+		return err
+	}
+
+	fnCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	var lost error
+	defer func() {
+		// Stop refreshing before releasing, which also runs if fn panics
+		cancel()
+		<-done
+
+		if lost != nil {
+			if err == nil {
+				err = fmt.Errorf("lock %s was lost while held: %w", lockPath, lost)
+			}
+			return
+		}
+		if releaseErr := locking.ReleaseLock(lockPath); releaseErr != nil && err == nil {
+			err = fmt.Errorf("failed to release lock %s: %w", lockPath, releaseErr)
+		}
+	}()
+
+	errs, err := locking.KeepAlive(fnCtx, lockPath, 0)
+	if err != nil {
+		close(done)
+		return fmt.Errorf("failed to keep lock %s alive: %w", lockPath, err)
+	}
+	go func() {
+		defer close(done)
+		// Other refresh failures are retried on the next interval
+		for refreshErr := range errs {
+			if errors.Is(refreshErr, ErrLockNotOwned) {
+				lost = refreshErr
+				cancel()
+			}
+		}
+	}()
+
+	return fn(fnCtx)
+}
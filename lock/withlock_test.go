@@ -0,0 +1,122 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestWithLock(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+
+		locking := NewRepoLocking(repo)
+		locking.Backend = NewRefBackend(repo)
+		other := NewRepoLocking(repo)
+		other.Backend = NewRefBackend(repo)
+
+		lockPath := "locks/with.lock"
+		assertFree := func(when string) {
+			t.Helper()
+			if lock, err := locking.ReadLock(lockPath); err != nil || lock != nil {
+				t.Errorf("Expected the lock to be released %s, got %+v, %v", when, lock, err)
+			}
+		}
+
+		ran := false
+		err := WithLock(context.Background(), locking, lockPath, time.Minute, "job", func(ctx context.Context) error {
+			ran = true
+			if err := other.AcquireLock(lockPath, time.Minute, "other"); !errors.Is(err, ErrLockConflict) {
+				t.Errorf("Expected the lock to be held while fn runs, got %v", err)
+			}
+			return nil
+		})
+		if err != nil || !ran {
+			t.Fatalf("Expected fn to run, got %v", err)
+		}
+		assertFree("after fn returns")
+
+		fnErr := errors.New("job failed")
+		if err := WithLock(context.Background(), locking, lockPath, time.Minute, "job", func(ctx context.Context) error {
+			return fnErr
+		}); !errors.Is(err, fnErr) {
+			t.Errorf("Expected fn's error, got %v", err)
+		}
+		assertFree("after fn fails")
+
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected the panic to propagate")
+				}
+			}()
+			WithLock(context.Background(), locking, lockPath, time.Minute, "job", func(ctx context.Context) error {
+				panic("job panicked")
+			})
+		}()
+		assertFree("after fn panics")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		if err := WithLock(ctx, locking, lockPath, time.Minute, "job", func(ctx context.Context) error {
+			cancel()
+			<-ctx.Done()
+			return ctx.Err()
+		}); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+		assertFree("after ctx is cancelled")
+
+		// Wait for a lock held by another process
+		if err := other.AcquireLock(lockPath, time.Minute, "other"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+		go func() {
+			time.Sleep(200 * time.Millisecond)
+			other.ReleaseLock(lockPath)
+		}()
+		if err := WithLock(context.Background(), locking, lockPath, time.Minute, "job", func(ctx context.Context) error {
+			return nil
+		}); err != nil {
+			t.Errorf("Expected to acquire the lock once released, got %v", err)
+		}
+		assertFree("after waiting")
+	})
+}
+
+func TestWithLockLost(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+
+		locking := NewRepoLocking(repo)
+		locking.Backend = NewRefBackend(repo)
+		other := NewRepoLocking(repo)
+		other.Backend = NewRefBackend(repo)
+
+		lockPath := "locks/lost.lock"
+		err := WithLock(context.Background(), locking, lockPath, 300*time.Millisecond, "job", func(ctx context.Context) error {
+			if err := other.BreakLock(lockPath, BreakOptions{}); err != nil {
+				t.Errorf("Failed to break lock: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(10 * time.Second):
+				t.Error("Expected fn's context to be cancelled when the lock is lost")
+			}
+			return nil
+		})
+		if !errors.Is(err, ErrLockNotOwned) {
+			t.Errorf("Expected ErrLockNotOwned, got %v", err)
+		}
+	})
+}
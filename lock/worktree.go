@@ -0,0 +1,86 @@
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ocuroot/gittools"
+)
+
+// updateInWorktree applies fn to the lock files on the lock branch, committing the
+// change in a temporary linked worktree and pushing it as updateAllObserved does
+func (b *fileBackend) updateInWorktree(lockFilePaths []string, message string, fn func(current map[string]*Lock) (map[string]*Lock, error), observe func(name MetricName)) error {
+	tip, push, err := b.lockBranchTip(b.branch)
+	if err != nil {
+		return err
+	}
+
+	start := tip
+	if start == "" {
+		// A worktree needs a commit to check out, so start the branch with an empty one
+		tree, err := b.repo.MkTree(nil)
+		if err != nil {
+			return fmt.Errorf("failed to write lock tree: %w", err)
+		}
+		if start, err = b.repo.CommitTree(tree, "Create lock branch"); err != nil {
+			return fmt.Errorf("failed to create lock branch: %w", err)
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "gittools-lock-")
+	if err != nil {
+		return fmt.Errorf("failed to create lock worktree directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Detach so concurrent updates from this repository don't need the branch checked out
+	worktree, err := b.repo.AddWorktree(dir, gittools.WorktreeOptions{Commit: start, Detach: true})
+	if err != nil {
+		return fmt.Errorf("failed to create lock worktree: %w", err)
+	}
+	defer func() {
+		if err := b.repo.RemoveWorktree(dir); err != nil {
+			fmt.Printf("Warning: failed to remove lock worktree: %v\n", err)
+		}
+	}()
+
+	inner := &fileBackend{repo: worktree, codec: b.codec}
+	current, err := readLocks(lockFilePaths, inner.Read)
+	if err != nil {
+		return err
+	}
+
+	next, err := fn(current)
+	if err != nil {
+		return err
+	}
+
+	committed, err := inner.commitLocks(message, current, next)
+	if err != nil || !committed {
+		return err
+	}
+
+	if push {
+		if _, err := inner.pushWithRetry(b.branch, observe); err != nil {
+			return lockPushError(err)
+		}
+		return nil
+	}
+
+	head, err := worktree.RevParse("HEAD")
+	if err != nil {
+		return fmt.Errorf("failed to resolve lock commit: %w", err)
+	}
+	expected := tip
+	if expected == "" {
+		expected = gittools.NullHash
+	}
+	if err := b.repo.UpdateRef("refs/heads/"+b.branch, head, expected); err != nil {
+		if errors.Is(err, gittools.ErrRefMismatch) {
+			return fmt.Errorf("%w: %v", ErrLockConflict, err)
+		}
+		return fmt.Errorf("failed to update lock branch: %w", err)
+	}
+	return nil
+}
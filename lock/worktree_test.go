@@ -0,0 +1,140 @@
+package lock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools"
+)
+
+func TestWorktreeFileBackend(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+		otherRepo, cleanupOther := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupOther()
+
+		// Local changes, staged and unstaged, that lock updates must leave alone
+		if err := os.WriteFile(filepath.Join(repo.RepoPath, "README.md"), []byte("unstaged\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(repo.RepoPath, "staged.txt"), []byte("staged\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if _, _, err := repo.Client.Exec("add", "staged.txt"); err != nil {
+			t.Fatalf("Failed to stage file: %v", err)
+		}
+		snapshot := func() string {
+			t.Helper()
+			head, err := repo.RevParse("HEAD")
+			if err != nil {
+				t.Fatalf("Failed to get HEAD: %v", err)
+			}
+			status, _, err := repo.Client.Exec("status", "--porcelain", "--branch")
+			if err != nil {
+				t.Fatalf("Failed to get status: %v", err)
+			}
+			return head + "\n" + string(status)
+		}
+		before := snapshot()
+
+		locking := NewRepoLocking(repo)
+		locking.Backend = NewFileBackendWithOptions(repo, FileBackendOptions{Worktree: true})
+		other := NewRepoLocking(otherRepo)
+		other.Backend = NewFileBackendWithOptions(otherRepo, FileBackendOptions{Worktree: true})
+
+		lockPath := "locks/worktree.lock"
+		if err := locking.AcquireLock(lockPath, time.Minute, "worktree"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+		if after := snapshot(); after != before {
+			t.Errorf("Expected the working tree, index and branch to be untouched, before:\n%s\nafter:\n%s", before, after)
+		}
+		if _, err := os.Stat(filepath.Join(repo.RepoPath, lockPath)); !os.IsNotExist(err) {
+			t.Errorf("Expected no lock file in the working tree, got %v", err)
+		}
+
+		// The lock is pushed to the lock branch, not the current branch
+		refs, err := repo.LsRemote("origin", "refs/heads/"+DefaultLockBranch)
+		if err != nil {
+			t.Fatalf("Failed to list remote refs: %v", err)
+		}
+		if _, exists := refs["refs/heads/"+DefaultLockBranch]; !exists {
+			t.Errorf("Expected the lock branch to be pushed, got %v", refs)
+		}
+		if lock, err := other.ReadLock(lockPath); err != nil || lock == nil || lock.Owner != locking.LockKey {
+			t.Errorf("Expected the other clone to read the lock, got %+v, %v", lock, err)
+		}
+		if err := other.AcquireLock(lockPath, time.Minute, "other"); !errors.Is(err, ErrLockConflict) {
+			t.Errorf("Expected ErrLockConflict, got %v", err)
+		}
+
+		// Updates from the other clone are rebased onto the lock branch
+		if err := other.AcquireLock("locks/other.lock", time.Minute, "other"); err != nil {
+			t.Fatalf("Failed to acquire a second lock: %v", err)
+		}
+		if err := locking.ReleaseLock(lockPath); err != nil {
+			t.Fatalf("Failed to release lock: %v", err)
+		}
+		if lock, err := other.ReadLock("locks/other.lock"); err != nil || lock == nil {
+			t.Errorf("Expected the second lock to survive the release, got %+v, %v", lock, err)
+		}
+		if after := snapshot(); after != before {
+			t.Errorf("Expected the working tree, index and branch to be untouched, before:\n%s\nafter:\n%s", before, after)
+		}
+
+		// Temporary worktrees are removed
+		worktrees, _, err := repo.Client.Exec("worktree", "list", "--porcelain")
+		if err != nil {
+			t.Fatalf("Failed to list worktrees: %v", err)
+		}
+		if count := strings.Count(string(worktrees), "worktree "); count != 1 {
+			t.Errorf("Expected only the main worktree, got:\n%s", worktrees)
+		}
+	})
+}
+
+func TestWorktreeFileBackendOffline(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		_, remoteDir, cleanup := setupRemoteTestRepo(t)
+		defer cleanup()
+
+		repo, cleanupRepo := checkoutRemoteTestRepo(t, remoteDir)
+		defer cleanupRepo()
+
+		backend := NewFileBackendWithOptions(repo, FileBackendOptions{Offline: true, Worktree: true, Branch: "local-locks"})
+		locking := NewRepoLocking(repo)
+		locking.Backend = backend
+		other := NewRepoLocking(repo)
+		other.Backend = backend
+
+		lockPath := "locks/offline.lock"
+		if err := locking.AcquireLock(lockPath, time.Minute, "offline"); err != nil {
+			t.Fatalf("Failed to acquire lock: %v", err)
+		}
+		if exists, err := repo.BranchExists("local-locks", false); err != nil || !exists {
+			t.Errorf("Expected the local lock branch to be created, got %v, %v", exists, err)
+		}
+		if err := other.AcquireLock(lockPath, time.Minute, "other"); !errors.Is(err, ErrLockConflict) {
+			t.Errorf("Expected ErrLockConflict, got %v", err)
+		}
+		if err := locking.ReleaseLock(lockPath); err != nil {
+			t.Fatalf("Failed to release lock: %v", err)
+		}
+
+		refs, err := repo.LsRemote("origin", "refs/heads/local-locks")
+		if err != nil {
+			t.Fatalf("Failed to list remote refs: %v", err)
+		}
+		if len(refs) != 0 {
+			t.Errorf("Expected the offline lock branch not to be pushed, got %v", refs)
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package gittools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaintenanceTask is a task run by git maintenance
+type MaintenanceTask string
+
+const (
+	// MaintenanceCommitGraph writes the commit-graph file, which speeds up history walks
+	MaintenanceCommitGraph MaintenanceTask = "commit-graph"
+
+	// MaintenancePrefetch fetches from every remote in the background into refs/prefetch/,
+	// without updating remote-tracking branches, so later fetches have less to download
+	MaintenancePrefetch MaintenanceTask = "prefetch"
+
+	// MaintenanceGC runs git gc, the default task when none is given
+	MaintenanceGC MaintenanceTask = "gc"
+
+	// MaintenanceLooseObjects packs loose objects
+	MaintenanceLooseObjects MaintenanceTask = "loose-objects"
+
+	// MaintenanceIncrementalRepack repacks objects into larger packs using the multi-pack-index
+	MaintenanceIncrementalRepack MaintenanceTask = "incremental-repack"
+
+	// MaintenancePackRefs packs loose refs into packed-refs
+	MaintenancePackRefs MaintenanceTask = "pack-refs"
+)
+
+// MaintenanceRegister adds the repository to the maintenance.repo list in the user's
+// global config, so the background maintenance scheduled by `git maintenance start`
+// prefetches and writes the commit-graph for it. It also disables automatic gc after
+// commands in the repository, which the scheduled tasks replace.
+// Registering a repository more than once has no further effect.
+func (r *Repo) MaintenanceRegister() error {
+	stdout, stderr, err := r.Client.Exec("maintenance", "register")
+	if err != nil {
+		return fmt.Errorf("git maintenance register failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return nil
+}
+
+// MaintenanceUnregister removes the repository from the maintenance.repo list in the
+// user's global config. Unregistering a repository that is not registered is not an error.
+func (r *Repo) MaintenanceUnregister() error {
+	stdout, stderr, err := r.Client.Exec("maintenance", "unregister")
+	if err != nil {
+		// Before git 2.41 unregister fails if the repository is not registered
+		if strings.Contains(string(stderr), "is not registered") {
+			return nil
+		}
+		return fmt.Errorf("git maintenance unregister failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return nil
+}
+
+// MaintenanceRun runs the given maintenance tasks in the repository now, in the order
+// given. If no tasks are given, git runs the tasks enabled by maintenance.<task>.enabled,
+// which is only gc by default.
+func (r *Repo) MaintenanceRun(tasks ...MaintenanceTask) error {
+	args := []string{"maintenance", "run", "--quiet"}
+	for _, task := range tasks {
+		args = append(args, "--task="+string(task))
+	}
+
+	stdout, stderr, err := r.Client.Exec(args...)
+	if err != nil {
+		return fmt.Errorf("git maintenance run failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return nil
+}
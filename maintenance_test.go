@@ -0,0 +1,56 @@
+package gittools
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMaintenance(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		repo := openTestRepo(t)
+		writeAndCommit(t, repo, "a.txt", "a\n")
+
+		// Keep registrations out of the user's global config
+		repo.Client.Env = map[string]string{
+			"GIT_CONFIG_GLOBAL": filepath.Join(testDir, "gitconfig"),
+		}
+		global := ConfigOptions{Scope: ConfigScopeGlobal}
+
+		if err := repo.MaintenanceUnregister(); err != nil {
+			t.Errorf("Expected unregistering an unregistered repository to succeed, got %v", err)
+		}
+
+		if err := repo.MaintenanceRegister(); err != nil {
+			t.Fatalf("MaintenanceRegister failed: %v", err)
+		}
+		if err := repo.MaintenanceRegister(); err != nil {
+			t.Fatalf("Expected registering twice to succeed, got %v", err)
+		}
+		if _, err := repo.ConfigGetWithOptions("maintenance.repo", global); err != nil {
+			t.Errorf("Expected maintenance.repo to be set, got %v", err)
+		}
+
+		if err := repo.MaintenanceRun(MaintenanceCommitGraph, MaintenancePackRefs); err != nil {
+			t.Fatalf("MaintenanceRun failed: %v", err)
+		}
+		gitDir, err := repo.gitDir()
+		if err != nil {
+			t.Fatalf("Failed to get git dir: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(gitDir, "objects", "info", "commit-graphs")); err != nil {
+			t.Errorf("Expected a commit-graph to be written: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(gitDir, "packed-refs")); err != nil {
+			t.Errorf("Expected refs to be packed: %v", err)
+		}
+
+		if err := repo.MaintenanceUnregister(); err != nil {
+			t.Fatalf("MaintenanceUnregister failed: %v", err)
+		}
+		if _, err := repo.ConfigGetWithOptions("maintenance.repo", global); !errors.Is(err, ErrConfigNotFound) {
+			t.Errorf("Expected maintenance.repo to be removed, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package gittools
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Git merge error types
+var (
+	// ErrMergeConflict is returned when a merge stops on conflicts, or is continued
+	// before they are resolved
+	ErrMergeConflict = errors.New("git merge failed: merge conflict")
+
+	// ErrNoMergeInProgress is returned when reading the state of a merge while none is in progress
+	ErrNoMergeInProgress = errors.New("no merge in progress")
+)
+
+// Merge merges the specified branch or commit into the current branch. If the merge
+// stops on conflicts it returns ErrMergeConflict, and the merge can be finished with
+// MergeContinue once they are resolved, or abandoned with MergeAbort.
+func (r *Repo) Merge(ref string) error {
+	stdout, stderr, err := r.Client.ExecWithOptions(ExecOptions{
+		Env: map[string]string{"GIT_EDITOR": "true"},
+	}, "merge", ref)
+	if err != nil {
+		return mergeError(err, stdout, stderr)
+	}
+	return nil
+}
+
+// MergeAbort abandons a conflicted merge, restoring the state before it started
+func (r *Repo) MergeAbort() error {
+	stdout, stderr, err := r.Client.Exec("merge", "--abort")
+	if err != nil {
+		return fmt.Errorf("git merge abort failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return nil
+}
+
+// MergeContinue commits a merge once its conflicts have been resolved and staged,
+// using the message in MERGE_MSG
+func (r *Repo) MergeContinue() error {
+	// GIT_EDITOR keeps the prepared merge message without opening an editor
+	stdout, stderr, err := r.Client.ExecWithOptions(ExecOptions{
+		Env: map[string]string{"GIT_EDITOR": "true"},
+	}, "merge", "--continue")
+	if err != nil {
+		return mergeError(err, stdout, stderr)
+	}
+	return nil
+}
+
+// MergeHeads returns the commits being merged into the current branch by a stopped
+// merge, more than one for an octopus merge.
+// Returns ErrNoMergeInProgress if no merge is in progress.
+func (r *Repo) MergeHeads() ([]string, error) {
+	content, err := r.readMergeFile("MERGE_HEAD")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(content), nil
+}
+
+// MergeMessage returns the commit message prepared for a stopped merge, which
+// MergeContinue uses. It may be changed with SetMergeMessage.
+// Returns ErrNoMergeInProgress if no merge is in progress.
+func (r *Repo) MergeMessage() (string, error) {
+	if _, err := r.readMergeFile("MERGE_HEAD"); err != nil {
+		return "", err
+	}
+	return r.readMergeFile("MERGE_MSG")
+}
+
+// SetMergeMessage replaces the commit message prepared for a stopped merge.
+// Returns ErrNoMergeInProgress if no merge is in progress.
+func (r *Repo) SetMergeMessage(message string) error {
+	if _, err := r.readMergeFile("MERGE_HEAD"); err != nil {
+		return err
+	}
+	gitDir, err := r.gitDir()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(gitDir, "MERGE_MSG"), []byte(message), 0644); err != nil {
+		return fmt.Errorf("failed to write MERGE_MSG: %w", err)
+	}
+	return nil
+}
+
+// readMergeFile reads a file describing the merge in progress from the git directory
+func (r *Repo) readMergeFile(name string) (string, error) {
+	gitDir, err := r.gitDir()
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(filepath.Join(gitDir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrNoMergeInProgress
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return string(content), nil
+}
+
+// mergeError parses the output of a failed merge to determine the specific error type
+func mergeError(err error, stdout, stderr []byte) error {
+	output := string(stdout) + string(stderr)
+	switch {
+	case strings.Contains(output, "CONFLICT") ||
+		strings.Contains(output, "needs merge") ||
+		strings.Contains(output, "unmerged files"):
+		return fmt.Errorf("%w: %s", ErrMergeConflict, output)
+	case strings.Contains(output, "There is no merge in progress"):
+		return fmt.Errorf("%w: %s", ErrNoMergeInProgress, output)
+	default:
+		return fmt.Errorf("git merge failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+}
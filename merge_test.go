@@ -0,0 +1,91 @@
+package gittools
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMergeConflictLoop(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		repo := openTestRepo(t)
+		if err := repo.CreateBranch("feature"); err != nil {
+			t.Fatalf("Failed to create branch: %v", err)
+		}
+		writeAndCommit(t, repo, "README.md", "# Main\n")
+		if err := repo.Checkout("feature"); err != nil {
+			t.Fatalf("Failed to checkout: %v", err)
+		}
+		writeAndCommit(t, repo, "README.md", "# Feature\n")
+		feature, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+		if err := repo.Checkout("main"); err != nil {
+			t.Fatalf("Failed to checkout: %v", err)
+		}
+		head, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+
+		if _, err := repo.MergeHeads(); !errors.Is(err, ErrNoMergeInProgress) {
+			t.Errorf("Expected ErrNoMergeInProgress before merging, got %v", err)
+		}
+
+		if err := repo.Merge("feature"); !errors.Is(err, ErrMergeConflict) {
+			t.Fatalf("Expected ErrMergeConflict, got %v", err)
+		}
+
+		heads, err := repo.MergeHeads()
+		if err != nil || len(heads) != 1 || heads[0] != feature {
+			t.Errorf("Expected MERGE_HEAD %s, got %v, %v", feature, heads, err)
+		}
+		message, err := repo.MergeMessage()
+		if err != nil || !strings.HasPrefix(message, "Merge branch 'feature'") {
+			t.Errorf("Expected the default merge message, got %q, %v", message, err)
+		}
+
+		// Abort restores the branch, and the merge can be started again
+		if err := repo.MergeAbort(); err != nil {
+			t.Fatalf("MergeAbort failed: %v", err)
+		}
+		if after, err := repo.RevParse("HEAD"); err != nil || after != head {
+			t.Errorf("Expected HEAD to stay at %s, got %s, %v", head, after, err)
+		}
+		if err := repo.Merge("feature"); !errors.Is(err, ErrMergeConflict) {
+			t.Fatalf("Expected ErrMergeConflict, got %v", err)
+		}
+
+		if err := repo.MergeContinue(); !errors.Is(err, ErrMergeConflict) {
+			t.Errorf("Expected ErrMergeConflict with unresolved conflicts, got %v", err)
+		}
+
+		path := filepath.Join(repo.RepoPath, "README.md")
+		if err := os.WriteFile(path, []byte("# Resolved\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if stdout, stderr, err := repo.Client.Exec("add", "README.md"); err != nil {
+			t.Fatalf("Failed to add: %v\n%s%s", err, stdout, stderr)
+		}
+		if err := repo.SetMergeMessage("Merge feature with resolved README\n"); err != nil {
+			t.Fatalf("SetMergeMessage failed: %v", err)
+		}
+		if err := repo.MergeContinue(); err != nil {
+			t.Fatalf("MergeContinue failed: %v", err)
+		}
+
+		items, err := repo.Log(LogOptions{Oneline: true})
+		if err != nil || len(items) == 0 || items[0].Message != "Merge feature with resolved README" {
+			t.Errorf("Expected the merge to be committed with the new message, got %+v, %v", items, err)
+		}
+		if _, err := repo.MergeMessage(); !errors.Is(err, ErrNoMergeInProgress) {
+			t.Errorf("Expected ErrNoMergeInProgress after the merge, got %v", err)
+		}
+		if err := repo.MergeAbort(); err == nil {
+			t.Error("Expected MergeAbort to fail without a merge in progress")
+		}
+	})
+}
@@ -0,0 +1,23 @@
+module github.com/ocuroot/gittools/otelgittools
+
+go 1.19
+
+require (
+	github.com/ocuroot/gittools v0.0.0
+	go.opentelemetry.io/otel v1.16.0
+	go.opentelemetry.io/otel/sdk v1.16.0
+	go.opentelemetry.io/otel/trace v1.16.0
+)
+
+require (
+	github.com/cloudflare/backoff v0.0.0-20240920015135-e46b80a3a7d0 // indirect
+	github.com/go-logr/logr v1.2.4 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/oklog/ulid/v2 v2.1.0 // indirect
+	go.opentelemetry.io/otel/metric v1.16.0 // indirect
+	golang.org/x/sys v0.10.0 // indirect
+)
+
+// Until gittools is tagged with command hooks, otelgittools builds against the
+// gittools in this repository
+replace github.com/ocuroot/gittools => ../
@@ -0,0 +1,104 @@
+// Package otelgittools traces the git commands run by a gittools.Client with OpenTelemetry.
+//
+//	client.Hooks = append(client.Hooks, otelgittools.NewHook(otel.GetTracerProvider()))
+//
+// Each command is recorded as a span, a child of any span in the context passed to
+// Client.ExecContext.
+//
+// otelgittools is a separate module, so programs that only use gittools do not depend
+// on OpenTelemetry.
+package otelgittools
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ocuroot/gittools"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies this package as the source of spans
+const instrumentationName = "github.com/ocuroot/gittools/otelgittools"
+
+// Attribute keys recorded on each span
+const (
+	SubcommandKey  = attribute.Key("git.subcommand")
+	ArgsKey        = attribute.Key("git.args")
+	RepoPathKey    = attribute.Key("git.repo_path")
+	ExitCodeKey    = attribute.Key("git.exit_code")
+	StdoutBytesKey = attribute.Key("git.stdout_bytes")
+	StderrBytesKey = attribute.Key("git.stderr_bytes")
+)
+
+// Hook is a gittools.Hook that records a span for every git command
+type Hook struct {
+	tracer trace.Tracer
+
+	// RecordArgs adds the full command line to each span.
+	// Arguments may contain secrets such as URLs with credentials, so this is off by default.
+	RecordArgs bool
+
+	mu    sync.Mutex
+	spans map[*gittools.Command]trace.Span
+}
+
+var _ gittools.Hook = (*Hook)(nil)
+
+// NewHook creates a Hook that records spans with a tracer from provider
+func NewHook(provider trace.TracerProvider) *Hook {
+	return &Hook{
+		tracer: provider.Tracer(instrumentationName),
+		spans:  make(map[*gittools.Command]trace.Span),
+	}
+}
+
+// BeforeExec starts a span for the command
+func (h *Hook) BeforeExec(cmd *gittools.Command) error {
+	subcommand := cmd.Subcommand()
+
+	attrs := []attribute.KeyValue{
+		SubcommandKey.String(subcommand),
+		RepoPathKey.String(cmd.Dir),
+	}
+	if h.RecordArgs {
+		attrs = append(attrs, ArgsKey.String(strings.Join(cmd.Args, " ")))
+	}
+
+	_, span := h.tracer.Start(cmd.Context, "git "+subcommand,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+
+	h.mu.Lock()
+	h.spans[cmd] = span
+	h.mu.Unlock()
+	return nil
+}
+
+// AfterExec ends the span for the command, recording its outcome
+func (h *Hook) AfterExec(cmd *gittools.Command, stdout, stderr []byte, err error, duration time.Duration) {
+	h.mu.Lock()
+	span, ok := h.spans[cmd]
+	delete(h.spans, cmd)
+	h.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	span.SetAttributes(
+		StdoutBytesKey.Int(len(stdout)),
+		StderrBytesKey.Int(len(stderr)),
+	)
+
+	exitCode := 0
+	if err != nil {
+		exitCode = gittools.ExitCode(err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.SetAttributes(ExitCodeKey.Int(exitCode))
+	span.End()
+}
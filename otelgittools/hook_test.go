@@ -0,0 +1,82 @@
+package otelgittools
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/ocuroot/gittools"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestHookRecordsSpans(t *testing.T) {
+	gittools.SafeTest(t, func(t *testing.T, tempDir string) {
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+		client := gittools.Client{}
+		repoDir, err := os.MkdirTemp("", "otelgittools-")
+		if err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		defer os.RemoveAll(repoDir)
+
+		repo, err := client.Init(repoDir, "main")
+		if err != nil {
+			t.Fatalf("Failed to init repository: %v", err)
+		}
+		repo.Client.Hooks = []gittools.Hook{NewHook(provider)}
+
+		ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+		if _, _, err := repo.Client.ExecContext(ctx, "rev-parse", "--git-dir"); err != nil {
+			t.Fatalf("Failed to run rev-parse: %v", err)
+		}
+		if _, _, err := repo.Client.ExecContext(ctx, "rev-parse", "--verify", "missing"); err == nil {
+			t.Fatal("Expected rev-parse of a missing ref to fail")
+		}
+		parent.End()
+
+		spans := recorder.Ended()
+		if len(spans) != 3 {
+			t.Fatalf("Expected 3 spans, got %d", len(spans))
+		}
+
+		for i, span := range spans[:2] {
+			if span.Name() != "git rev-parse" {
+				t.Errorf("Span %d: expected name git rev-parse, got %s", i, span.Name())
+			}
+			if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+				t.Errorf("Span %d: expected to be a child of the context span", i)
+			}
+			attrs := attributes(span.Attributes())
+			if attrs[SubcommandKey].AsString() != "rev-parse" || attrs[RepoPathKey].AsString() != repoDir {
+				t.Errorf("Span %d: unexpected attributes %v", i, span.Attributes())
+			}
+			if _, ok := attrs[ArgsKey]; ok {
+				t.Errorf("Span %d: expected args not to be recorded by default", i)
+			}
+		}
+
+		if code := attributes(spans[0].Attributes())[ExitCodeKey].AsInt64(); code != 0 {
+			t.Errorf("Expected exit code 0, got %d", code)
+		}
+		failed := spans[1]
+		if code := attributes(failed.Attributes())[ExitCodeKey].AsInt64(); code != 128 {
+			t.Errorf("Expected exit code 128, got %d", code)
+		}
+		if failed.Status().Code != codes.Error {
+			t.Errorf("Expected failed command to have error status, got %v", failed.Status())
+		}
+	})
+}
+
+func attributes(kvs []attribute.KeyValue) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value, len(kvs))
+	for _, kv := range kvs {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
@@ -0,0 +1,179 @@
+package gittools
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// ErrPatchConflict is returned by Am and Apply when a patch does not apply cleanly
+var ErrPatchConflict = errors.New("patch does not apply")
+
+// FormatPatchOptions defines options for FormatPatch. Exactly one of OutputDir
+// and Stdout must be set.
+type FormatPatchOptions struct {
+	// OutputDir is the directory to write one patch file per commit to
+	OutputDir string
+
+	// Stdout receives the patches as a single mbox stream
+	Stdout io.Writer
+
+	// Numbered adds [PATCH n/m] to the subjects
+	Numbered bool
+
+	// CoverLetter adds a cover letter patch to fill in, only with OutputDir
+	CoverLetter bool
+
+	// Signoff adds a Signed-off-by trailer for the committer
+	Signoff bool
+
+	// SubjectPrefix replaces the PATCH prefix of the subjects
+	SubjectPrefix string
+}
+
+// FormatPatch formats the commits in revRange, e.g. "main..feature" or "-3", as
+// email patches that can be applied with Am. When writing to OutputDir, it returns
+// the paths of the patch files in order.
+func (r *Repo) FormatPatch(revRange string, options FormatPatchOptions) ([]string, error) {
+	if (options.OutputDir == "") == (options.Stdout == nil) {
+		return nil, fmt.Errorf("exactly one of OutputDir and Stdout must be set")
+	}
+
+	args := []string{"format-patch"}
+	if options.Numbered {
+		args = append(args, "--numbered")
+	}
+	if options.CoverLetter {
+		args = append(args, "--cover-letter")
+	}
+	if options.Signoff {
+		args = append(args, "--signoff")
+	}
+	if options.SubjectPrefix != "" {
+		args = append(args, "--subject-prefix="+options.SubjectPrefix)
+	}
+
+	if options.Stdout != nil {
+		args = append(args, "--stdout", revRange)
+		_, stderr, err := r.Client.ExecWithOptions(ExecOptions{Stdout: options.Stdout}, args...)
+		if err != nil {
+			return nil, fmt.Errorf("git format-patch failed: %w\nstderr: %s", err, stderr)
+		}
+		return nil, nil
+	}
+
+	outputDir, err := filepath.Abs(options.OutputDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	args = append(args, "--output-directory", outputDir, revRange)
+	stdout, stderr, err := r.Client.Exec(args...)
+	if err != nil {
+		return nil, fmt.Errorf("git format-patch failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+
+	var files []string
+	for _, line := range strings.Split(strings.TrimSpace(string(stdout)), "\n") {
+		if line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, nil
+}
+
+// AmOptions defines options for Am
+type AmOptions struct {
+	// ThreeWay falls back to a three-way merge when a patch does not apply
+	ThreeWay bool
+
+	// Signoff adds a Signed-off-by trailer for the committer
+	Signoff bool
+}
+
+// Am applies the email patches read from patches as commits, such as the output of
+// FormatPatch. If a patch does not apply, Am returns ErrPatchConflict and leaves the
+// session in progress to be resolved with AmContinue or abandoned with AmAbort.
+func (r *Repo) Am(patches io.Reader, options AmOptions) error {
+	args := []string{"am"}
+	if options.ThreeWay {
+		args = append(args, "--3way")
+	}
+	if options.Signoff {
+		args = append(args, "--signoff")
+	}
+
+	stdout, stderr, err := r.Client.ExecWithInput(patches, args...)
+	if err != nil {
+		return patchError("am", err, stdout, stderr)
+	}
+	return nil
+}
+
+// AmContinue commits the resolved patch of an Am session and applies the rest
+func (r *Repo) AmContinue() error {
+	stdout, stderr, err := r.Client.Exec("am", "--continue")
+	if err != nil {
+		return patchError("am", err, stdout, stderr)
+	}
+	return nil
+}
+
+// AmAbort abandons an Am session, restoring the branch to where it started
+func (r *Repo) AmAbort() error {
+	stdout, stderr, err := r.Client.Exec("am", "--abort")
+	if err != nil {
+		return fmt.Errorf("git am abort failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return nil
+}
+
+// ApplyOptions defines options for Apply
+type ApplyOptions struct {
+	// ThreeWay falls back to a three-way merge when the patch does not apply,
+	// leaving conflict markers in the working tree. It implies Index.
+	ThreeWay bool
+
+	// Index applies the patch to the index as well as the working tree
+	Index bool
+
+	// Check only checks that the patch applies, without changing anything
+	Check bool
+}
+
+// Apply applies the diff read from patch to the working tree without committing.
+// Returns ErrPatchConflict if the patch does not apply cleanly.
+func (r *Repo) Apply(patch io.Reader, options ApplyOptions) error {
+	args := []string{"apply"}
+	if options.ThreeWay {
+		args = append(args, "--3way")
+	}
+	if options.Index {
+		args = append(args, "--index")
+	}
+	if options.Check {
+		args = append(args, "--check")
+	}
+
+	stdout, stderr, err := r.Client.ExecWithInput(patch, args...)
+	if err != nil {
+		return patchError("apply", err, stdout, stderr)
+	}
+	return nil
+}
+
+// patchError returns ErrPatchConflict if the output of git am or apply shows a
+// patch that did not apply
+func patchError(subcommand string, err error, stdout, stderr []byte) error {
+	output := string(stdout) + string(stderr)
+	if strings.Contains(output, "patch does not apply") ||
+		strings.Contains(output, "Patch failed at") ||
+		strings.Contains(output, "with conflicts") {
+		return fmt.Errorf("%w: %s", ErrPatchConflict, output)
+	}
+	return fmt.Errorf("git %s failed: %w\nstdout: %s\nstderr: %s",
+		subcommand, err, stdout, stderr)
+}
@@ -0,0 +1,139 @@
+package gittools
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPatchWorkflow(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		source := openTestRepo(t)
+		target := openTestRepo(t)
+
+		writeAndCommit(t, source, "a.txt", "a\n")
+		writeAndCommit(t, source, "b.txt", "b\n")
+
+		files, err := source.FormatPatch("-2", FormatPatchOptions{OutputDir: filepath.Join(testDir, "patches"), Numbered: true})
+		if err != nil {
+			t.Fatalf("FormatPatch failed: %v", err)
+		}
+		if len(files) != 2 {
+			t.Fatalf("Expected 2 patch files, got %v", files)
+		}
+		for _, file := range files {
+			if _, err := os.Stat(file); err != nil {
+				t.Errorf("Expected patch file %s: %v", file, err)
+			}
+		}
+
+		var mbox bytes.Buffer
+		if _, err := source.FormatPatch("-2", FormatPatchOptions{Stdout: &mbox}); err != nil {
+			t.Fatalf("FormatPatch failed: %v", err)
+		}
+		if strings.Count(mbox.String(), "Subject: [PATCH") != 2 {
+			t.Errorf("Expected 2 patches in the stream, got:\n%s", mbox.String())
+		}
+
+		if _, err := source.FormatPatch("-1", FormatPatchOptions{}); err == nil {
+			t.Error("Expected an error without OutputDir or Stdout")
+		}
+
+		if err := target.Am(&mbox, AmOptions{}); err != nil {
+			t.Fatalf("Am failed: %v", err)
+		}
+		items, err := target.Log(LogOptions{Oneline: true})
+		if err != nil {
+			t.Fatalf("Log failed: %v", err)
+		}
+		if len(items) != 3 || items[0].Message != "Add b.txt" {
+			t.Errorf("Expected the two patches to be committed, got %+v", items)
+		}
+
+		// Apply an uncommitted change from source
+		if err := os.WriteFile(filepath.Join(source.RepoPath, "a.txt"), []byte("a\nchanged\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		diff, _, err := source.Client.Exec("diff")
+		if err != nil {
+			t.Fatalf("Failed to diff: %v", err)
+		}
+		if err := target.Apply(bytes.NewReader(diff), ApplyOptions{Check: true}); err != nil {
+			t.Errorf("Expected the diff to apply: %v", err)
+		}
+		if err := target.Apply(bytes.NewReader(diff), ApplyOptions{Index: true}); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+		content, err := os.ReadFile(filepath.Join(target.RepoPath, "a.txt"))
+		if err != nil || string(content) != "a\nchanged\n" {
+			t.Errorf("Expected the change to be applied, got %q, %v", content, err)
+		}
+		staged, _, err := target.Client.Exec("diff", "--cached", "--name-only")
+		if err != nil || strings.TrimSpace(string(staged)) != "a.txt" {
+			t.Errorf("Expected a.txt to be staged, got %q, %v", staged, err)
+		}
+
+		// The change is already applied, so the diff no longer applies
+		err = target.Apply(bytes.NewReader(diff), ApplyOptions{})
+		if !errors.Is(err, ErrPatchConflict) {
+			t.Errorf("Expected ErrPatchConflict, got %v", err)
+		}
+	})
+}
+
+func TestAmConflict(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		source := openTestRepo(t)
+		target := openTestRepo(t)
+
+		writeAndCommit(t, source, "README.md", "# Source\n")
+		writeAndCommit(t, target, "README.md", "# Target\n")
+		head, err := target.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+
+		var mbox bytes.Buffer
+		if _, err := source.FormatPatch("-1", FormatPatchOptions{Stdout: &mbox}); err != nil {
+			t.Fatalf("FormatPatch failed: %v", err)
+		}
+
+		err = target.Am(&mbox, AmOptions{})
+		if !errors.Is(err, ErrPatchConflict) {
+			t.Fatalf("Expected ErrPatchConflict, got %v", err)
+		}
+
+		if err := target.AmAbort(); err != nil {
+			t.Fatalf("AmAbort failed: %v", err)
+		}
+		if after, err := target.RevParse("HEAD"); err != nil || after != head {
+			t.Errorf("Expected HEAD to be restored to %s, got %s, %v", head, after, err)
+		}
+	})
+}
+
+// openTestRepo creates a repository with an initial commit and opens it with a test user
+func openTestRepo(t *testing.T) *Repo {
+	t.Helper()
+	repo, err := Open(setupTestRepo(t))
+	if err != nil {
+		t.Fatalf("Failed to open repository: %v", err)
+	}
+	repo.Client.SetUser("Test User", "test@example.com")
+	return repo
+}
+
+// writeAndCommit writes content to name in the repository and commits it
+func writeAndCommit(t *testing.T, repo *Repo, name, content string) {
+	t.Helper()
+	path := filepath.Join(repo.RepoPath, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	if err := repo.Commit("Add "+name, []string{path}); err != nil {
+		t.Fatalf("Failed to commit %s: %v", name, err)
+	}
+}
@@ -0,0 +1,153 @@
+package gittools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// HashObject writes content to the object database as a blob and returns its hash
+func (r *Repo) HashObject(content []byte) (string, error) {
+	stdout, stderr, err := r.Client.ExecWithInput(bytes.NewReader(content), "hash-object", "-w", "--stdin")
+	if err != nil {
+		return "", fmt.Errorf("git hash-object failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
+
+// ObjectsExist reports which of the named objects exist in the repository, checking
+// them all with a single git cat-file process. Names may be full or abbreviated
+// hashes, or revisions such as "HEAD~1" or "main:README.md".
+// The returned map has an entry for every name.
+func (r *Repo) ObjectsExist(oids []string) (map[string]bool, error) {
+	return r.ObjectsExistContext(context.Background(), oids)
+}
+
+// ObjectsExistContext is ObjectsExist with a context. Cancelling ctx kills the git command.
+func (r *Repo) ObjectsExistContext(ctx context.Context, oids []string) (map[string]bool, error) {
+	hashes, err := r.resolveObjects(ctx, oids)
+	if err != nil {
+		return nil, err
+	}
+
+	exists := make(map[string]bool, len(oids))
+	for _, oid := range oids {
+		_, exists[oid] = hashes[oid]
+	}
+	return exists, nil
+}
+
+// TreeEntry describes a single entry passed to MkTree
+type TreeEntry struct {
+	// Mode of the entry, e.g. "100644" for a regular file or "040000" for a tree
+	Mode string
+
+	// Type of the object: "blob", "tree" or "commit"
+	Type string
+
+	// Object is the hash of the object
+	Object string
+
+	// Name of the entry within the tree
+	Name string
+}
+
+// MkTree writes a tree object containing the given entries and returns its hash.
+// An empty list of entries produces the empty tree.
+func (r *Repo) MkTree(entries []TreeEntry) (string, error) {
+	var input bytes.Buffer
+	for _, entry := range entries {
+		fmt.Fprintf(&input, "%s %s %s\t%s\n", entry.Mode, entry.Type, entry.Object, entry.Name)
+	}
+
+	stdout, stderr, err := r.Client.ExecWithInput(&input, "mktree")
+	if err != nil {
+		return "", fmt.Errorf("git mktree failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
+
+// CommitTree creates a commit object for the given tree and returns its hash.
+// The commit is not referenced by any branch until a ref is pointed at it.
+func (r *Repo) CommitTree(tree string, message string, parents ...string) (string, error) {
+	if err := r.Client.CheckIdentity(); err != nil {
+		return "", err
+	}
+
+	args := []string{"commit-tree", tree, "-m", message}
+	for _, parent := range parents {
+		args = append(args, "-p", parent)
+	}
+
+	stdout, stderr, err := r.Client.Exec(args...)
+	if err != nil {
+		return "", fmt.Errorf("git commit-tree failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
+
+// LsRemote lists refs on a remote matching the given patterns
+// Returns a map of ref name to object hash. If no patterns are provided, all refs are listed.
+func (r *Repo) LsRemote(remote string, patterns ...string) (map[string]string, error) {
+	args := append([]string{"ls-remote", remote}, patterns...)
+	stdout, stderr, err := r.Client.Exec(args...)
+	if err != nil {
+		return nil, fmt.Errorf("git ls-remote failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+
+	refs := make(map[string]string)
+	for _, line := range strings.Split(string(stdout), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		refs[fields[1]] = fields[0]
+	}
+	return refs, nil
+}
+
+// LsTree lists the entries of a tree-ish (e.g. a commit, branch or tree hash)
+// If paths are provided, only matching entries are listed.
+func (r *Repo) LsTree(treeish string, paths ...string) ([]TreeEntry, error) {
+	args := []string{"ls-tree", "-z", treeish}
+	if len(paths) > 0 {
+		args = append(args, "--")
+		args = append(args, paths...)
+	}
+
+	stdout, stderr, err := r.Client.Exec(args...)
+	if err != nil {
+		return nil, fmt.Errorf("git ls-tree failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+
+	var entries []TreeEntry
+	for _, record := range strings.Split(string(stdout), "\x00") {
+		if record == "" {
+			continue
+		}
+
+		// Format: <mode> SP <type> SP <object> TAB <name>
+		meta, name, found := strings.Cut(record, "\t")
+		if !found {
+			return nil, fmt.Errorf("unexpected ls-tree output: %q", record)
+		}
+		fields := strings.Fields(meta)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected ls-tree output: %q", record)
+		}
+
+		entries = append(entries, TreeEntry{
+			Mode:   fields[0],
+			Type:   fields[1],
+			Object: fields[2],
+			Name:   name,
+		})
+	}
+	return entries, nil
+}
@@ -0,0 +1,58 @@
+package gittools
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestObjectsExist(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		repo, err := Open(setupTestRepo(t))
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		repo.Client.SetUser("Test User", "test@example.com")
+		writeAndCommit(t, repo, "file.txt", "content\n")
+		head, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+		blob, err := repo.HashObject([]byte("content\n"))
+		if err != nil {
+			t.Fatalf("Failed to hash object: %v", err)
+		}
+
+		faults := &FaultInjector{}
+		catFile := faults.On("cat-file")
+		repo.Client.Hooks = append(repo.Client.Hooks, faults)
+
+		missing := "0123456789abcdef0123456789abcdef01234567"
+		oids := []string{head, head[:8], blob, "HEAD~1", "HEAD:file.txt", missing, "HEAD:missing.txt", head}
+		exists, err := repo.ObjectsExist(oids)
+		if err != nil {
+			t.Fatalf("ObjectsExist failed: %v", err)
+		}
+		expected := map[string]bool{
+			head:               true,
+			head[:8]:           true,
+			blob:               true,
+			"HEAD~1":           true,
+			"HEAD:file.txt":    true,
+			missing:            false,
+			"HEAD:missing.txt": false,
+		}
+		if !reflect.DeepEqual(exists, expected) {
+			t.Errorf("Expected %v, got %v", expected, exists)
+		}
+		if calls := faults.Calls(catFile); calls != 1 {
+			t.Errorf("Expected a single git cat-file process, got %d", calls)
+		}
+
+		if exists, err := repo.ObjectsExist(nil); err != nil || len(exists) != 0 {
+			t.Errorf("Expected an empty result for no objects, got %v, %v", exists, err)
+		}
+		if _, err := repo.ObjectsExist([]string{"HEAD\nHEAD~1"}); err == nil {
+			t.Error("Expected an error for a name containing a newline")
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package gittools
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Progress is a transfer progress update reported by git during a fetch, pull or push
+type Progress struct {
+	// Phase is the stage of the transfer, e.g. "Counting objects", "Compressing objects",
+	// "Receiving objects", "Resolving deltas" or "Writing objects"
+	Phase string
+
+	// Remote is true for phases run by the remote, reported with a "remote:" prefix
+	Remote bool
+
+	// Current is the number of objects processed so far
+	Current int64
+
+	// Total is the number of objects in the phase, 0 if git does not know it in advance
+	Total int64
+
+	// Percent is the percentage of the phase completed, -1 if Total is unknown
+	Percent int
+
+	// Throughput is the transfer rate reported by git, e.g. "1.20 MiB | 2.00 MiB/s", if any
+	Throughput string
+
+	// Done is true for the final update of a phase
+	Done bool
+}
+
+// ProgressFunc receives transfer progress updates. Updates arrive as git reports them,
+// roughly once per second for each phase.
+type ProgressFunc func(Progress)
+
+// progressPattern matches git progress lines such as
+// "Receiving objects:  45% (450/1000), 1.20 MiB | 2.00 MiB/s" and "Enumerating objects: 5, done."
+var progressPattern = regexp.MustCompile(`^(remote: )?([A-Za-z][A-Za-z ]*):\s+(?:(\d+)% \((\d+)/(\d+)\)|(\d+))(.*)$`)
+
+// parseProgress parses a single progress line, returning false if it is not a progress update
+func parseProgress(line string) (Progress, bool) {
+	match := progressPattern.FindStringSubmatch(strings.TrimSpace(line))
+	if match == nil {
+		return Progress{}, false
+	}
+
+	progress := Progress{
+		Remote:  match[1] != "",
+		Phase:   match[2],
+		Percent: -1,
+	}
+	if match[3] != "" {
+		progress.Percent, _ = strconv.Atoi(match[3])
+		progress.Current, _ = strconv.ParseInt(match[4], 10, 64)
+		progress.Total, _ = strconv.ParseInt(match[5], 10, 64)
+	} else {
+		progress.Current, _ = strconv.ParseInt(match[6], 10, 64)
+	}
+
+	rest := strings.TrimSpace(match[7])
+	if strings.HasSuffix(rest, "done.") {
+		progress.Done = true
+		rest = strings.TrimSuffix(rest, "done.")
+	}
+	progress.Throughput = strings.Trim(rest, ", ")
+
+	return progress, true
+}
+
+// progressWriter calls fn for each progress line written to it. git separates
+// updates to the same line with \r and completed lines with \n.
+type progressWriter struct {
+	fn      ProgressFunc
+	pending []byte
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for {
+		end := bytes.IndexAny(w.pending, "\r\n")
+		if end < 0 {
+			break
+		}
+		w.report(string(w.pending[:end]))
+		w.pending = w.pending[end+1:]
+	}
+	return len(p), nil
+}
+
+// flush reports any final line without a line ending
+func (w *progressWriter) flush() {
+	if len(w.pending) > 0 {
+		w.report(string(w.pending))
+		w.pending = nil
+	}
+}
+
+func (w *progressWriter) report(line string) {
+	if progress, ok := parseProgress(line); ok {
+		w.fn(progress)
+	}
+}
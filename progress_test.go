@@ -0,0 +1,114 @@
+package gittools
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestParseProgress(t *testing.T) {
+	tests := []struct {
+		line string
+		want Progress
+		ok   bool
+	}{
+		{
+			line: "Receiving objects:  45% (450/1000), 1.20 MiB | 2.00 MiB/s",
+			want: Progress{Phase: "Receiving objects", Current: 450, Total: 1000, Percent: 45, Throughput: "1.20 MiB | 2.00 MiB/s"},
+			ok:   true,
+		},
+		{
+			line: "remote: Counting objects: 100% (5/5), done.        ",
+			want: Progress{Phase: "Counting objects", Remote: true, Current: 5, Total: 5, Percent: 100, Done: true},
+			ok:   true,
+		},
+		{
+			line: "Enumerating objects: 3, done.",
+			want: Progress{Phase: "Enumerating objects", Current: 3, Percent: -1, Done: true},
+			ok:   true,
+		},
+		{
+			line: "Writing objects: 100% (3/3), 195 bytes | 97.00 KiB/s, done.",
+			want: Progress{Phase: "Writing objects", Current: 3, Total: 3, Percent: 100, Throughput: "195 bytes | 97.00 KiB/s", Done: true},
+			ok:   true,
+		},
+		{line: "Total 3 (delta 0), reused 0 (delta 0), pack-reused 0"},
+		{line: "To /tmp/remote.git"},
+	}
+
+	for _, test := range tests {
+		got, ok := parseProgress(test.line)
+		if ok != test.ok || got != test.want {
+			t.Errorf("parseProgress(%q) = %+v, %v, want %+v, %v", test.line, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestProgressWriter(t *testing.T) {
+	var got []Progress
+	writer := &progressWriter{fn: func(p Progress) { got = append(got, p) }}
+
+	// Updates may be split across writes
+	writer.Write([]byte("Counting objects:  50% (1/2)\rCounting obj"))
+	writer.Write([]byte("ects: 100% (2/2)\rCounting objects: 100% (2/2), done.\n"))
+	writer.Write([]byte("Writing objects: 100% (2/2)"))
+	writer.flush()
+
+	if len(got) != 4 {
+		t.Fatalf("Expected 4 updates, got %+v", got)
+	}
+	if got[1].Current != 2 || !got[2].Done || got[3].Phase != "Writing objects" {
+		t.Errorf("Unexpected updates %+v", got)
+	}
+}
+
+func TestPushAndFetchProgress(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		remotePath, cleanup, err := CreateTestRemoteRepo("progress")
+		if err != nil {
+			t.Fatalf("Failed to create remote: %v", err)
+		}
+		defer cleanup()
+
+		client := &Client{}
+		pusher, err := client.Clone(remotePath, filepath.Join(testDir, "pusher"))
+		if err != nil {
+			t.Fatalf("Failed to clone: %v", err)
+		}
+		fetcher, err := client.Clone(remotePath, filepath.Join(testDir, "fetcher"))
+		if err != nil {
+			t.Fatalf("Failed to clone: %v", err)
+		}
+
+		if err := os.WriteFile(filepath.Join(pusher.RepoPath, "progress.txt"), []byte("progress"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if err := pusher.Commit("Add progress.txt", []string{"progress.txt"}); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+
+		var mu sync.Mutex
+		phases := make(map[string]bool)
+		record := func(p Progress) {
+			mu.Lock()
+			defer mu.Unlock()
+			phases[p.Phase] = true
+		}
+
+		if err := pusher.PushWithOptions("origin", PushOptions{Refspecs: []string{"HEAD:refs/heads/main"}, Progress: record}); err != nil {
+			t.Fatalf("Failed to push: %v", err)
+		}
+		if !phases["Writing objects"] {
+			t.Errorf("Expected push to report writing objects, got %v", phases)
+		}
+
+		phases = make(map[string]bool)
+		if err := fetcher.Fetch("origin", FetchOptions{Progress: record}); err != nil {
+			t.Fatalf("Failed to fetch: %v", err)
+		}
+		if !phases["Counting objects"] {
+			t.Errorf("Expected fetch to report counting objects, got %v", phases)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package gittools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// PullOutcome describes how PullWithResult updated the current branch
+type PullOutcome int
+
+const (
+	// PullUpToDate means the branch already contained the remote branch
+	PullUpToDate PullOutcome = iota
+
+	// PullFastForward means the branch was moved forward to the remote branch
+	PullFastForward
+
+	// PullMerged means a merge commit was created joining the local and remote changes
+	PullMerged
+
+	// PullRebased means the local commits were rebased onto the remote branch
+	PullRebased
+)
+
+func (o PullOutcome) String() string {
+	switch o {
+	case PullUpToDate:
+		return "up-to-date"
+	case PullFastForward:
+		return "fast-forward"
+	case PullMerged:
+		return "merged"
+	case PullRebased:
+		return "rebased"
+	default:
+		return fmt.Sprintf("PullOutcome(%d)", int(o))
+	}
+}
+
+// PullResult describes the effect of a successful PullWithResult
+type PullResult struct {
+	// Outcome is how the current branch was updated
+	Outcome PullOutcome
+
+	// OldHead and NewHead are the full hashes of HEAD before and after the pull.
+	// OldHead is empty if the branch had no commits yet.
+	OldHead string
+	NewHead string
+}
+
+// Changed returns true if the pull moved HEAD
+func (r *PullResult) Changed() bool {
+	return r.Outcome != PullUpToDate
+}
+
+// PullWithResult pulls changes from the specified remote and branch as PullWithOptions
+// does, and reports whether the current branch was fast-forwarded, merged or rebased
+func (g *Repo) PullWithResult(remote, branch string, options PullOptions) (*PullResult, error) {
+	oldHead, err := g.headCommit()
+	if err != nil {
+		return nil, err
+	}
+	if err := g.PullWithOptions(remote, branch, options); err != nil {
+		return nil, err
+	}
+	return g.pullResult(oldHead)
+}
+
+// headCommit returns the full hash of HEAD, or an empty string if the current branch
+// has no commits yet
+func (g *Repo) headCommit() (string, error) {
+	stdout, stderr, err := g.Client.Exec("rev-parse", "--verify", "-q", "HEAD^{commit}")
+	if err != nil {
+		// rev-parse exits with 1 and no output when HEAD doesn't resolve
+		if ExitCode(err) == 1 && len(strings.TrimSpace(string(stdout))) == 0 {
+			return "", nil
+		}
+		return "", fmt.Errorf("git rev-parse failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
+
+// pullResult works out how a pull moved HEAD from oldHead, comparing the new HEAD
+// with the commit it fetched
+func (g *Repo) pullResult(oldHead string) (*PullResult, error) {
+	newHead, err := g.headCommit()
+	if err != nil {
+		return nil, err
+	}
+	result := &PullResult{OldHead: oldHead, NewHead: newHead}
+	if newHead == oldHead {
+		result.Outcome = PullUpToDate
+		return result, nil
+	}
+
+	ctx := context.Background()
+	fetched, err := g.resolveCommit(ctx, "FETCH_HEAD")
+	if err != nil {
+		return nil, err
+	}
+	if oldHead == "" || newHead == fetched {
+		// Rebasing a branch without local commits also leaves it at the fetched commit
+		result.Outcome = PullFastForward
+		return result, nil
+	}
+
+	base, err := g.mergeBase(ctx, oldHead, newHead)
+	if err != nil {
+		return nil, err
+	}
+	if base == oldHead {
+		result.Outcome = PullMerged
+	} else {
+		result.Outcome = PullRebased
+	}
+	return result, nil
+}
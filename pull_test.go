@@ -0,0 +1,81 @@
+package gittools
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPullResult(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		remotePath, cleanup, err := CreateTestRemoteRepo("pull-result")
+		if err != nil {
+			t.Fatalf("Failed to create remote: %v", err)
+		}
+		defer cleanup()
+
+		client := &Client{}
+		client.SetUser("Test User", "test@example.com")
+		writer, err := client.Clone(remotePath, filepath.Join(testDir, "writer"))
+		if err != nil {
+			t.Fatalf("Failed to clone: %v", err)
+		}
+		reader, err := client.Clone(remotePath, filepath.Join(testDir, "reader"))
+		if err != nil {
+			t.Fatalf("Failed to clone: %v", err)
+		}
+
+		head := func(repo *Repo) string {
+			t.Helper()
+			hash, err := repo.RevParse("HEAD")
+			if err != nil {
+				t.Fatalf("Failed to get HEAD: %v", err)
+			}
+			return hash
+		}
+		pushChange := func(name string) {
+			t.Helper()
+			writeAndCommit(t, writer, name, name+"\n")
+			if err := writer.Push("origin", "main"); err != nil {
+				t.Fatalf("Failed to push: %v", err)
+			}
+		}
+		pull := func(expected PullOutcome) *PullResult {
+			t.Helper()
+			oldHead := head(reader)
+			result, err := reader.PullWithResult("origin", "main", PullOptions{})
+			if err != nil {
+				t.Fatalf("PullWithResult failed: %v", err)
+			}
+			if result.Outcome != expected {
+				t.Errorf("Expected %v, got %v", expected, result.Outcome)
+			}
+			if result.OldHead != oldHead || result.NewHead != head(reader) {
+				t.Errorf("Expected HEAD to move from %s to %s, got %+v", oldHead, head(reader), result)
+			}
+			return result
+		}
+
+		if result := pull(PullUpToDate); result.Changed() {
+			t.Error("Expected an up to date pull not to report a change")
+		}
+
+		pushChange("ff.txt")
+		if result := pull(PullFastForward); result.NewHead != head(writer) {
+			t.Errorf("Expected to fast-forward to %s, got %s", head(writer), result.NewHead)
+		}
+
+		if err := reader.ConfigSetWithOptions("pull.rebase", "false", ConfigOptions{}); err != nil {
+			t.Fatalf("Failed to set config: %v", err)
+		}
+		pushChange("merge-remote.txt")
+		writeAndCommit(t, reader, "merge-local.txt", "local\n")
+		pull(PullMerged)
+
+		if err := reader.ConfigSetWithOptions("pull.rebase", "true", ConfigOptions{}); err != nil {
+			t.Fatalf("Failed to set config: %v", err)
+		}
+		pushChange("rebase-remote.txt")
+		writeAndCommit(t, reader, "rebase-local.txt", "local\n")
+		pull(PullRebased)
+	})
+}
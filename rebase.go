@@ -0,0 +1,182 @@
+package gittools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// RebaseOptions defines options for RebaseWithOptions
+type RebaseOptions struct {
+	// Upstream is the branch or commit to rebase onto, and the base of the commits
+	// to replay unless Onto is set
+	Upstream string
+
+	// Onto replays the commits after Upstream onto this branch or commit instead,
+	// as git rebase --onto
+	Onto string
+
+	// Branch is checked out before rebasing, the current branch if empty
+	Branch string
+
+	// Autosquash moves fixup! and squash! commits after the commits they amend
+	// and squashes them
+	Autosquash bool
+
+	// Exec runs a shell command after each replayed commit, stopping the rebase
+	// if it fails
+	Exec string
+
+	// RebaseMerges recreates merge commits instead of flattening them
+	RebaseMerges bool
+}
+
+// RebaseStatus describes a rebase in progress, see Repo.RebaseStatus
+type RebaseStatus struct {
+	// InProgress is false if no rebase is in progress, in which case the other
+	// fields are empty
+	InProgress bool
+
+	// HeadName is the branch being rebased, e.g. "refs/heads/feature", or
+	// "detached HEAD"
+	HeadName string
+
+	// Onto is the commit the branch is being rebased onto
+	Onto string
+
+	// StoppedAt is the commit that was being replayed when the rebase stopped,
+	// empty if it stopped for another reason such as a failed Exec command
+	StoppedAt string
+
+	// Current is the number of the step the rebase stopped at, out of Total steps
+	Current int
+	Total   int
+
+	// Conflicts lists the paths with unresolved merge conflicts
+	Conflicts []string
+}
+
+// RebaseWithOptions rebases a branch as described by options. If the rebase stops
+// on a conflict it returns ErrRebaseMergeConflict, and the rebase can be inspected
+// with RebaseStatus and finished with RebaseContinue, RebaseSkip or RebaseAbort.
+func (r *Repo) RebaseWithOptions(options RebaseOptions) error {
+	if options.Upstream == "" {
+		return fmt.Errorf("no upstream to rebase onto")
+	}
+
+	args := []string{"rebase"}
+	var env map[string]string
+	if options.Autosquash {
+		// Before git 2.44 --autosquash only applies to interactive rebases, so
+		// run one that accepts the generated todo list unchanged
+		args = append(args, "--interactive", "--autosquash")
+		env = map[string]string{"GIT_SEQUENCE_EDITOR": "true"}
+	}
+	if options.Exec != "" {
+		args = append(args, "--exec", options.Exec)
+	}
+	if options.RebaseMerges {
+		args = append(args, "--rebase-merges")
+	}
+	if options.Onto != "" {
+		args = append(args, "--onto", options.Onto)
+	}
+	args = append(args, options.Upstream)
+	if options.Branch != "" {
+		args = append(args, options.Branch)
+	}
+
+	stdout, stderr, err := r.Client.ExecWithOptions(ExecOptions{Env: env}, args...)
+	if err != nil {
+		return rebaseError(err, stdout, stderr)
+	}
+	return nil
+}
+
+// RebaseOnto replays the commits of branch after upstream onto newBase, as
+// git rebase --onto newBase upstream branch. branch may be empty for the current branch.
+func (r *Repo) RebaseOnto(newBase, upstream, branch string) error {
+	return r.RebaseWithOptions(RebaseOptions{
+		Onto:     newBase,
+		Upstream: upstream,
+		Branch:   branch,
+	})
+}
+
+// RebaseContinue continues a stopped rebase once conflicts have been resolved and
+// staged, keeping the original commit message. It returns ErrRebaseMergeConflict if
+// a later commit conflicts or conflicts remain unresolved.
+func (r *Repo) RebaseContinue() error {
+	return r.rebaseResume("--continue")
+}
+
+// RebaseSkip skips the commit a rebase stopped at and continues with the next one
+func (r *Repo) RebaseSkip() error {
+	return r.rebaseResume("--skip")
+}
+
+func (r *Repo) rebaseResume(action string) error {
+	// GIT_EDITOR keeps the message of the resolved commit without opening an editor
+	stdout, stderr, err := r.Client.ExecWithOptions(ExecOptions{
+		Env: map[string]string{"GIT_EDITOR": "true"},
+	}, "rebase", action)
+	if err != nil {
+		output := string(stdout) + string(stderr)
+		if strings.Contains(output, "needs merge") || strings.Contains(output, "unmerged") {
+			return fmt.Errorf("%w: %s", ErrRebaseMergeConflict, output)
+		}
+		return rebaseError(err, stdout, stderr)
+	}
+	return nil
+}
+
+// RebaseStatus describes the rebase in progress, if any
+func (r *Repo) RebaseStatus() (*RebaseStatus, error) {
+	gitDir, err := r.gitDir()
+	if err != nil {
+		return nil, err
+	}
+
+	// The merge backend keeps its state in rebase-merge, the apply backend in rebase-apply
+	dir := filepath.Join(gitDir, "rebase-merge")
+	current, total := "msgnum", "end"
+	if _, err := os.Stat(dir); err != nil {
+		dir = filepath.Join(gitDir, "rebase-apply")
+		current, total = "next", "last"
+		if _, err := os.Stat(dir); err != nil {
+			return &RebaseStatus{}, nil
+		}
+	}
+
+	read := func(name string) string {
+		content, _ := os.ReadFile(filepath.Join(dir, name))
+		return strings.TrimSpace(string(content))
+	}
+	status := &RebaseStatus{
+		InProgress: true,
+		HeadName:   read("head-name"),
+		Onto:       read("onto"),
+	}
+	status.Current, _ = strconv.Atoi(read(current))
+	status.Total, _ = strconv.Atoi(read(total))
+
+	// REBASE_HEAD is the commit being replayed when the rebase stopped
+	stdout, _, err := r.Client.Exec("rev-parse", "--verify", "--quiet", "REBASE_HEAD")
+	if err == nil {
+		status.StoppedAt = strings.TrimSpace(string(stdout))
+	}
+
+	entries, err := r.Status()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.Conflicted() {
+			status.Conflicts = append(status.Conflicts, entry.Path)
+		}
+	}
+
+	return status, nil
+}
@@ -0,0 +1,165 @@
+package gittools
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRebaseConflictLoop(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		repo := openTestRepo(t)
+		git := func(args ...string) {
+			t.Helper()
+			if stdout, stderr, err := repo.Client.Exec(args...); err != nil {
+				t.Fatalf("git %v failed: %v\nstdout: %s\nstderr: %s", args, err, stdout, stderr)
+			}
+		}
+
+		git("checkout", "-b", "feature")
+		writeAndCommit(t, repo, "README.md", "# Feature\n")
+		conflicting, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+		writeAndCommit(t, repo, "feature.txt", "feature\n")
+		writeAndCommit(t, repo, "other.txt", "other\n")
+		git("checkout", "main")
+		writeAndCommit(t, repo, "README.md", "# Main\n")
+
+		err = repo.RebaseWithOptions(RebaseOptions{Upstream: "main", Branch: "feature"})
+		if !errors.Is(err, ErrRebaseMergeConflict) {
+			t.Fatalf("Expected ErrRebaseMergeConflict, got %v", err)
+		}
+
+		status, err := repo.RebaseStatus()
+		if err != nil {
+			t.Fatalf("RebaseStatus failed: %v", err)
+		}
+		if !status.InProgress || status.HeadName != "refs/heads/feature" || status.StoppedAt != conflicting {
+			t.Errorf("Unexpected rebase status %+v", status)
+		}
+		if status.Current != 1 || status.Total != 3 {
+			t.Errorf("Expected to stop at step 1 of 3, got %d of %d", status.Current, status.Total)
+		}
+		if len(status.Conflicts) != 1 || status.Conflicts[0] != "README.md" {
+			t.Errorf("Expected README.md to conflict, got %v", status.Conflicts)
+		}
+
+		// Conflicts must be resolved before continuing
+		if err := repo.RebaseContinue(); !errors.Is(err, ErrRebaseMergeConflict) {
+			t.Errorf("Expected ErrRebaseMergeConflict with unresolved conflicts, got %v", err)
+		}
+
+		if err := os.WriteFile(filepath.Join(repo.RepoPath, "README.md"), []byte("# Resolved\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		git("add", "README.md")
+
+		if err := repo.RebaseContinue(); err != nil {
+			t.Fatalf("RebaseContinue failed: %v", err)
+		}
+
+		status, err = repo.RebaseStatus()
+		if err != nil {
+			t.Fatalf("RebaseStatus failed: %v", err)
+		}
+		if status.InProgress {
+			t.Errorf("Expected the rebase to be finished, got %+v", status)
+		}
+
+		count, err := repo.CountCommits("feature")
+		if err != nil || count != 5 {
+			t.Errorf("Expected 5 commits on feature, got %d, %v", count, err)
+		}
+	})
+}
+
+func TestRebaseSkip(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		repo := openTestRepo(t)
+		if err := repo.CreateBranch("feature"); err != nil {
+			t.Fatalf("Failed to create branch: %v", err)
+		}
+		writeAndCommit(t, repo, "README.md", "# Main\n")
+		if err := repo.Checkout("feature"); err != nil {
+			t.Fatalf("Failed to checkout: %v", err)
+		}
+		writeAndCommit(t, repo, "README.md", "# Feature\n")
+		writeAndCommit(t, repo, "feature.txt", "feature\n")
+
+		if err := repo.Rebase("main"); !errors.Is(err, ErrRebaseMergeConflict) {
+			t.Fatalf("Expected ErrRebaseMergeConflict, got %v", err)
+		}
+		if err := repo.RebaseSkip(); err != nil {
+			t.Fatalf("RebaseSkip failed: %v", err)
+		}
+
+		count, err := repo.CountCommits("HEAD")
+		if err != nil || count != 3 {
+			t.Errorf("Expected the conflicting commit to be dropped, got %d commits, %v", count, err)
+		}
+	})
+}
+
+func TestRebaseOptions(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		repo := openTestRepo(t)
+		base, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+
+		writeAndCommit(t, repo, "a.txt", "a\n")
+		path := filepath.Join(repo.RepoPath, "a.txt")
+		if err := os.WriteFile(path, []byte("a fixed\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if err := repo.Commit("fixup! Add a.txt", []string{path}); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+
+		if err := repo.RebaseWithOptions(RebaseOptions{Upstream: base, Autosquash: true}); err != nil {
+			t.Fatalf("Autosquash rebase failed: %v", err)
+		}
+		items, err := repo.Log(LogOptions{Oneline: true})
+		if err != nil {
+			t.Fatalf("Log failed: %v", err)
+		}
+		if len(items) != 2 || items[0].Message != "Add a.txt" {
+			t.Errorf("Expected the fixup to be squashed, got %+v", items)
+		}
+
+		// Move the commits after base onto a new branch
+		if stdout, stderr, err := repo.Client.Exec("checkout", "-b", "other", base); err != nil {
+			t.Fatalf("Failed to checkout: %v\n%s%s", err, stdout, stderr)
+		}
+		writeAndCommit(t, repo, "b.txt", "b\n")
+		other, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+
+		if err := repo.RebaseOnto("other", base, "main"); err != nil {
+			t.Fatalf("RebaseOnto failed: %v", err)
+		}
+		parent, err := repo.RevParse("main~1")
+		if err != nil || parent != other {
+			t.Errorf("Expected main to be rebased onto %s, got %s, %v", other, parent, err)
+		}
+
+		// A failing exec command stops the rebase
+		err = repo.RebaseWithOptions(RebaseOptions{Upstream: base, Exec: "false"})
+		if err == nil {
+			t.Fatal("Expected a failing exec command to stop the rebase")
+		}
+		status, err := repo.RebaseStatus()
+		if err != nil || !status.InProgress {
+			t.Errorf("Expected the rebase to be in progress, got %+v, %v", status, err)
+		}
+		if err := repo.RebaseAbort(); err != nil {
+			t.Fatalf("RebaseAbort failed: %v", err)
+		}
+	})
+}
@@ -0,0 +1,220 @@
+package gittools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Recording is a single git command captured by a Recorder
+type Recording struct {
+	Args   []string `json:"args"`
+	Stdin  []byte   `json:"stdin,omitempty"`
+	Stdout []byte   `json:"stdout,omitempty"`
+	Stderr []byte   `json:"stderr,omitempty"`
+
+	// ExitCode is the exit status of git, -1 if the command failed without exiting
+	ExitCode int `json:"exit_code"`
+
+	// Error is the message of the error returned for the command, if any
+	Error string `json:"error,omitempty"`
+}
+
+// Recorder is a Hook that writes every command run by a Client, with its input and
+// output, as JSON lines. The recordings can be served by a Replayer to test code
+// using gittools without running git.
+//
+//	f, _ := os.Create("testdata/session.jsonl")
+//	recorder := gittools.NewRecorder(f)
+//	repo.Client.Hooks = append(repo.Client.Hooks, recorder)
+//
+// Add the Recorder after any hooks that modify commands so the final arguments are recorded.
+type Recorder struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	stdin map[*Command][]byte
+	err   error
+}
+
+// NewRecorder creates a Recorder writing to w
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{
+		enc:   json.NewEncoder(w),
+		stdin: make(map[*Command][]byte),
+	}
+}
+
+// BeforeExec captures the stdin of the command
+func (r *Recorder) BeforeExec(cmd *Command) error {
+	if cmd.Stdin == nil {
+		return nil
+	}
+
+	stdin, err := io.ReadAll(cmd.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to record stdin: %w", err)
+	}
+	cmd.Stdin = bytes.NewReader(stdin)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stdin[cmd] = stdin
+	return nil
+}
+
+// AfterExec writes the recording of the command
+func (r *Recorder) AfterExec(cmd *Command, stdout, stderr []byte, err error, duration time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	recording := Recording{
+		Args:   cmd.Args,
+		Stdin:  r.stdin[cmd],
+		Stdout: stdout,
+		Stderr: stderr,
+	}
+	delete(r.stdin, cmd)
+	if err != nil {
+		recording.ExitCode = ExitCode(err)
+		recording.Error = err.Error()
+	}
+
+	if encErr := r.enc.Encode(recording); encErr != nil && r.err == nil {
+		r.err = fmt.Errorf("failed to write recording: %w", encErr)
+	}
+}
+
+// Err returns the first error encountered writing recordings
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// RecordedExitError is returned by a Replayer for a command that exited with a non-zero status
+type RecordedExitError struct {
+	Code    int
+	Message string
+}
+
+func (e *RecordedExitError) Error() string {
+	return e.Message
+}
+
+// ExitCode returns the recorded exit status
+func (e *RecordedExitError) ExitCode() int {
+	return e.Code
+}
+
+// Replayer is a Hook that serves the output of commands captured by a Recorder
+// instead of running git. Each recording is served once, to the first command
+// with the same arguments and stdin, so repeated commands replay in order.
+// Commands without a matching recording fail.
+type Replayer struct {
+	mu         sync.Mutex
+	recordings []Recording
+	used       []bool
+}
+
+// NewReplayer creates a Replayer from the JSON lines written by a Recorder
+func NewReplayer(r io.Reader) (*Replayer, error) {
+	var recordings []Recording
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var recording Recording
+		err := dec.Decode(&recording)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read recording: %w", err)
+		}
+		recordings = append(recordings, recording)
+	}
+
+	return &Replayer{
+		recordings: recordings,
+		used:       make([]bool, len(recordings)),
+	}, nil
+}
+
+// BeforeExec sets the result of the command to its recorded output
+func (r *Replayer) BeforeExec(cmd *Command) error {
+	var stdin []byte
+	if cmd.Stdin != nil {
+		var err error
+		if stdin, err = io.ReadAll(cmd.Stdin); err != nil {
+			return fmt.Errorf("failed to read stdin: %w", err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, recording := range r.recordings {
+		if r.used[i] || !recording.matches(cmd.Args, stdin) {
+			continue
+		}
+		r.used[i] = true
+
+		cmd.Result = &CommandResult{
+			Stdout: recording.Stdout,
+			Stderr: recording.Stderr,
+			Err:    recording.err(),
+		}
+		return nil
+	}
+
+	return fmt.Errorf("no recording for git %s", strings.Join(cmd.Args, " "))
+}
+
+func (r *Replayer) AfterExec(cmd *Command, stdout, stderr []byte, err error, duration time.Duration) {
+}
+
+// Unused returns the recordings that have not been replayed, to check that code
+// under test ran every recorded command
+func (r *Replayer) Unused() []Recording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var unused []Recording
+	for i, recording := range r.recordings {
+		if !r.used[i] {
+			unused = append(unused, recording)
+		}
+	}
+	return unused
+}
+
+func (r Recording) matches(args []string, stdin []byte) bool {
+	return equalArgs(r.Args, args) && bytes.Equal(r.Stdin, stdin)
+}
+
+// equalArgs returns true if a and b contain the same arguments in the same order
+func equalArgs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// err recreates the error returned for the recorded command
+func (r Recording) err() error {
+	switch {
+	case r.Error == "" && r.ExitCode == 0:
+		return nil
+	case r.ExitCode < 0:
+		return fmt.Errorf("%s", r.Error)
+	default:
+		return &RecordedExitError{Code: r.ExitCode, Message: r.Error}
+	}
+}
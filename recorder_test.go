@@ -0,0 +1,91 @@
+package gittools
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRecorderReplay(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		tempDir := setupTestRepo(t)
+
+		repo, err := Open(tempDir)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+
+		var session bytes.Buffer
+		recorder := NewRecorder(&session)
+		repo.Client.Hooks = []Hook{recorder}
+
+		head, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to rev-parse: %v", err)
+		}
+		hash, _, err := repo.Client.ExecWithInput(strings.NewReader("content"), "hash-object", "--stdin")
+		if err != nil {
+			t.Fatalf("Failed to hash object: %v", err)
+		}
+		state, err := repo.State()
+		if err != nil {
+			t.Fatalf("Failed to get state: %v", err)
+		}
+		_, _, missingErr := repo.Client.Exec("rev-parse", "--verify", "-q", "refs/heads/missing")
+		if ExitCode(missingErr) != 1 {
+			t.Fatalf("Expected rev-parse to exit with status 1, got %v", missingErr)
+		}
+		if err := recorder.Err(); err != nil {
+			t.Fatalf("Failed to record: %v", err)
+		}
+
+		// Replay without a git binary
+		replayer, err := NewReplayer(&session)
+		if err != nil {
+			t.Fatalf("Failed to load recordings: %v", err)
+		}
+		replayed := &Repo{
+			Client:   &Client{Binary: "/nonexistent/git", WorkDir: tempDir, Hooks: []Hook{replayer}},
+			RepoPath: tempDir,
+		}
+
+		replayedHead, err := replayed.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to replay rev-parse: %v", err)
+		}
+		if replayedHead != head {
+			t.Errorf("Expected replayed HEAD %s, got %s", head, replayedHead)
+		}
+
+		replayedHash, _, err := replayed.Client.ExecWithInput(strings.NewReader("content"), "hash-object", "--stdin")
+		if err != nil {
+			t.Fatalf("Failed to replay hash-object: %v", err)
+		}
+		if string(replayedHash) != string(hash) {
+			t.Errorf("Expected replayed hash %q, got %q", hash, replayedHash)
+		}
+
+		// Commands are matched by their stdin as well as their arguments
+		if _, _, err := replayed.Client.ExecWithInput(strings.NewReader("other"), "hash-object", "--stdin"); err == nil {
+			t.Error("Expected command with different stdin to have no recording")
+		}
+
+		if len(replayer.Unused()) == 0 {
+			t.Error("Expected state commands to be unused")
+		}
+		replayedState, err := replayed.State()
+		if err != nil {
+			t.Fatalf("Failed to replay state: %v", err)
+		}
+		if replayedState != state {
+			t.Errorf("Expected replayed state %+v, got %+v", state, replayedState)
+		}
+		_, _, err = replayed.Client.Exec("rev-parse", "--verify", "-q", "refs/heads/missing")
+		if ExitCode(err) != 1 || err.Error() != missingErr.Error() {
+			t.Errorf("Expected replayed error %v with status 1, got %v", missingErr, err)
+		}
+		if unused := replayer.Unused(); len(unused) != 0 {
+			t.Errorf("Expected all recordings to be replayed, got %d unused", len(unused))
+		}
+	})
+}
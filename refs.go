@@ -0,0 +1,219 @@
+package gittools
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// refFields are the for-each-ref fields read into every Ref
+var refFields = []string{
+	"refname",
+	"objectname",
+	"objecttype",
+	"upstream",
+	"committerdate:unix",
+	"*committerdate:unix",
+}
+
+// Ref describes a ref listed by ForEachRef
+type Ref struct {
+	// Name is the full name of the ref, e.g. "refs/heads/main"
+	Name string
+
+	// Object is the hash of the object the ref points at
+	Object string
+
+	// Type of the object: "commit", "tag", "tree" or "blob"
+	Type string
+
+	// Upstream is the full name of the ref a branch tracks, e.g.
+	// "refs/remotes/origin/main", or empty if it has none
+	Upstream string
+
+	// CommitterDate is the committer date of the commit, or of the commit an
+	// annotated tag points at. It is zero for other objects.
+	CommitterDate time.Time
+
+	// Fields holds the values of the extra fields requested from ForEachRef,
+	// keyed by the field as given
+	Fields map[string]string
+}
+
+// ForEachRef lists the refs matching pattern, sorted by name. A pattern matches refs
+// with it as a prefix, e.g. "refs/heads/" or "refs/tags/v1", or by glob, e.g.
+// "refs/remotes/*/main". An empty pattern lists every ref.
+// fields are extra for-each-ref fields to read into Ref.Fields, e.g. "subject" or
+// "objectname:short", see the FIELD NAMES section of git-for-each-ref(1).
+func (r *Repo) ForEachRef(pattern string, fields ...string) ([]Ref, error) {
+	all := append(refFields[:len(refFields):len(refFields)], fields...)
+
+	// Every field is terminated by NUL, so values may contain newlines
+	var format strings.Builder
+	for _, field := range all {
+		format.WriteString("%(" + field + ")%00")
+	}
+
+	args := []string{"for-each-ref", "--format=" + format.String()}
+	if pattern != "" {
+		args = append(args, pattern)
+	}
+	stdout, stderr, err := r.Client.Exec(args...)
+	if err != nil {
+		return nil, fmt.Errorf("git for-each-ref failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+
+	values := strings.Split(string(stdout), "\x00")
+	var refs []Ref
+	for i := 0; i+len(all) <= len(values); i += len(all) {
+		record := values[i : i+len(all)]
+
+		// git ends each record with a newline after the last NUL
+		ref := Ref{
+			Name:     strings.TrimPrefix(record[0], "\n"),
+			Object:   record[1],
+			Type:     record[2],
+			Upstream: record[3],
+		}
+
+		date := record[4]
+		if date == "" {
+			date = record[5]
+		}
+		if date != "" {
+			seconds, err := strconv.ParseInt(date, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid committer date %q for %s: %w", date, ref.Name, err)
+			}
+			ref.CommitterDate = time.Unix(seconds, 0)
+		}
+
+		if len(fields) > 0 {
+			ref.Fields = make(map[string]string, len(fields))
+			for j, field := range fields {
+				ref.Fields[field] = record[len(refFields)+j]
+			}
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// ErrRefMismatch is returned by UpdateRef and DeleteRef when the ref does not have
+// the expected old value, e.g. because it was moved concurrently
+var ErrRefMismatch = errors.New("ref does not have the expected value")
+
+// ErrNotSymbolicRef is returned by SymbolicRef when the ref is not a symbolic ref
+var ErrNotSymbolicRef = errors.New("not a symbolic ref")
+
+// NullHash is the all-zero object name. Passed as the old value to UpdateRef it
+// requires that the ref does not exist yet.
+const NullHash = "0000000000000000000000000000000000000000"
+
+// UpdateRef points ref at newValue, which may be any revision. If oldValue is not empty,
+// the ref is only updated if it currently points at oldValue, and ErrRefMismatch is
+// returned otherwise, so a ref moved concurrently is never overwritten.
+// Pass NullHash as oldValue to create a ref that must not already exist.
+func (r *Repo) UpdateRef(ref, newValue, oldValue string) error {
+	args := []string{"update-ref", ref, newValue}
+	if oldValue != "" {
+		args = append(args, oldValue)
+	}
+	stdout, stderr, err := r.Client.Exec(args...)
+	if err != nil {
+		return updateRefError(err, stdout, stderr)
+	}
+	return nil
+}
+
+// DeleteRef deletes ref. If oldValue is not empty, the ref is only deleted if it currently
+// points at oldValue, and ErrRefMismatch is returned otherwise.
+func (r *Repo) DeleteRef(ref, oldValue string) error {
+	args := []string{"update-ref", "-d", ref}
+	if oldValue != "" {
+		args = append(args, oldValue)
+	}
+	stdout, stderr, err := r.Client.Exec(args...)
+	if err != nil {
+		return updateRefError(err, stdout, stderr)
+	}
+	return nil
+}
+
+// updateRefError classifies a failed git update-ref
+func updateRefError(err error, stdout, stderr []byte) error {
+	output := string(stderr)
+	switch {
+	case strings.Contains(output, "but expected"),
+		strings.Contains(output, "reference already exists"),
+		strings.Contains(output, "unable to resolve reference"):
+		return fmt.Errorf("%w: %s", ErrRefMismatch, strings.TrimSpace(output))
+	default:
+		return fmt.Errorf("git update-ref failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+}
+
+// SymbolicRef returns the full name of the ref that the symbolic ref name points at,
+// e.g. "refs/heads/main" for "HEAD" or "refs/remotes/origin/main" for
+// "refs/remotes/origin/HEAD".
+// Returns ErrNotSymbolicRef if name is not a symbolic ref, e.g. a detached HEAD,
+// or does not exist.
+func (r *Repo) SymbolicRef(name string) (string, error) {
+	stdout, stderr, err := r.Client.Exec("symbolic-ref", "--quiet", name)
+	if err != nil {
+		// symbolic-ref -q exits with status 1 when name is not a symbolic ref
+		if ExitCode(err) == 1 {
+			return "", fmt.Errorf("%w: %s", ErrNotSymbolicRef, name)
+		}
+		return "", fmt.Errorf("git symbolic-ref failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
+
+// SetSymbolicRef points the symbolic ref name at the ref target, e.g. setting
+// "refs/remotes/origin/HEAD" to "refs/remotes/origin/main". target must be a full
+// ref name under refs/, but need not exist yet.
+func (r *Repo) SetSymbolicRef(name, target string) error {
+	stdout, stderr, err := r.Client.Exec("symbolic-ref", name, target)
+	if err != nil {
+		return fmt.Errorf("git symbolic-ref failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return nil
+}
+
+// RefExists returns true if the ref with the full name ref exists, e.g.
+// "refs/heads/main", "refs/tags/v1.0" or "HEAD". A ref that points at a missing
+// object does not exist.
+func (r *Repo) RefExists(ref string) (bool, error) {
+	stdout, stderr, err := r.Client.Exec("show-ref", "--verify", "--quiet", ref)
+	if err != nil {
+		// show-ref exits with status 1 when the ref does not exist
+		if ExitCode(err) == 1 {
+			return false, nil
+		}
+		return false, fmt.Errorf("git show-ref failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return true, nil
+}
+
+// BranchExists returns true if the branch exists. If remote is true, name is a
+// remote-tracking branch including the remote, e.g. "origin/main", otherwise a
+// local branch, e.g. "main".
+func (r *Repo) BranchExists(name string, remote bool) (bool, error) {
+	if remote {
+		return r.RefExists("refs/remotes/" + name)
+	}
+	return r.RefExists("refs/heads/" + name)
+}
+
+// TagExists returns true if the tag exists
+func (r *Repo) TagExists(name string) (bool, error) {
+	return r.RefExists("refs/tags/" + name)
+}
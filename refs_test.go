@@ -0,0 +1,207 @@
+package gittools
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestForEachRef(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		remotePath, cleanup, err := CreateTestRemoteRepo("for-each-ref")
+		if err != nil {
+			t.Fatalf("Failed to create remote: %v", err)
+		}
+		defer cleanup()
+
+		client := &Client{}
+		client.SetUser("Test User", "test@example.com")
+		repo, err := client.Clone(remotePath, filepath.Join(testDir, "clone"))
+		if err != nil {
+			t.Fatalf("Failed to clone: %v", err)
+		}
+		writeAndCommit(t, repo, "a.txt", "a\n")
+		head, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+		if stdout, stderr, err := repo.Client.Exec("tag", "-a", "v1.0", "-m", "Release 1.0"); err != nil {
+			t.Fatalf("Failed to tag: %v\n%s%s", err, stdout, stderr)
+		}
+
+		refs, err := repo.ForEachRef("refs/heads/")
+		if err != nil {
+			t.Fatalf("ForEachRef failed: %v", err)
+		}
+		if len(refs) != 1 {
+			t.Fatalf("Expected one branch, got %+v", refs)
+		}
+		branch := refs[0]
+		if branch.Name != "refs/heads/main" || branch.Object != head || branch.Type != "commit" {
+			t.Errorf("Unexpected branch %+v", branch)
+		}
+		if branch.Upstream != "refs/remotes/origin/main" {
+			t.Errorf("Expected upstream refs/remotes/origin/main, got %q", branch.Upstream)
+		}
+		if branch.CommitterDate.IsZero() {
+			t.Error("Expected a committer date")
+		}
+		if branch.Fields != nil {
+			t.Errorf("Expected no extra fields, got %v", branch.Fields)
+		}
+
+		refs, err = repo.ForEachRef("refs/tags/", "contents:subject", "*objectname")
+		if err != nil {
+			t.Fatalf("ForEachRef failed: %v", err)
+		}
+		if len(refs) != 1 {
+			t.Fatalf("Expected one tag, got %+v", refs)
+		}
+		tag := refs[0]
+		if tag.Name != "refs/tags/v1.0" || tag.Type != "tag" || tag.Object == head {
+			t.Errorf("Unexpected tag %+v", tag)
+		}
+		if !tag.CommitterDate.Equal(branch.CommitterDate) {
+			t.Errorf("Expected the tagged commit's date %v, got %v", branch.CommitterDate, tag.CommitterDate)
+		}
+		if tag.Fields["contents:subject"] != "Release 1.0" || tag.Fields["*objectname"] != head {
+			t.Errorf("Unexpected fields %v", tag.Fields)
+		}
+
+		refs, err = repo.ForEachRef("")
+		if err != nil {
+			t.Fatalf("ForEachRef failed: %v", err)
+		}
+		var names []string
+		for _, ref := range refs {
+			names = append(names, ref.Name)
+		}
+		want := []string{"refs/heads/main", "refs/remotes/origin/HEAD", "refs/remotes/origin/main", "refs/tags/v1.0"}
+		if len(names) != len(want) {
+			t.Fatalf("Expected %v, got %v", want, names)
+		}
+		for i := range want {
+			if names[i] != want[i] {
+				t.Errorf("Expected %v, got %v", want, names)
+				break
+			}
+		}
+	})
+}
+
+func TestUpdateRef(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		repo := openTestRepo(t)
+		first, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+		writeAndCommit(t, repo, "a.txt", "a\n")
+		second, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+
+		if err := repo.UpdateRef("refs/locks/a", first, NullHash); err != nil {
+			t.Fatalf("Failed to create ref: %v", err)
+		}
+		if err := repo.UpdateRef("refs/locks/a", second, NullHash); !errors.Is(err, ErrRefMismatch) {
+			t.Errorf("Expected ErrRefMismatch creating an existing ref, got %v", err)
+		}
+		if err := repo.UpdateRef("refs/locks/a", second, second); !errors.Is(err, ErrRefMismatch) {
+			t.Errorf("Expected ErrRefMismatch with a stale old value, got %v", err)
+		}
+		if got, err := repo.RevParse("refs/locks/a"); err != nil || got != first {
+			t.Errorf("Expected the ref to stay at %s, got %s, %v", first, got, err)
+		}
+		if err := repo.UpdateRef("refs/locks/a", second, first); err != nil {
+			t.Fatalf("Failed to update ref: %v", err)
+		}
+		if err := repo.UpdateRef("refs/locks/a", "HEAD~1", ""); err != nil {
+			t.Fatalf("Failed to update ref unconditionally: %v", err)
+		}
+		if got, err := repo.RevParse("refs/locks/a"); err != nil || got != first {
+			t.Errorf("Expected the ref at %s, got %s, %v", first, got, err)
+		}
+
+		if err := repo.DeleteRef("refs/locks/a", second); !errors.Is(err, ErrRefMismatch) {
+			t.Errorf("Expected ErrRefMismatch deleting with a stale old value, got %v", err)
+		}
+		if err := repo.DeleteRef("refs/locks/a", first); err != nil {
+			t.Fatalf("Failed to delete ref: %v", err)
+		}
+		if err := repo.UpdateRef("refs/locks/a", second, first); !errors.Is(err, ErrRefMismatch) {
+			t.Errorf("Expected ErrRefMismatch updating a deleted ref, got %v", err)
+		}
+	})
+}
+
+func TestSymbolicRef(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		repo := openTestRepo(t)
+
+		if target, err := repo.SymbolicRef("HEAD"); err != nil || target != "refs/heads/main" {
+			t.Errorf("Expected HEAD to point at refs/heads/main, got %q, %v", target, err)
+		}
+
+		if err := repo.SetSymbolicRef("refs/remotes/origin/HEAD", "refs/remotes/origin/main"); err != nil {
+			t.Fatalf("SetSymbolicRef failed: %v", err)
+		}
+		if target, err := repo.SymbolicRef("refs/remotes/origin/HEAD"); err != nil || target != "refs/remotes/origin/main" {
+			t.Errorf("Expected refs/remotes/origin/main, got %q, %v", target, err)
+		}
+
+		if err := repo.Checkout("HEAD~0"); err != nil {
+			t.Fatalf("Failed to detach HEAD: %v", err)
+		}
+		if _, err := repo.SymbolicRef("HEAD"); !errors.Is(err, ErrNotSymbolicRef) {
+			t.Errorf("Expected ErrNotSymbolicRef for a detached HEAD, got %v", err)
+		}
+	})
+}
+
+func TestRefExists(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		remotePath, cleanup, err := CreateTestRemoteRepo("ref-exists")
+		if err != nil {
+			t.Fatalf("Failed to create remote: %v", err)
+		}
+		defer cleanup()
+
+		repo, err := (&Client{}).Clone(remotePath, filepath.Join(testDir, "clone"))
+		if err != nil {
+			t.Fatalf("Failed to clone: %v", err)
+		}
+		if err := repo.CreateBranch("feature"); err != nil {
+			t.Fatalf("Failed to create branch: %v", err)
+		}
+		if stdout, stderr, err := repo.Client.Exec("tag", "v1.0"); err != nil {
+			t.Fatalf("Failed to tag: %v\n%s%s", err, stdout, stderr)
+		}
+
+		tests := []struct {
+			name   string
+			exists func() (bool, error)
+			want   bool
+		}{
+			{"HEAD", func() (bool, error) { return repo.RefExists("HEAD") }, true},
+			{"full ref", func() (bool, error) { return repo.RefExists("refs/heads/feature") }, true},
+			{"short ref", func() (bool, error) { return repo.RefExists("feature") }, false},
+			{"missing ref", func() (bool, error) { return repo.RefExists("refs/heads/missing") }, false},
+			{"local branch", func() (bool, error) { return repo.BranchExists("feature", false) }, true},
+			{"local branch not on remote", func() (bool, error) { return repo.BranchExists("origin/feature", true) }, false},
+			{"remote branch", func() (bool, error) { return repo.BranchExists("origin/main", true) }, true},
+			{"remote branch as local", func() (bool, error) { return repo.BranchExists("origin/main", false) }, false},
+			{"tag", func() (bool, error) { return repo.TagExists("v1.0") }, true},
+			{"missing tag", func() (bool, error) { return repo.TagExists("v2.0") }, false},
+		}
+		for _, test := range tests {
+			got, err := test.exists()
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", test.name, err)
+			} else if got != test.want {
+				t.Errorf("%s: expected %v, got %v", test.name, test.want, got)
+			}
+		}
+	})
+}
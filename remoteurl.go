@@ -0,0 +1,141 @@
+package gittools
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ParsedRemoteURL is a remote URL split into its parts, see ParseRemoteURL
+type ParsedRemoteURL struct {
+	// Scheme is "ssh", "https", "http", "git" or "file". scp-like URLs such as
+	// git@github.com:org/repo.git have the scheme "ssh".
+	Scheme string
+
+	// User is the user name, e.g. "git". Passwords are discarded.
+	User string
+
+	// Host is the lowercased host name, empty for file URLs
+	Host string
+
+	// Port is the port if one is given
+	Port string
+
+	// Path is the path of the repository on the host, without a leading slash
+	// for remote hosts. It keeps any .git suffix.
+	Path string
+
+	// Owner is the path of the repository excluding its name, e.g. "org" or
+	// "group/subgroup"
+	Owner string
+
+	// Repo is the name of the repository without any .git suffix
+	Repo string
+}
+
+// ParseRemoteURL parses a git remote URL in any of the forms git accepts:
+// scheme URLs (https://host/org/repo.git, ssh://git@host:2222/org/repo.git),
+// scp-like syntax (git@host:org/repo.git) and local paths.
+func ParseRemoteURL(rawURL string) (*ParsedRemoteURL, error) {
+	if rawURL == "" {
+		return nil, fmt.Errorf("empty remote URL")
+	}
+
+	var parsed ParsedRemoteURL
+	switch {
+	case strings.Contains(rawURL, "://"):
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid remote URL %q: %w", rawURL, err)
+		}
+		parsed.Scheme = strings.ToLower(u.Scheme)
+		parsed.Host = strings.ToLower(u.Hostname())
+		parsed.Port = u.Port()
+		if u.User != nil {
+			parsed.User = u.User.Username()
+		}
+		parsed.Path = u.Path
+		if parsed.Scheme != "file" {
+			parsed.Path = strings.TrimPrefix(parsed.Path, "/")
+		}
+
+	case isSCPLike(rawURL):
+		host, repoPath, _ := strings.Cut(rawURL, ":")
+		if user, h, found := strings.Cut(host, "@"); found {
+			parsed.User = user
+			host = h
+		}
+		parsed.Scheme = "ssh"
+		parsed.Host = strings.ToLower(host)
+		parsed.Path = strings.TrimPrefix(repoPath, "/")
+
+	default:
+		parsed.Scheme = "file"
+		parsed.Path = filepath.ToSlash(rawURL)
+	}
+
+	switch parsed.Scheme {
+	case "ssh", "https", "http", "git", "file":
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q in remote URL %q", parsed.Scheme, rawURL)
+	}
+	if parsed.Scheme != "file" && parsed.Host == "" {
+		return nil, fmt.Errorf("remote URL %q has no host", rawURL)
+	}
+
+	trimmed := strings.TrimSuffix(strings.TrimSuffix(parsed.Path, "/"), ".git")
+	parsed.Owner, parsed.Repo = path.Split(trimmed)
+	parsed.Owner = strings.Trim(parsed.Owner, "/")
+	if parsed.Scheme == "file" {
+		// The directory of a local repository is not an owner
+		parsed.Owner = ""
+	}
+	if parsed.Repo == "" {
+		return nil, fmt.Errorf("remote URL %q has no repository path", rawURL)
+	}
+
+	return &parsed, nil
+}
+
+// isSCPLike returns true for URLs such as git@host:org/repo.git, which git recognizes
+// by a colon before the first slash
+func isSCPLike(rawURL string) bool {
+	colon := strings.Index(rawURL, ":")
+	if colon <= 0 {
+		return false
+	}
+	slash := strings.Index(rawURL, "/")
+	if slash >= 0 && slash < colon {
+		return false
+	}
+	// A single letter before the colon is a Windows drive, e.g. C:\repo
+	return colon > 1
+}
+
+// Canonical returns a form of the URL that is the same for every way of addressing
+// the repository, e.g. "github.com/org/repo" for both git@github.com:org/repo.git and
+// https://github.com/org/repo. Local repositories return their cleaned path.
+func (u *ParsedRemoteURL) Canonical() string {
+	if u.Scheme == "file" {
+		return path.Clean(strings.TrimSuffix(u.Path, "/"))
+	}
+	return path.Join(u.Host, u.Owner, u.Repo)
+}
+
+// String returns the URL in scheme form, e.g. "ssh://git@github.com/org/repo.git"
+func (u *ParsedRemoteURL) String() string {
+	if u.Scheme == "file" {
+		return "file://" + u.Path
+	}
+
+	host := u.Host
+	if u.Port != "" {
+		host += ":" + u.Port
+	}
+	if u.User != "" {
+		host = u.User + "@" + host
+	}
+	return u.Scheme + "://" + host + "/" + u.Path
+}
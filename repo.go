@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -29,6 +30,14 @@ var (
 
 	// ErrPushRemoteRefMissing is returned when the remote reference does not exist
 	ErrPushRemoteRefMissing = errors.New("git push rejected: remote ref does not exist")
+
+	// ErrPushStaleInfo is returned when a --force-with-lease push is rejected
+	// because the remote ref no longer has the expected value
+	ErrPushStaleInfo = errors.New("git push rejected: stale info")
+
+	// ErrPushProtectedBranch is returned when the remote refuses to update or delete a
+	// protected branch, e.g. its default branch, or a branch protected by the hosting service
+	ErrPushProtectedBranch = errors.New("git push rejected: protected branch")
 )
 
 // Git rebase error types
@@ -43,58 +52,138 @@ var (
 	ErrRebaseNoCommitsApplied = errors.New("git rebase failed: no commits applied")
 )
 
+// ErrBranchExists is returned when creating a branch that already exists
+var ErrBranchExists = errors.New("git branch already exists")
+
 // Repo represents a Git repository
 type Repo struct {
 	Client   *Client
 	RepoPath string
+
+	// Bare is true if the repository has no working tree.
+	// RepoPath is then the repository's git directory.
+	Bare bool
+}
+
+// WithContext returns a copy of the Repo whose git commands are killed when ctx is done,
+// as if each were run with ExecContext. Repos created from the copy, such as worktrees,
+// are bound to ctx too. Commands given their own context, such as with
+// FetchOptions.Context, are killed when either context is done.
+func (r *Repo) WithContext(ctx context.Context) *Repo {
+	r2 := *r
+	r2.Client = r.Client.withContext(ctx)
+	return &r2
 }
 
-// Open opens a GitRepo instance from an existing repository
+// Open opens a GitRepo instance from an existing repository.
+// repoPath may be anywhere inside the working tree, or inside the git directory of a bare repository.
 func Open(repoPath string) (*Repo, error) {
 	absPath, err := filepath.Abs(repoPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get absolute path: %w", err)
 	}
 
-	// Walk up the directory tree to find the git repository root
-	gitRoot, err := findGitRoot(absPath)
+	// Ask git for the repository root
+	root, bare, err := discoverRepo(absPath)
 	if err != nil {
 		return nil, err
 	}
 
-	// Use the git root as the repo path
-	absPath = gitRoot
-
 	return &Repo{
-		Client:   &Client{WorkDir: absPath},
-		RepoPath: absPath,
+		Client:   &Client{WorkDir: root},
+		RepoPath: root,
+		Bare:     bare,
 	}, nil
 }
 
-// findGitRoot walks up the directory tree to find the git repository root
-func findGitRoot(startPath string) (string, error) {
-	currentPath := startPath
+// OpenGitDir opens the repository whose git directory is gitDir, with its working tree
+// at workTree, without discovering either from a working directory. This suits servers
+// that keep many bare repositories in one place and check them out into separate work trees.
+// If gitDir is empty, the GIT_DIR environment variable is used, and if workTree is empty,
+// GIT_WORK_TREE. Without a work tree the repository is opened as bare, and RepoPath is gitDir.
+func OpenGitDir(gitDir, workTree string) (*Repo, error) {
+	if gitDir == "" {
+		gitDir = os.Getenv("GIT_DIR")
+	}
+	if gitDir == "" {
+		return nil, fmt.Errorf("no git directory given and GIT_DIR is not set")
+	}
+	if workTree == "" {
+		workTree = os.Getenv("GIT_WORK_TREE")
+	}
 
-	for {
-		// Check if .git exists in the current directory
-		gitPath := filepath.Join(currentPath, ".git")
-		if _, err := os.Stat(gitPath); err == nil {
-			return currentPath, nil
+	absGitDir, err := filepath.Abs(gitDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	repo := &Repo{
+		Client:   &Client{WorkDir: absGitDir, GitDir: absGitDir},
+		RepoPath: absGitDir,
+		Bare:     true,
+	}
+	if workTree != "" {
+		absWorkTree, err := filepath.Abs(workTree)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get absolute path: %w", err)
 		}
+		repo.Client.WorkDir = absWorkTree
+		repo.Client.WorkTree = absWorkTree
+		repo.RepoPath = absWorkTree
+		repo.Bare = false
+	}
+
+	if _, stderr, err := repo.Client.Exec("rev-parse", "--git-dir"); err != nil {
+		return nil, fmt.Errorf("not a git repository: %s\nstderr: %s", absGitDir, stderr)
+	}
+	return repo, nil
+}
 
-		// Get the parent directory
-		parentPath := filepath.Dir(currentPath)
+// discoverRepo asks git for the repository containing path, which handles linked worktrees,
+// submodules where .git is a file, GIT_DIR overrides and bare repositories.
+// Returns the top level of the working tree, or the git directory of a bare repository.
+func discoverRepo(path string) (string, bool, error) {
+	client := &Client{WorkDir: path}
+	stdout, stderr, err := client.Exec("rev-parse", "--is-bare-repository", "--absolute-git-dir")
+	if err != nil {
+		return "", false, fmt.Errorf("not a git repository: %s (or any of the parent directories)\nstderr: %s", path, stderr)
+	}
 
-		// Check if we've reached the filesystem root
-		if parentPath == currentPath {
-			return "", fmt.Errorf("not a git repository: %s (or any of the parent directories)", startPath)
-		}
+	lines := strings.Split(strings.TrimSpace(string(stdout)), "\n")
+	if len(lines) != 2 {
+		return "", false, fmt.Errorf("unexpected git rev-parse output: %q", stdout)
+	}
+	if lines[0] == "true" {
+		return unresolvedAncestor(path, lines[1]), true, nil
+	}
 
-		currentPath = parentPath
+	stdout, stderr, err = client.Exec("rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", false, fmt.Errorf("git rev-parse failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return unresolvedAncestor(path, strings.TrimSpace(string(stdout))), false, nil
+}
+
+// unresolvedAncestor returns the ancestor of path that resolves to target.
+// git reports paths with symlinks resolved, this keeps the caller's spelling of the path
+// so that paths derived from it match paths the caller already has.
+// If no ancestor of path resolves to target, target is returned.
+func unresolvedAncestor(path string, target string) string {
+	for current := path; ; current = filepath.Dir(current) {
+		if resolved, err := filepath.EvalSymlinks(current); err == nil && resolved == target {
+			return current
+		}
+		if filepath.Dir(current) == current {
+			return target
+		}
 	}
 }
 
 func (g *Repo) CommitAll(message string) error {
+	if err := g.Client.CheckIdentity(); err != nil {
+		return err
+	}
+
 	stdout, stderr, err := g.Client.Exec("add", "--all")
 	if err != nil {
 		return fmt.Errorf("git add failed: %w\nstdout: %s\nstderr: %s",
@@ -112,6 +201,10 @@ func (g *Repo) CommitAll(message string) error {
 
 // Commit stages and commits the specified files
 func (g *Repo) Commit(message string, files []string) error {
+	if err := g.Client.CheckIdentity(); err != nil {
+		return err
+	}
+
 	// Add the files
 	for _, file := range files {
 		stdout, stderr, err := g.Client.Exec("add", file)
@@ -133,15 +226,24 @@ func (g *Repo) Commit(message string, files []string) error {
 
 type FetchOptions struct {
 	Depth int
+
+	// Refspecs to fetch instead of the remote's configured refspecs
+	Refspecs []string
+
+	// Progress receives transfer progress updates while fetching
+	Progress ProgressFunc
+
+	// Context kills the fetch when done
+	Context context.Context
+
+	// Prune removes remote-tracking refs whose branch no longer exists on the remote
+	Prune bool
 }
 
-// Fetch fetches updates from the specified remote
+// Fetch fetches updates from the specified remote.
+// Use FetchWithResult to find out which refs changed.
 func (g *Repo) Fetch(remote string, options FetchOptions) error {
-	args := []string{"fetch", remote}
-	if options.Depth != 0 {
-		args = append(args, fmt.Sprintf("--depth=%d", options.Depth))
-	}
-	stdout, stderr, err := g.Client.Exec(args...)
+	stdout, stderr, err := g.fetch(remote, options)
 	if err != nil {
 		return fmt.Errorf("git fetch failed: %w\nstdout: %s\nstderr: %s",
 			err, stdout, stderr)
@@ -150,9 +252,46 @@ func (g *Repo) Fetch(remote string, options FetchOptions) error {
 	return nil
 }
 
-// Pull pulls changes from the specified remote and branch
+// fetch runs git fetch with the given options, prefixed by extra global options
+func (g *Repo) fetch(remote string, options FetchOptions, global ...string) ([]byte, []byte, error) {
+	args := append(global, "fetch")
+	if options.Progress != nil {
+		args = append(args, "--progress")
+	}
+	if options.Prune {
+		args = append(args, "--prune")
+	}
+	args = append(args, remote)
+	if options.Depth != 0 {
+		args = append(args, fmt.Sprintf("--depth=%d", options.Depth))
+	}
+	args = append(args, options.Refspecs...)
+	return g.Client.ExecWithOptions(ExecOptions{
+		Context:  options.Context,
+		Progress: options.Progress,
+	}, args...)
+}
+
+// Pull pulls changes from the specified remote and branch.
+// Use PullWithResult to find out how the current branch was updated.
 func (g *Repo) Pull(remote, branch string) error {
-	stdout, stderr, err := g.Client.Exec("pull", remote, branch)
+	return g.PullWithOptions(remote, branch, PullOptions{})
+}
+
+// PullOptions defines options for pulling
+type PullOptions struct {
+	// Progress receives transfer progress updates while fetching
+	Progress ProgressFunc
+}
+
+// PullWithOptions pulls changes from the specified remote and branch
+func (g *Repo) PullWithOptions(remote, branch string, options PullOptions) error {
+	args := []string{"pull"}
+	if options.Progress != nil {
+		args = append(args, "--progress")
+	}
+	args = append(args, remote, branch)
+	stdout, stderr, err := g.Client.ExecWithOptions(ExecOptions{Progress: options.Progress}, args...)
 	if err != nil {
 		return fmt.Errorf("git pull failed: %w\nstdout: %s\nstderr: %s",
 			err, stdout, stderr)
@@ -161,47 +300,269 @@ func (g *Repo) Pull(remote, branch string) error {
 	return nil
 }
 
-// Push pushes changes to the specified remote and branch
-func (g *Repo) Push(remote, branch string) error {
+// Push fetches from the remote, then pushes the given branches or refspecs to it, e.g.
+// Push("origin", "main") or Push("origin", "main", "refs/tags/v1.0") to release a branch
+// and its tag together. Several refspecs are pushed atomically, so either every ref is
+// updated or none are, unless the remote doesn't support atomic pushes.
+func (g *Repo) Push(remote string, refspecs ...string) error {
+	if len(refspecs) == 0 {
+		return fmt.Errorf("no refspecs provided to push")
+	}
 	err := g.Fetch(remote, FetchOptions{})
 	if err != nil {
 		return fmt.Errorf("git fetch failed: %w", err)
 	}
-	stdout, stderr, err := g.Client.Exec("push", "--porcelain", remote, branch)
+	return g.pushAtomic(remote, refspecs...)
+}
+
+// PushTags pushes the given tags to the remote atomically, as Push does
+func (g *Repo) PushTags(remote string, tags ...string) error {
+	if len(tags) == 0 {
+		return fmt.Errorf("no tags provided to push")
+	}
+	refspecs := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		refspecs = append(refspecs, "refs/tags/"+tag)
+	}
+	return g.pushAtomic(remote, refspecs...)
+}
+
+// PushAllTags pushes every local tag to the remote atomically, as Push does
+func (g *Repo) PushAllTags(remote string) error {
+	return g.pushAtomic(remote, "--tags")
+}
+
+// pushAtomic pushes args with --atomic when there is more than one ref to update,
+// falling back to a regular push if the remote doesn't support atomic pushes
+func (g *Repo) pushAtomic(remote string, args ...string) error {
+	if len(args) > 1 || args[0] == "--tags" {
+		atomic := append([]string{"push", "--porcelain", "--atomic", remote}, args...)
+		stdout, stderr, err := g.Client.Exec(atomic...)
+		if err == nil {
+			return nil
+		}
+		if !strings.Contains(string(stderr), "does not support --atomic push") {
+			return classifyPushError(stdout, stderr, err)
+		}
+	}
+
+	stdout, stderr, err := g.Client.Exec(append([]string{"push", "--porcelain", remote}, args...)...)
+	if err != nil {
+		return classifyPushError(stdout, stderr, err)
+	}
+	return nil
+}
+
+// remoteNamePattern matches the name of a configured remote, as opposed to a URL or path
+var remoteNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// Lease describes the value a remote ref is expected to have for a push
+// using --force-with-lease to succeed
+type Lease struct {
+	// Ref is the full name of the remote ref (e.g. "refs/heads/main")
+	Ref string
+
+	// Expected is the object the remote ref must currently point to.
+	// An empty value requires that the ref does not yet exist.
+	Expected string
+}
+
+// PushOptions defines options for pushing arbitrary refspecs
+type PushOptions struct {
+	// Refspecs to push (e.g. "HEAD:refs/heads/main", ":refs/tags/old")
+	Refspecs []string
+
+	// Atomic requests that either all refs are updated or none are (--atomic)
+	Atomic bool
+
+	// ForceWithLease makes the push a compare-and-swap against the expected
+	// values of the listed remote refs
+	ForceWithLease []Lease
+
+	// Progress receives transfer progress updates while pushing
+	Progress ProgressFunc
+
+	// IgnoreMirror overrides remote.<name>.mirror for this push. A remote cloned
+	// with --mirror has it set, which makes git reject refspecs given on the command line.
+	IgnoreMirror bool
+}
+
+// PushWithOptions pushes the given refspecs to the remote without fetching first.
+// Rejections caused by a failed lease are reported as ErrPushStaleInfo.
+func (g *Repo) PushWithOptions(remote string, options PushOptions) error {
+	if len(options.Refspecs) == 0 {
+		return fmt.Errorf("no refspecs provided to push")
+	}
+
+	args := []string{"push", "--porcelain"}
+	if options.IgnoreMirror && remoteNamePattern.MatchString(remote) {
+		args = append([]string{"-c", "remote." + remote + ".mirror=false"}, args...)
+	}
+	if options.Progress != nil {
+		args = append(args, "--progress")
+	}
+	if options.Atomic {
+		args = append(args, "--atomic")
+	}
+	for _, lease := range options.ForceWithLease {
+		args = append(args, fmt.Sprintf("--force-with-lease=%s:%s", lease.Ref, lease.Expected))
+	}
+	args = append(args, remote)
+	args = append(args, options.Refspecs...)
+
+	stdout, stderr, err := g.Client.ExecWithOptions(ExecOptions{Progress: options.Progress}, args...)
 	if err != nil {
-		// Parse the output to determine the specific error type
-		outputStr := string(stdout)
-		stderrStr := string(stderr)
-		combinedOutput := outputStr + stderrStr
+		return classifyPushError(stdout, stderr, err)
+	}
+
+	return nil
+}
+
+// classifyPushError converts the output of a failed push into one of the typed push errors
+func classifyPushError(stdout, stderr []byte, err error) error {
+	// Parse the output to determine the specific error type
+	outputStr := string(stdout)
+	stderrStr := string(stderr)
+	combinedOutput := outputStr + stderrStr
+
+	switch {
+	case strings.Contains(combinedOutput, "stale info"):
+		return fmt.Errorf("%w: %s", ErrPushStaleInfo, combinedOutput)
 
-		switch {
-		case strings.Contains(combinedOutput, "fetch first"):
-			return fmt.Errorf("%w: %s", ErrPushFetchFirst, combinedOutput)
-		case strings.Contains(combinedOutput, "non-fast-forward"):
-			return fmt.Errorf("%w: %s", ErrPushNonFastForward, combinedOutput)
+	case strings.Contains(combinedOutput, "fetch first"):
+		return fmt.Errorf("%w: %s", ErrPushFetchFirst, combinedOutput)
+	case strings.Contains(combinedOutput, "non-fast-forward"):
+		return fmt.Errorf("%w: %s", ErrPushNonFastForward, combinedOutput)
 
-		case strings.Contains(combinedOutput, "permission denied") || strings.Contains(combinedOutput, "access denied"):
-			return fmt.Errorf("%w: %s", ErrPushPermissionDenied, combinedOutput)
+	case strings.Contains(combinedOutput, "permission denied") || strings.Contains(combinedOutput, "access denied"):
+		return fmt.Errorf("%w: %s", ErrPushPermissionDenied, combinedOutput)
 
-		case strings.Contains(combinedOutput, "! [remote rejected]") || strings.Contains(combinedOutput, "! [rejected]"):
-			return fmt.Errorf("%w: %s", ErrPushRejected, combinedOutput)
+	case isProtectedBranchRejection(combinedOutput):
+		return fmt.Errorf("%w: %s", ErrPushProtectedBranch, combinedOutput)
 
-		case strings.Contains(combinedOutput, "couldn't find remote ref") || strings.Contains(combinedOutput, "remote ref does not exist"):
-			return fmt.Errorf("%w: %s", ErrPushRemoteRefMissing, combinedOutput)
+	case strings.Contains(combinedOutput, "! [remote rejected]") || strings.Contains(combinedOutput, "! [rejected]"):
+		return fmt.Errorf("%w: %s", ErrPushRejected, combinedOutput)
 
-		default:
-			// Generic push error
-			return fmt.Errorf("git push failed: %w\nstdout: %s\nstderr: %s", err, stdout, stderr)
+	case strings.Contains(combinedOutput, "couldn't find remote ref") || strings.Contains(combinedOutput, "remote ref does not exist"):
+		return fmt.Errorf("%w: %s", ErrPushRemoteRefMissing, combinedOutput)
+
+	default:
+		// Generic push error
+		return fmt.Errorf("git push failed: %w\nstdout: %s\nstderr: %s", err, stdout, stderr)
+	}
+}
+
+// isProtectedBranchRejection returns true if push output reports that the remote refused
+// to change a protected branch: git's own receive.denyDeletes and receive.denyDeleteCurrent,
+// or the branch protection of GitHub, GitLab and Bitbucket
+func isProtectedBranchRejection(output string) bool {
+	lower := strings.ToLower(output)
+	for _, message := range []string{
+		"deletion prohibited",
+		"deletion of the current branch prohibited",
+		"protected branch",
+		"can only be modified through pull requests",
+	} {
+		if strings.Contains(lower, message) {
+			return true
 		}
 	}
+	return false
+}
 
+// DeleteRemoteBranch deletes branch from the remote with git push --delete. The
+// remote-tracking branch, e.g. origin/branch, is removed as well.
+// Returns ErrPushProtectedBranch if the remote refuses to delete the branch, and
+// ErrPushRemoteRefMissing if the branch does not exist on the remote.
+func (g *Repo) DeleteRemoteBranch(remote, branch string) error {
+	stdout, stderr, err := g.Client.Exec("push", "--porcelain", remote, "--delete", "refs/heads/"+branch)
+	if err != nil {
+		return classifyPushError(stdout, stderr, err)
+	}
+	// The remote accepts deleting a missing ref, but warns about it
+	if strings.Contains(string(stderr), "deleting a non-existent ref") {
+		return fmt.Errorf("%w: %s", ErrPushRemoteRefMissing, branch)
+	}
 	return nil
 }
 
 // Checkout switches to the specified branch
 func (g *Repo) Checkout(branch string) error {
-	stdout, stderr, err := g.Client.Exec("checkout", branch)
+	return g.CheckoutWithOptions(branch, CheckoutOptions{})
+}
+
+// CheckoutCommit checks out a commit with a detached HEAD, even if hash names a branch,
+// so the working tree stays pinned to that commit when branches move.
+// Check out a branch to leave the detached HEAD.
+func (g *Repo) CheckoutCommit(hash string) error {
+	return g.CheckoutWithOptions(hash, CheckoutOptions{Detach: true})
+}
+
+// CheckoutOptions defines options for checking out a branch or commit
+type CheckoutOptions struct {
+	// Create creates the branch and switches to it in one step (-b), failing with
+	// ErrBranchExists if it already exists
+	Create bool
+
+	// Force discards local changes that would otherwise stop the checkout (--force)
+	Force bool
+
+	// Track sets the upstream of a created branch to StartPoint, which must be
+	// a remote-tracking branch such as "origin/main" (--track)
+	Track bool
+
+	// Detach checks out the commit with a detached HEAD, even if ref is a branch (--detach)
+	Detach bool
+
+	// StartPoint is the commit a created branch starts from, HEAD if empty
+	StartPoint string
+}
+
+// validate returns an error for combinations of options git can't honour
+func (o CheckoutOptions) validate() error {
+	if o.Create && o.Detach {
+		return fmt.Errorf("cannot both create a branch and detach HEAD")
+	}
+	if !o.Create && (o.Track || o.StartPoint != "") {
+		return fmt.Errorf("a start point and tracking can only be set when creating a branch")
+	}
+	if o.Track && o.StartPoint == "" {
+		return fmt.Errorf("tracking requires a remote-tracking branch as the start point")
+	}
+	return nil
+}
+
+// CheckoutWithOptions switches to a branch or commit, optionally creating the branch
+func (g *Repo) CheckoutWithOptions(ref string, options CheckoutOptions) error {
+	if ref == "" {
+		return fmt.Errorf("no ref provided to check out")
+	}
+	if err := options.validate(); err != nil {
+		return err
+	}
+
+	args := []string{"checkout"}
+	if options.Force {
+		args = append(args, "--force")
+	}
+	if options.Detach {
+		args = append(args, "--detach")
+	}
+	if options.Track {
+		args = append(args, "--track")
+	}
+	if options.Create {
+		args = append(args, "-b")
+	}
+	args = append(args, ref)
+	if options.StartPoint != "" {
+		args = append(args, options.StartPoint)
+	}
+	stdout, stderr, err := g.Client.Exec(args...)
 	if err != nil {
+		if options.Create && isBranchExists(stderr) {
+			return fmt.Errorf("%w: %s", ErrBranchExists, strings.TrimSpace(string(stderr)))
+		}
 		return fmt.Errorf("git checkout failed: %w\nstdout: %s\nstderr: %s",
 			err, stdout, stderr)
 	}
@@ -209,10 +570,46 @@ func (g *Repo) Checkout(branch string) error {
 	return nil
 }
 
-// CreateBranch creates a new branch from the current HEAD
+// CreateBranch creates a new branch from the current HEAD.
+// Returns ErrBranchExists if the branch already exists.
 func (g *Repo) CreateBranch(branch string) error {
-	stdout, stderr, err := g.Client.Exec("branch", branch)
+	return g.CreateBranchWithOptions(branch, CreateBranchOptions{})
+}
+
+// CreateBranchOptions defines options for creating a branch
+type CreateBranchOptions struct {
+	// StartPoint is the commit the branch starts from, e.g. "origin/main".
+	// If empty, the branch starts from HEAD.
+	StartPoint string
+
+	// Force resets the branch to the start point if it already exists (--force).
+	// The branch that is checked out can't be reset.
+	Force bool
+
+	// Track sets the upstream of the branch to StartPoint, or to the current branch
+	// if StartPoint is empty (--track)
+	Track bool
+}
+
+// CreateBranchWithOptions creates a new branch without checking it out.
+// Returns ErrBranchExists if the branch already exists and Force is not set.
+func (g *Repo) CreateBranchWithOptions(branch string, options CreateBranchOptions) error {
+	args := []string{"branch"}
+	if options.Force {
+		args = append(args, "--force")
+	}
+	if options.Track {
+		args = append(args, "--track")
+	}
+	args = append(args, branch)
+	if options.StartPoint != "" {
+		args = append(args, options.StartPoint)
+	}
+	stdout, stderr, err := g.Client.Exec(args...)
 	if err != nil {
+		if isBranchExists(stderr) {
+			return fmt.Errorf("%w: %s", ErrBranchExists, strings.TrimSpace(string(stderr)))
+		}
 		return fmt.Errorf("git branch failed: %w\nstdout: %s\nstderr: %s",
 			err, stdout, stderr)
 	}
@@ -220,6 +617,13 @@ func (g *Repo) CreateBranch(branch string) error {
 	return nil
 }
 
+// isBranchExists returns true if stderr from git branch or git checkout -b reports
+// that the branch already exists
+func isBranchExists(stderr []byte) bool {
+	output := string(stderr)
+	return strings.Contains(output, "a branch named") && strings.Contains(output, "already exists")
+}
+
 // ResetMode represents the Git reset mode
 type ResetMode int
 
@@ -281,28 +685,32 @@ func (g *Repo) ResetHard(target string) error {
 func (g *Repo) Rebase(onto string) error {
 	stdout, stderr, err := g.Client.Exec("rebase", onto)
 	if err != nil {
-		// Parse output to determine specific error type
-		stdoutStr := string(stdout)
-		stderrStr := string(stderr)
-		combinedOutput := stdoutStr + stderrStr
+		return rebaseError(err, stdout, stderr)
+	}
 
-		switch {
-		case strings.Contains(combinedOutput, "CONFLICT") || strings.Contains(combinedOutput, "Merge conflict"):
-			return fmt.Errorf("%w: %s", ErrRebaseMergeConflict, combinedOutput)
+	return nil
+}
 
-		case strings.Contains(combinedOutput, "already in progress") || strings.Contains(combinedOutput, "rebase-merge directory"):
-			return fmt.Errorf("%w: %s", ErrRebaseAlreadyInProgress, combinedOutput)
+// rebaseError parses the output of a failed rebase to determine the specific error type
+func rebaseError(err error, stdout, stderr []byte) error {
+	stdoutStr := string(stdout)
+	stderrStr := string(stderr)
+	combinedOutput := stdoutStr + stderrStr
 
-		case strings.Contains(combinedOutput, "no commits applied"):
-			return fmt.Errorf("%w: %s", ErrRebaseNoCommitsApplied, combinedOutput)
+	switch {
+	case strings.Contains(combinedOutput, "CONFLICT") || strings.Contains(combinedOutput, "Merge conflict"):
+		return fmt.Errorf("%w: %s", ErrRebaseMergeConflict, combinedOutput)
 
-		default:
-			return fmt.Errorf("git rebase failed: %w\nstdout: %s\nstderr: %s",
-				err, stdout, stderr)
-		}
-	}
+	case strings.Contains(combinedOutput, "already in progress") || strings.Contains(combinedOutput, "rebase-merge directory"):
+		return fmt.Errorf("%w: %s", ErrRebaseAlreadyInProgress, combinedOutput)
 
-	return nil
+	case strings.Contains(combinedOutput, "no commits applied"):
+		return fmt.Errorf("%w: %s", ErrRebaseNoCommitsApplied, combinedOutput)
+
+	default:
+		return fmt.Errorf("git rebase failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
 }
 
 // CurrentBranch returns the name of the current branch
@@ -323,6 +731,36 @@ func (g *Repo) CurrentBranch() (string, error) {
 	return strings.TrimSpace(string(stdout)), nil
 }
 
+// RemoteDefaultBranch returns the name of the branch that HEAD points to on the given remote,
+// e.g. "main" or "master".
+// If the remote cannot be reached or does not report its HEAD, as with some older servers,
+// refs/remotes/<remote>/HEAD recorded by clone or `git remote set-head` is used instead.
+func (g *Repo) RemoteDefaultBranch(remote string) (string, error) {
+	stdout, stderr, err := g.Client.Exec("ls-remote", "--symref", remote, "HEAD")
+	if err == nil {
+		// Format: "ref: refs/heads/main\tHEAD"
+		for _, line := range strings.Split(string(stdout), "\n") {
+			if !strings.HasPrefix(line, "ref: ") {
+				continue
+			}
+			ref, _, _ := strings.Cut(strings.TrimPrefix(line, "ref: "), "\t")
+			return strings.TrimPrefix(ref, "refs/heads/"), nil
+		}
+	}
+
+	trackingStdout, trackingStderr, trackingErr := g.Client.Exec("symbolic-ref", "--quiet", "refs/remotes/"+remote+"/HEAD")
+	if trackingErr == nil {
+		ref := strings.TrimSpace(string(trackingStdout))
+		return strings.TrimPrefix(ref, "refs/remotes/"+remote+"/"), nil
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("git ls-remote failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return "", fmt.Errorf("remote %s has no default branch\nstderr: %s", remote, trackingStderr)
+}
+
 // RebaseAbort aborts the current rebase
 func (g *Repo) RebaseAbort() error {
 	stdout, stderr, err := g.Client.Exec("rebase", "--abort")
@@ -334,22 +772,16 @@ func (g *Repo) RebaseAbort() error {
 	return nil
 }
 
-// ConfigSet sets a git config value for the repository
+// ConfigSet sets a git config value for the repository, replacing any existing values of the key.
+// See ConfigSetWithOptions for other scopes.
 func (c *Repo) ConfigSet(key, value string) error {
-	// --local is the default, but setting it here to be explicit
-	_, _, err := c.Client.Exec("config", "set", "--local", key, value)
-	return err
+	return c.ConfigSetWithOptions(key, value, ConfigOptions{Scope: ConfigScopeLocal})
 }
 
-// ConfigGet gets a git config value for the repository
+// ConfigGet gets a git config value for the repository, without a trailing newline.
+// Returns ErrConfigNotFound if the key is not set. See ConfigGetWithOptions for other scopes.
 func (c *Repo) ConfigGet(key string) (string, error) {
-	// --local is the default, but setting it here to be explicit
-	stdout, stderr, err := c.Client.Exec("config", "get", "--local", key)
-	if err != nil {
-		return "", fmt.Errorf("git config get failed: %w\nstdout: %s\nstderr: %s",
-			err, stdout, stderr)
-	}
-	return string(stdout), nil
+	return c.ConfigGetWithOptions(key, ConfigOptions{Scope: ConfigScopeLocal})
 }
 
 // AddRemote adds a remote to the repository
@@ -434,10 +866,9 @@ func (r *Repo) FileAtCommit(commit string, path string) (string, error) {
 	return string(stdout), nil
 }
 
-// RevParse executes git rev-parse with the given arguments
-// Common usages include getting HEAD commit (RevParse("HEAD")),
-// checking if a string is a valid reference (RevParse("--verify", ref)),
-// and getting the short version of a commit (RevParse("--short", commit))
+// RevParse executes git rev-parse with the given arguments.
+// For the common usages, prefer Head, IsValidRef, ShortHash and ResolveToCommit,
+// which report a missing revision as ErrRevisionNotFound.
 func (r *Repo) RevParse(args ...string) (string, error) {
 	cmdArgs := append([]string{"rev-parse"}, args...)
 	stdout, stderr, err := r.Client.Exec(cmdArgs...)
@@ -464,6 +895,9 @@ type CatFileOptions struct {
 
 	// Object ID (commit hash, tag, etc)
 	ObjectID string
+
+	// Context kills the command when done
+	Context context.Context
 }
 
 // CatFile executes git cat-file with the provided options
@@ -496,9 +930,14 @@ func (r *Repo) CatFile(options CatFileOptions) (exists bool, content string, err
 	args = append(args, options.ObjectID)
 
 	// Execute the command
-	stdout, stderr, err := r.Client.Exec(args...)
+	stdout, stderr, err := r.Client.ExecWithOptions(ExecOptions{Context: options.Context}, args...)
 
 	if err != nil {
+		// A cancelled command says nothing about whether the object exists
+		if options.Context != nil && options.Context.Err() != nil {
+			return false, "", fmt.Errorf("git cat-file failed: %w", options.Context.Err())
+		}
+
 		// For -e flag, exit status 1 means the object doesn't exist
 		// This is expected behavior, not an error condition
 		if options.Exists {
@@ -837,31 +1276,52 @@ type RevListOptions struct {
 
 	// MaxCount limits the number of commits returned
 	MaxCount int
-}
 
-// RevList runs git rev-list with the specified options and returns the list of commit hashes
-func (r *Repo) RevList(options RevListOptions) ([]string, error) {
-	args := []string{"rev-list"}
+	// TopoOrder lists children before their parents and avoids interleaving
+	// the commits of parallel lines of history
+	TopoOrder bool
 
-	if options.AncestryPath {
-		args = append(args, "--ancestry-path")
-	}
+	// FirstParent follows only the first parent of merge commits
+	FirstParent bool
 
-	if options.Count {
-		args = append(args, "--count")
-	}
+	// Merges lists only merge commits
+	Merges bool
 
-	if options.MaxCount > 0 {
-		args = append(args, "--max-count", fmt.Sprintf("%d", options.MaxCount))
-	}
+	// NoMerges excludes merge commits
+	NoMerges bool
 
-	// Add the range
-	if options.Range != "" {
-		args = append(args, options.Range)
+	// Since excludes commits committed before this time
+	Since time.Time
+
+	// Until excludes commits committed after this time
+	Until time.Time
+
+	// Author limits the commits to those whose author matches this pattern
+	Author string
+
+	// Paths limits the commits to those that touch these paths
+	Paths []string
+
+	// Reverse lists the commits in reverse order, oldest first
+	Reverse bool
+
+	// Boundary also lists the excluded commits at the edge of the range,
+	// prefixed with "-" as git prints them
+	Boundary bool
+
+	// Context kills the command when done
+	Context context.Context
+}
+
+// RevList runs git rev-list with the specified options and returns the list of commit hashes
+func (r *Repo) RevList(options RevListOptions) ([]string, error) {
+	args, err := revListArgs(options)
+	if err != nil {
+		return nil, err
 	}
 
 	// Execute the command
-	stdout, stderr, err := r.Client.Exec(args...)
+	stdout, stderr, err := r.Client.ExecWithOptions(ExecOptions{Context: options.Context}, args...)
 	if err != nil {
 		return nil, fmt.Errorf("git rev-list failed: %w\nstdout: %s\nstderr: %s",
 			err, stdout, stderr)
@@ -881,6 +1341,72 @@ func (r *Repo) RevList(options RevListOptions) ([]string, error) {
 	return commits, nil
 }
 
+// revListArgs returns the git rev-list arguments for options
+func revListArgs(options RevListOptions) ([]string, error) {
+	args := []string{"rev-list"}
+
+	if options.AncestryPath {
+		args = append(args, "--ancestry-path")
+	}
+
+	if options.Count {
+		args = append(args, "--count")
+	}
+
+	if options.TopoOrder {
+		args = append(args, "--topo-order")
+	}
+
+	if options.FirstParent {
+		args = append(args, "--first-parent")
+	}
+
+	if options.Merges && options.NoMerges {
+		return nil, fmt.Errorf("Merges and NoMerges cannot both be set")
+	}
+	if options.Merges {
+		args = append(args, "--merges")
+	}
+	if options.NoMerges {
+		args = append(args, "--no-merges")
+	}
+
+	if !options.Since.IsZero() {
+		args = append(args, "--since="+options.Since.Format(time.RFC3339))
+	}
+	if !options.Until.IsZero() {
+		args = append(args, "--until="+options.Until.Format(time.RFC3339))
+	}
+
+	if options.Author != "" {
+		args = append(args, "--author="+options.Author)
+	}
+
+	if options.Reverse {
+		args = append(args, "--reverse")
+	}
+
+	if options.Boundary {
+		args = append(args, "--boundary")
+	}
+
+	if options.MaxCount > 0 {
+		args = append(args, "--max-count", fmt.Sprintf("%d", options.MaxCount))
+	}
+
+	// Add the range
+	if options.Range != "" {
+		args = append(args, options.Range)
+	}
+
+	if len(options.Paths) > 0 {
+		args = append(args, "--")
+		args = append(args, options.Paths...)
+	}
+
+	return args, nil
+}
+
 // CountCommits returns the number of commits from a reference (HEAD by default)
 // This is a convenience method for RevList with the --count option
 func (r *Repo) CountCommits(ref string) (int, error) {
@@ -955,10 +1481,45 @@ func DefaultCommitSearchOptions() *GetCommitsBetweenOptions {
 //   - latestCommit: The later commit hash
 //   - opts: Optional configuration options. If nil, default options will be used.
 //
-// Returns the list of commits between earliestCommit and latestCommit (inclusive) if found.
+// Returns the list of commits between earliestCommit and latestCommit (inclusive) if found,
+// ordered from the descendant to the ancestor, whichever order the commits were given in.
+// See CommitRange.Commits for the order of non-linear history.
 // Returns nil if one or both commits were not found.
-// Also returns an error if any issues occurred during the search.
+// Returns ErrCommitsNotRelated if neither commit is an ancestor of the other.
+// Use GetCommitRange to find out how the commits are related.
 func (r *Repo) GetCommitsBetween(earliestCommit string, latestCommit string, opts *GetCommitsBetweenOptions) ([]string, error) {
+	return r.GetCommitsBetweenContext(context.Background(), earliestCommit, latestCommit, opts)
+}
+
+// GetCommitsBetweenContext is GetCommitsBetween with a context. Cancelling ctx kills
+// any running git command and stops the search.
+func (r *Repo) GetCommitsBetweenContext(ctx context.Context, earliestCommit string, latestCommit string, opts *GetCommitsBetweenOptions) ([]string, error) {
+	commitRange, err := r.GetCommitRangeContext(ctx, earliestCommit, latestCommit, opts)
+	if err != nil || commitRange == nil {
+		return nil, err
+	}
+	if !commitRange.Related() {
+		return nil, fmt.Errorf("%w: %s and %s are %s", ErrCommitsNotRelated,
+			earliestCommit, latestCommit, commitRange.Relation)
+	}
+	return commitRange.Commits, nil
+}
+
+// GetCommitRange finds two commits and describes the history between them, fetching
+// more history as needed in the same way as GetCommitsBetween.
+//
+// In a shallow clone the common history of the commits may not have been fetched yet,
+// so the history is deepened until the commits are found to be related or the full
+// history is available. The returned range may be diverged or unrelated.
+//
+// Returns nil if one or both commits were not found.
+func (r *Repo) GetCommitRange(earliestCommit string, latestCommit string, opts *GetCommitsBetweenOptions) (*CommitRange, error) {
+	return r.GetCommitRangeContext(context.Background(), earliestCommit, latestCommit, opts)
+}
+
+// GetCommitRangeContext is GetCommitRange with a context. Cancelling ctx kills
+// any running git command and stops the search.
+func (r *Repo) GetCommitRangeContext(ctx context.Context, earliestCommit string, latestCommit string, opts *GetCommitsBetweenOptions) (*CommitRange, error) {
 	// Use default options if none provided
 	if opts == nil {
 		opts = DefaultCommitSearchOptions()
@@ -973,25 +1534,17 @@ func (r *Repo) GetCommitsBetween(earliestCommit string, latestCommit string, opt
 	}
 
 	// Check if both commits already exist in the repository before attempting any fetches
-	earliestExists, err := r.commitExists(earliestCommit, opts.OperationTimeout)
-	if err != nil {
-		return nil, fmt.Errorf("error checking if earliest commit exists: %w", err)
-	}
-
-	latestExists, err := r.commitExists(latestCommit, opts.OperationTimeout)
+	commitRange, err := r.findCommitRange(ctx, earliestCommit, latestCommit, opts.OperationTimeout)
 	if err != nil {
-		return nil, fmt.Errorf("error checking if latest commit exists: %w", err)
+		return nil, err
 	}
-
-	if earliestExists && latestExists {
-		// Both commits exist, get commits between them
-		return r.getCommitsBetween(earliestCommit, latestCommit, opts.OperationTimeout)
+	if commitRange != nil && commitRange.Related() {
+		return commitRange, nil
 	}
 
 	// If DoNotExpandDepth is true, don't attempt fetching more history
 	if opts.DoNotExpandDepth {
-		// If commits don't exist and we're not allowed to expand depth, return nil
-		return nil, nil
+		return commitRange, nil
 	}
 
 	// Initialize the depth and maximum depth
@@ -1003,60 +1556,79 @@ func (r *Repo) GetCommitsBetween(earliestCommit string, latestCommit string, opt
 
 	// Keep doubling the depth until we reach maxDepth
 	for depth <= maxDepth {
-		// Create fetch options with the current depth
-		fetchOpts := FetchOptions{
-			Depth: depth,
+		shallow, err := r.isShallow(ctx)
+		if err != nil {
+			return nil, err
 		}
-
-		// Attempt to fetch with the current depth
-
-		// Use a bounded timeout for each fetch operation
-		fetchCtx, fetchCancel := context.WithTimeout(context.Background(), opts.OperationTimeout)
-
-		// Use a channel to manage fetch operation with timeout
-		done := make(chan error, 1)
-		go func() {
-			done <- r.Fetch(remote, fetchOpts)
-		}()
-
-		// Wait for either fetch completion or timeout
-		var fetchErr error
-		select {
-		case fetchErr = <-done:
-			// Fetch completed
-		case <-fetchCtx.Done():
-			fetchCancel()
-			return nil, fmt.Errorf("fetch operation timed out after %v at depth %d", opts.OperationTimeout, depth)
+		if !shallow && commitRange != nil {
+			// The full history is available, so the commits are truly unrelated
+			return commitRange, nil
 		}
 
-		// Clean up the context regardless of outcome
+		// A shallow clone is deepened by the current depth, doubling the history
+		// available. A complete clone can only gain commits from refs not yet fetched.
+		fetchCtx, fetchCancel := withOperationTimeout(ctx, opts.OperationTimeout)
+		if shallow {
+			err = r.deepen(fetchCtx, depth)
+		} else {
+			err = r.Fetch(remote, FetchOptions{Context: fetchCtx})
+		}
+		fetchErr := fetchCtx.Err()
 		fetchCancel()
 
-		if fetchErr != nil {
-			return nil, fmt.Errorf("error fetching from repository with depth %d: %w", depth, fetchErr)
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(fetchErr, context.DeadlineExceeded) {
+				return nil, fmt.Errorf("fetch operation timed out after %v at depth %d", opts.OperationTimeout, depth)
+			}
+			return nil, fmt.Errorf("error fetching from repository with depth %d: %w", depth, err)
 		}
 
 		// Check if both commits are now available in the repository
-		earliestExists, err := r.commitExists(earliestCommit, opts.OperationTimeout)
+		commitRange, err = r.findCommitRange(ctx, earliestCommit, latestCommit, opts.OperationTimeout)
 		if err != nil {
-			return nil, fmt.Errorf("error checking if earliest commit exists: %w", err)
+			return nil, err
 		}
-
-		latestExists, err := r.commitExists(latestCommit, opts.OperationTimeout)
-		if err != nil {
-			return nil, fmt.Errorf("error checking if latest commit exists: %w", err)
+		if commitRange != nil && commitRange.Related() {
+			return commitRange, nil
 		}
 
-		if earliestExists && latestExists {
-			return r.getCommitsBetween(earliestCommit, latestCommit, opts.OperationTimeout)
+		if !shallow {
+			// The full history has been searched
+			return commitRange, nil
 		}
 
 		// Double the depth for next iteration
 		depth *= 2
 	}
 
-	// If we've reached this point, we didn't find the commit within maxDepth
-	return nil, nil
+	// If we've reached this point, we didn't relate the commits within maxDepth
+	return commitRange, nil
+}
+
+// findCommitRange returns the range between two commits if both exist locally,
+// nil otherwise
+func (r *Repo) findCommitRange(ctx context.Context, earliestCommit string, latestCommit string, timeout time.Duration) (*CommitRange, error) {
+	ctx, cancel := withOperationTimeout(ctx, timeout)
+	defer cancel()
+
+	exists, err := r.ObjectsExistContext(ctx, []string{earliestCommit, latestCommit})
+	if err != nil {
+		return nil, fmt.Errorf("error checking if commits exist: %w", err)
+	}
+
+	if !exists[earliestCommit] || !exists[latestCommit] {
+		return nil, nil
+	}
+	return r.commitRange(ctx, earliestCommit, latestCommit)
+}
+
+// withOperationTimeout limits ctx to the timeout of a single step of a commit search.
+// A timeout of zero leaves ctx unchanged.
+func withOperationTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
 }
 
 // FindCommitWithExponentialDepth is a backward compatibility wrapper for GetCommitsBetween
@@ -1076,157 +1648,6 @@ func (r *Repo) FindCommitWithExponentialDepth(targetCommit string, opts *GetComm
 	return r.GetCommitsBetween(targetCommit, latestCommit, opts)
 }
 
-// getCommitsBetween retrieves all commits between two specified commits (inclusive).
-// The commits are returned in chronological order from earliestCommit to latestCommit.
-// isShallowClone checks if the repository is a shallow clone by checking for the existence of .git/shallow file
-func (r *Repo) isShallowClone() bool {
-	shallowFilePath := filepath.Join(r.RepoPath, ".git", "shallow")
-	_, err := os.Stat(shallowFilePath)
-	return err == nil
-}
-
-func (r *Repo) getCommitsBetween(earliestCommit string, latestCommit string, timeout time.Duration) ([]string, error) {
-	if earliestCommit == "" || latestCommit == "" {
-		return nil, fmt.Errorf("both commits must be specified")
-	}
-
-	// First, try to determine if this is a shallow clone
-	isShallow := r.isShallowClone()
-
-	// Set up the range based on the commit relationship
-	var revListOpts RevListOptions
-	var reversed bool
-
-	if !isShallow {
-		// For full clones, we can reliably determine ancestry
-		isAncestor, err := r.isAncestor(earliestCommit, latestCommit, timeout)
-		if err != nil {
-			// If we can't determine ancestry even in a full clone, try both directions
-			// Default to assuming normal direction (earliest to latest)
-			revListOpts = RevListOptions{
-				Range: earliestCommit + ".." + latestCommit,
-			}
-			reversed = false
-		} else if isAncestor {
-			// Normal case: earliestCommit is an ancestor of latestCommit
-			revListOpts = RevListOptions{
-				Range: earliestCommit + ".." + latestCommit,
-			}
-			reversed = false
-		} else {
-			// Reversed case: latestCommit is an ancestor of earliestCommit
-			revListOpts = RevListOptions{
-				Range: latestCommit + ".." + earliestCommit,
-			}
-			reversed = true
-		}
-	} else {
-		// For shallow clones, we can't reliably determine ancestry
-		// Default to assuming normal direction (earliest to latest)
-		// This assumption may not always be correct, but it's the best we can do
-		revListOpts = RevListOptions{
-			Range: earliestCommit + ".." + latestCommit,
-		}
-		reversed = false
-	}
-
-	// Use RevList with the range argument to get commits between the two points
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	revListDone := make(chan struct {
-		commits []string
-		err     error
-	})
-
-	go func() {
-		// Use RevList with the options we've set up
-
-		commits, err := r.RevList(revListOpts)
-		revListDone <- struct {
-			commits []string
-			err     error
-		}{commits, err}
-	}()
-
-	// Wait for rev-list completion or timeout
-	var commits []string
-	var revListErr error
-
-	select {
-	case result := <-revListDone:
-		commits = result.commits
-		revListErr = result.err
-	case <-ctx.Done():
-		cancel() // Cancel context before returning on timeout
-		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
-			return nil, fmt.Errorf("rev-list operation timed out after %v", timeout)
-		}
-		return nil, ctx.Err() // Handle other context errors
-	}
-
-	cancel() // Cancel context for normal completion path
-
-	if revListErr != nil {
-		return nil, fmt.Errorf("error running rev-list: %w", revListErr)
-	}
-
-	// With the '..' notation, we need to make sure both endpoints are included
-	// First, check if the endpoints are already in the list
-	earliestFound := false
-	latestFound := false
-	for _, commit := range commits {
-		if commit == earliestCommit {
-			earliestFound = true
-		}
-		if commit == latestCommit {
-			latestFound = true
-		}
-	}
-
-	// In the reversed case, the contract of this function requires that
-	// we need to maintain the earliest commit (which is HEAD) as first in the list,
-	// and the latest commit (which is an ancestor) as last in the list
-	if reversed {
-		// First reverse the commits to get the original order
-		for i, j := 0, len(commits)-1; i < j; i, j = i+1, j-1 {
-			commits[i], commits[j] = commits[j], commits[i]
-		}
-
-		// For the reversed case, the order from git will be from oldest to newest
-		// But we need earliest (HEAD) first and latest (ancestor) last
-		// So we'll reverse it again to put the latest commit first, then
-		// make sure endpoints are in the right place
-		var resultCommits []string
-
-		// Add earliest (HEAD) first
-		resultCommits = append(resultCommits, earliestCommit)
-
-		// Add any commits in between, excluding endpoints
-		for _, commit := range commits {
-			if commit != earliestCommit && commit != latestCommit {
-				resultCommits = append(resultCommits, commit)
-			}
-		}
-
-		// Add latest (ancestor) last
-		resultCommits = append(resultCommits, latestCommit)
-
-		// Replace our commit list
-		commits = resultCommits
-	} else {
-		// Normal case: latest first, earliest last
-		// Add endpoints if needed
-		if !latestFound {
-			commits = append([]string{latestCommit}, commits...)
-		}
-		if !earliestFound {
-			commits = append(commits, earliestCommit)
-		}
-	}
-
-	// Now commits are ordered with latest first and earliest last
-	return commits, nil
-}
-
 // initialCommit returns the first commit in the repository (the root commit)
 func (r *Repo) initialCommit() (string, error) {
 	// Use git rev-list with --max-parents=0 to find the root commit(s)
@@ -1250,197 +1671,57 @@ func (r *Repo) initialCommit() (string, error) {
 	return commitID, nil
 }
 
-// isAncestor checks if the potential ancestor commit is an ancestor of the descendant commit.
-// Returns true if potentialAncestor is an ancestor of descendant, false otherwise.
-func (r *Repo) isAncestor(potentialAncestor string, descendant string, timeout time.Duration) (bool, error) {
-	if potentialAncestor == "" || descendant == "" {
-		return false, fmt.Errorf("both commits must be specified")
-	}
-
-	// First check if both commits exist in the repository
-	existsA, err := r.commitExists(potentialAncestor, timeout)
-	if err != nil {
-		return false, fmt.Errorf("error checking if ancestor commit exists: %w", err)
-	}
-
-	existsD, err := r.commitExists(descendant, timeout)
-	if err != nil {
-		return false, fmt.Errorf("error checking if descendant commit exists: %w", err)
-	}
-
-	// If either commit doesn't exist locally, we can't determine ancestry directly
-	// So we'll assume they're not in an ancestral relationship
-	if !existsA || !existsD {
-		return false, nil
-	}
-
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	// Run git merge-base --is-ancestor to check if potentialAncestor is an ancestor of descendant
-	cmd := exec.CommandContext(ctx, "git", "merge-base", "--is-ancestor", potentialAncestor, descendant)
-	cmd.Dir = r.RepoPath
-
-	// The command exits with status 0 if true, status 1 if false, and 128 if error
-	err = cmd.Run()
-	if err == nil {
-		// Exit status 0 means potentialAncestor is an ancestor of descendant
-		return true, nil
-	}
-
-	// Check if it's a normal exit status 1, which means "not an ancestor"
-	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
-		// Exit status 1 means potentialAncestor is NOT an ancestor of descendant
-		return false, nil
-	}
-
-	// Any other error is an actual error
-	return false, fmt.Errorf("error checking ancestor relationship: %w", err)
-}
-
 // commitExists checks if a commit exists in the repository.
-func (r *Repo) commitExists(commitID string, timeout time.Duration) (bool, error) {
+func (r *Repo) commitExists(ctx context.Context, commitID string) (bool, error) {
 	if commitID == "" {
 		return false, fmt.Errorf("empty commit ID")
 	}
 
-	// Use context with timeout for the operation
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	// Use a channel to make the operation cancellable with timeout
-	done := make(chan struct {
-		exists bool
-		err    error
+	exists, _, err := r.CatFile(CatFileOptions{
+		Exists:   true,
+		ObjectID: commitID,
+		Context:  ctx,
 	})
-
-	go func() {
-		// Call the CatFile method with options
-		options := CatFileOptions{
-			Exists:   true,
-			ObjectID: commitID,
-		}
-		exists, _, err := r.CatFile(options)
-		done <- struct {
-			exists bool
-			err    error
-		}{exists, err}
-	}()
-
-	// Wait for either completion or timeout
-	select {
-	case result := <-done:
-		return result.exists, result.err
-
-	case <-ctx.Done():
-		// If the context timed out, return an error
-		return false, fmt.Errorf("commit existence check timed out after %v", timeout)
-	}
+	return exists, err
 }
 
 // buildCommitPath builds a path of commits from HEAD to the target commit.
 // Returns a slice where the first element is HEAD and the last element is the target commit.
-func (r *Repo) buildCommitPath(targetCommit string, timeout time.Duration) ([]string, error) {
+func (r *Repo) buildCommitPath(ctx context.Context, targetCommit string) ([]string, error) {
 	if targetCommit == "" {
 		return nil, fmt.Errorf("empty target commit")
 	}
 
-	// Get the HEAD commit first with timeout
-	headCtx, headCancel := context.WithTimeout(context.Background(), timeout)
-	headDone := make(chan struct {
-		commit string
-		err    error
-	})
-
-	go func() {
-		// Use the RevParse method
-		commit, err := r.RevParse("HEAD")
-		result := struct {
-			commit string
-			err    error
-		}{}
-
-		if err != nil {
-			result.err = fmt.Errorf("error getting HEAD commit: %w", err)
-		} else {
-			result.commit = commit
-		}
-		headDone <- result
-	}()
-
-	// Wait for command completion or timeout
-	var headCommit string
-	var headErr error
-
-	select {
-	case result := <-headDone:
-		// Command completed
-		headCommit = result.commit
-		headErr = result.err
-	case <-headCtx.Done():
-		headCancel()
-		return nil, fmt.Errorf("HEAD commit lookup timed out after %v", timeout)
-	}
-	headCancel() // Always cancel the context
-
-	if headErr != nil {
-		return nil, headErr
+	// Get the HEAD commit first
+	stdout, stderr, err := r.Client.ExecContext(ctx, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("error getting HEAD commit: git rev-parse failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
 	}
+	headCommit := strings.TrimSpace(string(stdout))
 
-	// Now use the RevList method with timeout to get commits between HEAD and target
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	revListDone := make(chan struct {
-		commits []string
-		err     error
+	// Get the commits between HEAD and target
+	commits, err := r.RevList(RevListOptions{
+		Range:        targetCommit + "..HEAD",
+		AncestryPath: true,
+		Context:      ctx,
 	})
-
-	go func() {
-		// Use the RevList method
-		revListOpts := RevListOptions{
-			Range:        targetCommit + "..HEAD",
-			AncestryPath: true,
-		}
-
-		commits, err := r.RevList(revListOpts)
-		revListDone <- struct {
-			commits []string
-			err     error
-		}{commits, err}
-	}()
-
-	// Wait for rev-list completion or timeout
-	var commits []string
-	var revListErr error
-
-	select {
-	case result := <-revListDone:
-		commits = result.commits
-		revListErr = result.err
-	case <-ctx.Done():
-		cancel() // Cancel context before returning on timeout
-		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
-			return nil, fmt.Errorf("rev-list operation timed out after %v", timeout)
-		}
-		return nil, ctx.Err() // Handle other context errors
-	}
-
-	cancel() // Cancel context for normal completion path
-
-	if revListErr != nil {
-		return nil, fmt.Errorf("error running rev-list: %w", revListErr)
+	if err != nil {
+		return nil, fmt.Errorf("error running rev-list: %w", err)
 	}
 
 	// Build the path - first element is HEAD
 	commitPath := []string{headCommit}
 
-	// Add the intermediate commits if any
-	if len(commits) > 0 {
-		commitPath = append(commitPath, commits...)
+	// Add the intermediate commits if any, rev-list already includes HEAD
+	for _, commit := range commits {
+		if commit != headCommit {
+			commitPath = append(commitPath, commit)
+		}
 	}
 
 	// Add target commit as the last element if it's not already included
-	if len(commitPath) == 0 || commitPath[len(commitPath)-1] != targetCommit {
+	if commitPath[len(commitPath)-1] != targetCommit {
 		commitPath = append(commitPath, targetCommit)
 	}
 
@@ -142,7 +142,7 @@ func TestExponentialCommitSearch(t *testing.T) {
 		t.Logf("Shallow clone HEAD commit: %s", headCommit[:8])
 
 		// Check that the target commit does not exist in the shallow clone
-		exists, err := shallowCloneRepo.commitExists(targetCommit, 5*time.Second)
+		exists, err := shallowCloneRepo.commitExists(context.Background(), targetCommit)
 		if err != nil {
 			t.Fatalf("Error checking if commit exists: %v", err)
 		}
@@ -2,11 +2,22 @@ package gittools
 
 import (
 	"bytes"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/ocuroot/gittools/testutils"
 )
 
+func TestMain(m *testing.M) {
+	code := m.Run()
+	testutils.CloseTestRemoteRepos()
+	os.Exit(code)
+}
+
 func TestOpen(t *testing.T) {
 	SafeTest(t, func(t *testing.T, testDir string) {
 		tempDir := setupTestRepo(t)
@@ -22,6 +33,182 @@ func TestOpen(t *testing.T) {
 	})
 }
 
+func TestOpenLinkedWorktree(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		tempDir := setupTestRepo(t)
+
+		repo, err := Open(tempDir)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+
+		// Linked worktrees have a .git file rather than a directory
+		worktreeDir := filepath.Join(testDir, "worktree")
+		if stdout, stderr, err := repo.Client.Exec("worktree", "add", "-b", "feature", worktreeDir); err != nil {
+			t.Fatalf("Failed to add worktree: %v\nstdout: %s\nstderr: %s", err, stdout, stderr)
+		}
+		subDir := filepath.Join(worktreeDir, "sub")
+		if err := os.MkdirAll(subDir, 0755); err != nil {
+			t.Fatalf("Failed to create subdirectory: %v", err)
+		}
+
+		worktree, err := Open(subDir)
+		if err != nil {
+			t.Fatalf("Failed to open worktree: %v", err)
+		}
+		if worktree.RepoPath != worktreeDir {
+			t.Errorf("Expected RepoPath to be %s, got %s", worktreeDir, worktree.RepoPath)
+		}
+		if worktree.Bare {
+			t.Error("Expected worktree not to be bare")
+		}
+
+		branch, err := worktree.CurrentBranch()
+		if err != nil {
+			t.Fatalf("Failed to get current branch: %v", err)
+		}
+		if branch != "feature" {
+			t.Errorf("Expected worktree to be on feature, got %s", branch)
+		}
+
+		if _, err := Open(filepath.Join(testDir, "missing")); err == nil {
+			t.Error("Expected error opening a directory that does not exist")
+		}
+	})
+}
+
+func TestOpenBare(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		remoteDir, cleanup, err := CreateTestRemoteRepo("gittools-bare")
+		if err != nil {
+			t.Fatalf("Failed to create bare repository: %v", err)
+		}
+		defer cleanup()
+
+		expectedPath, err := filepath.EvalSymlinks(remoteDir)
+		if err != nil {
+			t.Fatalf("Failed to resolve bare repository path: %v", err)
+		}
+
+		// Open from the repository and from a directory inside it
+		for _, path := range []string{remoteDir, filepath.Join(remoteDir, "refs")} {
+			repo, err := Open(path)
+			if err != nil {
+				t.Fatalf("Failed to open bare repository at %s: %v", path, err)
+			}
+			if !repo.Bare {
+				t.Errorf("Expected %s to be opened as a bare repository", path)
+			}
+			if resolved, _ := filepath.EvalSymlinks(repo.RepoPath); resolved != expectedPath {
+				t.Errorf("Expected RepoPath to be %s, got %s", expectedPath, repo.RepoPath)
+			}
+
+			items, err := repo.Log(LogOptions{Oneline: true})
+			if err != nil || len(items) != 1 {
+				t.Fatalf("Expected one log entry, got %v, %v", items, err)
+			}
+
+			commits, err := repo.RevList(RevListOptions{Range: "HEAD"})
+			if err != nil || len(commits) != 1 {
+				t.Fatalf("Expected one commit, got %v, %v", commits, err)
+			}
+
+			exists, _, err := repo.CatFile(CatFileOptions{Exists: true, ObjectID: "HEAD:README.md"})
+			if err != nil || !exists {
+				t.Errorf("Expected README.md to exist, got %v, %v", exists, err)
+			}
+
+			entries, err := repo.LsTree("HEAD")
+			if err != nil || len(entries) != 1 || entries[0].Name != "README.md" {
+				t.Errorf("Expected README.md in tree, got %+v, %v", entries, err)
+			}
+		}
+	})
+}
+
+func TestOpenGitDir(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		source := setupTestRepo(t)
+
+		// Bare storage with a separate work tree
+		gitDir := filepath.Join(testDir, "storage.git")
+		if out, err := GitExec(t, testDir, 10, "clone", "--bare", source, gitDir); err != nil {
+			t.Fatalf("Failed to clone: %v\n%s", err, out)
+		}
+		workTree := filepath.Join(testDir, "work")
+		if err := os.MkdirAll(workTree, 0755); err != nil {
+			t.Fatalf("Failed to create work tree: %v", err)
+		}
+
+		repo, err := OpenGitDir(gitDir, workTree)
+		if err != nil {
+			t.Fatalf("OpenGitDir failed: %v", err)
+		}
+		repo.Client.SetUser("Test User", "test@example.com")
+		if repo.Bare || repo.RepoPath != workTree {
+			t.Errorf("Expected a non-bare repository at %s, got %+v", workTree, repo)
+		}
+		if stdout, stderr, err := repo.Client.Exec("checkout", "-f", "main"); err != nil {
+			t.Fatalf("Failed to check out: %v\nstdout: %s\nstderr: %s", err, stdout, stderr)
+		}
+		if _, err := os.Stat(filepath.Join(workTree, "README.md")); err != nil {
+			t.Errorf("Expected README.md to be checked out into the work tree, got %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(workTree, ".git")); !os.IsNotExist(err) {
+			t.Errorf("Expected no .git in the work tree, got %v", err)
+		}
+
+		writeAndCommit(t, repo, "work.txt", "work\n")
+		head, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+
+		// Without a work tree the repository is bare
+		bare, err := OpenGitDir(gitDir, "")
+		if err != nil {
+			t.Fatalf("OpenGitDir failed: %v", err)
+		}
+		if !bare.Bare || bare.RepoPath != gitDir {
+			t.Errorf("Expected a bare repository at %s, got %+v", gitDir, bare)
+		}
+		if got, err := bare.RevParse("main"); err != nil || got != head {
+			t.Errorf("Expected the commit from the work tree on main, got %s, %v", got, err)
+		}
+
+		// Worktrees added from it locate their own git directory
+		linked := filepath.Join(testDir, "linked")
+		worktree, err := repo.AddWorktree(linked, WorktreeOptions{Commit: "main", Detach: true})
+		if err != nil {
+			t.Fatalf("AddWorktree failed: %v", err)
+		}
+		if worktree.Client.GitDir != "" || worktree.Client.WorkTree != "" {
+			t.Errorf("Expected the worktree not to inherit GitDir and WorkTree, got %+v", worktree.Client)
+		}
+		if _, err := os.Stat(filepath.Join(linked, "work.txt")); err != nil {
+			t.Errorf("Expected work.txt in the linked worktree, got %v", err)
+		}
+
+		if _, err := OpenGitDir(filepath.Join(testDir, "missing"), ""); err == nil {
+			t.Error("Expected an error opening a git directory that does not exist")
+		}
+
+		// GIT_DIR and GIT_WORK_TREE are used when no paths are given
+		t.Setenv("GIT_DIR", "")
+		if _, err := OpenGitDir("", ""); err == nil {
+			t.Error("Expected an error without a git directory")
+		}
+		t.Setenv("GIT_DIR", gitDir)
+		t.Setenv("GIT_WORK_TREE", workTree)
+		fromEnv, err := OpenGitDir("", "")
+		if err != nil {
+			t.Fatalf("OpenGitDir from the environment failed: %v", err)
+		}
+		if fromEnv.Bare || fromEnv.RepoPath != workTree || fromEnv.Client.GitDir != gitDir {
+			t.Errorf("Expected the repository from the environment, got %+v", fromEnv)
+		}
+	})
+}
 func TestBasicGitOperations(t *testing.T) {
 	SafeTest(t, func(t *testing.T, testDir string) {
 		tempDir := setupTestRepo(t)
@@ -138,6 +325,62 @@ func TestBasicGitOperations(t *testing.T) {
 	})
 }
 
+func TestCloneNonMainDefaultBranch(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		remoteDir, cleanup, err := CreateTestRemoteRepoWithBranch("gittools-master", "master")
+		if err != nil {
+			t.Fatalf("Failed to create remote repository: %v", err)
+		}
+		defer cleanup()
+
+		client := Client{}
+		repo, err := client.CloneWithOptions(CloneOptions{
+			URL:         remoteDir,
+			Destination: filepath.Join(testDir, "clone"),
+		})
+		if err != nil {
+			t.Fatalf("Failed to clone repository: %v", err)
+		}
+		if repo.RepoPath != filepath.Join(testDir, "clone") {
+			t.Errorf("Expected RepoPath to be set to the clone, got %q", repo.RepoPath)
+		}
+
+		branch, err := repo.CurrentBranch()
+		if err != nil {
+			t.Fatalf("Failed to get current branch: %v", err)
+		}
+		if branch != "master" {
+			t.Errorf("Expected clone to check out master, got %s", branch)
+		}
+
+		defaultBranch, err := repo.RemoteDefaultBranch("origin")
+		if err != nil {
+			t.Fatalf("Failed to get remote default branch: %v", err)
+		}
+		if defaultBranch != "master" {
+			t.Errorf("Expected remote default branch to be master, got %s", defaultBranch)
+		}
+
+		// Without access to the remote, the HEAD recorded by clone is used
+		if err := os.Rename(remoteDir, remoteDir+"-moved"); err != nil {
+			t.Fatalf("Failed to move remote: %v", err)
+		}
+		defer os.Rename(remoteDir+"-moved", remoteDir)
+
+		defaultBranch, err = repo.RemoteDefaultBranch("origin")
+		if err != nil {
+			t.Fatalf("Failed to get remote default branch offline: %v", err)
+		}
+		if defaultBranch != "master" {
+			t.Errorf("Expected offline remote default branch to be master, got %s", defaultBranch)
+		}
+
+		if _, err := repo.RemoteDefaultBranch("missing"); err == nil {
+			t.Error("Expected an error for a missing remote")
+		}
+	})
+}
+
 func TestGetHash(t *testing.T) {
 	SafeTest(t, func(t *testing.T, testDir string) {
 		// Create a temporary file within the SafeTest directory
@@ -220,6 +463,89 @@ func TestFileAtCommit(t *testing.T) {
 	})
 }
 
+func TestRevListOptions(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		tempDir := setupTestRepo(t)
+
+		repo, err := Open(tempDir)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+
+		commit := func(author, name string) string {
+			t.Helper()
+			repo.Client.SetUser(author, "test@example.com")
+			path := filepath.Join(tempDir, name)
+			if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+				t.Fatalf("Failed to write %s: %v", name, err)
+			}
+			if err := repo.Commit("Add "+name, []string{path}); err != nil {
+				t.Fatalf("Failed to commit %s: %v", name, err)
+			}
+			hash, err := repo.RevParse("HEAD")
+			if err != nil {
+				t.Fatalf("Failed to get HEAD: %v", err)
+			}
+			return hash
+		}
+
+		initial, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+		alice := commit("Alice", "a.txt")
+		if err := repo.CreateBranch("feature"); err != nil {
+			t.Fatalf("Failed to create branch: %v", err)
+		}
+		if err := repo.Checkout("feature"); err != nil {
+			t.Fatalf("Failed to checkout feature: %v", err)
+		}
+		feature := commit("Bob", "b.txt")
+		if err := repo.Checkout("main"); err != nil {
+			t.Fatalf("Failed to checkout main: %v", err)
+		}
+		mainCommit := commit("Bob", "c.txt")
+		if stdout, stderr, err := repo.Client.Exec("merge", "--no-ff", "-m", "Merge feature", "feature"); err != nil {
+			t.Fatalf("Failed to merge: %v\nstdout: %s\nstderr: %s", err, stdout, stderr)
+		}
+		merge, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+
+		tests := []struct {
+			name     string
+			options  RevListOptions
+			expected []string
+		}{
+			{"merges", RevListOptions{Range: "HEAD", Merges: true}, []string{merge}},
+			{"no merges", RevListOptions{Range: feature + "..HEAD", NoMerges: true}, []string{mainCommit}},
+			{"first parent", RevListOptions{Range: "HEAD", FirstParent: true}, []string{merge, mainCommit, alice, initial}},
+			{"author", RevListOptions{Range: "HEAD", Author: "Alice"}, []string{alice}},
+			{"paths", RevListOptions{Range: "HEAD", Paths: []string{"b.txt"}}, []string{feature}},
+			{"reverse", RevListOptions{Range: "HEAD", FirstParent: true, Reverse: true}, []string{initial, alice, mainCommit, merge}},
+			{"boundary", RevListOptions{Range: mainCommit + ".." + merge, Boundary: true}, []string{merge, feature, "-" + mainCommit, "-" + alice}},
+			{"since", RevListOptions{Range: "HEAD", Since: time.Now().Add(time.Hour)}, []string{}},
+			{"until", RevListOptions{Range: "HEAD", Until: time.Now().Add(-time.Hour)}, []string{}},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				commits, err := repo.RevList(test.options)
+				if err != nil {
+					t.Fatalf("RevList failed: %v", err)
+				}
+				if !equalArgs(commits, test.expected) {
+					t.Errorf("Expected %v, got %v", test.expected, commits)
+				}
+			})
+		}
+
+		if _, err := repo.RevList(RevListOptions{Range: "HEAD", Merges: true, NoMerges: true}); err == nil {
+			t.Error("Expected an error for Merges with NoMerges")
+		}
+	})
+}
+
 func setupTestRepo(t *testing.T) string {
 	t.Helper()
 	var err error
@@ -257,3 +583,411 @@ func setupTestRepo(t *testing.T) string {
 	// Return the temp directory
 	return tempDir
 }
+
+func TestDeleteRemoteBranch(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		remoteDir, cleanup, err := CreateTestRemoteRepo("delete-remote-branch")
+		if err != nil {
+			t.Fatalf("Failed to create remote repository: %v", err)
+		}
+		defer cleanup()
+
+		repo, err := (&Client{}).Clone(remoteDir, filepath.Join(testDir, "clone"))
+		if err != nil {
+			t.Fatalf("Failed to clone repository: %v", err)
+		}
+		if err := repo.PushWithOptions("origin", PushOptions{Refspecs: []string{"HEAD:refs/heads/feature", "HEAD:refs/heads/release"}}); err != nil {
+			t.Fatalf("Failed to push branches: %v", err)
+		}
+		if err := repo.Fetch("origin", FetchOptions{}); err != nil {
+			t.Fatalf("Failed to fetch: %v", err)
+		}
+
+		if err := repo.DeleteRemoteBranch("origin", "feature"); err != nil {
+			t.Fatalf("DeleteRemoteBranch failed: %v", err)
+		}
+		refs, err := repo.LsRemote("origin", "refs/heads/feature")
+		if err != nil {
+			t.Fatalf("Failed to list remote refs: %v", err)
+		}
+		if len(refs) != 0 {
+			t.Errorf("Expected feature to be deleted from the remote, got %v", refs)
+		}
+		if exists, err := repo.BranchExists("origin/feature", true); err != nil || exists {
+			t.Errorf("Expected origin/feature to be removed, got %v, %v", exists, err)
+		}
+
+		if err := repo.DeleteRemoteBranch("origin", "feature"); !errors.Is(err, ErrPushRemoteRefMissing) {
+			t.Errorf("Expected ErrPushRemoteRefMissing deleting a missing branch, got %v", err)
+		}
+
+		if out, err := GitExec(t, remoteDir, 10, "config", "receive.denyDeletes", "true"); err != nil {
+			t.Fatalf("Failed to protect branches: %v\n%s", err, out)
+		}
+		if err := repo.DeleteRemoteBranch("origin", "release"); !errors.Is(err, ErrPushProtectedBranch) {
+			t.Errorf("Expected ErrPushProtectedBranch, got %v", err)
+		}
+	})
+}
+
+func TestClassifyPushErrorProtectedBranch(t *testing.T) {
+	outputs := []string{
+		" ! [remote rejected] main (deletion of the current branch prohibited)\n",
+		"remote: error: GH006: Protected branch update failed for refs/heads/main.\n ! [remote rejected] main (protected branch hook declined)\n",
+		"remote: GitLab: You are not allowed to delete protected branches from this project.\n ! [remote rejected] main (pre-receive hook declined)\n",
+		"remote: Branch refs/heads/main can only be modified through pull requests.\n ! [remote rejected] main (pre-receive hook declined)\n",
+	}
+	for _, output := range outputs {
+		err := classifyPushError(nil, []byte(output), errors.New("exit status 1"))
+		if !errors.Is(err, ErrPushProtectedBranch) {
+			t.Errorf("Expected ErrPushProtectedBranch for %q, got %v", output, err)
+		}
+	}
+	err := classifyPushError(nil, []byte(" ! [remote rejected] main (pre-receive hook declined)\n"), errors.New("exit status 1"))
+	if errors.Is(err, ErrPushProtectedBranch) || !errors.Is(err, ErrPushRejected) {
+		t.Errorf("Expected ErrPushRejected for other rejections, got %v", err)
+	}
+}
+
+func TestPushRefspecsAndTags(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		remoteDir, cleanup, err := CreateTestRemoteRepo("push-refspecs")
+		if err != nil {
+			t.Fatalf("Failed to create remote repository: %v", err)
+		}
+		defer cleanup()
+
+		client := &Client{}
+		client.SetUser("Test User", "test@example.com")
+		repo, err := client.Clone(remoteDir, filepath.Join(testDir, "clone"))
+		if err != nil {
+			t.Fatalf("Failed to clone repository: %v", err)
+		}
+		remoteRef := func(ref string) string {
+			t.Helper()
+			refs, err := repo.LsRemote("origin", ref)
+			if err != nil {
+				t.Fatalf("Failed to list remote refs: %v", err)
+			}
+			return refs[ref]
+		}
+		tag := func(name string) {
+			t.Helper()
+			if stdout, stderr, err := repo.Client.Exec("tag", name); err != nil {
+				t.Fatalf("Failed to tag: %v\n%s%s", err, stdout, stderr)
+			}
+		}
+
+		// Release a branch and its tag together
+		writeAndCommit(t, repo, "release.txt", "1.0\n")
+		head, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+		tag("v1.0")
+		if err := repo.Push("origin", "main", "refs/tags/v1.0"); err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+		if got := remoteRef("refs/heads/main"); got != head {
+			t.Errorf("Expected main at %s, got %q", head, got)
+		}
+		if got := remoteRef("refs/tags/v1.0"); got != head {
+			t.Errorf("Expected v1.0 at %s, got %q", head, got)
+		}
+
+		// If one ref is rejected, none are updated
+		if err := repo.Reset(ResetOptions{Mode: ResetHard, Target: "HEAD~1"}); err != nil {
+			t.Fatalf("Failed to reset: %v", err)
+		}
+		writeAndCommit(t, repo, "diverged.txt", "diverged\n")
+		tag("v1.1")
+		if err := repo.Push("origin", "main", "refs/tags/v1.1"); !errors.Is(err, ErrPushNonFastForward) {
+			t.Errorf("Expected ErrPushNonFastForward, got %v", err)
+		}
+		if got := remoteRef("refs/tags/v1.1"); got != "" {
+			t.Errorf("Expected v1.1 not to be pushed, got %s", got)
+		}
+
+		if err := repo.PushTags("origin", "v1.1"); err != nil {
+			t.Fatalf("PushTags failed: %v", err)
+		}
+		if got := remoteRef("refs/tags/v1.1"); got == "" {
+			t.Error("Expected v1.1 to be pushed")
+		}
+
+		tag("v2.0")
+		tag("v2.1")
+		if err := repo.PushAllTags("origin"); err != nil {
+			t.Fatalf("PushAllTags failed: %v", err)
+		}
+		if remoteRef("refs/tags/v2.0") == "" || remoteRef("refs/tags/v2.1") == "" {
+			t.Error("Expected every tag to be pushed")
+		}
+
+		// Remotes without atomic pushes get a regular push
+		faults := &FaultInjector{}
+		repo.Client.Hooks = append(repo.Client.Hooks, faults)
+		atomic := faults.On("push", "--porcelain", "--atomic")
+		atomic.Fail("fatal: the receiving end does not support --atomic push\n", 128)
+		tag("v3.0")
+		tag("v3.1")
+		if err := repo.PushTags("origin", "v3.0", "v3.1"); err != nil {
+			t.Fatalf("Expected PushTags to fall back to a regular push, got %v", err)
+		}
+		if faults.Calls(atomic) != 1 || remoteRef("refs/tags/v3.0") == "" || remoteRef("refs/tags/v3.1") == "" {
+			t.Error("Expected the tags to be pushed without --atomic")
+		}
+
+		if err := repo.Push("origin"); err == nil {
+			t.Error("Expected an error pushing no refspecs")
+		}
+	})
+}
+
+func TestPushIgnoreMirror(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		remoteDir, cleanup, err := CreateTestRemoteRepo("push-mirror")
+		if err != nil {
+			t.Fatalf("Failed to create remote repository: %v", err)
+		}
+		defer cleanup()
+
+		mirrorDir := filepath.Join(testDir, "mirror.git")
+		if out, err := GitExec(t, testDir, 10, "clone", "--mirror", remoteDir, mirrorDir); err != nil {
+			t.Fatalf("Failed to clone: %v\n%s", err, out)
+		}
+		repo, err := Open(mirrorDir)
+		if err != nil {
+			t.Fatalf("Failed to open mirror clone: %v", err)
+		}
+		hook := &argsHook{}
+		repo.Client.Hooks = append(repo.Client.Hooks, hook)
+		overridden := func() bool {
+			defer func() { hook.args = nil }()
+			return strings.Contains(strings.Join(hook.args, " "), "remote.origin.mirror=false")
+		}
+
+		// By default remote.origin.mirror applies, and git refuses the refspec
+		options := PushOptions{Refspecs: []string{"refs/heads/main:refs/heads/copy"}}
+		if err := repo.PushWithOptions("origin", options); err == nil {
+			t.Error("Expected pushing a refspec from a mirror clone to fail")
+		}
+		if overridden() {
+			t.Error("Expected remote.origin.mirror not to be overridden")
+		}
+
+		options.IgnoreMirror = true
+		if err := repo.PushWithOptions("origin", options); err != nil {
+			t.Fatalf("Expected the push to ignore remote.origin.mirror, got %v", err)
+		}
+		if !overridden() {
+			t.Error("Expected remote.origin.mirror to be overridden")
+		}
+		refs, err := repo.LsRemote("origin", "refs/heads/copy")
+		if err != nil {
+			t.Fatalf("Failed to list remote refs: %v", err)
+		}
+		if refs["refs/heads/copy"] == "" {
+			t.Error("Expected copy to be pushed")
+		}
+	})
+}
+
+func TestCheckoutWithOptions(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		remoteDir, cleanup, err := CreateTestRemoteRepo("checkout-options")
+		if err != nil {
+			t.Fatalf("Failed to create remote repository: %v", err)
+		}
+		defer cleanup()
+
+		client := &Client{}
+		client.SetUser("Test User", "test@example.com")
+		repo, err := client.Clone(remoteDir, filepath.Join(testDir, "clone"))
+		if err != nil {
+			t.Fatalf("Failed to clone repository: %v", err)
+		}
+		base, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+		writeAndCommit(t, repo, "local.txt", "local\n")
+
+		assertBranch := func(expected string) {
+			t.Helper()
+			branch, err := repo.CurrentBranch()
+			if err != nil {
+				t.Fatalf("Failed to get current branch: %v", err)
+			}
+			if branch != expected {
+				t.Errorf("Expected to be on %s, got %s", expected, branch)
+			}
+		}
+
+		// Create a branch tracking the remote branch in one step
+		if err := repo.CheckoutWithOptions("tracking", CheckoutOptions{Create: true, Track: true, StartPoint: "origin/main"}); err != nil {
+			t.Fatalf("CheckoutWithOptions failed: %v", err)
+		}
+		assertBranch("tracking")
+		if head, _ := repo.RevParse("HEAD"); head != base {
+			t.Errorf("Expected the branch to start at %s, got %s", base, head)
+		}
+		if merge, err := repo.ConfigGetWithOptions("branch.tracking.merge", ConfigOptions{}); err != nil || merge != "refs/heads/main" {
+			t.Errorf("Expected the branch to track main, got %q, %v", merge, err)
+		}
+
+		// Creating an existing branch fails
+		if err := repo.CheckoutWithOptions("tracking", CheckoutOptions{Create: true}); !errors.Is(err, ErrBranchExists) {
+			t.Errorf("Expected ErrBranchExists, got %v", err)
+		}
+
+		// Force discards local changes
+		if err := os.WriteFile(filepath.Join(repo.RepoPath, "README.md"), []byte("changed\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if err := repo.CheckoutWithOptions("main", CheckoutOptions{Force: true}); err != nil {
+			t.Fatalf("Forced checkout failed: %v", err)
+		}
+		assertBranch("main")
+		if status, err := repo.Status(); err != nil || len(status) != 0 {
+			t.Errorf("Expected a clean working tree, got %v, %v", status, err)
+		}
+
+		// Detach at a branch
+		if err := repo.CheckoutWithOptions("main", CheckoutOptions{Detach: true}); err != nil {
+			t.Fatalf("Detached checkout failed: %v", err)
+		}
+		assertBranch("HEAD")
+
+		for _, options := range []CheckoutOptions{
+			{Create: true, Detach: true},
+			{StartPoint: "origin/main"},
+			{Create: true, Track: true},
+		} {
+			if err := repo.CheckoutWithOptions("invalid", options); err == nil {
+				t.Errorf("Expected %+v to be rejected", options)
+			}
+		}
+	})
+}
+
+func TestCheckoutCommit(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		repo, err := Open(setupTestRepo(t))
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		assertDetached := func(expected bool) {
+			t.Helper()
+			detached, err := repo.HeadDetached()
+			if err != nil {
+				t.Fatalf("HeadDetached failed: %v", err)
+			}
+			if detached != expected {
+				t.Errorf("Expected HeadDetached to be %v, got %v", expected, detached)
+			}
+		}
+
+		branch, err := repo.CurrentBranch()
+		if err != nil {
+			t.Fatalf("Failed to get current branch: %v", err)
+		}
+		pinned, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+		assertDetached(false)
+
+		if err := repo.CheckoutCommit(branch); err != nil {
+			t.Fatalf("CheckoutCommit failed: %v", err)
+		}
+		assertDetached(true)
+
+		// The branch moves on but the working tree stays at the pinned commit
+		if _, _, err := repo.Client.Exec("commit", "--allow-empty", "-m", "Moved on"); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+		if _, _, err := repo.Client.Exec("branch", "-f", branch, "HEAD"); err != nil {
+			t.Fatalf("Failed to move branch: %v", err)
+		}
+		if err := repo.CheckoutCommit(pinned); err != nil {
+			t.Fatalf("CheckoutCommit failed: %v", err)
+		}
+		if head, _ := repo.RevParse("HEAD"); head != pinned {
+			t.Errorf("Expected HEAD at %s, got %s", pinned, head)
+		}
+		assertDetached(true)
+
+		if err := repo.Checkout(branch); err != nil {
+			t.Fatalf("Failed to restore branch: %v", err)
+		}
+		assertDetached(false)
+		if head, _ := repo.RevParse("HEAD"); head == pinned {
+			t.Error("Expected the branch to have moved on from the pinned commit")
+		}
+	})
+}
+
+func TestCreateBranchWithOptions(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		remoteDir, cleanup, err := CreateTestRemoteRepo("create-branch")
+		if err != nil {
+			t.Fatalf("Failed to create remote repository: %v", err)
+		}
+		defer cleanup()
+
+		client := &Client{}
+		client.SetUser("Test User", "test@example.com")
+		repo, err := client.Clone(remoteDir, filepath.Join(testDir, "clone"))
+		if err != nil {
+			t.Fatalf("Failed to clone repository: %v", err)
+		}
+		base, err := repo.RevParse("origin/main")
+		if err != nil {
+			t.Fatalf("Failed to resolve origin/main: %v", err)
+		}
+		writeAndCommit(t, repo, "local.txt", "local\n")
+		head, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+		assertBranchAt := func(branch, expected string) {
+			t.Helper()
+			hash, err := repo.RevParse("refs/heads/" + branch)
+			if err != nil {
+				t.Fatalf("Failed to resolve %s: %v", branch, err)
+			}
+			if hash != expected {
+				t.Errorf("Expected %s at %s, got %s", branch, expected, hash)
+			}
+		}
+
+		if err := repo.CreateBranchWithOptions("release", CreateBranchOptions{StartPoint: "origin/main", Track: true}); err != nil {
+			t.Fatalf("CreateBranchWithOptions failed: %v", err)
+		}
+		assertBranchAt("release", base)
+		if remote, err := repo.ConfigGetWithOptions("branch.release.remote", ConfigOptions{}); err != nil || remote != "origin" {
+			t.Errorf("Expected release to track origin, got %q, %v", remote, err)
+		}
+		if branch, _ := repo.CurrentBranch(); branch != "main" {
+			t.Errorf("Expected to stay on main, got %s", branch)
+		}
+
+		if err := repo.CreateBranch("release"); !errors.Is(err, ErrBranchExists) {
+			t.Errorf("Expected ErrBranchExists, got %v", err)
+		}
+		if err := repo.CreateBranchWithOptions("release", CreateBranchOptions{StartPoint: "origin/main"}); !errors.Is(err, ErrBranchExists) {
+			t.Errorf("Expected ErrBranchExists, got %v", err)
+		}
+
+		// Force moves the existing branch
+		if err := repo.CreateBranchWithOptions("release", CreateBranchOptions{Force: true}); err != nil {
+			t.Fatalf("Forced CreateBranchWithOptions failed: %v", err)
+		}
+		assertBranchAt("release", head)
+
+		if err := repo.CreateBranchWithOptions("broken", CreateBranchOptions{StartPoint: "missing"}); err == nil || errors.Is(err, ErrBranchExists) {
+			t.Errorf("Expected a missing start point to fail, got %v", err)
+		}
+	})
+}
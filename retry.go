@@ -0,0 +1,112 @@
+package gittools
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/cloudflare/backoff"
+)
+
+// transientErrorPattern matches the stderr of network failures worth retrying:
+// dropped and refused connections, timeouts, DNS failures and 5xx responses from
+// smart HTTP servers
+var transientErrorPattern = regexp.MustCompile(`(?i)` +
+	`early EOF|` +
+	`connection (reset|refused|timed out)|` +
+	`operation timed out|` +
+	`remote end hung up unexpectedly|` +
+	`unexpected disconnect while reading sideband packet|` +
+	`temporary failure in name resolution|` +
+	`could not resolve host|` +
+	`RPC failed; HTTP 5\d\d|` +
+	`RPC failed; curl \d+|` +
+	`returned error: 5\d\d|` +
+	`SSL_ERROR_SYSCALL|` +
+	`gnutls_handshake\(\) failed`)
+
+// RetryPolicy retries clone, fetch, ls-remote, pull and push when they fail with a
+// transient network error, so a single dropped connection doesn't fail an operation.
+// Hooks see every attempt as a separate command.
+//
+// Commands killed because their context is done are not retried, nor are commands
+// with ExecOptions.Stdin or ExecOptions.Stdout set, whose input and output can't be
+// replayed. Note that a push whose connection drops after the remote accepted it is
+// retried, and then typically reports that everything is up-to-date.
+type RetryPolicy struct {
+	// MaxAttempts is the most times a command is run, including the first attempt.
+	// Values below 2 disable retries.
+	MaxAttempts int
+
+	// InitialBackoff is the longest wait before the first retry. Each further retry
+	// may wait up to twice as long, capped at MaxBackoff. The wait is randomized
+	// between zero and that limit. If zero, 100ms and 5s are used.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Retryable decides whether a failed command is retried, given its stderr and
+	// error. If nil, IsTransientError is used.
+	Retryable func(stderr []byte, err error) bool
+}
+
+// Backoff limits used when a RetryPolicy doesn't set them
+const (
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 5 * time.Second
+)
+
+// DefaultRetryPolicy returns a policy that makes up to 3 attempts
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: defaultInitialBackoff,
+		MaxBackoff:     defaultMaxBackoff,
+	}
+}
+
+// IsTransientError returns true if stderr from a failed git command reports a network
+// failure that may succeed if retried, e.g. "early EOF", "Connection reset by peer"
+// or a 5xx response from an HTTP remote
+func IsTransientError(stderr []byte, err error) bool {
+	return err != nil && transientErrorPattern.Match(stderr)
+}
+
+// appliesTo returns true if the policy retries the command
+func (p *RetryPolicy) appliesTo(options ExecOptions, args []string) bool {
+	if p == nil || p.MaxAttempts < 2 || options.Stdin != nil || options.Stdout != nil {
+		return false
+	}
+	return networkCommands[(&Command{Args: args}).Subcommand()]
+}
+
+// run calls exec until it succeeds, fails with an error that is not retryable,
+// ctx is done or the attempts are used up, and returns the result of the last call
+func (p *RetryPolicy) run(ctx context.Context, exec func() ([]byte, []byte, error)) ([]byte, []byte, error) {
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsTransientError
+	}
+	initial, max := p.InitialBackoff, p.MaxBackoff
+	if initial <= 0 {
+		initial = defaultInitialBackoff
+	}
+	if max <= 0 {
+		max = defaultMaxBackoff
+	}
+	b := backoff.New(max, initial)
+
+	for attempt := 1; ; attempt++ {
+		stdout, stderr, err := exec()
+		if err == nil || attempt >= p.MaxAttempts || ctx.Err() != nil || !retryable(stderr, err) {
+			return stdout, stderr, err
+		}
+
+		timer := time.NewTimer(b.Duration())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return stdout, stderr, err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package gittools
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIsTransientError(t *testing.T) {
+	exitErr := &RecordedExitError{Code: 128, Message: "exit status 128"}
+	tests := []struct {
+		stderr string
+		want   bool
+	}{
+		{"fatal: early EOF\nfatal: index-pack failed\n", true},
+		{"fatal: unable to access 'https://example.com/repo.git/': Connection reset by peer\n", true},
+		{"error: RPC failed; HTTP 502 curl 22 The requested URL returned error: 502\n", true},
+		{"fatal: unable to access 'https://example.com/repo.git/': The requested URL returned error: 503\n", true},
+		{"fatal: unable to access 'https://example.com/repo.git/': Could not resolve host: example.com\n", true},
+		{"fatal: the remote end hung up unexpectedly\n", true},
+		{"fatal: unable to access 'https://example.com/repo.git/': The requested URL returned error: 403\n", false},
+		{"fatal: couldn't find remote ref refs/heads/missing\n", false},
+		{" ! [rejected]        main -> main (non-fast-forward)\n", false},
+		{"fatal: Authentication failed for 'https://example.com/repo.git/'\n", false},
+	}
+	for _, test := range tests {
+		if got := IsTransientError([]byte(test.stderr), exitErr); got != test.want {
+			t.Errorf("IsTransientError(%q) = %v, expected %v", test.stderr, got, test.want)
+		}
+	}
+	if IsTransientError([]byte("fatal: early EOF"), nil) {
+		t.Error("Expected a successful command not to be transient")
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		remotePath, cleanup, err := CreateTestRemoteRepo("retry")
+		if err != nil {
+			t.Fatalf("Failed to create remote: %v", err)
+		}
+		defer cleanup()
+
+		repo, err := (&Client{}).Clone(remotePath, filepath.Join(testDir, "clone"))
+		if err != nil {
+			t.Fatalf("Failed to clone: %v", err)
+		}
+		faults := &FaultInjector{}
+		repo.Client.Hooks = append(repo.Client.Hooks, faults)
+		repo.Client.Retry = &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+		// A transient failure is retried
+		fetch := faults.On("fetch")
+		fetch.Nth(1).Fail("fatal: early EOF\nfatal: index-pack failed\n", 128)
+		if err := repo.Fetch("origin", FetchOptions{}); err != nil {
+			t.Fatalf("Expected the fetch to be retried, got %v", err)
+		}
+		if calls := faults.Calls(fetch); calls != 2 {
+			t.Errorf("Expected 2 attempts, got %d", calls)
+		}
+
+		// Attempts are limited
+		lsRemote := faults.On("ls-remote").Fail("fatal: the remote end hung up unexpectedly\n", 128)
+		if _, err := repo.LsRemote("origin"); err == nil {
+			t.Error("Expected ls-remote to fail after every attempt failed")
+		}
+		if calls := faults.Calls(lsRemote); calls != 3 {
+			t.Errorf("Expected 3 attempts, got %d", calls)
+		}
+
+		// Other failures are not retried
+		push := faults.On("push")
+		push.RejectNonFastForward()
+		if err := repo.Push("origin", "main"); !errors.Is(err, ErrPushNonFastForward) {
+			t.Errorf("Expected ErrPushNonFastForward, got %v", err)
+		}
+		if calls := faults.Calls(push); calls != 1 {
+			t.Errorf("Expected a single push, got %d", calls)
+		}
+
+		// Neither are commands other than the network ones
+		revParse := faults.On("rev-parse").Fail("fatal: early EOF\n", 128)
+		if _, err := repo.RevParse("HEAD"); err == nil {
+			t.Error("Expected rev-parse to fail")
+		}
+		if calls := faults.Calls(revParse); calls != 1 {
+			t.Errorf("Expected a single rev-parse, got %d", calls)
+		}
+
+		// Cancelling the context stops retries
+		repo.Client.Retry = &RetryPolicy{MaxAttempts: 10, InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+		pull := faults.On("pull").Fail("fatal: early EOF\n", 128)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		if _, _, err := repo.Client.ExecContext(ctx, "pull", "origin", "main"); err == nil {
+			t.Error("Expected pull to fail")
+		}
+		if calls := faults.Calls(pull); calls != 1 {
+			t.Errorf("Expected a single pull before the context was done, got %d", calls)
+		}
+	})
+}
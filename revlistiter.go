@@ -0,0 +1,109 @@
+package gittools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+)
+
+// RevListIter yields the commits listed by git rev-list as git produces them,
+// without holding the whole list in memory. See Repo.RevListIter.
+//
+//	iter, err := repo.RevListIter(gittools.RevListOptions{Range: "HEAD"})
+//	if err != nil {
+//		return err
+//	}
+//	defer iter.Close()
+//	for iter.Next() {
+//		fmt.Println(iter.Commit())
+//	}
+//	return iter.Err()
+type RevListIter struct {
+	reader  *io.PipeReader
+	scanner *bufio.Scanner
+	cancel  context.CancelFunc
+	done    chan struct{}
+	commit  string
+	err     error
+	closed  bool
+}
+
+// RevListIter starts git rev-list with the specified options and returns an iterator
+// over the commit hashes it prints. The iterator must be closed to stop git if it is
+// not read to the end.
+func (r *Repo) RevListIter(options RevListOptions) (*RevListIter, error) {
+	args, err := revListArgs(options)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+
+	reader, writer := io.Pipe()
+	iter := &RevListIter{
+		reader:  reader,
+		scanner: bufio.NewScanner(reader),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		defer close(iter.done)
+		_, stderr, err := r.Client.ExecWithOptions(ExecOptions{Context: ctx, Stdout: writer}, args...)
+		if err != nil {
+			writer.CloseWithError(fmt.Errorf("git rev-list failed: %w\nstderr: %s", err, stderr))
+			return
+		}
+		writer.Close()
+	}()
+
+	return iter, nil
+}
+
+// Next advances to the next commit, returning false at the end of the list or on error
+func (it *RevListIter) Next() bool {
+	if it.err != nil || it.closed {
+		return false
+	}
+	for it.scanner.Scan() {
+		commit := string(bytes.TrimSpace(it.scanner.Bytes()))
+		if commit == "" {
+			continue
+		}
+		it.commit = commit
+		return true
+	}
+	it.err = it.scanner.Err()
+	it.commit = ""
+	return false
+}
+
+// Commit returns the current commit hash, as returned by RevList
+func (it *RevListIter) Commit() string {
+	return it.commit
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *RevListIter) Err() error {
+	return it.err
+}
+
+// Close stops git if it is still running and waits for it to exit
+func (it *RevListIter) Close() error {
+	if it.closed {
+		return nil
+	}
+	it.closed = true
+	it.commit = ""
+
+	it.cancel()
+	it.reader.Close()
+	<-it.done
+	return nil
+}
@@ -0,0 +1,84 @@
+package gittools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRevListIter(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		tempDir := setupTestRepo(t)
+
+		repo, err := Open(tempDir)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		repo.Client.SetUser("Test User", "test@example.com")
+
+		for i := 0; i < 5; i++ {
+			path := filepath.Join(tempDir, fmt.Sprintf("file_%d.txt", i))
+			if err := os.WriteFile(path, []byte(fmt.Sprint(i)), 0644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+			if err := repo.Commit(fmt.Sprintf("Add file %d", i), []string{path}); err != nil {
+				t.Fatalf("Failed to commit: %v", err)
+			}
+		}
+
+		expected, err := repo.RevList(RevListOptions{Range: "HEAD"})
+		if err != nil {
+			t.Fatalf("RevList failed: %v", err)
+		}
+
+		iter, err := repo.RevListIter(RevListOptions{Range: "HEAD"})
+		if err != nil {
+			t.Fatalf("RevListIter failed: %v", err)
+		}
+		var commits []string
+		for iter.Next() {
+			commits = append(commits, iter.Commit())
+		}
+		if err := iter.Err(); err != nil {
+			t.Fatalf("Iteration failed: %v", err)
+		}
+		if err := iter.Close(); err != nil {
+			t.Errorf("Close failed: %v", err)
+		}
+		if !equalArgs(commits, expected) {
+			t.Errorf("Expected %v, got %v", expected, commits)
+		}
+
+		// Closing part way stops the iteration without an error
+		iter, err = repo.RevListIter(RevListOptions{Range: "HEAD"})
+		if err != nil {
+			t.Fatalf("RevListIter failed: %v", err)
+		}
+		if !iter.Next() || iter.Commit() != expected[0] {
+			t.Fatalf("Expected first commit %s, got %s", expected[0], iter.Commit())
+		}
+		if err := iter.Close(); err != nil {
+			t.Errorf("Close failed: %v", err)
+		}
+		if iter.Next() {
+			t.Error("Expected no commits after Close")
+		}
+		if err := iter.Err(); err != nil {
+			t.Errorf("Expected no error after Close, got %v", err)
+		}
+
+		// Errors from git are reported once the output ends
+		iter, err = repo.RevListIter(RevListOptions{Range: "missing-ref"})
+		if err != nil {
+			t.Fatalf("RevListIter failed: %v", err)
+		}
+		defer iter.Close()
+		if iter.Next() {
+			t.Errorf("Expected no commits for a missing ref, got %s", iter.Commit())
+		}
+		if iter.Err() == nil {
+			t.Error("Expected an error for a missing ref")
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package gittools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrRevisionNotFound is returned when a revision doesn't resolve to an object, or not
+// to an object of the type asked for
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// IsValidRef returns true if ref resolves to an object, e.g. a branch, tag, commit
+// hash or an expression such as "HEAD~2" or "main:README.md"
+func (r *Repo) IsValidRef(ref string) bool {
+	_, err := r.verifyRevision(context.Background(), ref)
+	return err == nil
+}
+
+// ShortHash returns the abbreviated hash of the object ref resolves to. The hash has
+// at least length characters, and more if needed to keep it unique in the repository.
+// If length is 0, git's core.abbrev setting decides the length.
+// Returns ErrRevisionNotFound if ref doesn't resolve.
+func (r *Repo) ShortHash(ref string, length int) (string, error) {
+	short := "--short"
+	if length > 0 {
+		short += "=" + strconv.Itoa(length)
+	}
+	return r.verifyRevision(context.Background(), ref, short)
+}
+
+// Head returns the full hash of the commit HEAD points to.
+// Returns ErrRevisionNotFound if the current branch has no commits yet.
+func (r *Repo) Head() (string, error) {
+	return r.ResolveToCommit("HEAD")
+}
+
+// ResolveToCommit returns the full hash of the commit ref points to, peeling
+// annotated tags.
+// Returns ErrRevisionNotFound if ref doesn't resolve, or resolves to a tree or blob.
+func (r *Repo) ResolveToCommit(ref string) (string, error) {
+	return r.resolveCommit(context.Background(), ref)
+}
+
+// resolveCommit returns the full hash of the commit a revision points to
+func (r *Repo) resolveCommit(ctx context.Context, rev string) (string, error) {
+	return r.verifyRevision(ctx, rev+"^{commit}")
+}
+
+// verifyRevision resolves a single revision with git rev-parse --verify, adding args
+// before the revision
+func (r *Repo) verifyRevision(ctx context.Context, rev string, args ...string) (string, error) {
+	args = append(append([]string{"rev-parse", "--verify", "--quiet"}, args...), rev)
+	stdout, stderr, err := r.Client.ExecContext(ctx, args...)
+	if err != nil {
+		// rev-parse exits with 1 and no output when the revision doesn't resolve
+		if ExitCode(err) == 1 && len(strings.TrimSpace(string(stdout))) == 0 {
+			return "", fmt.Errorf("%w: %s", ErrRevisionNotFound, rev)
+		}
+		return "", fmt.Errorf("git rev-parse failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
@@ -0,0 +1,79 @@
+package gittools
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRevParseHelpers(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		repo, err := Open(setupTestRepo(t))
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		repo.Client.SetUser("Test User", "test@example.com")
+		writeAndCommit(t, repo, "file.txt", "content\n")
+
+		head, err := repo.Head()
+		if err != nil {
+			t.Fatalf("Head failed: %v", err)
+		}
+		if expected, _ := repo.RevParse("HEAD"); head != expected || len(head) != 40 {
+			t.Errorf("Expected HEAD to be %s, got %s", expected, head)
+		}
+
+		for _, ref := range []string{"HEAD", "main", "refs/heads/main", "HEAD~1", head, head[:10], "HEAD:file.txt"} {
+			if !repo.IsValidRef(ref) {
+				t.Errorf("Expected %q to be a valid ref", ref)
+			}
+		}
+		for _, ref := range []string{"missing", "HEAD~5", "HEAD:missing.txt", "main..HEAD", ""} {
+			if repo.IsValidRef(ref) {
+				t.Errorf("Expected %q not to be a valid ref", ref)
+			}
+		}
+
+		short, err := repo.ShortHash("HEAD", 10)
+		if err != nil || short != head[:10] {
+			t.Errorf("Expected a 10 character hash %s, got %s, %v", head[:10], short, err)
+		}
+		if short, err := repo.ShortHash("HEAD", 0); err != nil || len(short) < 4 || !strings.HasPrefix(head, short) {
+			t.Errorf("Expected a default length abbreviation of %s, got %s, %v", head, short, err)
+		}
+		if _, err := repo.ShortHash("missing", 7); !errors.Is(err, ErrRevisionNotFound) {
+			t.Errorf("Expected ErrRevisionNotFound, got %v", err)
+		}
+
+		// Annotated tags are peeled to the commit they point to
+		if _, _, err := repo.Client.Exec("tag", "-a", "v1.0", "-m", "Release", "HEAD~1"); err != nil {
+			t.Fatalf("Failed to tag: %v", err)
+		}
+		parent, _ := repo.RevParse("HEAD~1")
+		if commit, err := repo.ResolveToCommit("v1.0"); err != nil || commit != parent {
+			t.Errorf("Expected v1.0 to resolve to %s, got %s, %v", parent, commit, err)
+		}
+		if _, err := repo.ResolveToCommit("HEAD^{tree}"); !errors.Is(err, ErrRevisionNotFound) {
+			t.Errorf("Expected ErrRevisionNotFound for a tree, got %v", err)
+		}
+		if _, err := repo.ResolveToCommit("missing"); !errors.Is(err, ErrRevisionNotFound) {
+			t.Errorf("Expected ErrRevisionNotFound, got %v", err)
+		}
+	})
+}
+
+func TestHeadWithoutCommits(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		client := &Client{}
+		repo, err := client.Init(testDir, "main")
+		if err != nil {
+			t.Fatalf("Failed to init repository: %v", err)
+		}
+		if _, err := repo.Head(); !errors.Is(err, ErrRevisionNotFound) {
+			t.Errorf("Expected ErrRevisionNotFound, got %v", err)
+		}
+		if repo.IsValidRef("HEAD") {
+			t.Error("Expected HEAD not to be valid without commits")
+		}
+	})
+}
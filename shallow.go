@@ -0,0 +1,70 @@
+package gittools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// IsShallow returns true if the repository is a shallow clone with incomplete history
+func (r *Repo) IsShallow() (bool, error) {
+	return r.isShallow(context.Background())
+}
+
+func (r *Repo) isShallow(ctx context.Context) (bool, error) {
+	stdout, stderr, err := r.Client.ExecContext(ctx, "rev-parse", "--is-shallow-repository")
+	if err != nil {
+		return false, fmt.Errorf("git rev-parse failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return strings.TrimSpace(string(stdout)) == "true", nil
+}
+
+// Deepen fetches n more commits of history below the shallow boundary from the default remote.
+// It has no effect on a repository with complete history.
+func (r *Repo) Deepen(n int) error {
+	return r.deepen(context.Background(), n)
+}
+
+func (r *Repo) deepen(ctx context.Context, n int) error {
+	if n <= 0 {
+		return fmt.Errorf("invalid deepen count %d", n)
+	}
+	stdout, stderr, err := r.Client.ExecContext(ctx, "fetch", fmt.Sprintf("--deepen=%d", n))
+	if err != nil {
+		return fmt.Errorf("git fetch failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return nil
+}
+
+// DeepenSince fetches history from the default remote so the repository includes
+// all commits made after since
+func (r *Repo) DeepenSince(since time.Time) error {
+	stdout, stderr, err := r.Client.Exec("fetch", "--shallow-since="+since.Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("git fetch failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return nil
+}
+
+// Unshallow fetches the complete history from the default remote.
+// It has no effect on a repository that is not shallow.
+func (r *Repo) Unshallow() error {
+	shallow, err := r.IsShallow()
+	if err != nil {
+		return err
+	}
+	if !shallow {
+		return nil
+	}
+
+	stdout, stderr, err := r.Client.Exec("fetch", "--unshallow")
+	if err != nil {
+		return fmt.Errorf("git fetch failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package gittools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestShallowClone(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		remotePath, cleanup, err := CreateTestRemoteRepo("shallow")
+		if err != nil {
+			t.Fatalf("Failed to create remote: %v", err)
+		}
+		defer cleanup()
+
+		client := &Client{}
+		writer, err := client.Clone(remotePath, filepath.Join(testDir, "writer"))
+		if err != nil {
+			t.Fatalf("Failed to clone: %v", err)
+		}
+		for i := 0; i < 5; i++ {
+			path := filepath.Join(writer.RepoPath, fmt.Sprintf("file_%d.txt", i))
+			if err := os.WriteFile(path, []byte(fmt.Sprint(i)), 0644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+			if err := writer.Commit(fmt.Sprintf("Add file %d", i), []string{path}); err != nil {
+				t.Fatalf("Failed to commit: %v", err)
+			}
+		}
+		if err := writer.Push("origin", "main"); err != nil {
+			t.Fatalf("Failed to push: %v", err)
+		}
+
+		full, err := writer.IsShallow()
+		if err != nil {
+			t.Fatalf("IsShallow failed: %v", err)
+		}
+		if full {
+			t.Error("Expected a full clone not to be shallow")
+		}
+
+		repo, err := client.CloneWithOptions(CloneOptions{
+			URL:         "file://" + remotePath,
+			Destination: filepath.Join(testDir, "shallow"),
+			Depth:       1,
+		})
+		if err != nil {
+			t.Fatalf("Failed to clone: %v", err)
+		}
+
+		shallow, err := repo.IsShallow()
+		if err != nil {
+			t.Fatalf("IsShallow failed: %v", err)
+		}
+		if !shallow {
+			t.Fatal("Expected a depth 1 clone to be shallow")
+		}
+		assertCommitCount(t, repo, 1)
+
+		if err := repo.Deepen(2); err != nil {
+			t.Fatalf("Deepen failed: %v", err)
+		}
+		assertCommitCount(t, repo, 3)
+
+		if err := repo.DeepenSince(time.Now().Add(-time.Hour)); err != nil {
+			t.Fatalf("DeepenSince failed: %v", err)
+		}
+		assertCommitCount(t, repo, 6)
+
+		if err := repo.Unshallow(); err != nil {
+			t.Fatalf("Unshallow failed: %v", err)
+		}
+		if shallow, _ := repo.IsShallow(); shallow {
+			t.Error("Expected repository not to be shallow after Unshallow")
+		}
+
+		// Unshallowing a complete repository has no effect
+		if err := repo.Unshallow(); err != nil {
+			t.Errorf("Expected Unshallow of a complete repository to succeed, got %v", err)
+		}
+	})
+}
+
+func assertCommitCount(t *testing.T, repo *Repo, want int) {
+	t.Helper()
+	count, err := repo.CountCommits("HEAD")
+	if err != nil {
+		t.Fatalf("Failed to count commits: %v", err)
+	}
+	if count != want {
+		t.Errorf("Expected %d commits, got %d", want, count)
+	}
+}
@@ -0,0 +1,104 @@
+package gittools
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ShortlogOptions defines options for the git shortlog command
+type ShortlogOptions struct {
+	// Email groups commits by name and email instead of by name alone, and fills in
+	// ShortlogEntry.Email (-e)
+	Email bool
+
+	// Committer counts commits by committer instead of by author (-c)
+	Committer bool
+
+	// Paths limits the count to commits that change the given paths or pathspecs
+	Paths []string
+
+	// Context for the command, if nil context.Background() is used
+	Context context.Context
+}
+
+// ShortlogEntry is the number of commits by a single contributor
+type ShortlogEntry struct {
+	Name string
+
+	// Email is only set if ShortlogOptions.Email was set
+	Email string
+
+	Commits int
+}
+
+// Shortlog counts the commits in revRange by contributor, e.g. for release notes
+// or ownership reports. revRange is any revision range git log accepts, such as
+// "v1.0..v2.0", and defaults to HEAD if empty.
+// Names and emails are mapped to their canonical form with the repository's .mailmap.
+// Entries are sorted by number of commits, most first.
+func (r *Repo) Shortlog(revRange string, options ShortlogOptions) ([]ShortlogEntry, error) {
+	// Without a revision, shortlog reads a log from stdin instead of running it
+	if revRange == "" {
+		revRange = "HEAD"
+	}
+
+	args := []string{"shortlog", "--summary", "--numbered"}
+	if options.Email {
+		args = append(args, "--email")
+	}
+	if options.Committer {
+		args = append(args, "--committer")
+	}
+	args = append(args, revRange, "--")
+	args = append(args, options.Paths...)
+
+	ctx := options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	stdout, stderr, err := r.Client.ExecContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("git shortlog failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+
+	var entries []ShortlogEntry
+	for _, line := range strings.Split(string(stdout), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		entry, err := parseShortlogLine(line, options.Email)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// parseShortlogLine parses a line of git shortlog --summary output, a count and a
+// name separated by a tab, with the email in angle brackets after the name if
+// withEmail is set
+func parseShortlogLine(line string, withEmail bool) (ShortlogEntry, error) {
+	count, contributor, found := strings.Cut(strings.TrimSpace(line), "\t")
+	if !found {
+		return ShortlogEntry{}, fmt.Errorf("unexpected git shortlog output: %q", line)
+	}
+	commits, err := strconv.Atoi(count)
+	if err != nil {
+		return ShortlogEntry{}, fmt.Errorf("unexpected git shortlog commit count %q: %w", count, err)
+	}
+
+	entry := ShortlogEntry{Name: contributor, Commits: commits}
+	if withEmail {
+		start := strings.LastIndex(contributor, "<")
+		if start < 0 || !strings.HasSuffix(contributor, ">") {
+			return ShortlogEntry{}, fmt.Errorf("unexpected git shortlog contributor %q", contributor)
+		}
+		entry.Name = strings.TrimSpace(contributor[:start])
+		entry.Email = contributor[start+1 : len(contributor)-1]
+	}
+	return entry, nil
+}
@@ -0,0 +1,94 @@
+package gittools
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShortlog(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		repo, err := Open(setupTestRepo(t))
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		initial, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+
+		repo.Client.SetUser("Alice", "alice@example.com")
+		writeAndCommit(t, repo, "a.txt", "a\n")
+		writeAndCommit(t, repo, "b.txt", "b\n")
+		repo.Client.SetUser("Bob", "bob@example.com")
+		writeAndCommit(t, repo, "a.txt", "bob\n")
+
+		// An old email is mapped to Alice's current identity by the .mailmap
+		repo.Client.SetUser("alice", "alice@old.example.com")
+		repo.Client.CommitterName, repo.Client.CommitterEmail = "Release Bot", "bot@example.com"
+		writeAndCommit(t, repo, "c.txt", "c\n")
+		repo.Client.SetUser("Alice", "alice@example.com")
+		writeAndCommit(t, repo, ".mailmap", "Alice <alice@example.com> <alice@old.example.com>\n")
+
+		tests := []struct {
+			name     string
+			revRange string
+			options  ShortlogOptions
+			expected []ShortlogEntry
+		}{
+			{
+				name: "all",
+				expected: []ShortlogEntry{
+					{Name: "Alice", Commits: 4},
+					{Name: "Bob", Commits: 1},
+					{Name: "Test Repo Setup", Commits: 1},
+				},
+			},
+			{
+				name:     "email",
+				revRange: initial + "..HEAD",
+				options:  ShortlogOptions{Email: true},
+				expected: []ShortlogEntry{
+					{Name: "Alice", Email: "alice@example.com", Commits: 4},
+					{Name: "Bob", Email: "bob@example.com", Commits: 1},
+				},
+			},
+			{
+				name:     "committer",
+				revRange: initial + "..HEAD",
+				options:  ShortlogOptions{Committer: true, Email: true},
+				expected: []ShortlogEntry{
+					{Name: "Alice", Email: "alice@example.com", Commits: 3},
+					{Name: "Bob", Email: "bob@example.com", Commits: 1},
+					{Name: "Release Bot", Email: "bot@example.com", Commits: 1},
+				},
+			},
+			{
+				name:    "paths",
+				options: ShortlogOptions{Paths: []string{"a.txt"}},
+				expected: []ShortlogEntry{
+					{Name: "Alice", Commits: 1},
+					{Name: "Bob", Commits: 1},
+				},
+			},
+			{
+				name:     "empty range",
+				revRange: "HEAD..HEAD",
+			},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				entries, err := repo.Shortlog(test.revRange, test.options)
+				if err != nil {
+					t.Fatalf("Shortlog failed: %v", err)
+				}
+				if !reflect.DeepEqual(entries, test.expected) {
+					t.Errorf("Expected %+v, got %+v", test.expected, entries)
+				}
+			})
+		}
+
+		if _, err := repo.Shortlog("missing..HEAD", ShortlogOptions{}); err == nil {
+			t.Error("Expected an error for a missing revision")
+		}
+	})
+}
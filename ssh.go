@@ -0,0 +1,59 @@
+package gittools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SSHOptions configure how git connects to SSH remotes, see Client.SSH
+type SSHOptions struct {
+	// Command is the ssh binary to run. If empty, "ssh" is used.
+	Command string
+
+	// IdentityFile is the private key to authenticate with. Only this key is offered,
+	// so keys from an ssh agent or ~/.ssh are not tried first.
+	IdentityFile string
+
+	// KnownHostsFile replaces ~/.ssh/known_hosts for verifying host keys
+	KnownHostsFile string
+
+	// StrictHostKeyChecking is passed to ssh as StrictHostKeyChecking, e.g. "yes",
+	// "accept-new" or "no". If empty, the ssh default applies.
+	StrictHostKeyChecking string
+
+	// Port overrides the port of remotes that do not specify one
+	Port int
+}
+
+// command renders the options as a GIT_SSH_COMMAND, which git runs with a shell
+func (o *SSHOptions) command() string {
+	binary := o.Command
+	if binary == "" {
+		binary = "ssh"
+	}
+
+	args := []string{shellQuote(binary)}
+	if o.IdentityFile != "" {
+		args = append(args, "-i", shellQuote(o.IdentityFile), "-o", "IdentitiesOnly=yes")
+	}
+	if o.KnownHostsFile != "" {
+		args = append(args, "-o", shellQuote("UserKnownHostsFile="+o.KnownHostsFile))
+	}
+	if o.StrictHostKeyChecking != "" {
+		args = append(args, "-o", shellQuote("StrictHostKeyChecking="+o.StrictHostKeyChecking))
+	}
+	if o.Port != 0 {
+		args = append(args, "-p", fmt.Sprint(o.Port))
+	}
+	return strings.Join(args, " ")
+}
+
+// shellQuote quotes s for use as a single shell word
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:@+,", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
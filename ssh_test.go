@@ -0,0 +1,59 @@
+package gittools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSSHOptionsCommand(t *testing.T) {
+	options := &SSHOptions{
+		IdentityFile:          "/keys/deploy key",
+		KnownHostsFile:        "/keys/known_hosts",
+		StrictHostKeyChecking: "yes",
+		Port:                  2222,
+	}
+	want := `ssh -i '/keys/deploy key' -o IdentitiesOnly=yes -o UserKnownHostsFile=/keys/known_hosts -o StrictHostKeyChecking=yes -p 2222`
+	if got := options.command(); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	if got := (&SSHOptions{}).command(); got != "ssh" {
+		t.Errorf("Expected plain ssh, got %q", got)
+	}
+	if got := shellQuote("it's"); got != `'it'\''s'` {
+		t.Errorf("Unexpected quoting %q", got)
+	}
+}
+
+func TestClientSSH(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		// A fake ssh records the arguments git runs it with
+		argsFile := filepath.Join(testDir, "ssh-args")
+		fakeSSH := filepath.Join(testDir, "fake-ssh")
+		script := "#!/bin/sh\necho \"$@\" > '" + argsFile + "'\nexit 1\n"
+		if err := os.WriteFile(fakeSSH, []byte(script), 0755); err != nil {
+			t.Fatalf("Failed to write fake ssh: %v", err)
+		}
+
+		client := &Client{SSH: &SSHOptions{
+			Command:               fakeSSH,
+			IdentityFile:          "/keys/deploy",
+			StrictHostKeyChecking: "accept-new",
+		}}
+		if _, _, err := client.Exec("ls-remote", "ssh://git@example.com/repo.git"); err == nil {
+			t.Fatal("Expected ls-remote through the fake ssh to fail")
+		}
+
+		args, err := os.ReadFile(argsFile)
+		if err != nil {
+			t.Fatalf("Expected git to run the configured ssh: %v", err)
+		}
+		for _, want := range []string{"-i /keys/deploy", "IdentitiesOnly=yes", "StrictHostKeyChecking=accept-new", "git@example.com"} {
+			if !strings.Contains(string(args), want) {
+				t.Errorf("Expected ssh args to contain %q, got %q", want, args)
+			}
+		}
+	})
+}
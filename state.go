@@ -0,0 +1,86 @@
+package gittools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RepoState describes the operations in progress in a repository
+type RepoState struct {
+	// Rebasing is true while a rebase (or git am) is stopped waiting for the user
+	Rebasing bool
+
+	// Merging is true while a merge is stopped waiting for conflicts to be resolved
+	Merging bool
+
+	// CherryPicking is true while a cherry-pick is stopped waiting for the user
+	CherryPicking bool
+
+	// Reverting is true while a revert is stopped waiting for the user
+	Reverting bool
+
+	// Bisecting is true during a bisect session
+	Bisecting bool
+
+	// DetachedHead is true if HEAD points directly at a commit rather than a branch
+	DetachedHead bool
+}
+
+// InProgress returns true if a rebase, merge, cherry-pick, revert or bisect is in progress
+func (s RepoState) InProgress() bool {
+	return s.Rebasing || s.Merging || s.CherryPicking || s.Reverting || s.Bisecting
+}
+
+// State reports the operations in progress in the repository by inspecting its git directory
+func (r *Repo) State() (RepoState, error) {
+	var state RepoState
+
+	gitDir, err := r.gitDir()
+	if err != nil {
+		return state, err
+	}
+
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(gitDir, name))
+		return err == nil
+	}
+	state.Rebasing = exists("rebase-merge") || exists("rebase-apply")
+	state.Merging = exists("MERGE_HEAD")
+	state.CherryPicking = exists("CHERRY_PICK_HEAD")
+	state.Reverting = exists("REVERT_HEAD")
+	state.Bisecting = exists("BISECT_LOG")
+
+	state.DetachedHead, err = r.HeadDetached()
+	if err != nil {
+		return state, err
+	}
+
+	return state, nil
+}
+
+// HeadDetached returns true if HEAD points directly at a commit rather than a branch,
+// e.g. after CheckoutCommit
+func (r *Repo) HeadDetached() (bool, error) {
+	// symbolic-ref -q exits with status 1 when HEAD is not a symbolic ref
+	stdout, stderr, err := r.Client.Exec("symbolic-ref", "-q", "HEAD")
+	if err != nil {
+		if ExitCode(err) != 1 {
+			return false, fmt.Errorf("git symbolic-ref failed: %w\nstdout: %s\nstderr: %s",
+				err, stdout, stderr)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// gitDir returns the absolute path of the repository's git directory
+func (r *Repo) gitDir() (string, error) {
+	stdout, stderr, err := r.Client.Exec("rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
@@ -0,0 +1,84 @@
+package gittools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRepoState(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		tempDir := setupTestRepo(t)
+
+		repo, err := Open(tempDir)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+
+		state, err := repo.State()
+		if err != nil {
+			t.Fatalf("Failed to get state: %v", err)
+		}
+		if state != (RepoState{}) {
+			t.Fatalf("Expected clean state, got %+v", state)
+		}
+
+		// Create conflicting changes to README.md on two branches
+		writeAndCommit := func(content string) {
+			t.Helper()
+			if err := os.WriteFile(filepath.Join(tempDir, "README.md"), []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write README.md: %v", err)
+			}
+			if err := repo.CommitAll("Change README"); err != nil {
+				t.Fatalf("Failed to commit: %v", err)
+			}
+		}
+		if err := repo.CreateBranch("other"); err != nil {
+			t.Fatalf("Failed to create branch: %v", err)
+		}
+		writeAndCommit("main\n")
+		if err := repo.Checkout("other"); err != nil {
+			t.Fatalf("Failed to checkout: %v", err)
+		}
+		writeAndCommit("other\n")
+
+		if _, _, err := repo.Client.Exec("merge", "main"); err == nil {
+			t.Fatal("Expected merge to conflict")
+		}
+		state, err = repo.State()
+		if err != nil {
+			t.Fatalf("Failed to get state: %v", err)
+		}
+		if !state.Merging || !state.InProgress() {
+			t.Errorf("Expected merge in progress, got %+v", state)
+		}
+		if _, _, err := repo.Client.Exec("merge", "--abort"); err != nil {
+			t.Fatalf("Failed to abort merge: %v", err)
+		}
+
+		if err := repo.Rebase("main"); err == nil {
+			t.Fatal("Expected rebase to conflict")
+		}
+		state, err = repo.State()
+		if err != nil {
+			t.Fatalf("Failed to get state: %v", err)
+		}
+		if !state.Rebasing {
+			t.Errorf("Expected rebase in progress, got %+v", state)
+		}
+		if err := repo.RebaseAbort(); err != nil {
+			t.Fatalf("Failed to abort rebase: %v", err)
+		}
+
+		if err := repo.Checkout("HEAD~1"); err != nil {
+			t.Fatalf("Failed to detach HEAD: %v", err)
+		}
+		state, err = repo.State()
+		if err != nil {
+			t.Fatalf("Failed to get state: %v", err)
+		}
+		if !state.DetachedHead || state.InProgress() {
+			t.Errorf("Expected only a detached HEAD, got %+v", state)
+		}
+	})
+}
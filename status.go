@@ -0,0 +1,154 @@
+package gittools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StatusEntry is a single path reported by git status
+type StatusEntry struct {
+	// Index is the status of the path in the index relative to HEAD,
+	// e.g. 'M' modified, 'A' added, 'D' deleted, 'R' renamed, ' ' unchanged,
+	// '?' untracked or '!' ignored
+	Index byte
+
+	// WorkTree is the status of the path in the working tree relative to the index,
+	// using the same codes as Index
+	WorkTree byte
+
+	// Path of the file relative to the repository root
+	Path string
+
+	// OrigPath is the path the file was renamed or copied from, if any
+	OrigPath string
+}
+
+// Untracked returns true if the path is not tracked by git
+func (e StatusEntry) Untracked() bool {
+	return e.Index == '?'
+}
+
+// Staged returns true if the path has changes in the index
+func (e StatusEntry) Staged() bool {
+	return e.Index != ' ' && e.Index != '?' && e.Index != '!'
+}
+
+// Unstaged returns true if a tracked path has changes in the working tree that are not in the index
+func (e StatusEntry) Unstaged() bool {
+	return e.WorkTree != ' ' && e.WorkTree != '?' && e.WorkTree != '!'
+}
+
+// Conflicted returns true if the path has unresolved merge conflicts
+func (e StatusEntry) Conflicted() bool {
+	switch string([]byte{e.Index, e.WorkTree}) {
+	case "DD", "AU", "UD", "UA", "DU", "AA", "UU":
+		return true
+	}
+	return false
+}
+
+// Status returns the changed and untracked paths in the working tree, parsed from git status --porcelain.
+// Ignored files are not included.
+func (r *Repo) Status() ([]StatusEntry, error) {
+	stdout, stderr, err := r.Client.Exec("status", "--porcelain=v1", "-z", "--untracked-files=all")
+	if err != nil {
+		return nil, fmt.Errorf("git status failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+
+	var entries []StatusEntry
+	records := strings.Split(string(stdout), "\x00")
+	for i := 0; i < len(records); i++ {
+		record := records[i]
+		if record == "" {
+			continue
+		}
+		// Format: XY SP path, followed by the original path as a separate record for renames and copies
+		if len(record) < 4 {
+			return nil, fmt.Errorf("unexpected git status output: %q", record)
+		}
+
+		entry := StatusEntry{
+			Index:    record[0],
+			WorkTree: record[1],
+			Path:     record[3:],
+		}
+		if entry.Index == 'R' || entry.Index == 'C' || entry.WorkTree == 'R' || entry.WorkTree == 'C' {
+			if i+1 < len(records) {
+				i++
+				entry.OrigPath = records[i]
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// IsClean returns true if the working tree has no staged, unstaged or untracked changes.
+// Ignored files do not affect the result.
+func (r *Repo) IsClean() (bool, error) {
+	entries, err := r.Status()
+	if err != nil {
+		return false, err
+	}
+	return len(entries) == 0, nil
+}
+
+// HasStagedChanges returns true if the index differs from HEAD
+func (r *Repo) HasStagedChanges() (bool, error) {
+	entries, err := r.Status()
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if entry.Staged() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// HasUnstagedChanges returns true if tracked files in the working tree differ from the index.
+// Untracked files are not counted, use IsClean to include them.
+func (r *Repo) HasUnstagedChanges() (bool, error) {
+	entries, err := r.Status()
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if entry.Unstaged() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// UntrackedFiles returns the paths of files that are neither tracked nor ignored,
+// relative to the repository root
+func (r *Repo) UntrackedFiles() ([]string, error) {
+	return r.lsOthers()
+}
+
+// IgnoredFiles returns the paths of untracked files excluded by .gitignore, .git/info/exclude
+// or the global excludes file, relative to the repository root
+func (r *Repo) IgnoredFiles() ([]string, error) {
+	return r.lsOthers("--ignored")
+}
+
+// lsOthers lists untracked files with the standard exclusions applied
+func (r *Repo) lsOthers(extraArgs ...string) ([]string, error) {
+	args := append([]string{"ls-files", "-z", "--others", "--exclude-standard", "--full-name"}, extraArgs...)
+	stdout, stderr, err := r.Client.Exec(args...)
+	if err != nil {
+		return nil, fmt.Errorf("git ls-files failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+
+	files := []string{}
+	for _, path := range strings.Split(string(stdout), "\x00") {
+		if path != "" {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,143 @@
+package gittools
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStatusHelpers(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		tempDir := setupTestRepo(t)
+
+		repo, err := Open(tempDir)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+
+		check := func(wantClean, wantStaged, wantUnstaged bool) {
+			t.Helper()
+			clean, err := repo.IsClean()
+			if err != nil {
+				t.Fatalf("IsClean failed: %v", err)
+			}
+			staged, err := repo.HasStagedChanges()
+			if err != nil {
+				t.Fatalf("HasStagedChanges failed: %v", err)
+			}
+			unstaged, err := repo.HasUnstagedChanges()
+			if err != nil {
+				t.Fatalf("HasUnstagedChanges failed: %v", err)
+			}
+			if clean != wantClean || staged != wantStaged || unstaged != wantUnstaged {
+				t.Errorf("Expected clean=%v staged=%v unstaged=%v, got clean=%v staged=%v unstaged=%v",
+					wantClean, wantStaged, wantUnstaged, clean, staged, unstaged)
+			}
+		}
+
+		check(true, false, false)
+
+		// Ignored files do not make the tree dirty
+		if err := os.WriteFile(filepath.Join(tempDir, ".gitignore"), []byte("*.log\n"), 0644); err != nil {
+			t.Fatalf("Failed to write .gitignore: %v", err)
+		}
+		if err := repo.CommitAll("Ignore logs"); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(tempDir, "debug.log"), []byte("log\n"), 0644); err != nil {
+			t.Fatalf("Failed to write ignored file: %v", err)
+		}
+		check(true, false, false)
+
+		// Untracked files are reported by IsClean only
+		if err := os.WriteFile(filepath.Join(tempDir, "new.txt"), []byte("new\n"), 0644); err != nil {
+			t.Fatalf("Failed to write untracked file: %v", err)
+		}
+		check(false, false, false)
+
+		if _, _, err := repo.Client.Exec("add", "new.txt"); err != nil {
+			t.Fatalf("Failed to stage file: %v", err)
+		}
+		check(false, true, false)
+
+		if err := os.WriteFile(filepath.Join(tempDir, "README.md"), []byte("changed\n"), 0644); err != nil {
+			t.Fatalf("Failed to modify file: %v", err)
+		}
+		check(false, true, true)
+
+		entries, err := repo.Status()
+		if err != nil {
+			t.Fatalf("Status failed: %v", err)
+		}
+		if len(entries) != 2 {
+			t.Fatalf("Expected 2 status entries, got %+v", entries)
+		}
+		for _, entry := range entries {
+			switch entry.Path {
+			case "new.txt":
+				if entry.Index != 'A' {
+					t.Errorf("Expected new.txt to be added, got %+v", entry)
+				}
+			case "README.md":
+				if entry.WorkTree != 'M' {
+					t.Errorf("Expected README.md to be modified, got %+v", entry)
+				}
+			default:
+				t.Errorf("Unexpected status entry %+v", entry)
+			}
+		}
+	})
+}
+
+func TestUntrackedAndIgnoredFiles(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		tempDir := setupTestRepo(t)
+
+		repo, err := Open(tempDir)
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+
+		untracked, err := repo.UntrackedFiles()
+		if err != nil {
+			t.Fatalf("UntrackedFiles failed: %v", err)
+		}
+		if len(untracked) != 0 {
+			t.Errorf("Expected no untracked files, got %v", untracked)
+		}
+
+		files := map[string]string{
+			".gitignore":        "*.log\n",
+			"new file.txt":      "new\n",
+			"sub/nested.txt":    "nested\n",
+			"debug.log":         "log\n",
+			"sub/build/out.log": "log\n",
+		}
+		for name, content := range files {
+			path := filepath.Join(tempDir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write %s: %v", name, err)
+			}
+		}
+
+		untracked, err = repo.UntrackedFiles()
+		if err != nil {
+			t.Fatalf("UntrackedFiles failed: %v", err)
+		}
+		if want := []string{".gitignore", "new file.txt", "sub/nested.txt"}; !reflect.DeepEqual(untracked, want) {
+			t.Errorf("Expected untracked files %v, got %v", want, untracked)
+		}
+
+		ignored, err := repo.IgnoredFiles()
+		if err != nil {
+			t.Fatalf("IgnoredFiles failed: %v", err)
+		}
+		if want := []string{"debug.log", "sub/build/out.log"}; !reflect.DeepEqual(ignored, want) {
+			t.Errorf("Expected ignored files %v, got %v", want, ignored)
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package gittools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SwitchOptions defines options for switching branches.
+// The fields match CheckoutOptions, which is used on gits without git switch.
+type SwitchOptions struct {
+	// Create creates the branch and switches to it in one step (-c), failing with
+	// ErrBranchExists if it already exists
+	Create bool
+
+	// Force discards local changes that would otherwise stop the switch (--discard-changes)
+	Force bool
+
+	// Track sets the upstream of a created branch to StartPoint, which must be
+	// a remote-tracking branch such as "origin/main" (--track)
+	Track bool
+
+	// Detach switches to the commit with a detached HEAD (--detach). Without it,
+	// branch must name a branch.
+	Detach bool
+
+	// StartPoint is the commit a created branch starts from, HEAD if empty
+	StartPoint string
+}
+
+// Switch switches to a branch, optionally creating it, using git switch.
+// Gits older than 2.23 don't have git switch, so git checkout is used instead.
+func (g *Repo) Switch(branch string, options SwitchOptions) error {
+	if branch == "" {
+		return fmt.Errorf("no branch provided to switch to")
+	}
+	if err := CheckoutOptions(options).validate(); err != nil {
+		return err
+	}
+
+	args := []string{"switch"}
+	if options.Force {
+		args = append(args, "--discard-changes")
+	}
+	if options.Detach {
+		args = append(args, "--detach")
+	}
+	if options.Track {
+		args = append(args, "--track")
+	}
+	if options.Create {
+		args = append(args, "-c")
+	}
+	args = append(args, branch)
+	if options.StartPoint != "" {
+		args = append(args, options.StartPoint)
+	}
+	stdout, stderr, err := g.Client.Exec(args...)
+	if err != nil {
+		if isUnknownCommand(stderr, "switch") {
+			return g.CheckoutWithOptions(branch, CheckoutOptions(options))
+		}
+		if options.Create && isBranchExists(stderr) {
+			return fmt.Errorf("%w: %s", ErrBranchExists, strings.TrimSpace(string(stderr)))
+		}
+		return fmt.Errorf("git switch failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+
+	return nil
+}
+
+// RestoreOptions defines options for restoring files
+type RestoreOptions struct {
+	// Source is the commit to restore the files from. If empty, the working tree
+	// is restored from the index and the index from HEAD.
+	Source string
+
+	// Staged restores the index (--staged)
+	Staged bool
+
+	// Worktree restores the working tree (--worktree). If neither Staged nor
+	// Worktree is set, only the working tree is restored.
+	Worktree bool
+}
+
+// Restore restores the given paths in the working tree and/or the index using
+// git restore, discarding local changes to them.
+// Gits older than 2.23 don't have git restore, so git checkout and git reset are used
+// instead. They can't restore the working tree from a Source without also updating
+// the index, so that combination returns an error on those gits.
+func (g *Repo) Restore(paths []string, options RestoreOptions) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("no paths provided to restore")
+	}
+
+	args := []string{"restore"}
+	if options.Source != "" {
+		args = append(args, "--source="+options.Source)
+	}
+	if options.Staged {
+		args = append(args, "--staged")
+	}
+	if options.Worktree {
+		args = append(args, "--worktree")
+	}
+	args = append(args, "--")
+	args = append(args, paths...)
+	stdout, stderr, err := g.Client.Exec(args...)
+	if err != nil {
+		if isUnknownCommand(stderr, "restore") {
+			return g.restoreWithCheckout(paths, options)
+		}
+		return fmt.Errorf("git restore failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+
+	return nil
+}
+
+// restoreWithCheckout restores paths with the commands that predate git restore
+func (g *Repo) restoreWithCheckout(paths []string, options RestoreOptions) error {
+	source := options.Source
+	if source == "" {
+		source = "HEAD"
+	}
+
+	var args []string
+	switch {
+	case options.Staged && options.Worktree:
+		args = []string{"checkout", source}
+	case options.Staged:
+		args = []string{"reset", "-q", source}
+	case options.Source == "":
+		// Restore the working tree from the index
+		args = []string{"checkout"}
+	default:
+		return fmt.Errorf("restoring only the working tree from %s requires git restore", options.Source)
+	}
+	args = append(args, "--")
+	args = append(args, paths...)
+	stdout, stderr, err := g.Client.Exec(args...)
+	if err != nil {
+		return fmt.Errorf("git %s failed: %w\nstdout: %s\nstderr: %s",
+			args[0], err, stdout, stderr)
+	}
+
+	return nil
+}
+
+// isUnknownCommand returns true if stderr reports that git doesn't have the subcommand
+func isUnknownCommand(stderr []byte, subcommand string) bool {
+	return strings.Contains(string(stderr), fmt.Sprintf("'%s' is not a git command", subcommand))
+}
@@ -0,0 +1,169 @@
+package gittools
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// oldGitFaults makes git switch and git restore fail as they do on gits that predate them
+func oldGitFaults(repo *Repo) {
+	faults := &FaultInjector{}
+	faults.On("switch").Fail("git: 'switch' is not a git command. See 'git --help'.\n", 1)
+	faults.On("restore").Fail("git: 'restore' is not a git command. See 'git --help'.\n", 1)
+	repo.Client.Hooks = append(repo.Client.Hooks, faults)
+}
+
+func TestSwitch(t *testing.T) {
+	for _, old := range []bool{false, true} {
+		name := "switch"
+		if old {
+			name = "checkout"
+		}
+		t.Run(name, func(t *testing.T) {
+			SafeTest(t, func(t *testing.T, testDir string) {
+				remoteDir, cleanup, err := CreateTestRemoteRepo("switch")
+				if err != nil {
+					t.Fatalf("Failed to create remote repository: %v", err)
+				}
+				defer cleanup()
+
+				client := &Client{}
+				client.SetUser("Test User", "test@example.com")
+				repo, err := client.Clone(remoteDir, filepath.Join(testDir, "clone"))
+				if err != nil {
+					t.Fatalf("Failed to clone repository: %v", err)
+				}
+				if old {
+					oldGitFaults(repo)
+				}
+				base, err := repo.RevParse("HEAD")
+				if err != nil {
+					t.Fatalf("Failed to get HEAD: %v", err)
+				}
+				writeAndCommit(t, repo, "local.txt", "local\n")
+
+				if err := repo.Switch("release", SwitchOptions{Create: true, Track: true, StartPoint: "origin/main"}); err != nil {
+					t.Fatalf("Switch failed: %v", err)
+				}
+				if branch, _ := repo.CurrentBranch(); branch != "release" {
+					t.Errorf("Expected to be on release, got %s", branch)
+				}
+				if head, _ := repo.RevParse("HEAD"); head != base {
+					t.Errorf("Expected release to start at %s, got %s", base, head)
+				}
+				if merge, err := repo.ConfigGetWithOptions("branch.release.merge", ConfigOptions{}); err != nil || merge != "refs/heads/main" {
+					t.Errorf("Expected release to track main, got %q, %v", merge, err)
+				}
+				if err := repo.Switch("release", SwitchOptions{Create: true}); !errors.Is(err, ErrBranchExists) {
+					t.Errorf("Expected ErrBranchExists, got %v", err)
+				}
+
+				// Force discards local changes
+				if err := os.WriteFile(filepath.Join(repo.RepoPath, "README.md"), []byte("changed\n"), 0644); err != nil {
+					t.Fatalf("Failed to write file: %v", err)
+				}
+				if err := repo.Switch("main", SwitchOptions{Force: true}); err != nil {
+					t.Fatalf("Forced switch failed: %v", err)
+				}
+				if status, err := repo.Status(); err != nil || len(status) != 0 {
+					t.Errorf("Expected a clean working tree, got %v, %v", status, err)
+				}
+
+				if err := repo.Switch(base, SwitchOptions{Detach: true}); err != nil {
+					t.Fatalf("Detached switch failed: %v", err)
+				}
+				if detached, err := repo.HeadDetached(); err != nil || !detached {
+					t.Errorf("Expected a detached HEAD, got %v, %v", detached, err)
+				}
+
+				if err := repo.Switch("main", SwitchOptions{StartPoint: "origin/main"}); err == nil {
+					t.Error("Expected a start point without Create to be rejected")
+				}
+			})
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	for _, old := range []bool{false, true} {
+		name := "restore"
+		if old {
+			name = "checkout"
+		}
+		t.Run(name, func(t *testing.T) {
+			SafeTest(t, func(t *testing.T, testDir string) {
+				repo, err := Open(setupTestRepo(t))
+				if err != nil {
+					t.Fatalf("Failed to open repository: %v", err)
+				}
+				repo.Client.SetUser("Test User", "test@example.com")
+				if old {
+					oldGitFaults(repo)
+				}
+				writeAndCommit(t, repo, "file.txt", "v1\n")
+				writeAndCommit(t, repo, "file.txt", "v2\n")
+
+				path := filepath.Join(repo.RepoPath, "file.txt")
+				assertFile := func(worktree, staged string) {
+					t.Helper()
+					content, err := os.ReadFile(path)
+					if err != nil {
+						t.Fatalf("Failed to read file: %v", err)
+					}
+					if string(content) != worktree {
+						t.Errorf("Expected %q in the working tree, got %q", worktree, content)
+					}
+					stdout, _, err := repo.Client.Exec("show", ":file.txt")
+					if err != nil {
+						t.Fatalf("Failed to read index: %v", err)
+					}
+					if string(stdout) != staged {
+						t.Errorf("Expected %q in the index, got %q", staged, stdout)
+					}
+				}
+				modify := func() {
+					t.Helper()
+					if err := os.WriteFile(path, []byte("changed\n"), 0644); err != nil {
+						t.Fatalf("Failed to write file: %v", err)
+					}
+					if _, _, err := repo.Client.Exec("add", "file.txt"); err != nil {
+						t.Fatalf("Failed to add file: %v", err)
+					}
+				}
+
+				// Unstage, keeping the change in the working tree
+				modify()
+				if err := repo.Restore([]string{"file.txt"}, RestoreOptions{Staged: true}); err != nil {
+					t.Fatalf("Restore failed: %v", err)
+				}
+				assertFile("changed\n", "v2\n")
+
+				// Discard the change in the working tree
+				if err := repo.Restore([]string{"file.txt"}, RestoreOptions{}); err != nil {
+					t.Fatalf("Restore failed: %v", err)
+				}
+				assertFile("v2\n", "v2\n")
+
+				// Restore both from an earlier commit
+				if err := repo.Restore([]string{"file.txt"}, RestoreOptions{Source: "HEAD~1", Staged: true, Worktree: true}); err != nil {
+					t.Fatalf("Restore failed: %v", err)
+				}
+				assertFile("v1\n", "v1\n")
+
+				err = repo.Restore([]string{"file.txt"}, RestoreOptions{Source: "HEAD"})
+				if old {
+					if err == nil {
+						t.Error("Expected restoring only the working tree from a source to fail without git restore")
+					}
+				} else {
+					if err != nil {
+						t.Fatalf("Restore failed: %v", err)
+					}
+					assertFile("v2\n", "v1\n")
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package testutils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// builderEpoch is the date of the first commit made by a RepoBuilder.
+// Each further commit is a minute later, so hashes are the same on every build.
+var builderEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// RepoBuilder creates a repository with a known history of commits, branches and
+// merges, for example:
+//
+//	commits, err := testutils.NewRepoBuilder().
+//		Commit("A").
+//		Branch("feature").Commit("B").
+//		Checkout("main").Commit("C").
+//		Merge("feature").Tag("M").
+//		Build(dir)
+//
+// Every commit is made by the same author at a fixed date, so a builder produces the
+// same hashes every time it is built.
+type RepoBuilder struct {
+	defaultBranch string
+	steps         []builderStep
+}
+
+// builderStep is an operation recorded by a RepoBuilder
+type builderStep struct {
+	description string
+	run         func(b *repoBuild) error
+}
+
+// repoBuild is the state of a RepoBuilder while it is built
+type repoBuild struct {
+	dir     string
+	commits map[string]string
+	count   int
+}
+
+// NewRepoBuilder returns a builder for a repository whose default branch is "main"
+func NewRepoBuilder() *RepoBuilder {
+	return &RepoBuilder{defaultBranch: "main"}
+}
+
+// DefaultBranch sets the name of the branch the first commit is made on
+func (r *RepoBuilder) DefaultBranch(name string) *RepoBuilder {
+	r.defaultBranch = name
+	return r
+}
+
+// Commit commits a new file on the current branch with name as the message,
+// and records the commit as name
+func (r *RepoBuilder) Commit(name string) *RepoBuilder {
+	return r.step("commit "+name, func(b *repoBuild) error {
+		file := strings.NewReplacer("/", "_", string(filepath.Separator), "_").Replace(name) + ".txt"
+		if err := os.WriteFile(filepath.Join(b.dir, file), []byte(name+"\n"), 0644); err != nil {
+			return err
+		}
+		if _, err := b.git("add", "--", file); err != nil {
+			return err
+		}
+		if _, err := b.git("commit", "-m", name); err != nil {
+			return err
+		}
+		return b.record(name)
+	})
+}
+
+// Branch creates a branch at the current commit and switches to it
+func (r *RepoBuilder) Branch(name string) *RepoBuilder {
+	return r.step("branch "+name, func(b *repoBuild) error {
+		_, err := b.git("checkout", "-b", name)
+		return err
+	})
+}
+
+// Checkout switches to an existing branch
+func (r *RepoBuilder) Checkout(branch string) *RepoBuilder {
+	return r.step("checkout "+branch, func(b *repoBuild) error {
+		_, err := b.git("checkout", branch)
+		return err
+	})
+}
+
+// Merge merges branch into the current branch, always creating a merge commit.
+// Use Tag to record the merge commit.
+func (r *RepoBuilder) Merge(branch string) *RepoBuilder {
+	return r.step("merge "+branch, func(b *repoBuild) error {
+		_, err := b.git("merge", "--no-ff", "-m", "Merge "+branch, branch)
+		return err
+	})
+}
+
+// Tag creates a lightweight tag at the current commit and records the commit as name
+func (r *RepoBuilder) Tag(name string) *RepoBuilder {
+	return r.step("tag "+name, func(b *repoBuild) error {
+		if _, err := b.git("tag", name); err != nil {
+			return err
+		}
+		return b.record(name)
+	})
+}
+
+// Build creates the repository in dir, which is created if needed, and returns the
+// hashes of the recorded commits keyed by name
+func (r *RepoBuilder) Build(dir string) (map[string]string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	if err := os.MkdirAll(absDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", absDir, err)
+	}
+
+	b := &repoBuild{dir: absDir, commits: make(map[string]string)}
+	if _, err := b.git("init", "--initial-branch="+r.defaultBranch); err != nil {
+		return nil, err
+	}
+	for _, step := range r.steps {
+		if err := step.run(b); err != nil {
+			return nil, fmt.Errorf("%s: %w", step.description, err)
+		}
+	}
+	return b.commits, nil
+}
+
+func (r *RepoBuilder) step(description string, run func(b *repoBuild) error) *RepoBuilder {
+	r.steps = append(r.steps, builderStep{description: description, run: run})
+	return r
+}
+
+// record records the current commit as name
+func (b *repoBuild) record(name string) error {
+	if _, exists := b.commits[name]; exists {
+		return fmt.Errorf("commit %q already recorded", name)
+	}
+	hash, err := b.git("rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	b.commits[name] = hash
+	return nil
+}
+
+// git runs a git command in the repository with a fixed identity, and a date that
+// advances with each commit
+func (b *repoBuild) git(args ...string) (string, error) {
+	if args[0] == "commit" || args[0] == "merge" {
+		b.count++
+	}
+	date := builderEpoch.Add(time.Duration(b.count) * time.Minute).Format(time.RFC3339)
+
+	return gitWithEnv(b.dir, []string{
+		"GIT_AUTHOR_NAME=Test User",
+		"GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_AUTHOR_DATE=" + date,
+		"GIT_COMMITTER_NAME=Test User",
+		"GIT_COMMITTER_EMAIL=test@example.com",
+		"GIT_COMMITTER_DATE=" + date,
+	}, args...)
+}
@@ -0,0 +1,61 @@
+package testutils
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRepoBuilder(t *testing.T) {
+	builder := NewRepoBuilder().
+		Commit("A").
+		Branch("feature").Commit("B").
+		Checkout("main").Commit("C").
+		Merge("feature").Tag("M")
+
+	dir := filepath.Join(t.TempDir(), "repo")
+	commits, err := builder.Build(dir)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if len(commits) != 4 {
+		t.Fatalf("Expected 4 recorded commits, got %v", commits)
+	}
+
+	parents, err := git(dir, "rev-list", "--parents", "-n", "1", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Join([]string{commits["M"], commits["C"], commits["B"]}, " "); parents != want {
+		t.Errorf("Expected main to be a merge of C and B, got %q", parents)
+	}
+	if base, err := git(dir, "merge-base", commits["B"], commits["C"]); err != nil || base != commits["A"] {
+		t.Errorf("Expected the merge base of B and C to be A, got %s, %v", base, err)
+	}
+	if feature, err := git(dir, "rev-parse", "feature"); err != nil || feature != commits["B"] {
+		t.Errorf("Expected feature at B, got %s, %v", feature, err)
+	}
+
+	// Builds are reproducible
+	again, err := builder.Build(filepath.Join(t.TempDir(), "again"))
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	for name, hash := range commits {
+		if again[name] != hash {
+			t.Errorf("Expected %s to be %s in a second build, got %s", name, hash, again[name])
+		}
+	}
+}
+
+func TestRepoBuilderErrors(t *testing.T) {
+	_, err := NewRepoBuilder().Commit("A").Commit("A").Build(t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "commit A") {
+		t.Errorf("Expected an error recording A twice, got %v", err)
+	}
+
+	_, err = NewRepoBuilder().Commit("A").Checkout("missing").Build(t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "checkout missing") {
+		t.Errorf("Expected an error checking out a missing branch, got %v", err)
+	}
+}
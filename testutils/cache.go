@@ -0,0 +1,114 @@
+package testutils
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// CloneCache builds a template repository the first time it is used and hands out
+// copies of it, so tests that need the same starting repository don't each pay for
+// creating it with git init, commit and push:
+//
+//	var remotes = testutils.NewCloneCache(func(dir string) error {
+//		_, err := testutils.NewRepoBuilder().Commit("A").Commit("B").Build(dir)
+//		return err
+//	})
+//
+//	func TestSomething(t *testing.T) {
+//		remotePath, cleanup, err := remotes.Remote("something")
+//		...
+//	}
+//
+// Copies are local clones, which hard link the template's objects rather than
+// copying them. Remote is safe for concurrent use.
+type CloneCache struct {
+	build func(dir string) error
+
+	once     sync.Once
+	template string
+	err      error
+}
+
+// NewCloneCache returns a cache whose template is created by build, which must
+// create a repository in the empty directory it is given. build is called at most once.
+func NewCloneCache(build func(dir string) error) *CloneCache {
+	return &CloneCache{build: build}
+}
+
+// Remote returns a new bare copy of the template, with the same branches, tags and
+// HEAD, and a cleanup function that removes it. The copy has no remotes, so changes
+// pushed to it never reach the template.
+func (c *CloneCache) Remote(baseName string) (repoPath string, cleanup func(), err error) {
+	template, err := c.init()
+	if err != nil {
+		return "", nil, err
+	}
+
+	repoPath, err = os.MkdirTemp("", baseName+"-bare-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create bare repo directory: %w", err)
+	}
+	cleanup = func() {
+		os.RemoveAll(repoPath)
+	}
+	if err := cloneBare(template, repoPath); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return repoPath, cleanup, nil
+}
+
+// Close removes the template. A cache is usually shared by every test in a package,
+// so call Close from TestMain after the tests have run, otherwise the template is
+// left in the temp directory. Remote fails after Close.
+func (c *CloneCache) Close() {
+	c.once.Do(func() {})
+	if c.template != "" {
+		os.RemoveAll(c.template)
+	}
+	c.template = ""
+	c.err = fmt.Errorf("clone cache is closed")
+}
+
+// init creates the template the first time it is called and returns its path
+func (c *CloneCache) init() (string, error) {
+	c.once.Do(func() {
+		workDir, err := os.MkdirTemp("", "clone-cache-init-")
+		if err != nil {
+			c.err = fmt.Errorf("failed to create temp directory: %w", err)
+			return
+		}
+		defer os.RemoveAll(workDir)
+
+		if err := c.build(workDir); err != nil {
+			c.err = fmt.Errorf("failed to build template repository: %w", err)
+			return
+		}
+
+		template, err := os.MkdirTemp("", "clone-cache-template-")
+		if err != nil {
+			c.err = fmt.Errorf("failed to create template directory: %w", err)
+			return
+		}
+		if err := cloneBare(workDir, template); err != nil {
+			os.RemoveAll(template)
+			c.err = err
+			return
+		}
+		c.template = template
+	})
+	return c.template, c.err
+}
+
+// cloneBare clones the repository at src into a bare repository at dst, without
+// a remote pointing back at src
+func cloneBare(src, dst string) error {
+	if _, err := git(dst, "clone", "--bare", "--local", "--quiet", src, dst); err != nil {
+		return fmt.Errorf("failed to create bare repository: %w", err)
+	}
+	if _, err := git(dst, "remote", "remove", "origin"); err != nil {
+		return fmt.Errorf("failed to remove origin: %w", err)
+	}
+	return nil
+}
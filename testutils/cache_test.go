@@ -0,0 +1,71 @@
+package testutils
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCloneCache(t *testing.T) {
+	builds := 0
+	cache := NewCloneCache(func(dir string) error {
+		builds++
+		_, err := NewRepoBuilder().Commit("A").Branch("feature").Commit("B").Checkout("main").Tag("v1").Build(dir)
+		return err
+	})
+	defer cache.Close()
+
+	first, cleanupFirst, err := cache.Remote("first")
+	if err != nil {
+		t.Fatalf("Remote failed: %v", err)
+	}
+	defer cleanupFirst()
+	second, cleanupSecond, err := cache.Remote("second")
+	if err != nil {
+		t.Fatalf("Remote failed: %v", err)
+	}
+	defer cleanupSecond()
+
+	if builds != 1 {
+		t.Errorf("Expected the template to be built once, got %d builds", builds)
+	}
+	if first == second {
+		t.Fatalf("Expected separate copies, got %s twice", first)
+	}
+	for _, ref := range []string{"main", "feature", "v1"} {
+		a, err := git(first, "rev-parse", ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, err := git(second, "rev-parse", ref); err != nil || a != b {
+			t.Errorf("Expected %s at %s in both copies, got %s, %v", ref, a, b, err)
+		}
+	}
+	if head, err := git(first, "symbolic-ref", "HEAD"); err != nil || head != "refs/heads/main" {
+		t.Errorf("Expected HEAD to point at main, got %s, %v", head, err)
+	}
+	if remotes, err := git(first, "remote"); err != nil || remotes != "" {
+		t.Errorf("Expected no remotes, got %q, %v", remotes, err)
+	}
+
+	// Changes to one copy are not seen by the other
+	if _, err := git(first, "update-ref", "refs/heads/main", "feature"); err != nil {
+		t.Fatal(err)
+	}
+	main, err := git(second, "rev-parse", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feature, _ := git(second, "rev-parse", "feature"); main == feature {
+		t.Error("Expected the second copy to be unaffected by the first")
+	}
+
+	cleanupFirst()
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Errorf("Expected cleanup to remove %s, got %v", first, err)
+	}
+
+	cache.Close()
+	if _, _, err := cache.Remote("closed"); err == nil {
+		t.Error("Expected Remote to fail after Close")
+	}
+}
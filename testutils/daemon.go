@@ -0,0 +1,99 @@
+package testutils
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// daemonStartTimeout is how long StartGitDaemon waits for git daemon to accept connections
+const daemonStartTimeout = 10 * time.Second
+
+// StartGitDaemon serves the repositories in dir over the git:// protocol with
+// `git daemon --export-all`, listening on a random port on the loopback interface.
+// Pushes are allowed. It returns the URL of dir, so a repository at dir/remote.git
+// is at url + "/remote.git", and a function that stops the daemon.
+func StartGitDaemon(dir string) (url string, shutdown func(), err error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Run git-daemon itself, as killing the git wrapper would leave it running
+	execPath, err := git(absDir, "--exec-path")
+	if err != nil {
+		return "", nil, err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(filepath.Join(execPath, "git-daemon"),
+		"--listen=127.0.0.1",
+		"--port="+strconv.Itoa(port),
+		"--reuseaddr",
+		"--export-all",
+		"--enable=receive-pack",
+		"--base-path="+absDir,
+		absDir,
+	)
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return "", nil, fmt.Errorf("failed to start git daemon: %w", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	shutdown = func() {
+		cmd.Process.Kill()
+		<-exited
+	}
+
+	address := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	if err := waitForListener(address, exited); err != nil {
+		shutdown()
+		return "", nil, fmt.Errorf("git daemon did not start: %w\nstderr: %s", err, stderr.Bytes())
+	}
+	return "git://" + address, shutdown, nil
+}
+
+// freePort returns a TCP port on the loopback interface that is not in use
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// waitForListener waits until address accepts connections, or the process
+// signalled by exited stops
+func waitForListener(address string, exited <-chan struct{}) error {
+	deadline := time.Now().Add(daemonStartTimeout)
+	for {
+		conn, err := net.DialTimeout("tcp", address, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		select {
+		case <-exited:
+			return fmt.Errorf("process exited")
+		default:
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,51 @@
+package testutils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStartGitDaemon(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := git(dir, "init", "--bare", "--initial-branch=main", "remote.git"); err != nil {
+		t.Fatal(err)
+	}
+
+	url, shutdown, err := StartGitDaemon(dir)
+	if err != nil {
+		t.Fatalf("StartGitDaemon failed: %v", err)
+	}
+	defer shutdown()
+
+	work := filepath.Join(dir, "work")
+	if _, err := git(dir, "clone", url+"/remote.git", work); err != nil {
+		t.Fatalf("Failed to clone over git://: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(work, "a.txt"), []byte("a\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := git(work, "add", "a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := git(work, "-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "-m", "Add a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := git(work, "push", "origin", "HEAD:refs/heads/main"); err != nil {
+		t.Fatalf("Failed to push over git://: %v", err)
+	}
+
+	head, err := git(work, "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err := git(filepath.Join(dir, "remote.git"), "rev-parse", "refs/heads/main")
+	if err != nil || remote != head {
+		t.Errorf("Expected the push to update main to %s, got %s, %v", head, remote, err)
+	}
+
+	shutdown()
+	if _, err := git(dir, "ls-remote", url+"/remote.git"); err == nil {
+		t.Error("Expected the daemon to be stopped")
+	}
+}
@@ -0,0 +1,77 @@
+package testutils
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"path/filepath"
+)
+
+// HTTPRemoteOptions configures the server started by StartHTTPRemote
+type HTTPRemoteOptions struct {
+	// Username and Password, if set, are required as basic auth credentials
+	Username string
+	Password string
+
+	// Token, if set, is required as a bearer token in the Authorization header.
+	// It is accepted in place of basic auth credentials when both are set.
+	Token string
+}
+
+// StartHTTPRemote serves the repositories in dir over the smart HTTP protocol with
+// git http-backend, on a random port on the loopback interface. Pushes are allowed.
+// It returns the URL of dir, so a repository at dir/remote.git is at
+// url + "/remote.git", and a function that stops the server.
+func StartHTTPRemote(dir string, opts HTTPRemoteOptions) (url string, shutdown func(), err error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+	execPath, err := git(absDir, "--exec-path")
+	if err != nil {
+		return "", nil, err
+	}
+
+	backend := &cgi.Handler{
+		Path: filepath.Join(execPath, "git-http-backend"),
+		Dir:  absDir,
+		Env: []string{
+			"GIT_PROJECT_ROOT=" + absDir,
+			"GIT_HTTP_EXPORT_ALL=1",
+			// Anonymous pushes are refused unless receive-pack is enabled
+			"GIT_CONFIG_PARAMETERS='http.receivepack=true'",
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !opts.authorized(req) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		backend.ServeHTTP(w, req)
+	}))
+	return server.URL, server.Close, nil
+}
+
+// authorized returns true if the request carries the credentials required by the options
+func (o HTTPRemoteOptions) authorized(req *http.Request) bool {
+	if o.Username == "" && o.Password == "" && o.Token == "" {
+		return true
+	}
+	if o.Token != "" && equal(req.Header.Get("Authorization"), "Bearer "+o.Token) {
+		return true
+	}
+	if o.Username != "" || o.Password != "" {
+		username, password, ok := req.BasicAuth()
+		return ok && equal(username, o.Username) && equal(password, o.Password)
+	}
+	return false
+}
+
+// equal compares secrets in constant time
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,84 @@
+package testutils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStartHTTPRemote(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := git(dir, "init", "--bare", "--initial-branch=main", "remote.git"); err != nil {
+		t.Fatal(err)
+	}
+
+	url, shutdown, err := StartHTTPRemote(dir, HTTPRemoteOptions{})
+	if err != nil {
+		t.Fatalf("StartHTTPRemote failed: %v", err)
+	}
+	defer shutdown()
+
+	work := filepath.Join(dir, "work")
+	if _, err := git(dir, "clone", url+"/remote.git", work); err != nil {
+		t.Fatalf("Failed to clone over HTTP: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(work, "a.txt"), []byte("a\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := git(work, "add", "a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := git(work, "-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "-m", "Add a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := git(work, "push", "origin", "HEAD:refs/heads/main"); err != nil {
+		t.Fatalf("Failed to push over HTTP: %v", err)
+	}
+
+	head, err := git(work, "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err := git(filepath.Join(dir, "remote.git"), "rev-parse", "refs/heads/main")
+	if err != nil || remote != head {
+		t.Errorf("Expected the push to update main to %s, got %s, %v", head, remote, err)
+	}
+}
+
+func TestStartHTTPRemoteAuth(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := git(dir, "init", "--bare", "--initial-branch=main", "remote.git"); err != nil {
+		t.Fatal(err)
+	}
+
+	url, shutdown, err := StartHTTPRemote(dir, HTTPRemoteOptions{
+		Username: "user",
+		Password: "secret",
+		Token:    "token",
+	})
+	if err != nil {
+		t.Fatalf("StartHTTPRemote failed: %v", err)
+	}
+	defer shutdown()
+
+	// Stop git asking for credentials on the terminal
+	t.Setenv("GIT_TERMINAL_PROMPT", "0")
+
+	if _, err := git(dir, "ls-remote", url+"/remote.git"); err == nil {
+		t.Error("Expected ls-remote without credentials to fail")
+	}
+
+	withCredentials := strings.Replace(url, "http://", "http://user:secret@", 1)
+	if _, err := git(dir, "ls-remote", withCredentials+"/remote.git"); err != nil {
+		t.Errorf("Expected ls-remote with basic auth to succeed: %v", err)
+	}
+	wrongPassword := strings.Replace(url, "http://", "http://user:wrong@", 1)
+	if _, err := git(dir, "ls-remote", wrongPassword+"/remote.git"); err == nil {
+		t.Error("Expected ls-remote with the wrong password to fail")
+	}
+
+	if _, err := git(dir, "-c", "http.extraHeader=Authorization: Bearer token", "ls-remote", url+"/remote.git"); err != nil {
+		t.Errorf("Expected ls-remote with a bearer token to succeed: %v", err)
+	}
+}
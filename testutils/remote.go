@@ -0,0 +1,237 @@
+package testutils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RemoteRepoOptions configures the repository created by CreateTestRemoteRepoWithOptions
+type RemoteRepoOptions struct {
+	// DefaultBranch is the branch the initial commit is made on, "main" if empty
+	DefaultBranch string
+
+	// UserName and UserEmail are the author and committer of the initial commit,
+	// "Test User" <test@example.com> if empty
+	UserName  string
+	UserEmail string
+
+	// Files maps the paths of the files in the initial commit, relative to the
+	// repository root, to their content. If empty, the commit adds a README.md.
+	Files map[string]string
+}
+
+// defaultReadme is the content of the README.md added when RemoteRepoOptions.Files is empty
+const defaultReadme = "# Test Repository\n\nThis is a test repository created for gittools tests."
+
+// remoteCaches holds the templates of the repositories created by
+// CreateTestRemoteRepoWithOptions, keyed by RemoteRepoOptions.key
+var remoteCaches = struct {
+	sync.Mutex
+	caches map[string]*CloneCache
+}{caches: make(map[string]*CloneCache)}
+
+// CreateTestRemoteRepo creates a bare repository whose "main" branch has a single
+// commit adding README.md. It returns the path to the repository and a cleanup function.
+func CreateTestRemoteRepo(baseName string) (repoPath string, cleanup func(), err error) {
+	return CreateTestRemoteRepoWithOptions(baseName, RemoteRepoOptions{})
+}
+
+// CreateTestRemoteRepoWithBranch creates a bare repository whose defaultBranch has a
+// single commit adding README.md. It returns the path to the repository and a cleanup function.
+func CreateTestRemoteRepoWithBranch(baseName, defaultBranch string) (repoPath string, cleanup func(), err error) {
+	return CreateTestRemoteRepoWithOptions(baseName, RemoteRepoOptions{DefaultBranch: defaultBranch})
+}
+
+// CreateTestRemoteRepoWithOptions creates a bare repository with a single commit
+// described by opts. It returns the path to the repository and a cleanup function.
+// The repository is a copy of a template created once per process for each set of
+// options, see CloneCache. The initial commit is dated when the template is created.
+// Packages that use it should call CloseTestRemoteRepos from TestMain to remove the templates.
+func CreateTestRemoteRepoWithOptions(baseName string, opts RemoteRepoOptions) (repoPath string, cleanup func(), err error) {
+	opts = opts.withDefaults()
+	key := opts.key()
+
+	remoteCaches.Lock()
+	cache, ok := remoteCaches.caches[key]
+	if !ok {
+		cache = NewCloneCache(opts.build)
+		remoteCaches.caches[key] = cache
+	}
+	remoteCaches.Unlock()
+
+	return cache.Remote(baseName)
+}
+
+// CloseTestRemoteRepos removes the templates created by CreateTestRemoteRepoWithOptions.
+// Call it from TestMain after the tests have run, otherwise the templates are left in
+// the temp directory:
+//
+//	func TestMain(m *testing.M) {
+//		code := m.Run()
+//		testutils.CloseTestRemoteRepos()
+//		os.Exit(code)
+//	}
+//
+// Repositories created afterwards use new templates.
+func CloseTestRemoteRepos() {
+	remoteCaches.Lock()
+	defer remoteCaches.Unlock()
+
+	for key, cache := range remoteCaches.caches {
+		cache.Close()
+		delete(remoteCaches.caches, key)
+	}
+}
+
+// withDefaults returns the options with empty fields set to their defaults
+func (o RemoteRepoOptions) withDefaults() RemoteRepoOptions {
+	if o.DefaultBranch == "" {
+		o.DefaultBranch = "main"
+	}
+	if o.UserName == "" {
+		o.UserName = "Test User"
+	}
+	if o.UserEmail == "" {
+		o.UserEmail = "test@example.com"
+	}
+	if len(o.Files) == 0 {
+		o.Files = map[string]string{"README.md": defaultReadme}
+	}
+	return o
+}
+
+// key identifies the repository described by the options
+func (o RemoteRepoOptions) key() string {
+	var paths []string
+	for path := range o.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	parts := []string{o.DefaultBranch, o.UserName, o.UserEmail}
+	for _, path := range paths {
+		parts = append(parts, path, o.Files[path])
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// build creates the repository described by the options in dir
+func (o RemoteRepoOptions) build(dir string) error {
+	if _, err := git(dir, "init", "--quiet", "--initial-branch="+o.DefaultBranch); err != nil {
+		return err
+	}
+	for path, content := range o.Files {
+		file := filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", path, err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to create %s: %w", path, err)
+		}
+	}
+	if _, err := git(dir, "add", "--all"); err != nil {
+		return err
+	}
+	_, err := gitWithEnv(dir, []string{
+		"GIT_AUTHOR_NAME=" + o.UserName,
+		"GIT_AUTHOR_EMAIL=" + o.UserEmail,
+		"GIT_COMMITTER_NAME=" + o.UserName,
+		"GIT_COMMITTER_EMAIL=" + o.UserEmail,
+	}, "commit", "--quiet", "-m", "Initial commit")
+	return err
+}
+
+// CreateTestRemoteRepoWithCommits creates a bare repository with n commits on its
+// "main" branch, each adding a file. It returns the path to the repository, the
+// hashes of the commits from oldest to newest and a cleanup function.
+func CreateTestRemoteRepoWithCommits(baseName string, n int) (repoPath string, commits []string, cleanup func(), err error) {
+	if n < 1 {
+		return "", nil, nil, fmt.Errorf("a repository needs at least one commit, got %d", n)
+	}
+	repoPath, branches, cleanup, err := CreateTestRemoteRepoWithBranches(baseName, map[string]int{"main": n})
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return repoPath, branches["main"], cleanup, nil
+}
+
+// CreateTestRemoteRepoWithBranches creates a bare repository with branches described by
+// spec, which maps each branch name to the number of commits made on it. The "main"
+// branch is created first, with one commit if spec does not include it, and every other
+// branch forks from its last commit. It returns the path to the repository, the hashes
+// of the commits made on each branch from oldest to newest and a cleanup function.
+func CreateTestRemoteRepoWithBranches(baseName string, spec map[string]int) (repoPath string, commits map[string][]string, cleanup func(), err error) {
+	mainCommits, ok := spec["main"]
+	if !ok {
+		mainCommits = 1
+	}
+	if mainCommits < 1 {
+		return "", nil, nil, fmt.Errorf("main needs at least one commit, got %d", mainCommits)
+	}
+
+	var branches []string
+	for branch := range spec {
+		if branch != "main" {
+			branches = append(branches, branch)
+		}
+	}
+	sort.Strings(branches)
+
+	builder := NewRepoBuilder()
+	commit := func(branch string, count int) {
+		for i := 1; i <= count; i++ {
+			builder.Commit(branch + "-" + strconv.Itoa(i))
+		}
+	}
+	commit("main", mainCommits)
+	for _, branch := range branches {
+		builder.Checkout("main").Branch(branch)
+		commit(branch, spec[branch])
+	}
+	// The bare clone's HEAD follows the working repository's
+	builder.Checkout("main")
+
+	workDir, err := os.MkdirTemp("", baseName+"-init-")
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	hashes, err := builder.Build(workDir)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to build repository: %w", err)
+	}
+
+	repoPath, err = os.MkdirTemp("", baseName+"-bare-")
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to create bare repo directory: %w", err)
+	}
+	cleanup = func() {
+		os.RemoveAll(repoPath)
+	}
+	if _, err := git(workDir, "clone", "--bare", "--quiet", workDir, repoPath); err != nil {
+		cleanup()
+		return "", nil, nil, fmt.Errorf("failed to create bare repository: %w", err)
+	}
+
+	commits = make(map[string][]string)
+	commits["main"] = branchCommits(hashes, "main", mainCommits)
+	for _, branch := range branches {
+		commits[branch] = branchCommits(hashes, branch, spec[branch])
+	}
+	return repoPath, commits, cleanup, nil
+}
+
+// branchCommits returns the hashes of the count commits a RepoBuilder made on branch
+func branchCommits(hashes map[string]string, branch string, count int) []string {
+	commits := []string{}
+	for i := 1; i <= count; i++ {
+		commits = append(commits, hashes[branch+"-"+strconv.Itoa(i)])
+	}
+	return commits
+}
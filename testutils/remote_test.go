@@ -0,0 +1,170 @@
+package testutils
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	CloseTestRemoteRepos()
+	os.Exit(code)
+}
+
+func TestCreateTestRemoteRepo(t *testing.T) {
+	repoPath, cleanup, err := CreateTestRemoteRepo("remote")
+	if err != nil {
+		t.Fatalf("CreateTestRemoteRepo failed: %v", err)
+	}
+	defer cleanup()
+
+	if head, err := git(repoPath, "symbolic-ref", "HEAD"); err != nil || head != "refs/heads/main" {
+		t.Errorf("Expected HEAD to point at main, got %s, %v", head, err)
+	}
+	if files, err := git(repoPath, "ls-tree", "--name-only", "main"); err != nil || files != "README.md" {
+		t.Errorf("Expected README.md, got %q, %v", files, err)
+	}
+	if author, err := git(repoPath, "log", "-1", "--format=%an <%ae>", "main"); err != nil || author != "Test User <test@example.com>" {
+		t.Errorf("Expected the default identity, got %q, %v", author, err)
+	}
+
+	other, cleanupOther, err := CreateTestRemoteRepoWithBranch("remote", "main")
+	if err != nil {
+		t.Fatalf("CreateTestRemoteRepoWithBranch failed: %v", err)
+	}
+	defer cleanupOther()
+	if other == repoPath {
+		t.Fatal("Expected a separate repository")
+	}
+	a, _ := git(repoPath, "rev-parse", "main")
+	if b, err := git(other, "rev-parse", "main"); err != nil || a != b {
+		t.Errorf("Expected both repositories to share the initial commit %s, got %s, %v", a, b, err)
+	}
+}
+
+func TestCreateTestRemoteRepoWithOptions(t *testing.T) {
+	repoPath, cleanup, err := CreateTestRemoteRepoWithOptions("options", RemoteRepoOptions{
+		DefaultBranch: "trunk",
+		UserName:      "Alice",
+		UserEmail:     "alice@example.com",
+		Files: map[string]string{
+			"go.mod":          "module example.com/test\n",
+			"cmd/app/main.go": "package main\n",
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateTestRemoteRepoWithOptions failed: %v", err)
+	}
+	defer cleanup()
+
+	if head, err := git(repoPath, "symbolic-ref", "HEAD"); err != nil || head != "refs/heads/trunk" {
+		t.Errorf("Expected HEAD to point at trunk, got %s, %v", head, err)
+	}
+	if author, err := git(repoPath, "log", "-1", "--format=%an <%ae> %cn <%ce>", "trunk"); err != nil || author != "Alice <alice@example.com> Alice <alice@example.com>" {
+		t.Errorf("Expected Alice as author and committer, got %q, %v", author, err)
+	}
+	files, err := git(repoPath, "ls-tree", "-r", "--name-only", "trunk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(strings.Fields(files), " "); got != "cmd/app/main.go go.mod" {
+		t.Errorf("Expected the given files, got %s", got)
+	}
+	if content, err := git(repoPath, "show", "trunk:go.mod"); err != nil || content != "module example.com/test" {
+		t.Errorf("Unexpected go.mod %q, %v", content, err)
+	}
+}
+
+func TestCloseTestRemoteRepos(t *testing.T) {
+	for _, opts := range []RemoteRepoOptions{{}, {DefaultBranch: "trunk"}} {
+		_, cleanup, err := CreateTestRemoteRepoWithOptions("close", opts)
+		if err != nil {
+			t.Fatalf("CreateTestRemoteRepoWithOptions failed: %v", err)
+		}
+		cleanup()
+	}
+
+	var templates []string
+	remoteCaches.Lock()
+	for _, cache := range remoteCaches.caches {
+		templates = append(templates, cache.template)
+	}
+	remoteCaches.Unlock()
+	if len(templates) < 2 {
+		t.Fatalf("Expected a template for each set of options, got %d", len(templates))
+	}
+
+	CloseTestRemoteRepos()
+	for _, template := range templates {
+		if _, err := os.Stat(template); !os.IsNotExist(err) {
+			t.Errorf("Expected template %s to be removed, got %v", template, err)
+		}
+	}
+
+	// Later repositories use a new template
+	repoPath, cleanup, err := CreateTestRemoteRepo("after-close")
+	if err != nil {
+		t.Fatalf("CreateTestRemoteRepo failed after CloseTestRemoteRepos: %v", err)
+	}
+	defer cleanup()
+	if _, err := git(repoPath, "rev-parse", "main"); err != nil {
+		t.Errorf("Expected main in the new repository: %v", err)
+	}
+}
+
+func TestCreateTestRemoteRepoWithCommits(t *testing.T) {
+	repoPath, commits, cleanup, err := CreateTestRemoteRepoWithCommits("with-commits", 3)
+	if err != nil {
+		t.Fatalf("CreateTestRemoteRepoWithCommits failed: %v", err)
+	}
+	defer cleanup()
+
+	if len(commits) != 3 {
+		t.Fatalf("Expected 3 commits, got %v", commits)
+	}
+	history, err := git(repoPath, "rev-list", "--reverse", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Fields(history); strings.Join(got, " ") != strings.Join(commits, " ") {
+		t.Errorf("Expected history %v, got %v", commits, got)
+	}
+	if bare, err := git(repoPath, "rev-parse", "--is-bare-repository"); err != nil || bare != "true" {
+		t.Errorf("Expected a bare repository, got %s, %v", bare, err)
+	}
+
+	if _, _, _, err := CreateTestRemoteRepoWithCommits("with-commits", 0); err == nil {
+		t.Error("Expected an error creating a repository without commits")
+	}
+}
+
+func TestCreateTestRemoteRepoWithBranches(t *testing.T) {
+	repoPath, commits, cleanup, err := CreateTestRemoteRepoWithBranches("with-branches", map[string]int{
+		"main":      2,
+		"feature":   3,
+		"release/1": 0,
+	})
+	if err != nil {
+		t.Fatalf("CreateTestRemoteRepoWithBranches failed: %v", err)
+	}
+	defer cleanup()
+
+	if len(commits["main"]) != 2 || len(commits["feature"]) != 3 || len(commits["release/1"]) != 0 {
+		t.Fatalf("Unexpected commits %v", commits)
+	}
+	mainTip := commits["main"][1]
+
+	if base, err := git(repoPath, "merge-base", "main", "feature"); err != nil || base != mainTip {
+		t.Errorf("Expected feature to fork from %s, got %s, %v", mainTip, base, err)
+	}
+	if tip, err := git(repoPath, "rev-parse", "feature"); err != nil || tip != commits["feature"][2] {
+		t.Errorf("Expected feature at %s, got %s, %v", commits["feature"][2], tip, err)
+	}
+	if tip, err := git(repoPath, "rev-parse", "release/1"); err != nil || tip != mainTip {
+		t.Errorf("Expected release/1 at %s, got %s, %v", mainTip, tip, err)
+	}
+	if head, err := git(repoPath, "symbolic-ref", "HEAD"); err != nil || head != "refs/heads/main" {
+		t.Errorf("Expected HEAD to point at main, got %s, %v", head, err)
+	}
+}
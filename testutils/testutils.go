@@ -0,0 +1,37 @@
+// Package testutils provides fixtures for testing code that uses git: remotes served
+// over the git and HTTP protocols, and repositories with a known history.
+//
+// It only depends on the git binary and the standard library, so it can be used by
+// the tests of any package, including gittools itself.
+package testutils
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// git runs a git command in dir and returns its trimmed stdout
+func git(dir string, args ...string) (string, error) {
+	return gitWithEnv(dir, nil, args...)
+}
+
+// gitWithEnv runs a git command in dir with env added to its environment
+func gitWithEnv(dir string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed: %w\nstdout: %s\nstderr: %s",
+			strings.Join(args, " "), err, stdout.Bytes(), stderr.Bytes())
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,57 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// GitExec runs a git command in repoPath, killing it if it runs for longer than
+// timeoutSeconds, and returns its combined output
+func GitExec(t testing.TB, repoPath string, timeoutSeconds int, args ...string) ([]byte, error) {
+	t.Helper()
+
+	// Set up a timeout context
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutSeconds)*time.Second)
+	defer cancel()
+
+	// Create and execute the command
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = repoPath
+
+	// Run the command and return output
+	return cmd.CombinedOutput()
+}
+
+// RunWithTimeout runs fn and returns its result, or an error naming operation if it
+// doesn't return within timeoutSeconds. fn keeps running in the background after a timeout.
+func RunWithTimeout(t testing.TB, operation string, timeoutSeconds int, fn func() (interface{}, error)) (interface{}, error) {
+	t.Helper()
+
+	// Create timeout context
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutSeconds)*time.Second)
+	defer cancel()
+
+	// Use a channel to collect the result
+	type result struct {
+		res interface{}
+		err error
+	}
+	ch := make(chan result, 1)
+
+	// Run the function in a goroutine
+	go func() {
+		res, err := fn()
+		ch <- result{res, err}
+	}()
+
+	// Wait for either the operation to complete or the context to timeout
+	select {
+	case r := <-ch:
+		return r.res, r.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%s operation timed out after %d seconds", operation, timeoutSeconds)
+	}
+}
@@ -0,0 +1,35 @@
+package testutils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunWithTimeout(t *testing.T) {
+	res, err := RunWithTimeout(t, "quick", 5, func() (interface{}, error) {
+		return "done", nil
+	})
+	if err != nil || res != "done" {
+		t.Errorf("Expected done, got %v, %v", res, err)
+	}
+
+	_, err = RunWithTimeout(t, "slow", 1, func() (interface{}, error) {
+		time.Sleep(3 * time.Second)
+		return nil, nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "slow operation timed out") {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+}
+
+func TestGitExec(t *testing.T) {
+	dir := t.TempDir()
+	if out, err := GitExec(t, dir, 5, "init", "--initial-branch=main"); err != nil {
+		t.Fatalf("GitExec failed: %v\n%s", err, out)
+	}
+	out, err := GitExec(t, dir, 5, "symbolic-ref", "HEAD")
+	if err != nil || strings.TrimSpace(string(out)) != "refs/heads/main" {
+		t.Errorf("Expected refs/heads/main, got %q, %v", out, err)
+	}
+}
@@ -0,0 +1,62 @@
+package gittools
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// WorktreeOptions defines options for adding a linked worktree
+type WorktreeOptions struct {
+	// Commit is the branch or commit to check out in the worktree, HEAD if empty
+	Commit string
+
+	// Detach checks out Commit with a detached HEAD, even if it is a branch (--detach).
+	// A branch can only be checked out in one worktree at a time.
+	Detach bool
+}
+
+// AddWorktree creates a linked worktree at path, which must not exist or be empty,
+// and returns a Repo for it using a copy of this Repo's Client.
+// The worktree shares the repository's objects and refs, but has its own HEAD, index
+// and working tree. Remove it with RemoveWorktree.
+func (r *Repo) AddWorktree(path string, options WorktreeOptions) (*Repo, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	args := []string{"worktree", "add"}
+	if options.Detach {
+		args = append(args, "--detach")
+	}
+	args = append(args, absPath)
+	if options.Commit != "" {
+		args = append(args, options.Commit)
+	}
+	stdout, stderr, err := r.Client.Exec(args...)
+	if err != nil {
+		return nil, fmt.Errorf("git worktree add failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+
+	return &Repo{
+		Client:   r.Client.withWorkDir(absPath),
+		RepoPath: absPath,
+	}, nil
+}
+
+// RemoveWorktree deletes a linked worktree created by AddWorktree, discarding any
+// changes in it
+func (r *Repo) RemoveWorktree(path string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	stdout, stderr, err := r.Client.Exec("worktree", "remove", "--force", absPath)
+	if err != nil {
+		return fmt.Errorf("git worktree remove failed: %w\nstdout: %s\nstderr: %s",
+			err, stdout, stderr)
+	}
+	return nil
+}
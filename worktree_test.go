@@ -0,0 +1,69 @@
+package gittools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAddWorktree(t *testing.T) {
+	SafeTest(t, func(t *testing.T, testDir string) {
+		repo, err := Open(setupTestRepo(t))
+		if err != nil {
+			t.Fatalf("Failed to open repository: %v", err)
+		}
+		repo.Client.SetUser("Test User", "test@example.com")
+		head, err := repo.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get HEAD: %v", err)
+		}
+		branch, err := repo.CurrentBranch()
+		if err != nil {
+			t.Fatalf("Failed to get current branch: %v", err)
+		}
+
+		worktreeDir := filepath.Join(testDir, "worktree")
+		worktree, err := repo.AddWorktree(worktreeDir, WorktreeOptions{Commit: branch, Detach: true})
+		if err != nil {
+			t.Fatalf("AddWorktree failed: %v", err)
+		}
+		if worktree.RepoPath != worktreeDir {
+			t.Errorf("Expected RepoPath to be %s, got %s", worktreeDir, worktree.RepoPath)
+		}
+		if detached, err := worktree.HeadDetached(); err != nil || !detached {
+			t.Errorf("Expected a detached HEAD in the worktree, got %v, %v", detached, err)
+		}
+
+		// Commits in the worktree don't touch the main working tree
+		writeAndCommit(t, worktree, "worktree.txt", "worktree\n")
+		if _, err := os.Stat(filepath.Join(repo.RepoPath, "worktree.txt")); !os.IsNotExist(err) {
+			t.Errorf("Expected worktree.txt not to be in the main working tree, got %v", err)
+		}
+		if current, _ := repo.RevParse("HEAD"); current != head {
+			t.Errorf("Expected HEAD to stay at %s, got %s", head, current)
+		}
+		committed, err := worktree.RevParse("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get worktree HEAD: %v", err)
+		}
+		if exists, _, err := repo.CatFile(CatFileOptions{Exists: true, ObjectID: committed}); err != nil || !exists {
+			t.Errorf("Expected the worktree commit to be in the shared object database, got %v, %v", exists, err)
+		}
+
+		// Changes in the worktree are discarded on removal
+		if err := os.WriteFile(filepath.Join(worktreeDir, "dirty.txt"), []byte("dirty\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if err := repo.RemoveWorktree(worktreeDir); err != nil {
+			t.Fatalf("RemoveWorktree failed: %v", err)
+		}
+		if _, err := os.Stat(worktreeDir); !os.IsNotExist(err) {
+			t.Errorf("Expected the worktree to be deleted, got %v", err)
+		}
+
+		// Without Detach the branch is checked out, which a branch already checked out refuses
+		if _, err := repo.AddWorktree(filepath.Join(testDir, "branch"), WorktreeOptions{Commit: branch}); err == nil {
+			t.Error("Expected checking out the current branch in a second worktree to fail")
+		}
+	})
+}